@@ -0,0 +1,30 @@
+// Command hostcapture records what the collectors read on this host into a
+// fixture set for hosttest.FromDir. Run it on the device:
+//
+//	go run ./cmd/hostcapture -out internal/host/hosttest/testdata/pizero2w
+//
+// Review the output before committing it: iw and journalctl name the WiFi
+// network and may log client addresses.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
+
+func main() {
+	out := flag.String("out", "pizero2w", "directory to write the fixture set to")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := hosttest.Capture(ctx, host.Default(), *out); err != nil {
+		log.Printf("incomplete capture: %v", err)
+	}
+	log.Printf("fixture set written to %s", *out)
+}
@@ -0,0 +1,33 @@
+// Command mediamtx-stub serves a fake MediaMTX Control API for running the
+// UI on a machine without MediaMTX.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx/mediamtxtest"
+)
+
+func main() {
+	addr := os.Getenv("STUB_ADDR")
+	if addr == "" {
+		addr = ":9997"
+	}
+	pathName := os.Getenv("MEDIAMTX_PATH_NAME")
+	if pathName == "" {
+		pathName = "cam"
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mediamtxtest.Sample(pathName),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	log.Printf("mediamtx stub listening on %s with path %q", addr, pathName)
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/xpereta/RaspiCam/internal/auth"
+	"github.com/xpereta/RaspiCam/internal/web"
+)
+
+const passwdUsage = `usage: raspicam-ui passwd [-role admin|viewer] [-file path] [user]
+
+Sets the password of user, "admin" by default, creating the account when it
+does not exist. New accounts are admins unless -role says otherwise; an
+existing account keeps its role. The password is read from the terminal, or
+from the first line of standard input when it is not one. Sessions of the
+user end at once.
+`
+
+// runPasswd implements the passwd subcommand, which works without the UI
+// running.
+func runPasswd(args []string) error {
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), passwdUsage) }
+	roleFlag := fs.String("role", "", "role of the account: admin or viewer")
+	file := fs.String("file", web.UsersPath(), "accounts file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("too many arguments")
+	}
+	name := "admin"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+	var role auth.Role
+	if *roleFlag != "" {
+		var err error
+		if role, err = auth.ParseRole(*roleFlag); err != nil {
+			return err
+		}
+	}
+
+	password, err := readPassword(name)
+	if err != nil {
+		return err
+	}
+	user, err := auth.SetPassword(*file, name, role, password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Password of %s (%s) saved to %s.\n", user.Name, user.Role, *file)
+	return nil
+}
+
+// readPassword prompts twice on a terminal and reads one line otherwise,
+// for scripted installs.
+func readPassword(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprintf(os.Stderr, "New password for %s: ", name)
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(first), nil
+}
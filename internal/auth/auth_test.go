@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	hashCost = bcrypt.MinCost
+}
+
+func TestSetPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+
+	admin, err := SetPassword(path, "admin", "", "correct horse")
+	if err != nil {
+		t.Fatalf("set admin: %v", err)
+	}
+	if admin.Role != RoleAdmin || !admin.CheckPassword("correct horse") || admin.CheckPassword("wrong horse") {
+		t.Fatalf("unexpected admin: %+v", admin)
+	}
+	if _, err := SetPassword(path, "alice", RoleViewer, "battery staple"); err != nil {
+		t.Fatalf("set viewer: %v", err)
+	}
+	// A reset without a role keeps the role.
+	if alice, err := SetPassword(path, "alice", "", "staple battery"); err != nil || alice.Role != RoleViewer {
+		t.Fatalf("unexpected reset: %+v %v", alice, err)
+	}
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(users) != 2 || users[0].Name != "admin" || users[1].Name != "alice" || !users[1].CheckPassword("staple battery") {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a private file: %v %v", info.Mode(), err)
+	}
+
+	if _, err := SetPassword(path, "bad name", "", "long enough"); !errors.Is(err, ErrInvalidUserName) {
+		t.Fatalf("expected ErrInvalidUserName, got %v", err)
+	}
+	if _, err := SetPassword(path, "bob", "root", "long enough"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+	if _, err := SetPassword(path, "bob", "", "short"); err == nil {
+		t.Fatal("expected a short password rejected")
+	}
+
+	if users, err := LoadUsers(filepath.Join(t.TempDir(), "missing.yml")); err != nil || users != nil {
+		t.Fatalf("expected no users for a missing file: %v %v", users, err)
+	}
+}
+
+func TestLoginAndSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+	a := New(path, time.Hour)
+	now := time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	if _, err := a.Login("10.0.0.2", "admin", "correct horse"); !errors.Is(err, ErrNoUsers) {
+		t.Fatalf("expected ErrNoUsers, got %v", err)
+	}
+	if _, err := SetPassword(path, "admin", "", "correct horse"); err != nil {
+		t.Fatalf("set admin: %v", err)
+	}
+	if _, err := a.Login("10.0.0.2", "admin", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := a.Login("10.0.0.2", "nobody", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	s, err := a.Login("10.0.0.2", "admin", "correct horse")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if got, ok := a.Session(s.Token); !ok || got.User != "admin" || got.Role != RoleAdmin || got.CSRF == "" || got.CSRF == s.Token {
+		t.Fatalf("unexpected session: %+v %v", got, ok)
+	}
+	if _, ok := a.Session("forged"); ok {
+		t.Fatal("expected an unknown token rejected")
+	}
+
+	// A password reset ends the session.
+	if _, err := SetPassword(path, "admin", "", "new password"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, ok := a.Session(s.Token); ok {
+		t.Fatal("expected the session ended by the reset")
+	}
+
+	s, err = a.Login("10.0.0.2", "admin", "new password")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	a.Logout(s.Token)
+	if _, ok := a.Session(s.Token); ok {
+		t.Fatal("expected the session ended by logout")
+	}
+
+	s, _ = a.Login("10.0.0.2", "admin", "new password")
+	now = now.Add(time.Hour)
+	if _, ok := a.Session(s.Token); ok {
+		t.Fatal("expected the session expired")
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleAdmin.Allows(RoleViewer) || !RoleViewer.Allows(RoleViewer) || RoleViewer.Allows(RoleAdmin) {
+		t.Fatal("unexpected role permissions")
+	}
+	if _, err := ParseRole("owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+}
+
+func TestCheckThrottle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+	a := New(path, time.Hour)
+	now := time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	if _, err := SetPassword(path, "admin", "", "correct horse"); err != nil {
+		t.Fatalf("set admin: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := a.Check("10.0.0.9", "admin", "guess"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i, err)
+		}
+	}
+	// The guessing client now waits, even with the right password.
+	_, err := a.Check("10.0.0.9", "admin", "correct horse")
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, ErrThrottled) || throttled.RetryAfter != time.Second {
+		t.Fatalf("expected a one second wait, got %v", err)
+	}
+	// Another client is not slowed down by it yet.
+	if _, err := a.Check("10.0.0.2", "admin", "correct horse"); err != nil {
+		t.Fatalf("expected another client let through: %v", err)
+	}
+
+	// Each further failure doubles the wait.
+	now = now.Add(time.Second)
+	if _, err := a.Check("10.0.0.9", "admin", "guess"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := a.Check("10.0.0.9", "admin", "guess"); !errors.As(err, &throttled) || throttled.RetryAfter != 2*time.Second {
+		t.Fatalf("expected a two second wait, got %v", err)
+	}
+
+	// A success clears the count.
+	now = now.Add(2 * time.Second)
+	if _, err := a.Check("10.0.0.9", "admin", "correct horse"); err != nil {
+		t.Fatalf("expected the wait over: %v", err)
+	}
+	if _, err := a.Check("10.0.0.9", "admin", "guess"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the count cleared, got %v", err)
+	}
+}
+
+func TestCheckBasicCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+	a := New(path, time.Hour)
+	if _, err := SetPassword(path, "prometheus", RoleViewer, "scrape secret"); err != nil {
+		t.Fatalf("set user: %v", err)
+	}
+
+	if user, err := a.CheckBasic("10.0.0.2", "prometheus", "scrape secret"); err != nil || user.Role != RoleViewer {
+		t.Fatalf("unexpected check: %+v %v", user, err)
+	}
+	if len(a.basic) != 1 {
+		t.Fatalf("expected the check cached, got %d entries", len(a.basic))
+	}
+	if _, err := a.CheckBasic("10.0.0.2", "prometheus", "scrape secret"); err != nil {
+		t.Fatalf("cached check: %v", err)
+	}
+	if _, err := a.CheckBasic("10.0.0.2", "prometheus", "other secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected a wrong password rejected, got %v", err)
+	}
+
+	// A password reset drops the cached check.
+	if _, err := SetPassword(path, "prometheus", "", "new scrape secret"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := a.CheckBasic("10.0.0.2", "prometheus", "scrape secret"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the old password rejected, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Session is a signed-in user. Token is the secret the session cookie
+// holds and CSRF the one its forms carry, which another site cannot read
+// and so cannot post along with the cookie.
+type Session struct {
+	Token   string
+	CSRF    string
+	User    string
+	Role    Role
+	Expires time.Time
+}
+
+// session is a stored session with the hash the user signed in with, so a
+// password reset ends it.
+type session struct {
+	Session
+	hash string
+}
+
+const (
+	// basicCacheTTL is how long a checked pair of basic credentials is
+	// trusted without hashing it again. A change of the accounts file ends
+	// it sooner.
+	basicCacheTTL = 5 * time.Minute
+	// basicCacheSize bounds the cached credentials; scripts and scrapers
+	// use a handful.
+	basicCacheSize = 32
+)
+
+// basicEntry is a cached successful basic credential check.
+type basicEntry struct {
+	user     User
+	revision uint64
+	expires  time.Time
+}
+
+// Authenticator checks passwords against the accounts file and keeps the
+// sessions. The file is read again whenever it changes, so the passwd
+// subcommand takes effect without a restart. Failed attempts slow down
+// further ones from the same client and for the same user name.
+type Authenticator struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	users    []User
+	modTime  time.Time
+	size     int64
+	loaded   bool
+	revision uint64
+	sessions map[string]session
+	// dummy is compared against when the user is unknown, so a login takes
+	// as long whether or not the name exists.
+	dummy []byte
+	// basic caches successful basic checks by an HMAC of the credentials
+	// under basicKey, so the passwords themselves are not kept.
+	basic    map[string]basicEntry
+	basicKey []byte
+	byClient *throttle
+	byUser   *throttle
+}
+
+// New returns an authenticator for the accounts file at path whose
+// sessions last ttl.
+func New(path string, ttl time.Duration) *Authenticator {
+	return &Authenticator{
+		path:     path,
+		ttl:      ttl,
+		now:      time.Now,
+		sessions: map[string]session{},
+		basic:    map[string]basicEntry{},
+		// A client gets a few more tries than a user name so one guessing
+		// client slows itself down before it locks out the real user.
+		byClient: newThrottle(5, 15*time.Minute),
+		byUser:   newThrottle(10, time.Minute),
+	}
+}
+
+// Path returns the accounts file.
+func (a *Authenticator) Path() string {
+	return a.path
+}
+
+// HasUsers reports whether any account exists.
+func (a *Authenticator) HasUsers() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	users, err := a.loadUsers()
+	return len(users) > 0, err
+}
+
+// Check verifies a user name and password sent by client, usually its IP
+// address, and returns the account. After repeated failures from client
+// or for name it returns a ThrottledError without checking.
+func (a *Authenticator) Check(client, name, password string) (User, error) {
+	a.mu.Lock()
+	users, err := a.loadUsers()
+	if err != nil {
+		a.mu.Unlock()
+		return User{}, err
+	}
+	if len(users) == 0 {
+		a.mu.Unlock()
+		return User{}, ErrNoUsers
+	}
+	now := a.now()
+	if wait := max(a.byClient.wait(client, now), a.byUser.wait(name, now)); wait > 0 {
+		a.mu.Unlock()
+		return User{}, &ThrottledError{RetryAfter: wait}
+	}
+	var user User
+	if i := userIndex(users, name); i >= 0 {
+		user = users[i]
+	}
+	if a.dummy == nil {
+		a.dummy, _ = bcrypt.GenerateFromPassword([]byte("raspicam"), hashCost)
+	}
+	dummy := a.dummy
+	a.mu.Unlock()
+
+	// Hashing is slow on a Pi, so it runs without the lock.
+	var ok bool
+	if user.Name == "" {
+		_ = bcrypt.CompareHashAndPassword(dummy, []byte(password))
+	} else {
+		ok = user.CheckPassword(password)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now = a.now()
+	if !ok {
+		a.byClient.fail(client, now)
+		a.byUser.fail(name, now)
+		return User{}, ErrInvalidCredentials
+	}
+	a.byClient.reset(client)
+	a.byUser.reset(name)
+	return user, nil
+}
+
+// CheckBasic is Check for HTTP basic credentials, which come with every
+// request of a script or scraper. A successful check is remembered for a
+// few minutes, or until the accounts file changes, so the password is not
+// hashed again each time.
+func (a *Authenticator) CheckBasic(client, name, password string) (User, error) {
+	a.mu.Lock()
+	if _, err := a.loadUsers(); err != nil {
+		a.mu.Unlock()
+		return User{}, err
+	}
+	if a.basicKey == nil {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			a.mu.Unlock()
+			return User{}, err
+		}
+		a.basicKey = key
+	}
+	mac := hmac.New(sha256.New, a.basicKey)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	key := string(mac.Sum(nil))
+	revision := a.revision
+	if e, ok := a.basic[key]; ok && e.revision == revision && a.now().Before(e.expires) {
+		a.mu.Unlock()
+		return e.user, nil
+	}
+	a.mu.Unlock()
+
+	user, err := a.Check(client, name, password)
+	if err != nil {
+		return User{}, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	for k, e := range a.basic {
+		if e.revision != a.revision || !now.Before(e.expires) {
+			delete(a.basic, k)
+		}
+	}
+	if len(a.basic) >= basicCacheSize {
+		clear(a.basic)
+	}
+	a.basic[key] = basicEntry{user: user, revision: revision, expires: now.Add(basicCacheTTL)}
+	return user, nil
+}
+
+// Login checks the password of user name sent by client and starts a
+// session.
+func (a *Authenticator) Login(client, name, password string) (Session, error) {
+	user, err := a.Check(client, name, password)
+	if err != nil {
+		return Session{}, err
+	}
+	b := make([]byte, 64)
+	if _, err := rand.Read(b); err != nil {
+		return Session{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	for token, s := range a.sessions {
+		if !now.Before(s.Expires) {
+			delete(a.sessions, token)
+		}
+	}
+	s := session{
+		Session: Session{
+			Token:   base64.RawURLEncoding.EncodeToString(b[:32]),
+			CSRF:    base64.RawURLEncoding.EncodeToString(b[32:]),
+			User:    user.Name,
+			Role:    user.Role,
+			Expires: now.Add(a.ttl),
+		},
+		hash: user.Hash,
+	}
+	a.sessions[s.Token] = s
+	return s.Session, nil
+}
+
+// Session returns the live session of token. A session ends when it
+// expires, or when its user is removed or gets a new password; a changed
+// role applies at once.
+func (a *Authenticator) Session(token string) (Session, bool) {
+	if token == "" {
+		return Session{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	if !a.now().Before(s.Expires) {
+		delete(a.sessions, token)
+		return Session{}, false
+	}
+	users, err := a.loadUsers()
+	if err != nil {
+		return Session{}, false
+	}
+	i := userIndex(users, s.User)
+	if i < 0 || subtle.ConstantTimeCompare([]byte(users[i].Hash), []byte(s.hash)) != 1 {
+		delete(a.sessions, token)
+		return Session{}, false
+	}
+	s.Role = users[i].Role
+	return s.Session, true
+}
+
+// Logout ends the session of token.
+func (a *Authenticator) Logout(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, token)
+}
+
+// loadUsers returns the accounts, reading the file again when its size or
+// modification time changed. Each change bumps the revision. The caller
+// holds mu.
+func (a *Authenticator) loadUsers() ([]User, error) {
+	info, err := os.Stat(a.path)
+	if os.IsNotExist(err) {
+		if a.loaded {
+			a.revision++
+		}
+		a.users, a.loaded = nil, false
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.loaded && info.ModTime().Equal(a.modTime) && info.Size() == a.size {
+		return a.users, nil
+	}
+	users, err := LoadUsers(a.path)
+	if err != nil {
+		return nil, err
+	}
+	a.users, a.modTime, a.size, a.loaded = users, info.ModTime(), info.Size(), true
+	a.revision++
+	return users, nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrThrottled is matched by the ThrottledError returned while a client or
+// user name must wait after too many failed attempts.
+var ErrThrottled = errors.New("too many failed attempts")
+
+// ThrottledError reports how long to wait before the next attempt.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrThrottled
+}
+
+const (
+	// throttleBase is the wait after the first failure past the free ones;
+	// each further failure doubles it.
+	throttleBase = time.Second
+	// throttleForget is how long a key has to go without a failure to
+	// start over.
+	throttleForget = 15 * time.Minute
+	// throttleMaxKeys bounds the memory a flood of client addresses or user
+	// names can take; past it new keys are not tracked.
+	throttleMaxKeys = 4096
+)
+
+// throttle slows down password guessing for one kind of key, a client
+// address or a user name. After free failures each attempt has to wait
+// twice as long as the previous one, up to max. The Authenticator lock
+// guards it.
+type throttle struct {
+	free    int
+	max     time.Duration
+	entries map[string]failures
+}
+
+type failures struct {
+	count int
+	last  time.Time
+}
+
+func newThrottle(free int, max time.Duration) *throttle {
+	return &throttle{free: free, max: max, entries: map[string]failures{}}
+}
+
+// wait returns how long key still has to wait at now.
+func (t *throttle) wait(key string, now time.Time) time.Duration {
+	f, ok := t.entries[key]
+	if !ok || f.count < t.free {
+		return 0
+	}
+	delay := t.max
+	if shift := f.count - t.free; shift < 20 {
+		delay = min(throttleBase<<shift, t.max)
+	}
+	if until := f.last.Add(delay); now.Before(until) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+func (t *throttle) fail(key string, now time.Time) {
+	f, ok := t.entries[key]
+	if !ok && len(t.entries) >= throttleMaxKeys {
+		t.prune(now)
+		if len(t.entries) >= throttleMaxKeys {
+			return
+		}
+	}
+	if ok && now.Sub(f.last) > throttleForget {
+		f.count = 0
+	}
+	t.entries[key] = failures{count: f.count + 1, last: now}
+}
+
+func (t *throttle) reset(key string) {
+	delete(t.entries, key)
+}
+
+func (t *throttle) prune(now time.Time) {
+	for key, f := range t.entries {
+		if now.Sub(f.last) > throttleForget {
+			delete(t.entries, key)
+		}
+	}
+}
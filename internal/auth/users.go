@@ -0,0 +1,206 @@
+// Package auth keeps the UI accounts and their login sessions. Accounts
+// are stored as bcrypt hashes in a YAML file beside mediamtx.yml, which the
+// passwd subcommand edits offline; sessions live in memory.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is what an account may do. Viewers see status and recordings;
+// admins can also change the configuration, control services and delete
+// recordings.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleAdmin  Role = "admin"
+)
+
+// MinPasswordLength is the shortest password SetPassword accepts.
+const MinPasswordLength = 8
+
+// maxPasswordBytes is the longest password bcrypt hashes in full.
+const maxPasswordBytes = 72
+
+var (
+	// ErrInvalidCredentials is returned for an unknown user or a wrong
+	// password, without telling which.
+	ErrInvalidCredentials = errors.New("invalid user name or password")
+	// ErrNoUsers is returned by Login while no account exists.
+	ErrNoUsers = errors.New("no user accounts configured")
+	// ErrInvalidUserName is returned for an empty name or one with
+	// characters other than letters, digits, dot, dash and underscore.
+	ErrInvalidUserName = errors.New("invalid user name")
+	// ErrInvalidRole is returned for a role other than viewer or admin.
+	ErrInvalidRole = errors.New("role must be viewer or admin")
+)
+
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+// hashCost is the bcrypt cost of new hashes. Tests lower it.
+var hashCost = bcrypt.DefaultCost
+
+// ParseRole parses "viewer" or "admin".
+func ParseRole(value string) (Role, error) {
+	switch role := Role(strings.TrimSpace(value)); role {
+	case RoleViewer, RoleAdmin:
+		return role, nil
+	}
+	return "", ErrInvalidRole
+}
+
+// Allows reports whether the role grants what need requires.
+func (r Role) Allows(need Role) bool {
+	return r == RoleAdmin || r == need
+}
+
+// User is one account of the users file.
+type User struct {
+	Name string `yaml:"name"`
+	Role Role   `yaml:"role"`
+	Hash string `yaml:"hash"`
+}
+
+// CheckPassword reports whether password matches the stored hash.
+func (u User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.Hash), []byte(password)) == nil
+}
+
+type usersFile struct {
+	Users []User `yaml:"users"`
+}
+
+// UsersPath returns the accounts file of the config file at path,
+// mediamtx.ui-users.yml for mediamtx.yml.
+func UsersPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".ui-users" + ext
+}
+
+// LoadUsers reads the accounts file at path. A missing file means no
+// accounts.
+func LoadUsers(path string) ([]User, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var file usersFile
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, u := range file.Users {
+		if _, err := ParseRole(string(u.Role)); err != nil {
+			return nil, fmt.Errorf("%s: user %q: %w", path, u.Name, err)
+		}
+	}
+	return file.Users, nil
+}
+
+// SetPassword sets the password of user name in the accounts file at
+// path, adding the user when it does not exist. An empty role keeps the
+// role of an existing user and makes a new one an admin. Changing the hash
+// ends the sessions of the user.
+func SetPassword(path, name string, role Role, password string) (User, error) {
+	if !userNamePattern.MatchString(name) {
+		return User{}, ErrInvalidUserName
+	}
+	if role != "" {
+		if _, err := ParseRole(string(role)); err != nil {
+			return User{}, err
+		}
+	}
+	if len(password) < MinPasswordLength {
+		return User{}, fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return User{}, fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+	}
+
+	users, err := LoadUsers(path)
+	if err != nil {
+		return User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), hashCost)
+	if err != nil {
+		return User{}, err
+	}
+
+	i := userIndex(users, name)
+	if i < 0 {
+		if role == "" {
+			role = RoleAdmin
+		}
+		users = append(users, User{Name: name})
+		i = len(users) - 1
+	}
+	if role != "" {
+		users[i].Role = role
+	}
+	users[i].Hash = string(hash)
+	if err := writeUsers(path, users); err != nil {
+		return User{}, err
+	}
+	return users[i], nil
+}
+
+func userIndex(users []User, name string) int {
+	for i, u := range users {
+		if u.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeUsers replaces the accounts file. A new file is readable by its
+// owner only; an existing one keeps its mode and, when run as root, its
+// owner, so resetting a password with sudo leaves it readable by the UI.
+func writeUsers(path string, users []User) error {
+	out, err := yaml.Marshal(usersFile{Users: users})
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o600)
+	owner, group := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && os.Geteuid() == 0 {
+			owner, group = int(st.Uid), int(st.Gid)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if owner >= 0 {
+		if err := os.Chown(tmp.Name(), owner, group); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	backupInfix      = ".bak-"
+	backupTimeLayout = "20060102-150405"
+)
+
+// ErrBackupNotFound is returned for a backup name that does not belong to
+// the config file.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup is a copy of the config file taken before it was replaced.
+type Backup struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+
+	seq int
+}
+
+// RetentionPolicy bounds the number and age of kept backups. Zero disables
+// a limit. The newest backup is always kept.
+type RetentionPolicy struct {
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// ListBackups returns the backups of the config file at path, newest first.
+func ListBackups(path string) ([]Backup, error) {
+	prefix := filepath.Base(path) + backupInfix
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		t, seq, ok := parseBackupStamp(stamp)
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Name: entry.Name(), Time: t, Size: info.Size(), seq: seq})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// DiffBackup compares the named backup with the current config file.
+func DiffBackup(path, name string) ([]DiffHunk, error) {
+	backupPath, err := backupFile(path, name)
+	if err != nil {
+		return nil, err
+	}
+	old, err := os.ReadFile(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	current, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DiffText(string(old), string(current)), nil
+}
+
+// RestoreBackup atomically replaces the config file with the named backup.
+// The replaced file is itself backed up, so a restore can be undone.
+func RestoreBackup(path, name string) error {
+	backupPath, err := backupFile(path, name)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(backupPath)
+	if err != nil {
+		return err
+	}
+
+	var check yaml.Node
+	if err := yaml.Unmarshal(b, &check); err != nil {
+		return fmt.Errorf("backup is not valid yaml: %w", err)
+	}
+
+	_, err = replaceFile(path, b)
+	return err
+}
+
+// PruneBackups removes the backups outside policy and returns them.
+func PruneBackups(path string, policy RetentionPolicy, now time.Time) ([]Backup, error) {
+	backups, err := ListBackups(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Backup
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		tooMany := policy.MaxCount > 0 && i >= policy.MaxCount
+		tooOld := policy.MaxAge > 0 && now.Sub(backup.Time) > policy.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(path), backup.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+// backupFile resolves a backup name to its path, rejecting anything that is
+// not a backup of path.
+func backupFile(path, name string) (string, error) {
+	if name != filepath.Base(name) {
+		return "", ErrBackupNotFound
+	}
+	stamp, ok := strings.CutPrefix(name, filepath.Base(path)+backupInfix)
+	if !ok {
+		return "", ErrBackupNotFound
+	}
+	if _, _, ok := parseBackupStamp(stamp); !ok {
+		return "", ErrBackupNotFound
+	}
+	backupPath := filepath.Join(filepath.Dir(path), name)
+	info, err := os.Lstat(backupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrBackupNotFound
+		}
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", ErrBackupNotFound
+	}
+	return backupPath, nil
+}
+
+// parseBackupStamp parses "20240610-081500" with an optional "-N" suffix
+// added when several backups are taken within the same second.
+func parseBackupStamp(stamp string) (time.Time, int, bool) {
+	if len(stamp) < len(backupTimeLayout) {
+		return time.Time{}, 0, false
+	}
+	t, err := time.ParseInLocation(backupTimeLayout, stamp[:len(backupTimeLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	rest := stamp[len(backupTimeLayout):]
+	if rest == "" {
+		return t, 0, true
+	}
+	seq, err := strconv.Atoi(strings.TrimPrefix(rest, "-"))
+	if err != nil || !strings.HasPrefix(rest, "-") || seq < 1 {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// backupPath returns an unused backup name for path taken at now.
+func backupPath(path string, now time.Time) string {
+	base := path + backupInfix + now.Format(backupTimeLayout)
+	candidate := base
+	for seq := 1; ; seq++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = base + "-" + strconv.Itoa(seq)
+	}
+}
+
+// replaceFile atomically replaces path with data, keeping the previous
+// content as a timestamped backup next to it, and returns the name of the
+// backup.
+func replaceFile(path string, data []byte) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := writeTemp(path, data, info.Mode())
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	backup := backupPath(path, time.Now())
+	if err := os.Rename(path, backup); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Rename(backup, path)
+		return "", err
+	}
+
+	return filepath.Base(backup), nil
+}
+
+// writeTemp writes data to a temporary file next to path, ready to be
+// renamed over it, and returns its name.
+func writeTemp(path string, data []byte, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeBackup(t *testing.T, path, stamp, content string) string {
+	t.Helper()
+	name := filepath.Base(path) + backupInfix + stamp
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+	return name
+}
+
+func TestListBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	writeBackup(t, path, "20240610-081500", "a\n")
+	writeBackup(t, path, "20240611-090000", "bb\n")
+	writeBackup(t, path, "20240611-090000-1", "ccc\n")
+	writeBackup(t, path, "garbage", "x\n")
+	writeBackup(t, filepath.Join(dir, "other.yml"), "20240612-000000", "x\n")
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	want := []string{"mediamtx.yml.bak-20240611-090000-1", "mediamtx.yml.bak-20240611-090000", "mediamtx.yml.bak-20240610-081500"}
+	if len(backups) != len(want) {
+		t.Fatalf("unexpected backups: %+v", backups)
+	}
+	for i, name := range want {
+		if backups[i].Name != name {
+			t.Fatalf("backup %d: got %s, want %s", i, backups[i].Name, name)
+		}
+	}
+	if backups[0].Size != 4 || backups[2].Time.Format(backupTimeLayout) != "20240610-081500" {
+		t.Fatalf("unexpected backup metadata: %+v", backups)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths:\n  cam:\n    rpiCameraAWB: indoor\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	name := writeBackup(t, path, "20240610-081500", "paths:\n  cam:\n    rpiCameraAWB: daylight\n")
+
+	hunks, err := DiffBackup(path, name)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(hunks) != 1 || hunks[0].Lines[2].Text != "    rpiCameraAWB: daylight" || hunks[0].Lines[3].Op != "+" {
+		t.Fatalf("unexpected diff: %+v", hunks)
+	}
+
+	if err := RestoreBackup(path, name); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.AWB != "daylight" {
+		t.Fatalf("expected restored awb, got %q", cfg.AWB)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode preserved, got %v", info.Mode().Perm())
+	}
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected the replaced file to be backed up, got %+v", backups)
+	}
+	undo, err := os.ReadFile(filepath.Join(dir, backups[0].Name))
+	if err != nil || !strings.Contains(string(undo), "indoor") {
+		t.Fatalf("unexpected undo backup: %q %v", undo, err)
+	}
+}
+
+func TestRestoreBackupRejectsForeignNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	for _, name := range []string{"secret", "../mediamtx.yml.bak-20240610-081500", "mediamtx.yml.bak-20240610-081500", "mediamtx.yml.bak-nope"} {
+		if err := RestoreBackup(path, name); !errors.Is(err, ErrBackupNotFound) {
+			t.Fatalf("%s: expected ErrBackupNotFound, got %v", name, err)
+		}
+	}
+
+	name := writeBackup(t, path, "20240610-081500", "paths: [\n")
+	if err := RestoreBackup(path, name); err == nil {
+		t.Fatalf("expected invalid yaml backup to be refused")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+	for _, stamp := range []string{"20240620-110000", "20240619-110000", "20240618-110000", "20240601-110000"} {
+		writeBackup(t, path, stamp, "x\n")
+	}
+
+	removed, err := PruneBackups(path, RetentionPolicy{MaxCount: 3, MaxAge: 72 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != "mediamtx.yml.bak-20240601-110000" {
+		t.Fatalf("unexpected removed: %+v", removed)
+	}
+
+	removed, err = PruneBackups(path, RetentionPolicy{MaxAge: time.Hour}, now.Add(30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	backups, _ := ListBackups(path)
+	if len(removed) != 2 || len(backups) != 1 || backups[0].Name != "mediamtx.yml.bak-20240620-110000" {
+		t.Fatalf("expected only the newest backup kept, removed %+v, left %+v", removed, backups)
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	newText := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+
+	hunks := DiffText(oldText, newText)
+	if len(hunks) != 2 {
+		t.Fatalf("expected two hunks, got %+v", hunks)
+	}
+	if hunks[0].Header != "@@ -1,5 +1,5 @@" || hunks[1].Header != "@@ -11,3 +11,4 @@" {
+		t.Fatalf("unexpected headers: %q %q", hunks[0].Header, hunks[1].Header)
+	}
+
+	got := UnifiedDiff("old", "new", hunks[:1])
+	want := "--- old\n+++ new\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n"
+	if got != want {
+		t.Fatalf("unexpected unified diff:\n%s", got)
+	}
+	if DiffText(oldText, oldText) != nil {
+		t.Fatalf("expected no hunks for equal texts")
+	}
+}
@@ -4,194 +4,301 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type CameraConfig struct {
-	VFlip           bool
-	HFlip           bool
-	Width           int
-	Height          int
-	AWB             string
-	Mode            string
-	AfMode          string
-	LensPosition    *float64
-	LensPositionSet bool
+	VFlip           bool     `json:"vFlip"`
+	HFlip           bool     `json:"hFlip"`
+	Width           int      `json:"width"`
+	Height          int      `json:"height"`
+	AWB             string   `json:"awb"`
+	Mode            string   `json:"mode"`
+	AfMode          string   `json:"afMode"`
+	LensPosition    *float64 `json:"lensPosition"`
+	LensPositionSet bool     `json:"-"`
+	// Params holds the canonical values of schema parameters without a
+	// dedicated field, keyed by YAML key. On save, an empty value removes the
+	// key and keys missing from the map are left untouched.
+	Params map[string]string `json:"params"`
+	// Revision identifies the path section the config was loaded from,
+	// with the pathDefaults it inherits. When set, SaveCameraConfig refuses
+	// to overwrite a path that changed since; changes to other paths do not
+	// count.
+	Revision string `json:"revision"`
 }
 
-func LoadCameraConfig(path string) (CameraConfig, error) {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return CameraConfig{}, err
+// Value returns the canonical value of a schema parameter and whether it is
+// set.
+func (c CameraConfig) Value(key string) (string, bool) {
+	switch key {
+	case "rpiCameraVFlip":
+		return strconv.FormatBool(c.VFlip), true
+	case "rpiCameraHFlip":
+		return strconv.FormatBool(c.HFlip), true
+	case "rpiCameraWidth":
+		return positiveInt(c.Width)
+	case "rpiCameraHeight":
+		return positiveInt(c.Height)
+	case "rpiCameraAWB":
+		return c.AWB, c.AWB != ""
+	case "rpiCameraMode":
+		return c.Mode, c.Mode != ""
+	case "rpiCameraAfMode":
+		return c.AfMode, c.AfMode != ""
+	case "rpiCameraLensPosition":
+		if c.LensPosition == nil {
+			return "", false
+		}
+		return strconv.FormatFloat(*c.LensPosition, 'g', -1, 64), true
 	}
+	v, ok := c.Params[key]
+	return v, ok && v != ""
+}
 
-	var root yaml.Node
-	if err := yaml.Unmarshal(b, &root); err != nil {
-		return CameraConfig{}, err
+// SetValue stores the canonical value of a schema parameter. An empty value
+// clears it.
+func (c *CameraConfig) SetValue(key, value string) error {
+	p, ok := LookupParam(key)
+	if !ok {
+		return fmt.Errorf("unknown parameter %s", key)
 	}
-
-	pathNode, err := findPathNode(&root, "cam")
+	value, err := p.Parse(value)
 	if err != nil {
-		return CameraConfig{}, err
+		return err
 	}
 
-	config := CameraConfig{}
-	if v, ok, err := getBool(pathNode, "rpiCameraVFlip"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.VFlip = v
-	}
-	if v, ok, err := getBool(pathNode, "rpiCameraHFlip"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.HFlip = v
+	switch key {
+	case "rpiCameraVFlip":
+		c.VFlip = value == "true"
+	case "rpiCameraHFlip":
+		c.HFlip = value == "true"
+	case "rpiCameraWidth":
+		c.Width, _ = strconv.Atoi(value)
+	case "rpiCameraHeight":
+		c.Height, _ = strconv.Atoi(value)
+	case "rpiCameraAWB":
+		c.AWB = value
+	case "rpiCameraMode":
+		c.Mode = value
+	case "rpiCameraAfMode":
+		c.AfMode = value
+	case "rpiCameraLensPosition":
+		c.LensPositionSet = true
+		c.LensPosition = nil
+		if value != "" {
+			v, _ := strconv.ParseFloat(value, 64)
+			c.LensPosition = &v
+		}
+	default:
+		if c.Params == nil {
+			c.Params = map[string]string{}
+		}
+		c.Params[key] = value
 	}
-	if v, ok, err := getInt(pathNode, "rpiCameraWidth"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.Width = v
+	return nil
+}
+
+func (c CameraConfig) schema() []Param {
+	return CameraSchema
+}
+
+// saveValue reports what SaveCameraConfig writes for key: a value to set,
+// an empty value to remove the key, or ok false to leave it untouched.
+func (c CameraConfig) saveValue(key string) (string, bool) {
+	switch key {
+	case "rpiCameraWidth", "rpiCameraHeight", "rpiCameraAWB":
+		return c.Value(key)
+	case "rpiCameraMode", "rpiCameraAfMode", "rpiCameraVFlip", "rpiCameraHFlip":
+		v, _ := c.Value(key)
+		return v, true
+	case "rpiCameraLensPosition":
+		if !c.LensPositionSet {
+			return "", false
+		}
+		v, _ := c.Value(key)
+		return v, true
 	}
-	if v, ok, err := getInt(pathNode, "rpiCameraHeight"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.Height = v
+	v, ok := c.Params[key]
+	return v, ok
+}
+
+func positiveInt(v int) (string, bool) {
+	if v <= 0 {
+		return "", false
 	}
-	if v, ok, err := getString(pathNode, "rpiCameraAWB"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.AWB = v
+	return strconv.Itoa(v), true
+}
+
+// CameraPaths lists the MediaMTX paths in the config file whose source is
+// rpiCamera, in file order.
+func CameraPaths(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
-	if v, ok, err := getString(pathNode, "rpiCameraMode"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.Mode = v
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
 	}
-	if v, ok, err := getString(pathNode, "rpiCameraAfMode"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.AfMode = v
+
+	mapping := rootMapping(&root)
+	if mapping == nil {
+		return nil, errors.New("invalid yaml root")
 	}
-	if v, ok, err := getFloat(pathNode, "rpiCameraLensPosition"); err != nil {
-		return CameraConfig{}, err
-	} else if ok {
-		config.LensPosition = &v
-		config.LensPositionSet = true
+	pathsNode := findMapValue(mapping, "paths")
+	if pathsNode == nil || pathsNode.Kind != yaml.MappingNode {
+		return nil, errors.New("paths section not found")
 	}
 
-	return config, nil
+	var names []string
+	for i := 0; i < len(pathsNode.Content)-1; i += 2 {
+		pathNode := pathsNode.Content[i+1]
+		if pathNode.Kind != yaml.MappingNode {
+			continue
+		}
+		if source, _ := getString(pathNode, "source"); source == "rpiCamera" {
+			names = append(names, pathsNode.Content[i].Value)
+		}
+	}
+	return names, nil
 }
 
-func SaveCameraConfig(path string, config CameraConfig) error {
+func LoadCameraConfig(path, name string) (CameraConfig, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return CameraConfig{}, err
 	}
+	return parseCameraConfig(b, name)
+}
 
+func parseCameraConfig(b []byte, name string) (CameraConfig, error) {
 	var root yaml.Node
 	if err := yaml.Unmarshal(b, &root); err != nil {
-		return err
+		return CameraConfig{}, err
 	}
 
-	pathNode, err := findPathNode(&root, "cam")
+	pathNode, err := findPathNode(&root, name)
 	if err != nil {
-		return err
+		return CameraConfig{}, err
 	}
 
-	setBool(pathNode, "rpiCameraVFlip", config.VFlip)
-	setBool(pathNode, "rpiCameraHFlip", config.HFlip)
-	if config.Width > 0 {
-		setInt(pathNode, "rpiCameraWidth", config.Width)
-	}
-	if config.Height > 0 {
-		setInt(pathNode, "rpiCameraHeight", config.Height)
-	}
-	if config.AWB != "" {
-		setString(pathNode, "rpiCameraAWB", config.AWB)
+	config := CameraConfig{Params: map[string]string{}}
+	for _, p := range CameraSchema {
+		raw, ok := getString(pathNode, p.Key)
+		if !ok {
+			continue
+		}
+		if err := config.SetValue(p.Key, raw); err != nil {
+			return CameraConfig{}, err
+		}
 	}
-	if config.Mode != "" {
-		setString(pathNode, "rpiCameraMode", config.Mode)
-	} else {
-		deleteKey(pathNode, "rpiCameraMode")
+	config.LensPositionSet = config.LensPosition != nil
+	if config.Revision, err = pathRevision(&root, name, pathNode); err != nil {
+		return CameraConfig{}, err
 	}
-	if config.AfMode != "" {
-		setString(pathNode, "rpiCameraAfMode", config.AfMode)
-	} else {
-		deleteKey(pathNode, "rpiCameraAfMode")
+
+	return config, nil
+}
+
+// SaveCameraConfig writes config to path name and returns the name of the
+// backup of the replaced file.
+func SaveCameraConfig(path, name string, config CameraConfig) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
 	}
-	if config.LensPositionSet {
-		if config.LensPosition == nil {
-			deleteKey(pathNode, "rpiCameraLensPosition")
-		} else {
-			setFloat(pathNode, "rpiCameraLensPosition", *config.LensPosition)
+	if config.Revision != "" {
+		current, err := parseCameraConfig(b, name)
+		if err != nil {
+			return "", err
+		}
+		if current.Revision != config.Revision {
+			return "", newConflictError(current.Revision, current, config)
 		}
 	}
+	return writePathKeys(path, b, name, config)
+}
 
-	out, err := yaml.Marshal(&root)
+// writePathKeys applies keys to path name in b, the current content of the
+// config file at path, and replaces the file with the result. It returns
+// the name of the backup of the replaced file.
+func writePathKeys(path string, b []byte, name string, keys pathKeys) (string, error) {
+	out, err := editPathKeys(b, name, keys)
+	if errors.Is(err, errNotEditable) {
+		out, err = marshalPathKeys(b, name, keys)
+	}
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	var check yaml.Node
-	if err := yaml.Unmarshal(out, &check); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
-	}
+	return replaceFile(path, out)
+}
 
-	info, err := os.Stat(path)
-	if err != nil {
-		return err
+// marshalPathKeys applies keys through the node tree and re-encodes the
+// whole document, normalizing its layout.
+func marshalPathKeys(b []byte, name string, keys pathKeys) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
 	}
 
-	dir := filepath.Dir(path)
-	tmp, err := os.CreateTemp(dir, "mediamtx.yml.tmp-*")
+	pathNode, err := findPathNode(&root, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer os.Remove(tmp.Name())
 
-	if _, err := tmp.Write(out); err != nil {
-		_ = tmp.Close()
-		return err
-	}
-	if err := tmp.Close(); err != nil {
-		return err
-	}
-	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
-		return err
+	for _, p := range keys.schema() {
+		value, ok := keys.saveValue(p.Key)
+		if !ok {
+			continue
+		}
+		if value == "" {
+			deleteKey(pathNode, p.Key)
+		} else {
+			setScalar(pathNode, p.Key, p.yamlTag(), value)
+		}
 	}
 
-	backup := path + ".bak-" + time.Now().Format("20060102-150405")
-	if err := os.Rename(path, backup); err != nil {
-		return err
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, err
 	}
-	if err := os.Rename(tmp.Name(), path); err != nil {
-		_ = os.Rename(backup, path)
-		return err
+
+	var check yaml.Node
+	if err := yaml.Unmarshal(out, &check); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	return nil
+	return out, nil
 }
 
 func findPathNode(root *yaml.Node, name string) (*yaml.Node, error) {
+	_, pathNode, err := findPathEntry(root, name)
+	return pathNode, err
+}
+
+// findPathEntry returns the key and mapping nodes of path name.
+func findPathEntry(root *yaml.Node, name string) (*yaml.Node, *yaml.Node, error) {
 	mapping := rootMapping(root)
 	if mapping == nil {
-		return nil, errors.New("invalid yaml root")
+		return nil, nil, errors.New("invalid yaml root")
 	}
 
 	pathsNode := findMapValue(mapping, "paths")
 	if pathsNode == nil || pathsNode.Kind != yaml.MappingNode {
-		return nil, errors.New("paths section not found")
+		return nil, nil, errors.New("paths section not found")
 	}
 
-	pathNode := findMapValue(pathsNode, name)
+	keyNode, pathNode := findMapEntry(pathsNode, name)
 	if pathNode == nil || pathNode.Kind != yaml.MappingNode {
-		return nil, fmt.Errorf("path %q not found", name)
+		return nil, nil, fmt.Errorf("path %q not found", name)
 	}
-	return pathNode, nil
+	return keyNode, pathNode, nil
 }
 
 func rootMapping(root *yaml.Node) *yaml.Node {
@@ -206,117 +313,40 @@ func rootMapping(root *yaml.Node) *yaml.Node {
 }
 
 func findMapValue(mapping *yaml.Node, key string) *yaml.Node {
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		k := mapping.Content[i]
-		v := mapping.Content[i+1]
-		if k.Value == key {
-			return v
-		}
-	}
-	return nil
+	_, v := findMapEntry(mapping, key)
+	return v
 }
 
-func setBool(mapping *yaml.Node, key string, value bool) {
+func findMapEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
 	for i := 0; i < len(mapping.Content)-1; i += 2 {
 		k := mapping.Content[i]
 		v := mapping.Content[i+1]
 		if k.Value == key {
-			v.Kind = yaml.ScalarNode
-			v.Tag = "!!bool"
-			v.Value = strconv.FormatBool(value)
-			return
+			return k, v
 		}
 	}
-
-	mapping.Content = append(mapping.Content,
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)},
-	)
+	return nil, nil
 }
 
-func getBool(mapping *yaml.Node, key string) (bool, bool, error) {
-	node := findMapValue(mapping, key)
-	if node == nil {
-		return false, false, nil
-	}
-	value := strings.TrimSpace(node.Value)
-	if value == "" {
-		return false, false, nil
-	}
-	parsed, err := strconv.ParseBool(value)
-	if err != nil {
-		return false, true, fmt.Errorf("invalid bool for %s", key)
-	}
-	return parsed, true, nil
-}
-
-func setInt(mapping *yaml.Node, key string, value int) {
+func setScalar(mapping *yaml.Node, key, tag, value string) {
 	for i := 0; i < len(mapping.Content)-1; i += 2 {
 		k := mapping.Content[i]
 		v := mapping.Content[i+1]
 		if k.Value == key {
 			v.Kind = yaml.ScalarNode
-			v.Tag = "!!int"
-			v.Value = strconv.Itoa(value)
-			return
-		}
-	}
-
-	mapping.Content = append(mapping.Content,
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(value)},
-	)
-}
-
-func getInt(mapping *yaml.Node, key string) (int, bool, error) {
-	node := findMapValue(mapping, key)
-	if node == nil {
-		return 0, false, nil
-	}
-	value := strings.TrimSpace(node.Value)
-	if value == "" {
-		return 0, false, nil
-	}
-	parsed, err := strconv.Atoi(value)
-	if err != nil {
-		return 0, true, fmt.Errorf("invalid int for %s", key)
-	}
-	return parsed, true, nil
-}
-
-func setFloat(mapping *yaml.Node, key string, value float64) {
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		k := mapping.Content[i]
-		v := mapping.Content[i+1]
-		if k.Value == key {
-			v.Kind = yaml.ScalarNode
-			v.Tag = "!!float"
-			v.Value = strconv.FormatFloat(value, 'g', -1, 64)
-			return
-		}
-	}
-
-	mapping.Content = append(mapping.Content,
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: strconv.FormatFloat(value, 'g', -1, 64)},
-	)
-}
-
-func setString(mapping *yaml.Node, key, value string) {
-	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		k := mapping.Content[i]
-		v := mapping.Content[i+1]
-		if k.Value == key {
-			v.Kind = yaml.ScalarNode
-			v.Tag = "!!str"
+			v.Tag = tag
 			v.Value = value
+			v.Content = nil
+			if tag != "!!str" {
+				v.Style = 0
+			}
 			return
 		}
 	}
 
 	mapping.Content = append(mapping.Content,
 		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
-		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value},
 	)
 }
 
@@ -330,30 +360,14 @@ func deleteKey(mapping *yaml.Node, key string) {
 	}
 }
 
-func getFloat(mapping *yaml.Node, key string) (float64, bool, error) {
-	node := findMapValue(mapping, key)
-	if node == nil {
-		return 0, false, nil
-	}
-	value := strings.TrimSpace(node.Value)
-	if value == "" {
-		return 0, false, nil
-	}
-	parsed, err := strconv.ParseFloat(value, 64)
-	if err != nil {
-		return 0, true, fmt.Errorf("invalid float for %s", key)
-	}
-	return parsed, true, nil
-}
-
-func getString(mapping *yaml.Node, key string) (string, bool, error) {
+func getString(mapping *yaml.Node, key string) (string, bool) {
 	node := findMapValue(mapping, key)
 	if node == nil {
-		return "", false, nil
+		return "", false
 	}
 	value := strings.TrimSpace(node.Value)
 	if value == "" {
-		return "", false, nil
+		return "", false
 	}
-	return value, true, nil
+	return value, true
 }
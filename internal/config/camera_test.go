@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,7 +29,7 @@ func TestLoadAndSaveCameraConfig(t *testing.T) {
 		t.Fatalf("write config: %v", err)
 	}
 
-	cfg, err := LoadCameraConfig(path)
+	cfg, err := LoadCameraConfig(path, "cam")
 	if err != nil {
 		t.Fatalf("load config: %v", err)
 	}
@@ -64,11 +65,15 @@ func TestLoadAndSaveCameraConfig(t *testing.T) {
 	lensPosition := 2.5
 	cfg.LensPosition = &lensPosition
 	cfg.LensPositionSet = true
-	if err := SaveCameraConfig(path, cfg); err != nil {
+	backup, err := SaveCameraConfig(path, "cam", cfg)
+	if err != nil {
 		t.Fatalf("save config: %v", err)
 	}
+	if b, err := os.ReadFile(filepath.Join(filepath.Dir(path), backup)); err != nil || string(b) != input {
+		t.Fatalf("expected the previous file in backup %q: %v", backup, err)
+	}
 
-	updated, err := LoadCameraConfig(path)
+	updated, err := LoadCameraConfig(path, "cam")
 	if err != nil {
 		t.Fatalf("load updated: %v", err)
 	}
@@ -126,12 +131,15 @@ func TestLoadAndSaveCameraConfig(t *testing.T) {
 		t.Fatalf("expected other path preserved")
 	}
 
+	// cfg now predates the file; the saves below reuse it without the
+	// revision check.
+	cfg.Revision = ""
 	cfg.Mode = ""
-	if err := SaveCameraConfig(path, cfg); err != nil {
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
 		t.Fatalf("save config without mode: %v", err)
 	}
 
-	updated, err = LoadCameraConfig(path)
+	updated, err = LoadCameraConfig(path, "cam")
 	if err != nil {
 		t.Fatalf("load without mode: %v", err)
 	}
@@ -149,11 +157,11 @@ func TestLoadAndSaveCameraConfig(t *testing.T) {
 
 	cfg.LensPosition = nil
 	cfg.LensPositionSet = true
-	if err := SaveCameraConfig(path, cfg); err != nil {
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
 		t.Fatalf("save config without lens position: %v", err)
 	}
 
-	updated, err = LoadCameraConfig(path)
+	updated, err = LoadCameraConfig(path, "cam")
 	if err != nil {
 		t.Fatalf("load without lens position: %v", err)
 	}
@@ -172,11 +180,11 @@ func TestLoadAndSaveCameraConfig(t *testing.T) {
 	lensPosition = 0
 	cfg.LensPosition = &lensPosition
 	cfg.LensPositionSet = true
-	if err := SaveCameraConfig(path, cfg); err != nil {
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
 		t.Fatalf("save config with infinity: %v", err)
 	}
 
-	updated, err = LoadCameraConfig(path)
+	updated, err = LoadCameraConfig(path, "cam")
 	if err != nil {
 		t.Fatalf("load with infinity: %v", err)
 	}
@@ -203,7 +211,7 @@ func TestLoadCameraConfigMissingPath(t *testing.T) {
 	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
 		t.Fatalf("write config: %v", err)
 	}
-	if _, err := LoadCameraConfig(path); err == nil {
+	if _, err := LoadCameraConfig(path, "cam"); err == nil {
 		t.Fatalf("expected error for missing cam path")
 	}
 }
@@ -219,7 +227,225 @@ func TestLoadCameraConfigInvalidValues(t *testing.T) {
 	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
 		t.Fatalf("write config: %v", err)
 	}
-	if _, err := LoadCameraConfig(path); err == nil {
+	if _, err := LoadCameraConfig(path, "cam"); err == nil {
 		t.Fatalf("expected error for invalid values")
 	}
 }
+
+func TestLoadAndSaveCameraParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraSaturation: 1.25
+    rpiCameraMetering: "matrix"
+    rpiCameraFlickerPeriod: 20000
+    rpiCameraDenoise: "off"
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Params["rpiCameraSaturation"] != "1.25" || cfg.Params["rpiCameraMetering"] != "matrix" {
+		t.Fatalf("unexpected params: %v", cfg.Params)
+	}
+	if v, ok := cfg.Value("rpiCameraDenoise"); !ok || v != "off" {
+		t.Fatalf("unexpected denoise: %q", v)
+	}
+
+	if err := cfg.SetValue("rpiCameraTextOverlay", "%H:%M"); err != nil {
+		t.Fatalf("set overlay: %v", err)
+	}
+	if err := cfg.SetValue("rpiCameraFlickerPeriod", ""); err != nil {
+		t.Fatalf("clear flicker: %v", err)
+	}
+	if err := cfg.SetValue("rpiCameraSaturation", "abc"); err == nil {
+		t.Fatalf("expected invalid float")
+	}
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if strings.Contains(string(out), "rpiCameraFlickerPeriod") {
+		t.Fatalf("expected flicker period removed:\n%s", out)
+	}
+	if !strings.Contains(string(out), `rpiCameraDenoise: "off"`) {
+		t.Fatalf("expected denoise kept as a quoted string:\n%s", out)
+	}
+
+	updated, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load updated: %v", err)
+	}
+	if updated.Params["rpiCameraTextOverlay"] != "%H:%M" || updated.Params["rpiCameraSaturation"] != "1.25" {
+		t.Fatalf("unexpected updated params: %v", updated.Params)
+	}
+}
+
+func TestCameraPaths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  front:
+    source: rpiCamera
+    rpiCameraCamID: 0
+  relay:
+    source: rtsp://10.0.0.2/stream
+  back:
+    source: rpiCamera
+    rpiCameraCamID: 1
+  all_others:
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	names, err := CameraPaths(path)
+	if err != nil {
+		t.Fatalf("camera paths: %v", err)
+	}
+	if len(names) != 2 || names[0] != "front" || names[1] != "back" {
+		t.Fatalf("unexpected paths: %v", names)
+	}
+
+	back, err := LoadCameraConfig(path, "back")
+	if err != nil {
+		t.Fatalf("load back: %v", err)
+	}
+	if back.Params["rpiCameraCamID"] != "1" {
+		t.Fatalf("unexpected back camera id: %v", back.Params)
+	}
+	back.HFlip = true
+	if _, err := SaveCameraConfig(path, "back", back); err != nil {
+		t.Fatalf("save back: %v", err)
+	}
+	front, err := LoadCameraConfig(path, "front")
+	if err != nil {
+		t.Fatalf("load front: %v", err)
+	}
+	if front.HFlip {
+		t.Fatalf("expected front camera untouched")
+	}
+}
+
+func TestSaveCameraConfigConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: indoor
+    rpiCameraHFlip: false
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	rev, err := Revision(path, "cam")
+	if err != nil || rev != cfg.Revision || rev == "" {
+		t.Fatalf("unexpected revision %q (loaded %q): %v", rev, cfg.Revision, err)
+	}
+	for _, inherited := range []string{"pathDefaults:\n  recordDeleteAfter: 7d\n", "recordDeleteAfter: 7d\n"} {
+		if err := os.WriteFile(path, []byte(inherited+input), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if rev, err := Revision(path, "cam"); err != nil || rev == cfg.Revision {
+			t.Fatalf("expected %q to change the revision, got %q: %v", inherited, rev, err)
+		}
+	}
+
+	edited := strings.Replace(input, "rpiCameraAWB: indoor", "rpiCameraAWB: daylight", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatalf("edit config: %v", err)
+	}
+
+	cfg.HFlip = true
+	_, err = SaveCameraConfig(path, "cam", cfg)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if conflict.Revision == cfg.Revision {
+		t.Fatalf("expected the current revision in the conflict")
+	}
+	want := []FieldConflict{
+		{Key: "rpiCameraHFlip", Current: "false", Submitted: "true"},
+		{Key: "rpiCameraAWB", Current: "daylight", Submitted: "indoor"},
+	}
+	if len(conflict.Fields) != len(want) {
+		t.Fatalf("unexpected conflict fields: %+v", conflict.Fields)
+	}
+	for i := range want {
+		if conflict.Fields[i] != want[i] {
+			t.Fatalf("field %d: got %+v, want %+v", i, conflict.Fields[i], want[i])
+		}
+	}
+	out, _ := os.ReadFile(path)
+	if string(out) != edited {
+		t.Fatalf("expected the edited file untouched")
+	}
+
+	cfg.Revision = conflict.Revision
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save with current revision: %v", err)
+	}
+}
+
+func TestRevisionCoversOnlyThePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `logLevel: info
+paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: indoor
+  cam2:
+    source: rpiCamera
+    rpiCameraCamID: 1
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cam, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cam2, err := LoadCameraConfig(path, "cam2")
+	if err != nil {
+		t.Fatalf("load cam2: %v", err)
+	}
+	if cam.Revision == cam2.Revision {
+		t.Fatal("expected a revision per path")
+	}
+
+	// Saving the other camera and editing another key leave cam's form valid.
+	cam2.HFlip = true
+	if _, err := SaveCameraConfig(path, "cam2", cam2); err != nil {
+		t.Fatalf("save cam2: %v", err)
+	}
+	b, _ := os.ReadFile(path)
+	edited := strings.Replace(string(b), "logLevel: info", "logLevel: debug # verbose", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatalf("edit config: %v", err)
+	}
+	cam.VFlip = true
+	if _, err := SaveCameraConfig(path, "cam", cam); err != nil {
+		t.Fatalf("save cam with its revision: %v", err)
+	}
+
+	// The stale cam2 revision still conflicts on cam2 itself.
+	cam2.HFlip = false
+	var conflict *ConflictError
+	if _, err := SaveCameraConfig(path, "cam2", cam2); !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict on cam2, got %v", err)
+	}
+}
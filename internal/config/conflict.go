@@ -0,0 +1,88 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldConflict is a parameter whose value in the file differs from the
+// value that was about to be saved.
+type FieldConflict struct {
+	Key       string `json:"key"`
+	Current   string `json:"current"`
+	Submitted string `json:"submitted"`
+}
+
+// ConflictError is returned by SaveCameraConfig and SaveRecordConfig when
+// the camera path changed after the config was loaded.
+type ConflictError struct {
+	// Revision is the revision of the path on disk.
+	Revision string
+	Fields   []FieldConflict
+}
+
+func (e *ConflictError) Error() string {
+	return "config file changed since it was loaded"
+}
+
+// Revision returns the revision of camera path name in the config file at
+// path.
+func Revision(path, name string) (string, error) {
+	config, err := LoadCameraConfig(path, name)
+	if err != nil {
+		return "", err
+	}
+	return config.Revision, nil
+}
+
+// pathRevision hashes what the camera card of path name shows: the section
+// of the path, which holds every key the camera forms edit, and the
+// pathDefaults section and top-level recording keys it inherits from.
+// Comments and layout do not count, and neither do other paths, so saving
+// one camera does not invalidate the form of another.
+func pathRevision(root *yaml.Node, name string, pathNode *yaml.Node) (string, error) {
+	var section map[string]any
+	if err := pathNode.Decode(&section); err != nil {
+		return "", err
+	}
+	inherited := map[string]any{}
+	if mapping := rootMapping(root); mapping != nil {
+		if defaults := findMapValue(mapping, "pathDefaults"); defaults != nil {
+			var v any
+			if err := defaults.Decode(&v); err != nil {
+				return "", err
+			}
+			inherited["pathDefaults"] = v
+		}
+		for _, p := range RecordSchema {
+			if v, ok := getString(mapping, p.Key); ok {
+				inherited[p.Key] = v
+			}
+		}
+	}
+	b, err := yaml.Marshal(map[string]any{"paths": map[string]any{name: section}, "inherited": inherited})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// newConflictError lists the keys that saving submitted would change
+// relative to current, the keys on disk at revision.
+func newConflictError(revision string, current, submitted pathKeys) *ConflictError {
+	conflict := &ConflictError{Revision: revision}
+	for _, p := range submitted.schema() {
+		want, ok := submitted.saveValue(p.Key)
+		if !ok {
+			continue
+		}
+		have, _ := current.Value(p.Key)
+		if have != want {
+			conflict.Fields = append(conflict.Fields, FieldConflict{Key: p.Key, Current: have, Submitted: want})
+		}
+	}
+	return conflict
+}
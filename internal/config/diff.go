@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffLine is one line of a unified diff. Op is " " for context, "-" for a
+// line only in the old text and "+" for a line only in the new text.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffHunk is a run of changes with up to diffContext lines of context.
+type DiffHunk struct {
+	Header string     `json:"header"`
+	Lines  []DiffLine `json:"lines"`
+}
+
+const diffContext = 3
+
+// DiffText compares two texts line by line and returns unified diff hunks.
+// An empty result means the texts are equal.
+func DiffText(oldText, newText string) []DiffHunk {
+	return diffHunks(diffLines(splitLines(oldText), splitLines(newText)))
+}
+
+// UnifiedDiff renders hunks in unified diff format.
+func UnifiedDiff(oldName, newName string, hunks []DiffHunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		b.WriteString(h.Header)
+		b.WriteByte('\n')
+		for _, l := range h.Lines {
+			b.WriteString(l.Op)
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the edit script from a to b. Config edits touch a few
+// lines, so the common prefix and suffix are stripped before the quadratic
+// LCS runs on the middle.
+func diffLines(a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DiffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, DiffLine{Op: " ", Text: line})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, DiffLine{Op: " ", Text: midA[i]})
+			i++
+			j++
+		case j < len(midB) && (i == len(midA) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, DiffLine{Op: "+", Text: midB[j]})
+			j++
+		default:
+			ops = append(ops, DiffLine{Op: "-", Text: midA[i]})
+			i++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, DiffLine{Op: " ", Text: line})
+	}
+	return ops
+}
+
+func diffHunks(ops []DiffLine) []DiffHunk {
+	var hunks []DiffHunk
+	for start := 0; start < len(ops); {
+		// Find the next change.
+		first := start
+		for first < len(ops) && ops[first].Op == " " {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context.
+		end := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].Op != " " {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		to := end + diffContext
+		if to > len(ops) {
+			to = len(ops)
+		}
+		hunks = append(hunks, newHunk(ops, from, to))
+		start = to
+	}
+	return hunks
+}
+
+func newHunk(ops []DiffLine, from, to int) DiffHunk {
+	oldStart, newStart := 1, 1
+	for _, op := range ops[:from] {
+		if op.Op != "+" {
+			oldStart++
+		}
+		if op.Op != "-" {
+			newStart++
+		}
+	}
+	var oldCount, newCount int
+	for _, op := range ops[from:to] {
+		if op.Op != "+" {
+			oldCount++
+		}
+		if op.Op != "-" {
+			newCount++
+		}
+	}
+	lines := append([]DiffLine(nil), ops[from:to]...)
+	return DiffHunk{
+		Header: fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount),
+		Lines:  lines,
+	}
+}
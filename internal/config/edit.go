@@ -0,0 +1,283 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errNotEditable marks a layout the line editor does not handle, such as a
+// flow mapping or a multi-line value. SaveCameraConfig then re-encodes the
+// whole document instead.
+var errNotEditable = errors.New("layout not editable in place")
+
+// lineEditor rewrites a YAML document line by line. Edits refer to the
+// original 0-based line numbers and every untouched line is kept byte for
+// byte.
+type lineEditor struct {
+	lines   []string
+	replace map[int]string
+	remove  map[int]bool
+	insert  map[int][]string
+}
+
+func newLineEditor(b []byte) *lineEditor {
+	return &lineEditor{
+		lines:   strings.SplitAfter(string(b), "\n"),
+		replace: map[int]string{},
+		remove:  map[int]bool{},
+		insert:  map[int][]string{},
+	}
+}
+
+func (e *lineEditor) touched(i int) bool {
+	_, replaced := e.replace[i]
+	return replaced || e.remove[i]
+}
+
+// setLine replaces the content of line i, keeping its line ending.
+func (e *lineEditor) setLine(i int, content string) {
+	e.replace[i] = content + lineEnding(e.lines[i])
+}
+
+// insertAfter adds a line after line i, using the ending of line i.
+func (e *lineEditor) insertAfter(i int, content string) {
+	eol := lineEnding(e.lines[i])
+	if eol == "" {
+		// The last line has no newline; give it the document's one and end
+		// the new line without.
+		eol = lineEnding(e.lines[0])
+		if eol == "" {
+			eol = "\n"
+		}
+		if replaced, ok := e.replace[i]; ok {
+			e.replace[i] = replaced + eol
+		} else {
+			e.replace[i] = e.lines[i] + eol
+		}
+		e.insert[i] = append(e.insert[i], content)
+		return
+	}
+	e.insert[i] = append(e.insert[i], content+eol)
+}
+
+func (e *lineEditor) bytes() []byte {
+	var b strings.Builder
+	for i, line := range e.lines {
+		if replaced, ok := e.replace[i]; ok {
+			line = replaced
+		}
+		if !e.remove[i] {
+			b.WriteString(line)
+		}
+		for _, added := range e.insert[i] {
+			b.WriteString(added)
+		}
+	}
+	return []byte(b.String())
+}
+
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return "\n"
+	}
+	return ""
+}
+
+// pathKeys is a set of keys of one path that is loaded and saved together:
+// the camera parameters or the recording settings.
+type pathKeys interface {
+	// schema lists the keys of the set.
+	schema() []Param
+	// Value returns the canonical value of key and whether it is set.
+	Value(key string) (string, bool)
+	// saveValue reports what a save writes for key: a value to set, an
+	// empty value to remove the key, or ok false to leave it untouched.
+	saveValue(key string) (string, bool)
+}
+
+// editPathKeys applies the save values of keys to path name in b,
+// rewriting only the lines of changed keys. A missing key reuses a
+// commented-out "#key:" line of the same path before it is appended.
+func editPathKeys(b []byte, name string, keys pathKeys) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	pathKey, pathNode, err := findPathEntry(&root, name)
+	if err != nil {
+		return nil, err
+	}
+	if pathNode.Style&yaml.FlowStyle != 0 || len(pathNode.Content) == 0 {
+		return nil, errNotEditable
+	}
+
+	e := newLineEditor(b)
+	indent := strings.Repeat(" ", pathNode.Content[0].Column-1)
+	regionEnd := pathRegionEnd(&root, pathKey, len(e.lines))
+	last := nodeEndLine(pathNode) - 1
+
+	for _, p := range keys.schema() {
+		value, ok := keys.saveValue(p.Key)
+		if !ok {
+			continue
+		}
+		keyNode, valNode := findMapEntry(pathNode, p.Key)
+
+		switch {
+		case keyNode != nil && value == "":
+			for i := keyNode.Line - 1; i < nodeEndLine(valNode); i++ {
+				e.remove[i] = true
+			}
+		case keyNode != nil:
+			if current, err := p.Parse(strings.TrimSpace(valNode.Value)); err == nil && current == value && valNode.Kind == yaml.ScalarNode {
+				continue
+			}
+			if valNode.Kind != yaml.ScalarNode || valNode.Line != keyNode.Line || valNode.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+				return nil, errNotEditable
+			}
+			line := keyNode.Line - 1
+			style := yaml.Style(0)
+			if p.yamlTag() == "!!str" {
+				style = valNode.Style
+			}
+			formatted, err := formatScalar(p.yamlTag(), value, style)
+			if err != nil {
+				return nil, err
+			}
+			content := strings.TrimRight(e.lines[line], "\r\n")
+			runes := []rune(content)
+			if valNode.Column-1 > len(runes) {
+				return nil, errNotEditable
+			}
+			e.setLine(line, string(runes[:valNode.Column-1])+formatted+trailingComment(content, valNode.LineComment))
+		case value != "":
+			formatted, err := formatScalar(p.yamlTag(), value, 0)
+			if err != nil {
+				return nil, err
+			}
+			entry := indent + p.Key + ": " + formatted
+			if line, ok := commentedKeyLine(e, p.Key, pathKey.Line, regionEnd, pathKey.Column); ok {
+				e.setLine(line, entry)
+			} else {
+				e.insertAfter(last, entry)
+			}
+		}
+	}
+
+	out := e.bytes()
+	if err := verifyPathKeys(out, name, keys); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pathRegionEnd returns the 0-based line where the next path or top-level
+// key after pathKey starts, or n at the end of the document.
+func pathRegionEnd(root *yaml.Node, pathKey *yaml.Node, n int) int {
+	end := n
+	var visit func(mapping *yaml.Node)
+	visit = func(mapping *yaml.Node) {
+		for i := 0; i < len(mapping.Content)-1; i += 2 {
+			k := mapping.Content[i]
+			if k.Line > pathKey.Line && k.Column <= pathKey.Column && k.Line-1 < end {
+				end = k.Line - 1
+			}
+			if v := mapping.Content[i+1]; v.Kind == yaml.MappingNode && k.Column < pathKey.Column {
+				visit(v)
+			}
+		}
+	}
+	if mapping := rootMapping(root); mapping != nil {
+		visit(mapping)
+	}
+	return end
+}
+
+// commentedKeyLine finds an unused "#key:" line inside the path region that
+// is indented deeper than the path key.
+func commentedKeyLine(e *lineEditor, key string, pathLine, regionEnd, pathColumn int) (int, bool) {
+	re := regexp.MustCompile(`^(\s*)#\s*` + regexp.QuoteMeta(key) + `\s*:`)
+	for i := pathLine; i < regionEnd && i < len(e.lines); i++ {
+		m := re.FindStringSubmatch(e.lines[i])
+		if m == nil || e.touched(i) || len(m[1]) < pathColumn {
+			continue
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// nodeEndLine returns the last 1-based line a node spans.
+func nodeEndLine(n *yaml.Node) int {
+	end := n.Line
+	for _, c := range n.Content {
+		if l := nodeEndLine(c); l > end {
+			end = l
+		}
+	}
+	return end
+}
+
+// trailingComment returns the comment after the value on line, with its
+// original spacing.
+func trailingComment(line, comment string) string {
+	if comment == "" {
+		return ""
+	}
+	i := strings.LastIndex(line, comment)
+	if i < 0 {
+		return " " + comment
+	}
+	start := i
+	for start > 0 && (line[start-1] == ' ' || line[start-1] == '\t') {
+		start--
+	}
+	return line[start:]
+}
+
+func formatScalar(tag, value string, style yaml.Style) (string, error) {
+	out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Style: style})
+	if err != nil {
+		return "", err
+	}
+	formatted := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(formatted, "\n") {
+		return "", errNotEditable
+	}
+	return formatted, nil
+}
+
+// verifyPathKeys checks that b parses and holds every value keys saves.
+func verifyPathKeys(b []byte, name string, keys pathKeys) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return fmt.Errorf("%w: %v", errNotEditable, err)
+	}
+	pathNode, err := findPathNode(&root, name)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNotEditable, err)
+	}
+	for _, p := range keys.schema() {
+		want, ok := keys.saveValue(p.Key)
+		if !ok {
+			continue
+		}
+		var got string
+		if raw, set := getString(pathNode, p.Key); set {
+			if got, err = p.Parse(raw); err != nil {
+				return fmt.Errorf("%w: %v", errNotEditable, err)
+			}
+		}
+		if got != want {
+			return fmt.Errorf("%w: %s is %q, want %q", errNotEditable, p.Key, got, want)
+		}
+	}
+	return nil
+}
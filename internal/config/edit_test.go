@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const commentedConfig = `# MediaMTX configuration
+logLevel: info
+
+paths:
+  cam:
+    source: rpiCamera
+    rpiCameraWidth: 1280
+    rpiCameraHeight: 720
+    #rpiCameraVFlip: true
+    #rpiCameraHFlip: true
+    #rpiCameraBitrate: 4000000
+    rpiCameraAfMode: "continuous"
+    # For european grid blink frequency
+    rpiCameraFlickerPeriod: 20000   # 50 Hz mains
+
+    # Color & image tuning
+    rpiCameraSaturation: 1.25
+
+    rpiCameraAWB: "indoor"
+  other:
+    source: rtsp://10.0.0.2/stream
+    #rpiCameraMode: "2304:1296:10:P"
+`
+
+func TestSaveCameraConfigMinimalEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	if err := os.WriteFile(path, []byte(commentedConfig), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cfg.VFlip = true
+	cfg.AfMode = "manual"
+	cfg.Mode = "2304:1296:10:P"
+	cfg.Params["rpiCameraBitrate"] = "5000000"
+	cfg.Params["rpiCameraFlickerPeriod"] = "16666"
+	cfg.Params["rpiCameraSaturation"] = ""
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	want := `# MediaMTX configuration
+logLevel: info
+
+paths:
+  cam:
+    source: rpiCamera
+    rpiCameraWidth: 1280
+    rpiCameraHeight: 720
+    rpiCameraVFlip: true
+    rpiCameraHFlip: false
+    rpiCameraBitrate: 5000000
+    rpiCameraAfMode: "manual"
+    # For european grid blink frequency
+    rpiCameraFlickerPeriod: 16666   # 50 Hz mains
+
+    # Color & image tuning
+
+    rpiCameraAWB: "indoor"
+    rpiCameraMode: 2304:1296:10:P
+  other:
+    source: rtsp://10.0.0.2/stream
+    #rpiCameraMode: "2304:1296:10:P"
+`
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(out) != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	// Saving unchanged values leaves the file byte for byte identical.
+	cfg, err = LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save unchanged: %v", err)
+	}
+	again, _ := os.ReadFile(path)
+	if string(again) != want {
+		t.Fatalf("expected no-op save to keep the file:\n%s", again)
+	}
+}
+
+func TestSaveCameraConfigFlowMappingFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths:\n  cam: {source: rpiCamera, rpiCameraAWB: indoor}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cfg.AWB = "daylight"
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	saved, err := LoadCameraConfig(path, "cam")
+	if err != nil || saved.AWB != "daylight" {
+		t.Fatalf("unexpected saved config: %+v %v", saved, err)
+	}
+}
+
+func TestSaveCameraConfigKeepsCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := "paths:\r\n  cam:\r\n    source: rpiCamera\r\n    rpiCameraVFlip: false\r\n    rpiCameraHFlip: false\r\n    rpiCameraAWB: indoor"
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cfg.HFlip = true
+	cfg.Params["rpiCameraBitrate"] = "4000000"
+	if _, err := SaveCameraConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	want := "paths:\r\n  cam:\r\n    source: rpiCamera\r\n    rpiCameraVFlip: false\r\n    rpiCameraHFlip: true\r\n    rpiCameraAWB: indoor\r\n    rpiCameraBitrate: 4000000"
+	out, _ := os.ReadFile(path)
+	if string(out) != want {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stream protocols, in the order URLs are listed.
+const (
+	StreamWebRTC = "webrtc"
+	StreamHLS    = "hls"
+	StreamRTSP   = "rtsp"
+	StreamRTSPS  = "rtsps"
+	StreamRTMP   = "rtmp"
+	StreamRTMPS  = "rtmps"
+	StreamSRT    = "srt"
+)
+
+// Listener is a MediaMTX server readers connect to. TLS reports that the
+// server only speaks HTTPS, for HLS and WebRTC.
+type Listener struct {
+	Protocol string `json:"protocol"`
+	Enabled  bool   `json:"enabled"`
+	Address  string `json:"address"`
+	TLS      bool   `json:"tls"`
+}
+
+// StreamURL is the address a reader uses to watch a path over one protocol.
+type StreamURL struct {
+	Protocol string `json:"protocol"`
+	URL      string `json:"url"`
+}
+
+// listenerSpec describes the global keys of one MediaMTX server.
+type listenerSpec struct {
+	protocol    string
+	enabledKey  string
+	addressKey  string
+	address     string
+	disabledKey string // pre-v1 releases
+}
+
+var listenerSpecs = []listenerSpec{
+	{StreamWebRTC, "webrtc", "webrtcAddress", ":8889", "webrtcDisable"},
+	{StreamHLS, "hls", "hlsAddress", ":8888", "hlsDisable"},
+	{StreamRTSP, "rtsp", "rtspAddress", ":8554", "rtspDisable"},
+	{StreamRTSPS, "rtsp", "rtspsAddress", ":8322", "rtspDisable"},
+	{StreamRTMP, "rtmp", "rtmpAddress", ":1935", "rtmpDisable"},
+	{StreamRTMPS, "rtmp", "rtmpsAddress", ":1936", "rtmpDisable"},
+	{StreamSRT, "srt", "srtAddress", ":8890", ""},
+}
+
+// LoadListeners reads the reader-facing servers from the global section of
+// a MediaMTX config. Keys missing from the file take MediaMTX defaults.
+func LoadListeners(path string) ([]Listener, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseListeners(b)
+}
+
+func parseListeners(b []byte) ([]Listener, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	mapping := rootMapping(&root)
+	if mapping == nil {
+		return nil, errors.New("invalid yaml root")
+	}
+
+	listeners := make([]Listener, 0, len(listenerSpecs))
+	for _, spec := range listenerSpecs {
+		l := Listener{Protocol: spec.protocol, Enabled: true, Address: spec.address}
+		if v, ok := getString(mapping, spec.enabledKey); ok {
+			l.Enabled = yamlBool(v)
+		}
+		if v, ok := getString(mapping, spec.disabledKey); spec.disabledKey != "" && ok && yamlBool(v) {
+			l.Enabled = false
+		}
+		if v, ok := getString(mapping, spec.addressKey); ok {
+			l.Address = v
+		}
+
+		// RTSP and RTMP move to their TLS listener with encryption
+		// "strict" and run both with "optional"; HLS and WebRTC switch to
+		// HTTPS.
+		encryption, _ := getString(mapping, spec.enabledKey+"Encryption")
+		switch spec.protocol {
+		case StreamRTSP, StreamRTMP:
+			l.Enabled = l.Enabled && encryption != "strict"
+		case StreamRTSPS, StreamRTMPS:
+			l.Enabled = l.Enabled && (encryption == "strict" || encryption == "optional")
+		case StreamHLS, StreamWebRTC:
+			l.TLS = yamlBool(encryption)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// StreamPlayback is the protocol of the playback server, which serves
+// recordings rather than live streams.
+const StreamPlayback = "playback"
+
+// LoadPlayback reads the playback server settings from the global section
+// of a MediaMTX config. It is disabled unless the file enables it.
+func LoadPlayback(path string) (Listener, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Listener{}, err
+	}
+	return parsePlayback(b)
+}
+
+func parsePlayback(b []byte) (Listener, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return Listener{}, err
+	}
+	mapping := rootMapping(&root)
+	if mapping == nil {
+		return Listener{}, errors.New("invalid yaml root")
+	}
+	l := Listener{Protocol: StreamPlayback, Address: ":9996"}
+	if v, ok := getString(mapping, "playback"); ok {
+		l.Enabled = yamlBool(v)
+	}
+	if v, ok := getString(mapping, "playbackAddress"); ok {
+		l.Address = v
+	}
+	if v, ok := getString(mapping, "playbackEncryption"); ok {
+		l.TLS = yamlBool(v)
+	}
+	return l, nil
+}
+
+// LocalURL is the base URL that reaches l from the Pi itself.
+func (l Listener) LocalURL() (string, error) {
+	host, port, err := net.SplitHostPort(l.Address)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return httpScheme(l.TLS) + "://" + net.JoinHostPort(host, port), nil
+}
+
+// yamlBool reads a MediaMTX boolean, which accepts yes/no as well as
+// true/false.
+func yamlBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "yes", "true", "on":
+		return true
+	}
+	return false
+}
+
+// StreamURLs returns the URLs that read path from host over every enabled
+// listener. A listener bound to a specific address keeps that address.
+func StreamURLs(listeners []Listener, host, path string) []StreamURL {
+	var urls []StreamURL
+	for _, l := range listeners {
+		if !l.Enabled {
+			continue
+		}
+		bindHost, port, err := net.SplitHostPort(l.Address)
+		if err != nil {
+			continue
+		}
+		h := host
+		if bindHost != "" && bindHost != "0.0.0.0" && bindHost != "::" {
+			h = bindHost
+		}
+		hostPort := net.JoinHostPort(h, port)
+		escaped := (&url.URL{Path: path}).EscapedPath()
+
+		var u string
+		switch l.Protocol {
+		case StreamWebRTC:
+			u = httpScheme(l.TLS) + "://" + hostPort + "/" + escaped + "/"
+		case StreamHLS:
+			u = httpScheme(l.TLS) + "://" + hostPort + "/" + escaped + "/index.m3u8"
+		case StreamSRT:
+			u = "srt://" + hostPort + "?streamid=read:" + url.QueryEscape(path)
+		default:
+			u = l.Protocol + "://" + hostPort + "/" + escaped
+		}
+		urls = append(urls, StreamURL{Protocol: l.Protocol, URL: u})
+	}
+	return urls
+}
+
+func httpScheme(tls bool) string {
+	if tls {
+		return "https"
+	}
+	return "http"
+}
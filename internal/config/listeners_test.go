@@ -0,0 +1,68 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStreamURLs(t *testing.T) {
+	input := `rtspAddress: :8555
+rtmp: no
+hls: yes
+hlsEncryption: yes
+webrtcAddress: 127.0.0.1:8889
+srt: false
+paths:
+  cam:
+    source: rpiCamera
+`
+	listeners, err := parseListeners([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := StreamURLs(listeners, "192.168.1.20", "cam")
+	want := []StreamURL{
+		{Protocol: StreamWebRTC, URL: "http://127.0.0.1:8889/cam/"},
+		{Protocol: StreamHLS, URL: "https://192.168.1.20:8888/cam/index.m3u8"},
+		{Protocol: StreamRTSP, URL: "rtsp://192.168.1.20:8555/cam"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("urls = %+v, want %+v", got, want)
+	}
+}
+
+func TestStreamURLsDefaults(t *testing.T) {
+	listeners, err := parseListeners([]byte("rtspEncryption: optional\npaths: {}\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := StreamURLs(listeners, "zero2", "front door")
+	want := []StreamURL{
+		{Protocol: StreamWebRTC, URL: "http://zero2:8889/front%20door/"},
+		{Protocol: StreamHLS, URL: "http://zero2:8888/front%20door/index.m3u8"},
+		{Protocol: StreamRTSP, URL: "rtsp://zero2:8554/front%20door"},
+		{Protocol: StreamRTSPS, URL: "rtsps://zero2:8322/front%20door"},
+		{Protocol: StreamRTMP, URL: "rtmp://zero2:1935/front%20door"},
+		{Protocol: StreamSRT, URL: "srt://zero2:8890?streamid=read:front+door"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("urls = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePlayback(t *testing.T) {
+	l, err := parsePlayback([]byte("paths: {}\n"))
+	if err != nil || l.Enabled || l.Address != ":9996" {
+		t.Fatalf("unexpected default playback: %+v, %v", l, err)
+	}
+
+	l, err = parsePlayback([]byte("playback: yes\nplaybackAddress: :9000\nplaybackEncryption: yes\n"))
+	if err != nil || !l.Enabled {
+		t.Fatalf("unexpected playback: %+v, %v", l, err)
+	}
+	if u, err := l.LocalURL(); err != nil || u != "https://127.0.0.1:9000" {
+		t.Fatalf("unexpected URL %q, %v", u, err)
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// FieldDrift is a live parameter whose running value, as MediaMTX reports
+// it, differs from the value in the config file.
+type FieldDrift struct {
+	Key     string `json:"key"`
+	File    string `json:"file"`
+	Running string `json:"running"`
+}
+
+// JSONValue converts a canonical value to the JSON type the Control API
+// expects. An empty value gives the default, since the API cannot unset a
+// key.
+func (p Param) JSONValue(value string) (any, error) {
+	if value == "" {
+		value = p.Default
+	}
+	value, err := p.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch p.Type {
+	case ParamBool:
+		return value == "true", nil
+	case ParamInt:
+		if value == "" {
+			return 0, nil
+		}
+		return strconv.Atoi(value)
+	case ParamFloat:
+		if value == "" {
+			return 0.0, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+	return value, nil
+}
+
+// parseJSONValue turns a value decoded from the Control API into its
+// canonical form.
+func (p Param) parseJSONValue(v any) (string, bool) {
+	var raw string
+	switch v := v.(type) {
+	case string:
+		raw = v
+	case bool:
+		raw = strconv.FormatBool(v)
+	case json.Number:
+		raw = v.String()
+	case float64:
+		raw = strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		raw = strconv.Itoa(v)
+	default:
+		return "", false
+	}
+	value, err := p.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// LiveDrift lists the live parameters whose value in running, a path
+// config read from the Control API, differs from file. Keys the API does
+// not report are skipped, as are unset keys without a schema default.
+func LiveDrift(file CameraConfig, running map[string]any) []FieldDrift {
+	var drift []FieldDrift
+	for _, p := range CameraSchema {
+		if !p.Live {
+			continue
+		}
+		raw, ok := running[p.Key]
+		if !ok {
+			continue
+		}
+		have, ok := p.parseJSONValue(raw)
+		if !ok {
+			continue
+		}
+		fileValue, set := file.Value(p.Key)
+		if !set && p.Default == "" {
+			continue
+		}
+		if !set {
+			fileValue = p.Default
+		}
+		if have != fileValue {
+			drift = append(drift, FieldDrift{Key: p.Key, File: fileValue, Running: have})
+		}
+	}
+	return drift
+}
+
+// WithRunning returns file with the running value of every drifted
+// parameter, which is the config MediaMTX is actually using.
+func WithRunning(file CameraConfig, drift []FieldDrift) CameraConfig {
+	cfg := file
+	cfg.Params = make(map[string]string, len(file.Params))
+	for k, v := range file.Params {
+		cfg.Params[k] = v
+	}
+	for _, d := range drift {
+		_ = cfg.SetValue(d.Key, d.Running)
+	}
+	return cfg
+}
+
+// StaticChangeError is returned by LivePatch when cfg changes parameters
+// MediaMTX can only apply by restarting the path.
+type StaticChangeError struct {
+	Keys []string
+}
+
+func (e *StaticChangeError) Error() string {
+	return fmt.Sprintf("%d parameters need a restart of the path", len(e.Keys))
+}
+
+// LivePatch returns the Control API patch that makes MediaMTX run cfg.
+// file is the config on disk and running the path config the API reports.
+// It fails with a StaticChangeError when cfg differs from file in a
+// parameter that is not live.
+func LivePatch(cfg, file CameraConfig, running map[string]any) (map[string]any, error) {
+	var static []string
+	patch := map[string]any{}
+	for _, p := range CameraSchema {
+		want, ok := cfg.saveValue(p.Key)
+		if !ok {
+			continue
+		}
+		if !p.Live {
+			if have, _ := file.Value(p.Key); have != want {
+				static = append(static, p.Key)
+			}
+			continue
+		}
+		if want == "" {
+			want = p.Default
+		}
+		if raw, ok := running[p.Key]; ok {
+			if have, ok := p.parseJSONValue(raw); ok && have == want {
+				continue
+			}
+		}
+		v, err := p.JSONValue(want)
+		if err != nil {
+			return nil, err
+		}
+		patch[p.Key] = v
+	}
+	if len(static) > 0 {
+		return nil, &StaticChangeError{Keys: static}
+	}
+	return patch, nil
+}
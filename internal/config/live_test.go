@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLiveDrift(t *testing.T) {
+	file := CameraConfig{AWB: "daylight", Params: map[string]string{"rpiCameraBrightness": "0.2"}}
+	running := map[string]any{
+		"rpiCameraAWB":        "auto",
+		"rpiCameraBrightness": json.Number("0.2"),
+		"rpiCameraContrast":   json.Number("1.5"),
+		"rpiCameraVFlip":      true,
+		"rpiCameraFPS":        json.Number("30"),
+	}
+
+	got := LiveDrift(file, running)
+	want := []FieldDrift{
+		{Key: "rpiCameraAWB", File: "daylight", Running: "auto"},
+		{Key: "rpiCameraContrast", File: "1", Running: "1.5"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("drift = %+v, want %+v", got, want)
+	}
+
+	cfg := WithRunning(file, got)
+	if cfg.AWB != "auto" || cfg.Params["rpiCameraContrast"] != "1.5" {
+		t.Fatalf("unexpected running config: %+v", cfg)
+	}
+	if _, ok := file.Params["rpiCameraContrast"]; ok {
+		t.Fatalf("WithRunning modified the file config")
+	}
+}
+
+func TestLivePatch(t *testing.T) {
+	file := CameraConfig{Width: 1920, Height: 1080, AWB: "daylight", Params: map[string]string{}}
+	running := map[string]any{
+		"rpiCameraAWB":        "daylight",
+		"rpiCameraBrightness": json.Number("0"),
+		"rpiCameraFPS":        json.Number("30"),
+	}
+
+	cfg := WithRunning(file, nil)
+	cfg.AWB = "cloudy"
+	cfg.Params["rpiCameraBrightness"] = "0.3"
+	cfg.Params["rpiCameraFPS"] = ""
+	cfg.Params["rpiCameraBitrate"] = "2000000"
+
+	patch, err := LivePatch(cfg, file, running)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		"rpiCameraAWB":        "cloudy",
+		"rpiCameraBrightness": 0.3,
+		"rpiCameraBitrate":    2000000,
+	}
+	if !reflect.DeepEqual(patch, want) {
+		t.Fatalf("patch = %#v, want %#v", patch, want)
+	}
+
+	cfg.VFlip = true
+	cfg.Width, cfg.Height = 1280, 720
+	_, err = LivePatch(cfg, file, running)
+	var static *StaticChangeError
+	if !errors.As(err, &static) {
+		t.Fatalf("expected StaticChangeError, got %v", err)
+	}
+	if !reflect.DeepEqual(static.Keys, []string{"rpiCameraWidth", "rpiCameraHeight", "rpiCameraVFlip"}) {
+		t.Fatalf("unexpected static keys: %v", static.Keys)
+	}
+}
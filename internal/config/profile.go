@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const maxProfileName = 64
+
+var (
+	// ErrProfileNotFound is returned for a profile name that is not stored.
+	ErrProfileNotFound = errors.New("profile not found")
+	// ErrProfileExists is returned when renaming onto a stored profile.
+	ErrProfileExists = errors.New("profile already exists")
+	// ErrInvalidProfileName is returned for an empty, overlong or
+	// unprintable profile name.
+	ErrInvalidProfileName = errors.New("invalid profile name")
+)
+
+// Profile is a named set of camera parameter values, keyed by YAML key.
+type Profile struct {
+	Name   string            `yaml:"name" json:"name"`
+	Values map[string]string `yaml:"values" json:"values"`
+}
+
+type profileFile struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// profileParam reports whether profiles hold key. rpiCameraCamID selects
+// the sensor rather than how it looks, so applying a profile to another
+// path must not change it.
+func profileParam(key string) bool {
+	return key != "rpiCameraCamID"
+}
+
+// NewProfile captures the set values of cfg as profile name.
+func NewProfile(name string, cfg CameraConfig) Profile {
+	p := Profile{Name: name, Values: map[string]string{}}
+	for _, param := range CameraSchema {
+		if !profileParam(param.Key) {
+			continue
+		}
+		if value, ok := cfg.Value(param.Key); ok {
+			p.Values[param.Key] = value
+		}
+	}
+	return p
+}
+
+// CameraConfig returns the config that applies the profile. Parameters the
+// profile does not set are reset: removed from the path, or off for the
+// flips. Width, height and white balance are left as they are, as in the
+// camera form.
+func (p Profile) CameraConfig() (CameraConfig, error) {
+	cfg := CameraConfig{Params: map[string]string{}}
+	for _, param := range CameraSchema {
+		if !profileParam(param.Key) {
+			continue
+		}
+		if err := cfg.SetValue(param.Key, p.Values[param.Key]); err != nil {
+			return CameraConfig{}, fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+	}
+	cfg.LensPositionSet = true
+	return cfg, nil
+}
+
+// Matches reports whether applying the profile to cfg would change
+// nothing.
+func (p Profile) Matches(cfg CameraConfig) bool {
+	want, err := p.CameraConfig()
+	if err != nil {
+		return false
+	}
+	for _, param := range CameraSchema {
+		value, ok := want.saveValue(param.Key)
+		if !ok || !profileParam(param.Key) {
+			continue
+		}
+		if current, _ := cfg.Value(param.Key); current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ProfilesPath returns the file holding the profiles of the config file at
+// path, mediamtx.profiles.yml for mediamtx.yml.
+func ProfilesPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".profiles" + ext
+}
+
+// ListProfiles returns the profiles stored beside the config file at path,
+// sorted by name. A missing profiles file means no profiles.
+func ListProfiles(path string) ([]Profile, error) {
+	b, err := os.ReadFile(ProfilesPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var file profileFile
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("parse profiles: %w", err)
+	}
+	sort.Slice(file.Profiles, func(i, j int) bool {
+		return strings.ToLower(file.Profiles[i].Name) < strings.ToLower(file.Profiles[j].Name)
+	})
+	return file.Profiles, nil
+}
+
+// LoadProfile returns the named profile.
+func LoadProfile(path, name string) (Profile, error) {
+	profiles, err := ListProfiles(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	if i := profileIndex(profiles, name); i >= 0 {
+		return profiles[i], nil
+	}
+	return Profile{}, ErrProfileNotFound
+}
+
+// SaveProfile stores profile, replacing a stored profile of the same name.
+func SaveProfile(path string, profile Profile) error {
+	name, err := cleanProfileName(profile.Name)
+	if err != nil {
+		return err
+	}
+	profile.Name = name
+	for key, value := range profile.Values {
+		p, ok := LookupParam(key)
+		if !ok || !profileParam(key) {
+			return fmt.Errorf("profile %q: unsupported parameter %s", name, key)
+		}
+		if _, err := p.Parse(value); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+
+	profiles, err := ListProfiles(path)
+	if err != nil {
+		return err
+	}
+	if i := profileIndex(profiles, name); i >= 0 {
+		profiles[i] = profile
+	} else {
+		profiles = append(profiles, profile)
+	}
+	return writeProfiles(path, profiles)
+}
+
+// RenameProfile renames a stored profile.
+func RenameProfile(path, name, newName string) error {
+	newName, err := cleanProfileName(newName)
+	if err != nil {
+		return err
+	}
+	profiles, err := ListProfiles(path)
+	if err != nil {
+		return err
+	}
+	i := profileIndex(profiles, name)
+	if i < 0 {
+		return ErrProfileNotFound
+	}
+	if j := profileIndex(profiles, newName); j >= 0 && j != i {
+		return ErrProfileExists
+	}
+	profiles[i].Name = newName
+	return writeProfiles(path, profiles)
+}
+
+// DeleteProfile removes a stored profile.
+func DeleteProfile(path, name string) error {
+	profiles, err := ListProfiles(path)
+	if err != nil {
+		return err
+	}
+	i := profileIndex(profiles, name)
+	if i < 0 {
+		return ErrProfileNotFound
+	}
+	return writeProfiles(path, append(profiles[:i], profiles[i+1:]...))
+}
+
+func profileIndex(profiles []Profile, name string) int {
+	for i, p := range profiles {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func cleanProfileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxProfileName {
+		return "", ErrInvalidProfileName
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return "", ErrInvalidProfileName
+		}
+	}
+	return name, nil
+}
+
+// writeProfiles atomically replaces the profiles file. Profiles are not
+// part of the MediaMTX config, so no backup is kept.
+func writeProfiles(path string, profiles []Profile) error {
+	out, err := yaml.Marshal(profileFile{Profiles: profiles})
+	if err != nil {
+		return err
+	}
+	profilesPath := ProfilesPath(path)
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(profilesPath); err == nil {
+		mode = info.Mode()
+	}
+	tmp, err := writeTemp(profilesPath, out, mode)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, profilesPath); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraCamID: 1
+    rpiCameraWidth: 1920
+    rpiCameraHeight: 1080
+    rpiCameraAWB: daylight
+    rpiCameraAfMode: continuous
+    rpiCameraSharpness: 1.5
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	profiles, err := ListProfiles(path)
+	if err != nil || profiles != nil {
+		t.Fatalf("expected no profiles, got %+v %v", profiles, err)
+	}
+
+	cfg, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	wide := NewProfile(" daylight wide ", cfg)
+	if _, ok := wide.Values["rpiCameraCamID"]; ok {
+		t.Fatalf("expected camera id to stay out of profiles: %+v", wide.Values)
+	}
+	if err := SaveProfile(path, wide); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+	closeUp := Profile{Name: "indoor close-up", Values: map[string]string{
+		"rpiCameraAWB":          "indoor",
+		"rpiCameraAfMode":       "manual",
+		"rpiCameraLensPosition": "8",
+	}}
+	if err := SaveProfile(path, closeUp); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mediamtx.profiles.yml")); err != nil {
+		t.Fatalf("expected profiles beside the config: %v", err)
+	}
+
+	profiles, err = ListProfiles(path)
+	if err != nil || len(profiles) != 2 || profiles[0].Name != "daylight wide" || profiles[1].Name != "indoor close-up" {
+		t.Fatalf("unexpected profiles: %+v %v", profiles, err)
+	}
+
+	if err := RenameProfile(path, "indoor close-up", "daylight wide"); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+	if err := RenameProfile(path, "indoor close-up", "  "); !errors.Is(err, ErrInvalidProfileName) {
+		t.Fatalf("expected ErrInvalidProfileName, got %v", err)
+	}
+	if err := RenameProfile(path, "indoor close-up", "close-up"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	profile, err := LoadProfile(path, "close-up")
+	if err != nil {
+		t.Fatalf("load profile: %v", err)
+	}
+	apply, err := profile.CameraConfig()
+	if err != nil {
+		t.Fatalf("profile config: %v", err)
+	}
+	if profile.Matches(cfg) {
+		t.Fatalf("expected profile not to match the camera yet")
+	}
+	if _, err := SaveCameraConfig(path, "cam", apply); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	cfg, err = LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.AWB != "indoor" || cfg.AfMode != "manual" || cfg.LensPosition == nil || *cfg.LensPosition != 8 {
+		t.Fatalf("profile not applied: %+v", cfg)
+	}
+	if cfg.Width != 1920 || cfg.Params["rpiCameraCamID"] != "1" {
+		t.Fatalf("expected resolution and camera id kept: %+v", cfg)
+	}
+	if !profile.Matches(cfg) {
+		t.Fatalf("expected applied profile to match")
+	}
+	if _, ok := cfg.Params["rpiCameraSharpness"]; ok {
+		t.Fatalf("expected keys outside the profile removed: %+v", cfg.Params)
+	}
+	if backups, _ := ListBackups(path); len(backups) != 1 {
+		t.Fatalf("expected applying to back up the config, got %+v", backups)
+	}
+
+	if err := DeleteProfile(path, "close-up"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := DeleteProfile(path, "close-up"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if profiles, _ := ListProfiles(path); len(profiles) != 1 {
+		t.Fatalf("unexpected profiles after delete: %+v", profiles)
+	}
+}
+
+func TestSaveProfileRejectsUnknownParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	for _, values := range []map[string]string{
+		{"rpiCameraCamID": "0"},
+		{"rpiCameraNope": "1"},
+		{"rpiCameraBitrate": "fast"},
+	} {
+		if err := SaveProfile(path, Profile{Name: "bad", Values: values}); err == nil {
+			t.Fatalf("expected %v to be refused", values)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MediaMTX recording defaults.
+const (
+	DefaultRecordPath            = "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f"
+	DefaultRecordFormat          = "fmp4"
+	DefaultRecordPartDuration    = "1s"
+	DefaultRecordSegmentDuration = "1h"
+	DefaultRecordDeleteAfter     = "1d"
+)
+
+// RecordSettings is where and how MediaMTX records a path. Durations are
+// kept as written in mediamtx.yml.
+type RecordSettings struct {
+	Record          bool   `json:"record"`
+	Path            string `json:"recordPath"`
+	Format          string `json:"recordFormat"`
+	PartDuration    string `json:"recordPartDuration"`
+	SegmentDuration string `json:"recordSegmentDuration"`
+	DeleteAfter     string `json:"recordDeleteAfter"`
+}
+
+// LoadRecordSettings returns the recording settings that apply to path
+// name: its own keys, then pathDefaults, then the top-level keys of
+// releases before pathDefaults existed, then MediaMTX defaults. An empty
+// name gives the defaults every path inherits.
+func LoadRecordSettings(path, name string) (RecordSettings, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return RecordSettings{}, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return RecordSettings{}, err
+	}
+	mapping := rootMapping(&root)
+	if mapping == nil {
+		return RecordSettings{}, errors.New("invalid yaml root")
+	}
+
+	// Most specific last, so it wins.
+	layers := []*yaml.Node{mapping}
+	if defaults := findMapValue(mapping, "pathDefaults"); defaults != nil && defaults.Kind == yaml.MappingNode {
+		layers = append(layers, defaults)
+	}
+	if name != "" {
+		pathNode, err := findPathNode(&root, name)
+		if err != nil {
+			return RecordSettings{}, err
+		}
+		layers = append(layers, pathNode)
+	}
+
+	settings := RecordSettings{
+		Path:            DefaultRecordPath,
+		Format:          DefaultRecordFormat,
+		PartDuration:    DefaultRecordPartDuration,
+		SegmentDuration: DefaultRecordSegmentDuration,
+		DeleteAfter:     DefaultRecordDeleteAfter,
+	}
+	fields := map[string]*string{
+		"recordPath":            &settings.Path,
+		"recordFormat":          &settings.Format,
+		"recordPartDuration":    &settings.PartDuration,
+		"recordSegmentDuration": &settings.SegmentDuration,
+		"recordDeleteAfter":     &settings.DeleteAfter,
+	}
+	for _, layer := range layers {
+		if v, ok := getString(layer, "record"); ok {
+			settings.Record = yamlBool(v)
+		}
+		for key, field := range fields {
+			if v, ok := getString(layer, key); ok {
+				*field = v
+			}
+		}
+	}
+	return settings, nil
+}
+
+// Value returns the setting of a recording key in its canonical form, for
+// showing what a path inherits when it does not set the key itself.
+func (r RecordSettings) Value(key string) (string, bool) {
+	switch key {
+	case "record":
+		return strconv.FormatBool(r.Record), true
+	case "recordPath":
+		return r.Path, true
+	case "recordFormat":
+		return r.Format, true
+	case "recordPartDuration":
+		return r.PartDuration, true
+	case "recordSegmentDuration":
+		return r.SegmentDuration, true
+	case "recordDeleteAfter":
+		return r.DeleteAfter, true
+	}
+	return "", false
+}
+
+// Extension is the file extension MediaMTX appends to recordPath.
+func (r RecordSettings) Extension() string {
+	if r.Format == "mpegts" {
+		return ".ts"
+	}
+	return ".mp4"
+}
+
+// RecordConfig holds the recording keys path name sets itself; the keys it
+// does not set are inherited, see LoadRecordSettings.
+type RecordConfig struct {
+	// Params holds the canonical values of RecordSchema keys, keyed by YAML
+	// key. On save, an empty value removes the key and keys missing from
+	// the map are left untouched.
+	Params map[string]string `json:"params"`
+	// Revision identifies the path section the config was loaded from, as
+	// in CameraConfig.
+	Revision string `json:"revision"`
+}
+
+// Value returns the canonical value of a recording key and whether the path
+// sets it.
+func (r RecordConfig) Value(key string) (string, bool) {
+	v, ok := r.Params[key]
+	return v, ok && v != ""
+}
+
+// SetValue stores the canonical value of a recording key. An empty value
+// clears it.
+func (r *RecordConfig) SetValue(key, value string) error {
+	p, ok := LookupRecordParam(key)
+	if !ok {
+		return fmt.Errorf("unknown recording key %s", key)
+	}
+	value, err := p.Parse(value)
+	if err != nil {
+		return err
+	}
+	if r.Params == nil {
+		r.Params = map[string]string{}
+	}
+	r.Params[key] = value
+	return nil
+}
+
+func (r RecordConfig) schema() []Param {
+	return RecordSchema
+}
+
+func (r RecordConfig) saveValue(key string) (string, bool) {
+	v, ok := r.Params[key]
+	return v, ok
+}
+
+// LoadRecordConfig returns the recording keys path name sets in the config
+// file at path.
+func LoadRecordConfig(path, name string) (RecordConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return RecordConfig{}, err
+	}
+	return parseRecordConfig(b, name)
+}
+
+func parseRecordConfig(b []byte, name string) (RecordConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return RecordConfig{}, err
+	}
+	pathNode, err := findPathNode(&root, name)
+	if err != nil {
+		return RecordConfig{}, err
+	}
+
+	config := RecordConfig{Params: map[string]string{}}
+	for _, p := range RecordSchema {
+		raw, ok := getString(pathNode, p.Key)
+		if !ok {
+			continue
+		}
+		if err := config.SetValue(p.Key, raw); err != nil {
+			return RecordConfig{}, err
+		}
+	}
+	if config.Revision, err = pathRevision(&root, name, pathNode); err != nil {
+		return RecordConfig{}, err
+	}
+	return config, nil
+}
+
+// SaveRecordConfig writes the recording keys of config to path name, with
+// the same backup and revision check as SaveCameraConfig, and returns the
+// name of the backup of the replaced file.
+func SaveRecordConfig(path, name string, config RecordConfig) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if config.Revision != "" {
+		current, err := parseRecordConfig(b, name)
+		if err != nil {
+			return "", err
+		}
+		if current.Revision != config.Revision {
+			return "", newConflictError(current.Revision, current, config)
+		}
+	}
+	return writePathKeys(path, b, name, config)
+}
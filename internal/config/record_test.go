@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRecordSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `pathDefaults:
+  record: yes
+  recordPath: /recordings/%path/%Y-%m-%d_%H-%M-%S-%f
+  recordDeleteAfter: 7d
+paths:
+  cam:
+    source: rpiCamera
+    recordFormat: mpegts
+  garden:
+    source: rpiCamera
+    record: no
+    recordSegmentDuration: 10m
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	defaults, err := LoadRecordSettings(path, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !defaults.Record || defaults.Path != "/recordings/%path/%Y-%m-%d_%H-%M-%S-%f" || defaults.Extension() != ".mp4" {
+		t.Fatalf("unexpected defaults: %+v", defaults)
+	}
+
+	cam, err := LoadRecordSettings(path, "cam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cam.Record || cam.Path != defaults.Path || cam.Extension() != ".ts" {
+		t.Fatalf("unexpected cam settings: %+v", cam)
+	}
+
+	garden, err := LoadRecordSettings(path, "garden")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if garden.Record || garden.SegmentDuration != "10m" || garden.DeleteAfter != "7d" || garden.PartDuration != DefaultRecordPartDuration {
+		t.Fatalf("unexpected garden settings: %+v", garden)
+	}
+	if v, ok := garden.Value("recordSegmentDuration"); !ok || v != "10m" {
+		t.Fatalf("unexpected segment duration value %q", v)
+	}
+}
+
+func TestLoadAndSaveRecordConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraVFlip: true
+    record: yes
+    recordDeleteAfter: 7d
+    #recordSegmentDuration: 1h
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cam, err := LoadCameraConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load camera config: %v", err)
+	}
+	if _, ok := cam.Value("record"); ok {
+		t.Fatal("recording key loaded as a camera parameter")
+	}
+
+	cfg, err := LoadRecordConfig(path, "cam")
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if v, _ := cfg.Value("record"); v != "true" {
+		t.Fatalf("expected record on, got %q", v)
+	}
+	if v, _ := cfg.Value("recordDeleteAfter"); v != "7d" {
+		t.Fatalf("unexpected delete after %q", v)
+	}
+	if cfg.Revision != cam.Revision {
+		t.Fatalf("expected the revision of the path, got %q and %q", cfg.Revision, cam.Revision)
+	}
+	if err := cfg.SetValue("recordSegmentDuration", "1 hour"); err == nil {
+		t.Fatal("expected invalid duration")
+	}
+	if err := cfg.SetValue("rpiCameraVFlip", "false"); err == nil {
+		t.Fatal("expected camera parameter rejected")
+	}
+
+	for key, value := range map[string]string{"record": "false", "recordSegmentDuration": "10m", "recordDeleteAfter": ""} {
+		if err := cfg.SetValue(key, value); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+	}
+	if _, err := SaveRecordConfig(path, "cam", cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	want := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraVFlip: true
+    record: false
+    recordSegmentDuration: 10m
+`
+	if string(out) != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if backups, err := ListBackups(path); err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup, got %d: %v", len(backups), err)
+	}
+
+	var conflict *ConflictError
+	if _, err := SaveRecordConfig(path, "cam", cfg); !errors.As(err, &conflict) {
+		t.Fatalf("expected conflict with the old revision, got %v", err)
+	}
+	if len(conflict.Fields) != 0 {
+		t.Fatalf("expected no differing keys, got %+v", conflict.Fields)
+	}
+}
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamType is the value type of a camera parameter in mediamtx.yml.
+type ParamType string
+
+const (
+	ParamBool   ParamType = "bool"
+	ParamInt    ParamType = "int"
+	ParamFloat  ParamType = "float"
+	ParamString ParamType = "string"
+	ParamEnum   ParamType = "enum"
+	// ParamDuration is a Go duration such as "1h30m". MediaMTX also accepts
+	// a leading number of days, as in "7d" or "1d12h". Min and Max bound it
+	// in seconds.
+	ParamDuration ParamType = "duration"
+)
+
+// Option is one allowed value of an enum parameter.
+type Option struct {
+	Value string
+	Label string
+}
+
+// Param describes one editable key of a MediaMTX path: an rpiCamera*
+// parameter or a recording setting.
+type Param struct {
+	Key     string
+	Label   string
+	Group   string
+	Type    ParamType
+	Min     *float64
+	Max     *float64
+	Options []Option
+	// Default is the value MediaMTX uses when the key is absent.
+	Default string
+	Help    string
+	// Since is the first MediaMTX release that accepts the key.
+	Since string
+	// Field is the JSON name of the dedicated CameraConfig field holding the
+	// value. Parameters without one are kept in CameraConfig.Params.
+	Field string
+	// Live reports that MediaMTX applies a change to a running camera
+	// without restarting the path, so it can be patched through the Control
+	// API without dropping viewers.
+	Live bool
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// CameraSchema lists the rpiCamera* parameters the UI can edit, in form
+// order. rpiCameraAWBGains is a YAML list rather than a scalar and is left to
+// hand editing.
+var CameraSchema = []Param{
+	{Key: "rpiCameraCamID", Label: "Camera ID", Group: "Sensor", Type: ParamInt, Min: bound(0), Max: bound(7), Default: "0", Help: "Index of the camera when several are attached.", Since: "v0.21.0"},
+	{Key: "rpiCameraWidth", Label: "Width", Group: "Sensor", Type: ParamInt, Min: bound(1), Default: "1920", Since: "v0.21.0", Field: "width"},
+	{Key: "rpiCameraHeight", Label: "Height", Group: "Sensor", Type: ParamInt, Min: bound(1), Default: "1080", Since: "v0.21.0", Field: "height"},
+	{Key: "rpiCameraMode", Label: "Sensor mode", Group: "Sensor", Type: ParamEnum, Options: []Option{
+		{Value: "2304:1296:10:P", Label: "Full sensor, wide FOV (2304 × 1296)"},
+		{Value: "1536:864:10:P", Label: "Cropped, narrow FOV (1536 × 864)"},
+	}, Since: "v0.21.0", Field: "mode"},
+	{Key: "rpiCameraVFlip", Label: "Vertical flip", Group: "Sensor", Type: ParamBool, Default: "false", Since: "v0.21.0", Field: "vFlip"},
+	{Key: "rpiCameraHFlip", Label: "Horizontal flip", Group: "Sensor", Type: ParamBool, Default: "false", Since: "v0.21.0", Field: "hFlip"},
+	{Key: "rpiCameraROI", Label: "Region of interest", Group: "Sensor", Type: ParamString, Help: "x,y,width,height normalised between 0 and 1.", Since: "v0.21.0"},
+	{Key: "rpiCameraTuningFile", Label: "Tuning file", Group: "Sensor", Type: ParamString, Since: "v0.21.0"},
+
+	{Key: "rpiCameraAWB", Label: "Auto white balance", Group: "Image", Type: ParamEnum, Options: []Option{
+		{Value: "auto", Label: "Auto"},
+		{Value: "incandescent", Label: "Incandescent"},
+		{Value: "tungsten", Label: "Tungsten"},
+		{Value: "fluorescent", Label: "Fluorescent"},
+		{Value: "indoor", Label: "Indoor"},
+		{Value: "daylight", Label: "Daylight"},
+		{Value: "cloudy", Label: "Cloudy"},
+		{Value: "custom", Label: "Custom"},
+	}, Default: "auto", Since: "v0.21.0", Field: "awb", Live: true},
+	{Key: "rpiCameraBrightness", Label: "Brightness", Group: "Image", Type: ParamFloat, Min: bound(-1), Max: bound(1), Default: "0", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraContrast", Label: "Contrast", Group: "Image", Type: ParamFloat, Min: bound(0), Max: bound(16), Default: "1", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraSaturation", Label: "Saturation", Group: "Image", Type: ParamFloat, Min: bound(0), Max: bound(16), Default: "1", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraSharpness", Label: "Sharpness", Group: "Image", Type: ParamFloat, Min: bound(0), Max: bound(16), Default: "1", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraDenoise", Label: "Denoise", Group: "Image", Type: ParamEnum, Options: []Option{
+		{Value: "off", Label: "Off"},
+		{Value: "cdn_off", Label: "CDN off"},
+		{Value: "cdn_fast", Label: "CDN fast"},
+		{Value: "cdn_hq", Label: "CDN high quality"},
+	}, Default: "off", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraHDR", Label: "HDR", Group: "Image", Type: ParamBool, Default: "false", Help: "Camera Module 3 only.", Since: "v0.22.0"},
+
+	{Key: "rpiCameraExposure", Label: "Exposure mode", Group: "Exposure", Type: ParamEnum, Options: []Option{
+		{Value: "normal", Label: "Normal"},
+		{Value: "short", Label: "Short"},
+		{Value: "long", Label: "Long"},
+		{Value: "custom", Label: "Custom"},
+	}, Default: "normal", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraMetering", Label: "Metering", Group: "Exposure", Type: ParamEnum, Options: []Option{
+		{Value: "centre", Label: "Centre-weighted"},
+		{Value: "spot", Label: "Spot"},
+		{Value: "matrix", Label: "Matrix"},
+		{Value: "custom", Label: "Custom"},
+	}, Default: "centre", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraEV", Label: "EV compensation", Group: "Exposure", Type: ParamFloat, Min: bound(-10), Max: bound(10), Default: "0", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraShutter", Label: "Shutter (µs)", Group: "Exposure", Type: ParamInt, Min: bound(0), Default: "0", Help: "0 lets the AGC choose.", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraGain", Label: "Gain", Group: "Exposure", Type: ParamFloat, Min: bound(0), Default: "0", Help: "0 lets the AGC choose.", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraFlickerPeriod", Label: "Flicker period (µs)", Group: "Exposure", Type: ParamInt, Min: bound(0), Default: "0", Help: "10000 for 50 Hz mains, 8333 for 60 Hz.", Since: "v1.1.0"},
+
+	{Key: "rpiCameraAfMode", Label: "Focus mode", Group: "Focus", Type: ParamEnum, Options: []Option{
+		{Value: "auto", Label: "Auto"},
+		{Value: "manual", Label: "Manual"},
+		{Value: "continuous", Label: "Continuous"},
+	}, Default: "continuous", Since: "v0.22.0", Field: "afMode"},
+	{Key: "rpiCameraAfRange", Label: "Focus range", Group: "Focus", Type: ParamEnum, Options: []Option{
+		{Value: "normal", Label: "Normal"},
+		{Value: "macro", Label: "Macro"},
+		{Value: "full", Label: "Full"},
+	}, Default: "normal", Since: "v0.22.0"},
+	{Key: "rpiCameraAfSpeed", Label: "Focus speed", Group: "Focus", Type: ParamEnum, Options: []Option{
+		{Value: "normal", Label: "Normal"},
+		{Value: "fast", Label: "Fast"},
+	}, Default: "normal", Since: "v0.22.0"},
+	{Key: "rpiCameraLensPosition", Label: "Lens position", Group: "Focus", Type: ParamFloat, Min: bound(0), Since: "v0.22.0", Field: "lensPosition"},
+	{Key: "rpiCameraAfWindow", Label: "Focus window", Group: "Focus", Type: ParamString, Help: "x,y,width,height normalised between 0 and 1.", Since: "v0.22.0"},
+
+	{Key: "rpiCameraFPS", Label: "Frame rate", Group: "Encoding", Type: ParamFloat, Min: bound(0.1), Max: bound(120), Default: "30", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraCodec", Label: "Codec", Group: "Encoding", Type: ParamEnum, Options: []Option{
+		{Value: "auto", Label: "Auto"},
+		{Value: "hardwareH264", Label: "Hardware H264"},
+		{Value: "softwareH264", Label: "Software H264"},
+	}, Default: "auto", Since: "v1.7.0"},
+	{Key: "rpiCameraBitrate", Label: "Bitrate (bit/s)", Group: "Encoding", Type: ParamInt, Min: bound(100000), Max: bound(25000000), Default: "1000000", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraIDRPeriod", Label: "IDR period (frames)", Group: "Encoding", Type: ParamInt, Min: bound(1), Default: "60", Since: "v0.21.0", Live: true},
+	{Key: "rpiCameraProfile", Label: "H264 profile", Group: "Encoding", Type: ParamEnum, Options: []Option{
+		{Value: "baseline", Label: "Baseline"},
+		{Value: "main", Label: "Main"},
+		{Value: "high", Label: "High"},
+	}, Default: "main", Since: "v0.21.0"},
+	{Key: "rpiCameraLevel", Label: "H264 level", Group: "Encoding", Type: ParamEnum, Options: []Option{
+		{Value: "4.0", Label: "4.0"},
+		{Value: "4.1", Label: "4.1"},
+		{Value: "4.2", Label: "4.2"},
+	}, Default: "4.1", Since: "v0.21.0"},
+
+	{Key: "rpiCameraTextOverlayEnable", Label: "Text overlay", Group: "Overlay", Type: ParamBool, Default: "false", Since: "v0.23.0"},
+	{Key: "rpiCameraTextOverlay", Label: "Overlay text", Group: "Overlay", Type: ParamString, Default: "%Y-%m-%d %H:%M:%S - MediaMTX", Help: "strftime() format.", Since: "v0.23.0"},
+}
+
+// RecordSchema lists the recording keys of a path, which the camera card
+// edits in a form of their own and profiles leave alone.
+var RecordSchema = []Param{
+	{Key: "record", Label: "Record", Type: ParamBool, Default: "false", Since: "v1.0.0"},
+	{Key: "recordPath", Label: "Recording path", Type: ParamString, Default: DefaultRecordPath, Help: "Must contain %path and a timestamp; the extension is added by MediaMTX.", Since: "v1.0.0"},
+	{Key: "recordFormat", Label: "Recording format", Type: ParamEnum, Options: []Option{
+		{Value: "fmp4", Label: "Fragmented MP4"},
+		{Value: "mpegts", Label: "MPEG-TS"},
+	}, Default: DefaultRecordFormat, Since: "v1.0.0"},
+	{Key: "recordPartDuration", Label: "Part duration", Type: ParamDuration, Min: bound(0.001), Default: DefaultRecordPartDuration, Help: "How often data is flushed to disk.", Since: "v1.0.0"},
+	{Key: "recordSegmentDuration", Label: "Segment duration", Type: ParamDuration, Min: bound(1), Default: DefaultRecordSegmentDuration, Help: "Length of each recording file, such as 10m or 1h.", Since: "v1.0.0"},
+	{Key: "recordDeleteAfter", Label: "Delete after", Type: ParamDuration, Min: bound(0), Default: DefaultRecordDeleteAfter, Help: "Age at which MediaMTX deletes segments, such as 7d; 0s keeps them.", Since: "v1.0.0"},
+}
+
+// LookupParam returns the CameraSchema entry for key.
+func LookupParam(key string) (Param, bool) {
+	return findParam(CameraSchema, key)
+}
+
+// LookupRecordParam returns the RecordSchema entry for key.
+func LookupRecordParam(key string) (Param, bool) {
+	return findParam(RecordSchema, key)
+}
+
+func findParam(schema []Param, key string) (Param, bool) {
+	for _, p := range schema {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// ValidateParam checks raw against the schema entry for key and returns the
+// canonical value. An empty value is valid and means the key is unset.
+func ValidateParam(key, raw string) (string, error) {
+	p, ok := LookupParam(key)
+	if !ok {
+		return "", fmt.Errorf("unknown parameter %s", key)
+	}
+	return p.Validate(raw)
+}
+
+// Parse checks raw against the parameter type and returns its canonical
+// form. It does not check ranges or enum values, so any value MediaMTX
+// would load can be read back.
+func (p Param) Parse(raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	switch p.Type {
+	case ParamBool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			// YAML 1.1 spellings, which MediaMTX also loads.
+			switch strings.ToLower(value) {
+			case "yes", "on":
+				v = true
+			case "no", "off":
+			default:
+				return "", fmt.Errorf("invalid bool for %s", p.Key)
+			}
+		}
+		return strconv.FormatBool(v), nil
+	case ParamInt:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid int for %s", p.Key)
+		}
+		return strconv.Itoa(v), nil
+	case ParamFloat:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid float for %s", p.Key)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case ParamDuration:
+		// Kept as written, since "1h" reads better than "1h0m0s".
+		if _, err := ParseDuration(value); err != nil {
+			return "", fmt.Errorf("invalid duration for %s", p.Key)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// Validate parses raw and checks it against the parameter range and allowed
+// values.
+func (p Param) Validate(raw string) (string, error) {
+	value, err := p.Parse(raw)
+	if err != nil || value == "" {
+		return value, err
+	}
+	switch p.Type {
+	case ParamInt, ParamFloat:
+		v, _ := strconv.ParseFloat(value, 64)
+		if p.Min != nil && v < *p.Min {
+			return "", fmt.Errorf("%s must be at least %s", p.Key, formatBound(*p.Min))
+		}
+		if p.Max != nil && v > *p.Max {
+			return "", fmt.Errorf("%s must be at most %s", p.Key, formatBound(*p.Max))
+		}
+	case ParamDuration:
+		d, _ := ParseDuration(value)
+		if p.Min != nil && d.Seconds() < *p.Min {
+			return "", fmt.Errorf("%s must be at least %s", p.Key, secondsDuration(*p.Min))
+		}
+		if p.Max != nil && d.Seconds() > *p.Max {
+			return "", fmt.Errorf("%s must be at most %s", p.Key, secondsDuration(*p.Max))
+		}
+	case ParamEnum:
+		for _, o := range p.Options {
+			if o.Value == value {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("unsupported value %q for %s", value, p.Key)
+	}
+	return value, nil
+}
+
+// SupportedBy reports whether a MediaMTX release accepts the key. Unknown or
+// unparseable versions are assumed to support everything.
+func (p Param) SupportedBy(version string) bool {
+	running, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	since, ok := parseVersion(p.Since)
+	if !ok {
+		return true
+	}
+	for i := range since {
+		if running[i] != since[i] {
+			return running[i] > since[i]
+		}
+	}
+	return true
+}
+
+func (p Param) yamlTag() string {
+	switch p.Type {
+	case ParamBool:
+		return "!!bool"
+	case ParamInt:
+		return "!!int"
+	case ParamFloat:
+		return "!!float"
+	default:
+		return "!!str"
+	}
+}
+
+func parseVersion(value string) ([3]int, bool) {
+	var out [3]int
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if value == "" {
+		return out, false
+	}
+	if i := strings.IndexAny(value, "-+"); i >= 0 {
+		value = value[:i]
+	}
+	parts := strings.Split(value, ".")
+	if len(parts) > 3 {
+		return out, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+func formatBound(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func secondsDuration(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
+
+// ParseDuration parses a duration as MediaMTX does: Go duration syntax,
+// optionally preceded by a number of days. Negative durations are
+// rejected.
+func ParseDuration(value string) (time.Duration, error) {
+	rest := strings.TrimSpace(value)
+	var days time.Duration
+	if i := strings.IndexByte(rest, 'd'); i > 0 {
+		n, err := strconv.ParseUint(rest[:i], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		rest = rest[i+1:]
+		if rest == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(rest)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return days + d, nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateParam(t *testing.T) {
+	valid := map[string]string{
+		"rpiCameraAWB":          "daylight",
+		"rpiCameraMode":         "2304:1296:10:P",
+		"rpiCameraAfMode":       "manual",
+		"rpiCameraSaturation":   "1.25",
+		"rpiCameraBrightness":   "-0.5",
+		"rpiCameraBitrate":      "4000000",
+		"rpiCameraTextOverlay":  "%H:%M cam1",
+		"rpiCameraHDR":          "true",
+		"rpiCameraLensPosition": "0",
+	}
+	for key, value := range valid {
+		if _, err := ValidateParam(key, value); err != nil {
+			t.Fatalf("expected %s=%q valid: %v", key, value, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"rpiCameraAWB":          "nope",
+		"rpiCameraMode":         "bad",
+		"rpiCameraAfMode":       "bad",
+		"rpiCameraSaturation":   "16.5",
+		"rpiCameraBrightness":   "-2",
+		"rpiCameraBitrate":      "4.5",
+		"rpiCameraHDR":          "maybe",
+		"rpiCameraLensPosition": "-1",
+		"rpiCameraNope":         "1",
+	}
+	for key, value := range invalid {
+		if _, err := ValidateParam(key, value); err == nil {
+			t.Fatalf("expected %s=%q invalid", key, value)
+		}
+	}
+
+	if v, err := ValidateParam("rpiCameraMode", ""); err != nil || v != "" {
+		t.Fatalf("expected empty value to clear: %q %v", v, err)
+	}
+	if v, _ := ValidateParam("rpiCameraContrast", " 1.50 "); v != "1.5" {
+		t.Fatalf("expected canonical float, got %q", v)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"1h30m": 90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		" 0s ":  0,
+	}
+	for value, want := range valid {
+		if got, err := ParseDuration(value); err != nil || got != want {
+			t.Fatalf("ParseDuration(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "1", "d", "1.5d", "-1s", "1dd", "1 day"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Fatalf("expected %q invalid", value)
+		}
+	}
+}
+
+func TestValidateRecordParam(t *testing.T) {
+	validate := func(key, value string) (string, error) {
+		p, ok := LookupRecordParam(key)
+		if !ok {
+			t.Fatalf("missing recording key %s", key)
+		}
+		return p.Validate(value)
+	}
+	valid := map[string]string{
+		"recordFormat":          "mpegts",
+		"recordPartDuration":    "500ms",
+		"recordSegmentDuration": "1h30m",
+		"recordDeleteAfter":     "0s",
+	}
+	for key, value := range valid {
+		if _, err := validate(key, value); err != nil {
+			t.Fatalf("expected %s=%q valid: %v", key, value, err)
+		}
+	}
+	invalid := map[string]string{
+		"recordFormat":          "mkv",
+		"recordPartDuration":    "0s",
+		"recordSegmentDuration": "90",
+		"recordDeleteAfter":     "-1h",
+	}
+	for key, value := range invalid {
+		if _, err := validate(key, value); err == nil {
+			t.Fatalf("expected %s=%q invalid", key, value)
+		}
+	}
+	if v, err := validate("record", "yes"); err != nil || v != "true" {
+		t.Fatalf("expected YAML bool accepted: %q %v", v, err)
+	}
+
+	if _, ok := LookupParam("record"); ok {
+		t.Fatal("recording key found in the camera schema")
+	}
+	if _, err := ValidateParam("recordFormat", "fmp4"); err == nil {
+		t.Fatal("expected recording key rejected as a camera parameter")
+	}
+}
+
+func TestParamSupportedBy(t *testing.T) {
+	p, ok := LookupParam("rpiCameraFlickerPeriod")
+	if !ok {
+		t.Fatalf("expected flicker period in schema")
+	}
+	cases := map[string]bool{
+		"v1.0.0":        false,
+		"v1.1.0":        true,
+		"v1.9.3":        true,
+		"v0.23.8":       false,
+		"1.2.0-rc1":     true,
+		"":              true,
+		"unknown-build": true,
+	}
+	for version, want := range cases {
+		if got := p.SupportedBy(version); got != want {
+			t.Fatalf("SupportedBy(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestCameraSchemaKeysUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, p := range CameraSchema {
+		if seen[p.Key] {
+			t.Fatalf("duplicate key %s", p.Key)
+		}
+		seen[p.Key] = true
+		if p.Type == ParamEnum && len(p.Options) == 0 {
+			t.Fatalf("enum %s has no options", p.Key)
+		}
+		if _, ok := parseVersion(p.Since); !ok {
+			t.Fatalf("invalid Since for %s: %q", p.Key, p.Since)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+// Package fmp4 reads the fragmented MP4 segments MediaMTX records and
+// writes fragments taken from several of them as one continuous file.
+//
+// Only the boxes needed for that are parsed: track timescales and handlers
+// from moov, and decode times, sequence numbers and the sync flag of the
+// first video sample from each moof. Sample data is copied untouched.
+package fmp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxBoxRead bounds the moov and moof boxes read into memory.
+const maxBoxRead = 16 << 20
+
+// sampleIsNonSync is the sample_is_non_sync_sample bit of sample flags.
+const sampleIsNonSync = 0x10000
+
+var (
+	ErrInvalid = errors.New("invalid fragmented mp4")
+	ErrNoInit  = errors.New("no moov box")
+)
+
+// Track is a track declared in the moov box.
+type Track struct {
+	ID        uint32
+	Timescale uint32
+	Video     bool
+
+	defaultFlags uint32 // default_sample_flags of its trex box
+}
+
+// Init is the ftyp and moov boxes that start a segment.
+type Init struct {
+	Raw    []byte
+	Tracks []Track
+
+	// signature covers the track layout and sample descriptions, which
+	// must match for fragments of two segments to share one init.
+	signature string
+}
+
+// Compatible reports whether fragments of a segment starting with other
+// may follow an init i.
+func (i Init) Compatible(other Init) bool {
+	return i.signature == other.signature
+}
+
+func (i Init) track(id uint32) (Track, bool) {
+	for _, t := range i.Tracks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Track{}, false
+}
+
+// TrackTime is the decode time of the first sample of a track in a
+// fragment, in the track timescale.
+type TrackTime struct {
+	Track uint32
+	Time  uint64
+
+	pos  int // of the tfdt value in the moof box
+	wide bool
+}
+
+// Fragment is a moof box and the mdat that follows it.
+type Fragment struct {
+	Offset   int64
+	MoofSize int64
+	Size     int64
+	Times    []TrackTime
+	// Keyframe reports that the fragment starts with a sync sample of
+	// the video track, or that it carries no video.
+	Keyframe bool
+
+	seqPos      int
+	baseOffsets []int // of tfhd base_data_offset values in the moof box
+}
+
+// Time returns the decode time of the video track in f, or of its first
+// track without video, and that track.
+func (f Fragment) Time(init Init) (TrackTime, bool) {
+	for _, tt := range f.Times {
+		if t, ok := init.track(tt.Track); ok && t.Video {
+			return tt, true
+		}
+	}
+	if len(f.Times) == 0 {
+		return TrackTime{}, false
+	}
+	return f.Times[0], true
+}
+
+// Segment is a parsed segment file.
+type Segment struct {
+	Init      Init
+	Fragments []Fragment
+}
+
+// Read parses the segment in r, size bytes long. A box cut short at the
+// end, as in a segment MediaMTX is still writing, ends the segment.
+func Read(r io.ReaderAt, size int64) (Segment, error) {
+	var seg Segment
+	var initEnd int64
+	var pending *Fragment
+	for off := int64(0); off+8 <= size; {
+		hdr := make([]byte, 16)
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return Segment{}, err
+		}
+		boxSize, hdrSize := int64(binary.BigEndian.Uint32(hdr)), int64(8)
+		typ := string(hdr[4:8])
+		switch boxSize {
+		case 1:
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return Segment{}, err
+			}
+			boxSize, hdrSize = int64(binary.BigEndian.Uint64(hdr[8:])), 16
+		case 0:
+			boxSize = size - off
+		}
+		if boxSize < hdrSize {
+			return Segment{}, fmt.Errorf("%w: %q box at %d", ErrInvalid, typ, off)
+		}
+		if off+boxSize > size {
+			break
+		}
+
+		switch typ {
+		case "moov":
+			b, err := readBox(r, off, boxSize)
+			if err != nil {
+				return Segment{}, err
+			}
+			if seg.Init, err = parseMoov(b[hdrSize:]); err != nil {
+				return Segment{}, err
+			}
+			initEnd = off + boxSize
+		case "moof":
+			if initEnd == 0 {
+				return Segment{}, ErrNoInit
+			}
+			b, err := readBox(r, off, boxSize)
+			if err != nil {
+				return Segment{}, err
+			}
+			frag, err := parseMoof(b, int(hdrSize), seg.Init)
+			if err != nil {
+				return Segment{}, err
+			}
+			frag.Offset, frag.MoofSize = off, boxSize
+			pending = &frag
+		case "mdat":
+			if pending != nil {
+				pending.Size = off + boxSize - pending.Offset
+				seg.Fragments = append(seg.Fragments, *pending)
+				pending = nil
+			}
+		}
+		off += boxSize
+	}
+	if initEnd == 0 {
+		return Segment{}, ErrNoInit
+	}
+	raw, err := readBox(r, 0, initEnd)
+	if err != nil {
+		return Segment{}, err
+	}
+	seg.Init.Raw = raw
+	return seg, nil
+}
+
+func readBox(r io.ReaderAt, off, size int64) ([]byte, error) {
+	if size > maxBoxRead {
+		return nil, fmt.Errorf("%w: %d byte box at %d", ErrInvalid, size, off)
+	}
+	b := make([]byte, size)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// walk calls fn with the type and payload of each box in b, and the offset
+// of the payload from the start of the buffer b was cut from at base.
+func walk(b []byte, base int, fn func(typ string, payload []byte, at int) error) error {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return ErrInvalid
+		}
+		size, hdr := uint64(binary.BigEndian.Uint32(b)), 8
+		typ := string(b[4:8])
+		switch size {
+		case 1:
+			if len(b) < 16 {
+				return ErrInvalid
+			}
+			size, hdr = binary.BigEndian.Uint64(b[8:]), 16
+		case 0:
+			size = uint64(len(b))
+		}
+		if size < uint64(hdr) || size > uint64(len(b)) {
+			return fmt.Errorf("%w: %q box", ErrInvalid, typ)
+		}
+		if err := fn(typ, b[hdr:size], base+hdr); err != nil {
+			return err
+		}
+		b = b[size:]
+		base += int(size)
+	}
+	return nil
+}
+
+func parseMoov(b []byte) (Init, error) {
+	var init Init
+	var signature bytes.Buffer
+	defaults := map[uint32]uint32{}
+	err := walk(b, 0, func(typ string, p []byte, _ int) error {
+		switch typ {
+		case "trak":
+			t, stsd, err := parseTrak(p)
+			if err != nil {
+				return err
+			}
+			init.Tracks = append(init.Tracks, t)
+			fmt.Fprintf(&signature, "%d/%d/%t/", t.ID, t.Timescale, t.Video)
+			signature.Write(stsd)
+		case "mvex":
+			return walk(p, 0, func(typ string, p []byte, _ int) error {
+				if typ == "trex" && len(p) >= 24 {
+					defaults[binary.BigEndian.Uint32(p[4:])] = binary.BigEndian.Uint32(p[20:])
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return Init{}, err
+	}
+	if len(init.Tracks) == 0 {
+		return Init{}, fmt.Errorf("%w: no tracks", ErrInvalid)
+	}
+	for i := range init.Tracks {
+		init.Tracks[i].defaultFlags = defaults[init.Tracks[i].ID]
+	}
+	init.signature = signature.String()
+	return init, nil
+}
+
+func parseTrak(b []byte) (Track, []byte, error) {
+	var t Track
+	var stsd []byte
+	err := walk(b, 0, func(typ string, p []byte, _ int) error {
+		switch typ {
+		case "tkhd":
+			t.ID = fullBoxUint32(p, 12, 20)
+		case "mdia":
+			return walk(p, 0, func(typ string, p []byte, _ int) error {
+				switch typ {
+				case "mdhd":
+					t.Timescale = fullBoxUint32(p, 12, 20)
+				case "hdlr":
+					t.Video = len(p) >= 12 && string(p[8:12]) == "vide"
+				case "minf":
+					return walk(p, 0, func(typ string, p []byte, _ int) error {
+						if typ == "stbl" {
+							return walk(p, 0, func(typ string, p []byte, _ int) error {
+								if typ == "stsd" {
+									stsd = p
+								}
+								return nil
+							})
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return Track{}, nil, err
+	}
+	if t.ID == 0 || t.Timescale == 0 {
+		return Track{}, nil, fmt.Errorf("%w: track without id or timescale", ErrInvalid)
+	}
+	return t, stsd, nil
+}
+
+// fullBoxUint32 reads the 32-bit field found at v0 in a version 0 full box
+// payload and at v1 in a version 1 one.
+func fullBoxUint32(p []byte, v0, v1 int) uint32 {
+	at := v0
+	if len(p) > 0 && p[0] == 1 {
+		at = v1
+	}
+	if len(p) < at+4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(p[at:])
+}
+
+// parseMoof reads the moof box b, whose payload starts at hdr.
+func parseMoof(b []byte, hdr int, init Init) (Fragment, error) {
+	frag := Fragment{Keyframe: true}
+	err := walk(b[hdr:], hdr, func(typ string, p []byte, at int) error {
+		switch typ {
+		case "mfhd":
+			if len(p) < 8 {
+				return fmt.Errorf("%w: short mfhd", ErrInvalid)
+			}
+			frag.seqPos = at + 4
+		case "traf":
+			return parseTraf(p, at, init, &frag)
+		}
+		return nil
+	})
+	if err != nil {
+		return Fragment{}, err
+	}
+	if frag.seqPos == 0 || len(frag.Times) == 0 {
+		return Fragment{}, fmt.Errorf("%w: moof without mfhd or tfdt", ErrInvalid)
+	}
+	return frag, nil
+}
+
+func parseTraf(b []byte, base int, init Init, frag *Fragment) error {
+	var track Track
+	var known bool
+	var defaultFlags uint32
+	var firstFlags *uint32
+	err := walk(b, base, func(typ string, p []byte, at int) error {
+		switch typ {
+		case "tfhd":
+			if len(p) < 8 {
+				return fmt.Errorf("%w: short tfhd", ErrInvalid)
+			}
+			flags := binary.BigEndian.Uint32(p) & 0xffffff
+			track, known = init.track(binary.BigEndian.Uint32(p[4:]))
+			if !known {
+				return fmt.Errorf("%w: fragment of undeclared track %d", ErrInvalid, binary.BigEndian.Uint32(p[4:]))
+			}
+			defaultFlags = track.defaultFlags
+			pos := 8
+			if flags&0x1 != 0 {
+				frag.baseOffsets = append(frag.baseOffsets, at+pos)
+				pos += 8
+			}
+			for _, bit := range []uint32{0x2, 0x8, 0x10} {
+				if flags&bit != 0 {
+					pos += 4
+				}
+			}
+			if flags&0x20 != 0 {
+				if len(p) < pos+4 {
+					return fmt.Errorf("%w: short tfhd", ErrInvalid)
+				}
+				defaultFlags = binary.BigEndian.Uint32(p[pos:])
+			}
+		case "tfdt":
+			tt := TrackTime{Track: track.ID, pos: at + 4}
+			switch {
+			case len(p) >= 12 && p[0] == 1:
+				tt.Time, tt.wide = binary.BigEndian.Uint64(p[4:]), true
+			case len(p) >= 8 && p[0] == 0:
+				tt.Time = uint64(binary.BigEndian.Uint32(p[4:]))
+			default:
+				return fmt.Errorf("%w: bad tfdt", ErrInvalid)
+			}
+			frag.Times = append(frag.Times, tt)
+		case "trun":
+			if firstFlags != nil || len(p) < 8 || binary.BigEndian.Uint32(p[4:]) == 0 {
+				return nil
+			}
+			flags := binary.BigEndian.Uint32(p) & 0xffffff
+			pos := 8
+			if flags&0x1 != 0 {
+				pos += 4
+			}
+			switch {
+			case flags&0x4 != 0 && len(p) >= pos+4:
+				v := binary.BigEndian.Uint32(p[pos:])
+				firstFlags = &v
+			case flags&0x400 != 0:
+				// Per-sample flags follow the duration and size of
+				// the first sample, when those are present.
+				for _, bit := range []uint32{0x100, 0x200} {
+					if flags&bit != 0 {
+						pos += 4
+					}
+				}
+				if len(p) >= pos+4 {
+					v := binary.BigEndian.Uint32(p[pos:])
+					firstFlags = &v
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if known && track.Video {
+		flags := defaultFlags
+		if firstFlags != nil {
+			flags = *firstFlags
+		}
+		frag.Keyframe = flags&sampleIsNonSync == 0
+	}
+	return nil
+}
+
+// Writer writes an init followed by fragments renumbered from 1.
+type Writer struct {
+	w   io.Writer
+	n   int64
+	seq uint32
+}
+
+// NewWriter writes init to w.
+func NewWriter(w io.Writer, init Init) (*Writer, error) {
+	n, err := w.Write(init.Raw)
+	return &Writer{w: w, n: int64(n)}, err
+}
+
+// Written returns the bytes written so far.
+func (w *Writer) Written() int64 {
+	return w.n
+}
+
+// WriteFragment copies fragment f of the segment in r with the decode
+// times in times, keyed by track, in place of its own.
+func (w *Writer) WriteFragment(r io.ReaderAt, f Fragment, times map[uint32]uint64) error {
+	moof, err := readBox(r, f.Offset, f.MoofSize)
+	if err != nil {
+		return err
+	}
+	w.seq++
+	binary.BigEndian.PutUint32(moof[f.seqPos:], w.seq)
+	for _, tt := range f.Times {
+		t, ok := times[tt.Track]
+		if !ok {
+			continue
+		}
+		if tt.wide {
+			binary.BigEndian.PutUint64(moof[tt.pos:], t)
+		} else if t > 0xffffffff {
+			return fmt.Errorf("decode time %d of track %d does not fit a version 0 tfdt", t, tt.Track)
+		} else {
+			binary.BigEndian.PutUint32(moof[tt.pos:], uint32(t))
+		}
+	}
+	// Absolute data offsets move with the fragment.
+	for _, pos := range f.baseOffsets {
+		old := binary.BigEndian.Uint64(moof[pos:])
+		binary.BigEndian.PutUint64(moof[pos:], uint64(int64(old)-f.Offset+w.n))
+	}
+
+	n, err := w.w.Write(moof)
+	w.n += int64(n)
+	if err != nil {
+		return err
+	}
+	copied, err := io.Copy(w.w, io.NewSectionReader(r, f.Offset+f.MoofSize, f.Size-f.MoofSize))
+	w.n += copied
+	return err
+}
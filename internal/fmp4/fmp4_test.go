@@ -0,0 +1,102 @@
+package fmp4
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/fmp4/fmp4test"
+)
+
+func TestRead(t *testing.T) {
+	b := fmp4test.Segment("h264",
+		fmp4test.Fragment{Time: 1000, Keyframe: true, Data: []byte("key")},
+		fmp4test.Fragment{Time: 91000, Data: []byte("delta")},
+	)
+	seg, err := Read(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seg.Init.Tracks) != 1 || seg.Init.Tracks[0].Timescale != fmp4test.Timescale || !seg.Init.Tracks[0].Video {
+		t.Fatalf("unexpected tracks: %+v", seg.Init.Tracks)
+	}
+	if len(seg.Fragments) != 2 || !seg.Fragments[0].Keyframe || seg.Fragments[1].Keyframe {
+		t.Fatalf("unexpected fragments: %+v", seg.Fragments)
+	}
+	if tt, ok := seg.Fragments[1].Time(seg.Init); !ok || tt.Time != 91000 {
+		t.Fatalf("unexpected time: %+v", tt)
+	}
+	if last := seg.Fragments[1]; last.Offset+last.Size != int64(len(b)) {
+		t.Fatalf("fragment does not end the file: %+v", last)
+	}
+
+	// A segment still being written ends at its last complete fragment.
+	partial, err := Read(bytes.NewReader(b[:len(b)-2]), int64(len(b)-2))
+	if err != nil || len(partial.Fragments) != 1 {
+		t.Fatalf("unexpected partial read: %+v, %v", partial.Fragments, err)
+	}
+
+	if _, err := Read(bytes.NewReader(b[:24]), 24); err != ErrNoInit {
+		t.Fatalf("expected ErrNoInit, got %v", err)
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	a := fmp4test.Segment("h264", fmp4test.Fragment{Keyframe: true})
+	b := fmp4test.Segment("h264", fmp4test.Fragment{Time: 5, Keyframe: true})
+	c := fmp4test.Segment("h265", fmp4test.Fragment{Keyframe: true})
+	segA, _ := Read(bytes.NewReader(a), int64(len(a)))
+	segB, _ := Read(bytes.NewReader(b), int64(len(b)))
+	segC, _ := Read(bytes.NewReader(c), int64(len(c)))
+	if !segA.Init.Compatible(segB.Init) || segA.Init.Compatible(segC.Init) {
+		t.Fatal("unexpected compatibility")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	first := fmp4test.Segment("h264",
+		fmp4test.Fragment{Time: 0, Keyframe: true, Data: []byte("one")},
+		fmp4test.Fragment{Time: 90000, Data: []byte("two")},
+	)
+	second := fmp4test.Segment("h264", fmp4test.Fragment{Time: 0, Keyframe: true, Data: []byte("three")})
+	segFirst, _ := Read(bytes.NewReader(first), int64(len(first)))
+	segSecond, _ := Read(bytes.NewReader(second), int64(len(second)))
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, segFirst.Init)
+	if err != nil {
+		t.Fatalf("write init: %v", err)
+	}
+	writes := []struct {
+		r    []byte
+		f    Fragment
+		time uint64
+	}{
+		{first, segFirst.Fragments[1], 0},
+		{second, segSecond.Fragments[0], 180000},
+	}
+	for _, wr := range writes {
+		if err := w.WriteFragment(bytes.NewReader(wr.r), wr.f, map[uint32]uint64{fmp4test.TrackID: wr.time}); err != nil {
+			t.Fatalf("write fragment: %v", err)
+		}
+	}
+	if w.Written() != int64(out.Len()) {
+		t.Fatalf("written %d, buffer %d", w.Written(), out.Len())
+	}
+
+	got, err := Read(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if len(got.Fragments) != 2 || got.Fragments[0].Times[0].Time != 0 || got.Fragments[1].Times[0].Time != 180000 || !got.Fragments[1].Keyframe {
+		t.Fatalf("unexpected output fragments: %+v", got.Fragments)
+	}
+	for i, f := range got.Fragments {
+		moof := out.Bytes()[f.Offset : f.Offset+f.MoofSize]
+		if seq := moof[f.seqPos+3]; int(seq) != i+1 {
+			t.Fatalf("fragment %d has sequence number %d", i, seq)
+		}
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("three")) {
+		t.Fatal("sample data not copied")
+	}
+}
@@ -0,0 +1,102 @@
+// Package fmp4test builds small fragmented MP4 segments shaped like the
+// ones MediaMTX records: one video track and a moof and mdat per part.
+// Sample data is opaque bytes, so the files only play in tests.
+package fmp4test
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Timescale is the timescale of the video track, as MediaMTX uses for
+// H.264.
+const Timescale = 90000
+
+// TrackID is the ID of the video track.
+const TrackID = 1
+
+// Fragment is one part of a segment: a single sample decoded at Time.
+type Fragment struct {
+	Time     uint64
+	Keyframe bool
+	Data     []byte
+}
+
+// Segment returns a segment holding fragments. codec distinguishes sample
+// descriptions, so segments built with different codecs are not
+// compatible.
+func Segment(codec string, fragments ...Fragment) []byte {
+	var out bytes.Buffer
+	out.Write(box("ftyp", []byte("iso5\x00\x00\x02\x00iso5iso6mp41")))
+	out.Write(Moov(codec))
+	for i, f := range fragments {
+		out.Write(Moof(uint32(i+1), f))
+		out.Write(box("mdat", f.Data))
+	}
+	return out.Bytes()
+}
+
+// Moov returns the moov box of a segment.
+func Moov(codec string) []byte {
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[12:], TrackID)
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], Timescale)
+	hdlr := append(make([]byte, 8), []byte("vide\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00VideoHandler\x00")...)
+	stsd := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, box("avc1", []byte(codec))...)
+	trex := make([]byte, 24)
+	binary.BigEndian.PutUint32(trex[4:], TrackID)
+	binary.BigEndian.PutUint32(trex[8:], 1)
+	binary.BigEndian.PutUint32(trex[20:], 0x10000) // non-sync unless a fragment says so
+
+	return box("moov",
+		box("mvhd", make([]byte, 100)),
+		box("trak",
+			box("tkhd", tkhd),
+			box("mdia",
+				box("mdhd", mdhd),
+				box("hdlr", hdlr),
+				box("minf", box("stbl", box("stsd", stsd))),
+			),
+		),
+		box("mvex", box("trex", trex)),
+	)
+}
+
+// Moof returns the moof box of fragment f with sequence number seq. Data
+// offsets are relative to the moof, as MediaMTX writes them.
+func Moof(seq uint32, f Fragment) []byte {
+	mfhd := make([]byte, 8)
+	binary.BigEndian.PutUint32(mfhd[4:], seq)
+	tfhd := make([]byte, 8)
+	binary.BigEndian.PutUint32(tfhd, 0x20000) // default-base-is-moof
+	binary.BigEndian.PutUint32(tfhd[4:], TrackID)
+	tfdt := make([]byte, 12)
+	tfdt[0] = 1
+	binary.BigEndian.PutUint64(tfdt[4:], f.Time)
+
+	// data_offset, first_sample_flags and a per-sample size.
+	trun := make([]byte, 20)
+	binary.BigEndian.PutUint32(trun, 0x1|0x4|0x200)
+	binary.BigEndian.PutUint32(trun[4:], 1)
+	if !f.Keyframe {
+		binary.BigEndian.PutUint32(trun[12:], 0x10000)
+	}
+	binary.BigEndian.PutUint32(trun[16:], uint32(len(f.Data)))
+
+	moof := box("moof", box("mfhd", mfhd), box("traf", box("tfhd", tfhd), box("tfdt", tfdt), box("trun", trun)))
+	// data_offset points past the mdat header.
+	binary.BigEndian.PutUint32(moof[len(moof)-12:], uint32(len(moof)+8))
+	return moof
+}
+
+func box(typ string, children ...[]byte) []byte {
+	var payload []byte
+	for _, c := range children {
+		payload = append(payload, c...)
+	}
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], typ)
+	return append(b, payload...)
+}
@@ -0,0 +1,118 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Runner runs external commands such as vcgencmd, systemctl and iw.
+type Runner interface {
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+	CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// Network reports the host network interfaces.
+type Network interface {
+	Interfaces() ([]net.Interface, error)
+	InterfaceAddrs(name string) ([]net.Addr, error)
+}
+
+// Env is the host environment collectors read from: a filesystem root that
+// prefixes every /proc, /sys and /etc path, a command runner and the
+// network interfaces.
+type Env struct {
+	Root   string
+	Runner Runner
+	Net    Network
+}
+
+func Default() Env {
+	return New("/")
+}
+
+// New returns an Env reading files below root and running real commands.
+func New(root string) Env {
+	if root == "" {
+		root = "/"
+	}
+	return Env{Root: root, Runner: ExecRunner{}, Net: SystemNetwork{}}
+}
+
+// Path maps an absolute host path to its location below Root.
+func (e Env) Path(path string) string {
+	if e.Root == "" || e.Root == "/" {
+		return path
+	}
+	return filepath.Join(e.Root, path)
+}
+
+func (e Env) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(e.Path(path))
+}
+
+func (e Env) Open(path string) (*os.File, error) {
+	return os.Open(e.Path(path))
+}
+
+// Glob matches pattern below Root and returns host paths.
+func (e Env) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(e.Path(pattern))
+	if err != nil || e.Root == "" || e.Root == "/" {
+		return matches, err
+	}
+	for i, match := range matches {
+		rel, err := filepath.Rel(e.Root, match)
+		if err != nil {
+			return nil, err
+		}
+		matches[i] = "/" + filepath.ToSlash(rel)
+	}
+	return matches, nil
+}
+
+// Hostname reads the kernel hostname below Root, falling back to
+// os.Hostname on the real root.
+func (e Env) Hostname() (string, error) {
+	b, err := e.ReadFile("/proc/sys/kernel/hostname")
+	if err == nil {
+		if name := strings.TrimSpace(string(b)); name != "" {
+			return name, nil
+		}
+	}
+	if e.Root == "" || e.Root == "/" {
+		return os.Hostname()
+	}
+	if err == nil {
+		err = errors.New("empty hostname")
+	}
+	return "", err
+}
+
+type ExecRunner struct{}
+
+func (ExecRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+func (ExecRunner) CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+type SystemNetwork struct{}
+
+func (SystemNetwork) Interfaces() ([]net.Interface, error) {
+	return net.Interfaces()
+}
+
+func (SystemNetwork) InterfaceAddrs(name string) ([]net.Addr, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return iface.Addrs()
+}
@@ -0,0 +1,92 @@
+package host_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
+
+func TestEnvPath(t *testing.T) {
+	if got := host.New("/").Path("/proc/stat"); got != "/proc/stat" {
+		t.Fatalf("unexpected path: %q", got)
+	}
+	if got := host.New("/srv/pi").Path("/proc/stat"); got != "/srv/pi/proc/stat" {
+		t.Fatalf("unexpected path: %q", got)
+	}
+}
+
+func TestEnvGlob(t *testing.T) {
+	env := hosttest.SyntheticPi()
+	got, err := env.Glob("/sys/class/hwmon/hwmon*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/sys/class/hwmon/hwmon0", "/sys/class/hwmon/hwmon1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected matches: %v", got)
+	}
+}
+
+func TestEnvHostname(t *testing.T) {
+	name, err := hosttest.SyntheticPi().Hostname()
+	if err != nil || name != "zero2" {
+		t.Fatalf("unexpected hostname: %q %v", name, err)
+	}
+	if _, err := host.New(t.TempDir()).Hostname(); err == nil {
+		t.Fatalf("expected error without hostname file")
+	}
+}
+
+func TestFixtureRunner(t *testing.T) {
+	dir := t.TempDir()
+	runner := hosttest.FixtureRunner{Dir: dir}
+	if err := os.WriteFile(filepath.Join(dir, "systemctl_is-active_mediamtx"), []byte("failed\n"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "systemctl_is-active_mediamtx.exit"), []byte("3\n"), 0o644); err != nil {
+		t.Fatalf("write exit: %v", err)
+	}
+
+	out, err := runner.CombinedOutput(context.Background(), "systemctl", "is-active", "mediamtx")
+	if err == nil || string(out) != "failed\n" {
+		t.Fatalf("expected failed output with error, got %q %v", out, err)
+	}
+
+	_, err = runner.Output(context.Background(), "vcgencmd", "measure_temp")
+	var execErr *exec.Error
+	if !errors.As(err, &execErr) || !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestCaptureRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if err := hosttest.Capture(context.Background(), hosttest.SyntheticPi(), dir); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	env, err := hosttest.FromDir(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if name, err := env.Hostname(); err != nil || name != "zero2" {
+		t.Fatalf("unexpected hostname: %q %v", name, err)
+	}
+	out, err := env.Runner.Output(context.Background(), "vcgencmd", "get_throttled")
+	if err != nil || string(out) != "throttled=0x50000\n" {
+		t.Fatalf("unexpected command output: %q %v", out, err)
+	}
+	addrs, err := env.Net.InterfaceAddrs("wlan0")
+	if err != nil || len(addrs) != 1 || addrs[0].String() != "192.168.1.42/24" {
+		t.Fatalf("unexpected addresses: %v %v", addrs, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "root/sys/firmware/devicetree/base/soc/i2c0mux/i2c@1/imx708@1a/compatible")); err != nil {
+		t.Fatalf("expected the camera node captured: %v", err)
+	}
+}
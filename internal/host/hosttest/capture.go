@@ -0,0 +1,214 @@
+package hosttest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+// captureFiles are the host files the collectors read, as globs.
+var captureFiles = []string{
+	"/etc/os-release",
+	"/proc/uptime",
+	"/proc/loadavg",
+	"/proc/meminfo",
+	"/proc/stat",
+	"/proc/sys/kernel/hostname",
+	"/proc/net/route",
+	"/proc/net/dev",
+	"/proc/net/wireless",
+	"/sys/class/thermal/thermal_zone*/type",
+	"/sys/class/thermal/thermal_zone*/temp",
+	"/sys/class/hwmon/hwmon*/name",
+	"/sys/class/hwmon/hwmon*/temp*_input",
+	"/sys/class/hwmon/hwmon*/in*_input",
+	"/sys/class/hwmon/hwmon*/in*_label",
+	"/sys/class/hwmon/hwmon*/in*_lcrit_alarm",
+	"/sys/firmware/devicetree/base/model",
+}
+
+// captureCommands are the command lines the collectors run, matching the
+// fixture names of the synthetic set.
+var captureCommands = [][]string{
+	{"vcgencmd", "measure_temp"},
+	{"vcgencmd", "measure_volts"},
+	{"vcgencmd", "get_throttled"},
+	{"iw", "dev", "wlan0", "link"},
+	{"systemctl", "is-active", "mediamtx"},
+	{"systemctl", "show", "mediamtx", "--property=ActiveState,SubState,MainPID,ActiveEnterTimestamp,NRestarts,MemoryCurrent"},
+	{"journalctl", "--unit=mediamtx", "--lines=200", "--output=json", "--no-pager"},
+}
+
+// versionCommands identify the kernel and firmware a capture comes from.
+var versionCommands = [][]string{
+	{"uname", "-srvm"},
+	{"vcgencmd", "version"},
+}
+
+// Capture records what the collectors read from env into dir, in the
+// layout FromDir loads: root/ with the files, commands/ with the command
+// output, interfaces with the addresses and VERSIONS with the kernel and
+// firmware. Files and commands missing on the host are left out, like on
+// the device. It returns the files and commands that failed otherwise.
+func Capture(ctx context.Context, env host.Env, dir string) error {
+	var errs []error
+	for _, pattern := range captureFiles {
+		matches, err := env.Glob(pattern)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, match := range matches {
+			if err := captureFile(env, dir, match); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := captureCamera(env, dir); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, args := range captureCommands {
+		out, err := env.Runner.Output(ctx, args[0], args[1:]...)
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		base := filepath.Join(dir, "commands", FixtureName(args[0], args[1:]...))
+		if err := writeFixture(base, out); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = writeFixture(base+".exit", []byte(fmt.Sprintf("%d\n", exitErr.ExitCode())))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", strings.Join(args, " "), err))
+		}
+	}
+
+	if err := captureInterfaces(env, dir); err != nil {
+		errs = append(errs, err)
+	}
+	var versions strings.Builder
+	for _, args := range versionCommands {
+		out, err := env.Runner.Output(ctx, args[0], args[1:]...)
+		if err != nil {
+			fmt.Fprintf(&versions, "$ %s\n(%v)\n", strings.Join(args, " "), err)
+			continue
+		}
+		fmt.Fprintf(&versions, "$ %s\n%s\n", strings.Join(args, " "), strings.TrimSpace(string(out)))
+	}
+	if err := writeFixture(filepath.Join(dir, "VERSIONS"), []byte(versions.String())); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func captureFile(env host.Env, dir, file string) error {
+	b, err := env.ReadFile(file)
+	if err != nil {
+		// Sysfs attributes of absent hardware fail to read on the device
+		// as well.
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			return nil
+		}
+		return err
+	}
+	return writeFixture(filepath.Join(dir, "root", file), b)
+}
+
+// captureCamera keeps the device tree files naming a camera sensor, which
+// is all camera detection looks for.
+func captureCamera(env host.Env, dir string) error {
+	base := "/sys/firmware/devicetree/base"
+	resolved, err := filepath.EvalSymlinks(env.Path(base))
+	if err != nil {
+		return nil
+	}
+	return filepath.WalkDir(resolved, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != "compatible" {
+			return nil
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil
+		}
+		for _, code := range []string{"imx708", "imx477", "imx219", "ov5647"} {
+			if strings.Contains(string(b), code) {
+				rel, err := filepath.Rel(resolved, file)
+				if err != nil {
+					return err
+				}
+				return writeFixture(filepath.Join(dir, "root", base, rel), b)
+			}
+		}
+		return nil
+	})
+}
+
+func captureInterfaces(env host.Env, dir string) error {
+	ifaces, err := env.Net.Interfaces()
+	if err != nil {
+		return err
+	}
+	var out strings.Builder
+	for _, iface := range ifaces {
+		addrs, err := env.Net.InterfaceAddrs(iface.Name)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			fmt.Fprintf(&out, "%s %s\n", iface.Name, addr.String())
+		}
+	}
+	return writeFixture(filepath.Join(dir, "interfaces"), []byte(out.String()))
+}
+
+func writeFixture(file string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
+
+// FromDir returns an Env backed by a fixture set written by Capture.
+func FromDir(dir string) (host.Env, error) {
+	network := StaticNetwork{}
+	f, err := os.Open(filepath.Join(dir, "interfaces"))
+	if err != nil {
+		return host.Env{}, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, cidr, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		network[name] = append(network[name], cidr)
+	}
+	if err := scanner.Err(); err != nil {
+		return host.Env{}, err
+	}
+	return host.Env{
+		Root:   filepath.Join(dir, "root"),
+		Runner: FixtureRunner{Dir: filepath.Join(dir, "commands")},
+		Net:    network,
+	}, nil
+}
+
+// RecordedPiZero2W returns the fixture set captured on a Pi Zero 2 W in
+// testdata/pizero2w, and false while none has been committed.
+func RecordedPiZero2W() (host.Env, bool) {
+	env, err := FromDir(filepath.Join(fixtureDir(), "pizero2w"))
+	return env, err == nil
+}
@@ -0,0 +1,126 @@
+// Package hosttest provides host.Env fixtures so collectors can be tested
+// off-device.
+package hosttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+// FixtureRunner replays recorded command output from Dir. The output of
+// "vcgencmd measure_temp" is read from Dir/vcgencmd_measure_temp; an optional
+// Dir/vcgencmd_measure_temp.exit holds a non-zero exit status. Commands
+// without a fixture fail as if the binary were not installed.
+type FixtureRunner struct {
+	Dir string
+}
+
+func (r FixtureRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(ctx, name, args)
+}
+
+func (r FixtureRunner) CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(ctx, name, args)
+}
+
+func (r FixtureRunner) run(ctx context.Context, name string, args []string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(r.Dir, FixtureName(name, args...))
+	out, err := os.ReadFile(base)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+		}
+		return nil, err
+	}
+	if code, err := os.ReadFile(base + ".exit"); err == nil {
+		status, convErr := strconv.Atoi(strings.TrimSpace(string(code)))
+		if convErr == nil && status != 0 {
+			return out, fmt.Errorf("exit status %d", status)
+		}
+	}
+	return out, nil
+}
+
+// FixtureName returns the fixture file name for a command line.
+func FixtureName(name string, args ...string) string {
+	parts := append([]string{filepath.Base(name)}, args...)
+	return strings.NewReplacer("/", "-", " ", "-").Replace(strings.Join(parts, "_"))
+}
+
+// StaticNetwork serves fixed interface addresses, keyed by interface name
+// with CIDR values.
+type StaticNetwork map[string][]string
+
+func (n StaticNetwork) Interfaces() ([]net.Interface, error) {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ifaces := make([]net.Interface, 0, len(names))
+	for i, name := range names {
+		flags := net.FlagUp | net.FlagRunning
+		if name == "lo" {
+			flags |= net.FlagLoopback
+		}
+		ifaces = append(ifaces, net.Interface{Index: i + 1, Name: name, Flags: flags})
+	}
+	return ifaces, nil
+}
+
+func (n StaticNetwork) InterfaceAddrs(name string) ([]net.Addr, error) {
+	cidrs, ok := n[name]
+	if !ok {
+		return nil, fmt.Errorf("interface %s not found", name)
+	}
+	addrs := make([]net.Addr, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		ipNet.IP = ip
+		addrs = append(addrs, ipNet)
+	}
+	return addrs, nil
+}
+
+// SyntheticPi returns an Env backed by the hand-written fixture set in
+// testdata/synthetic-pi: a filesystem tree under root/ and command output
+// under commands/. It imitates a Pi Zero 2 W with a Camera Module 3 on WiFi
+// but was not recorded from one, so it does not catch format quirks of real
+// sysfs, vcgencmd or netdev output; RecordedPiZero2W does.
+func SyntheticPi() host.Env {
+	dir := filepath.Join(fixtureDir(), "synthetic-pi")
+	return host.Env{
+		Root:   filepath.Join(dir, "root"),
+		Runner: FixtureRunner{Dir: filepath.Join(dir, "commands")},
+		Net: StaticNetwork{
+			"lo":    {"127.0.0.1/8"},
+			"wlan0": {"192.168.1.42/24"},
+		},
+	}
+}
+
+func fixtureDir() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return "testdata"
+	}
+	return filepath.Join(filepath.Dir(file), "testdata")
+}
@@ -0,0 +1,239 @@
+package mediamtx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds a single Control API request.
+const DefaultTimeout = 2 * time.Second
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnreachable  = errors.New("mediamtx api unreachable")
+)
+
+// APIError is a non-2xx answer from the Control API. Message is the
+// "error" field MediaMTX puts in the body, when there is one.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is matches ErrNotFound, ErrBadRequest and ErrUnauthorized by status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// ConnError is a request that never got an answer, such as a refused
+// connection while MediaMTX restarts. It matches ErrUnreachable.
+type ConnError struct {
+	URL string
+	Err error
+}
+
+func (e *ConnError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrUnreachable, e.Err)
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConnError) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
+// Client talks to the MediaMTX Control API (v3).
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	user       string
+	pass       string
+	token      string
+}
+
+type Option func(*Client)
+
+// WithBasicAuth sends user and pass on every request, for MediaMTX
+// installs with internal authentication on the api action.
+func WithBasicAuth(user, pass string) Option {
+	return func(c *Client) {
+		c.user, c.pass = user, pass
+	}
+}
+
+// WithBearerToken sends token as a bearer token, for JWT authentication.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = timeout
+		c.httpClient = &hc
+	}
+}
+
+// NewClient returns a client for the Control API at baseURL, such as
+// "http://127.0.0.1:9997".
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// do sends a request to endpoint and decodes a JSON answer into out, when
+// out is not nil.
+func (c *Client) do(ctx context.Context, method, endpoint string, query url.Values, in, out any) error {
+	target := c.baseURL + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return &ConnError{URL: target, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(method, endpoint, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// newAPIError reads the error of a non-2xx response.
+func newAPIError(method, endpoint string, resp *http.Response) *APIError {
+	apiErr := &APIError{Method: method, Endpoint: endpoint, StatusCode: resp.StatusCode}
+	var errBody struct {
+		Error string `json:"error"`
+	}
+	if b, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && json.Unmarshal(b, &errBody) == nil {
+		apiErr.Message = errBody.Error
+	}
+	return apiErr
+}
+
+// authorize adds the configured credentials to req.
+func (c *Client) authorize(req *http.Request) {
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.pass)
+	}
+}
+
+func (c *Client) get(ctx context.Context, endpoint string, out any) error {
+	return c.do(ctx, http.MethodGet, endpoint, nil, nil, out)
+}
+
+// page is the envelope of every */list endpoint.
+type page[T any] struct {
+	PageCount int `json:"pageCount"`
+	ItemCount int `json:"itemCount"`
+	Items     []T `json:"items"`
+}
+
+// listAll fetches every page of a */list endpoint.
+func listAll[T any](ctx context.Context, c *Client, endpoint string) ([]T, error) {
+	items := []T{}
+	for n := 0; ; n++ {
+		var p page[T]
+		if err := c.do(ctx, http.MethodGet, endpoint, url.Values{"page": {strconv.Itoa(n)}}, nil, &p); err != nil {
+			return nil, err
+		}
+		items = append(items, p.Items...)
+		if n+1 >= p.PageCount {
+			return items, nil
+		}
+	}
+}
+
+type Info struct {
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+}
+
+// Info returns the running release. /v3/info is missing on older releases.
+func (c *Client) Info(ctx context.Context) (Info, error) {
+	var info Info
+	err := c.get(ctx, "/v3/info", &info)
+	return info, err
+}
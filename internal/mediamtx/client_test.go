@@ -0,0 +1,260 @@
+package mediamtx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx/mediamtxtest"
+)
+
+func newFakeAPI(t *testing.T) (*mediamtxtest.Server, *Client) {
+	t.Helper()
+	fake := mediamtxtest.Sample("cam")
+	api := httptest.NewServer(fake)
+	t.Cleanup(api.Close)
+	return fake, NewClient(api.URL + "/")
+}
+
+func TestClientPaths(t *testing.T) {
+	fake, client := newFakeAPI(t)
+	fake.ItemsPerPage = 1
+	fake.SetPath(mediamtxtest.Path{Name: "back", ConfName: "back"})
+	ctx := context.Background()
+
+	paths, err := client.Paths(ctx)
+	if err != nil {
+		t.Fatalf("paths: %v", err)
+	}
+	if len(paths) != 2 || paths[0].Name != "back" || paths[1].Name != "cam" {
+		t.Fatalf("expected both pages of paths, got %+v", paths)
+	}
+	cam := paths[1]
+	if !cam.Ready || cam.Source == nil || cam.Source.Type != "rpiCameraSource" || len(cam.Readers) != 2 || cam.ReadyTime == nil {
+		t.Fatalf("unexpected path: %+v", cam)
+	}
+
+	status, err := client.PathStatus(ctx, "cam")
+	if err != nil || status.Readers != 2 || status.Tracks != 1 {
+		t.Fatalf("unexpected path status: %+v %v", status, err)
+	}
+	_, err = client.Path(ctx, "missing")
+	var apiErr *APIError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) || apiErr.Message != "path not found" {
+		t.Fatalf("expected a not found API error, got %v", err)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	fake, client := newFakeAPI(t)
+	ctx := context.Background()
+
+	global, err := client.GlobalConf(ctx)
+	if err != nil || global["rtspAddress"] != ":8554" {
+		t.Fatalf("unexpected global conf: %v %v", global, err)
+	}
+	if err := client.PatchGlobalConf(ctx, Conf{"logLevel": "debug"}); err != nil {
+		t.Fatalf("patch global: %v", err)
+	}
+	if fake.GlobalConf()["logLevel"] != "debug" {
+		t.Fatalf("expected the global patch applied")
+	}
+
+	defaults, err := client.PathDefaults(ctx)
+	if err != nil || defaults["rpiCameraBitrate"] != json.Number("1000000") {
+		t.Fatalf("unexpected path defaults: %v %v", defaults["rpiCameraBitrate"], err)
+	}
+	if err := client.PatchPathDefaults(ctx, Conf{"record": true}); err != nil {
+		t.Fatalf("patch path defaults: %v", err)
+	}
+
+	confs, err := client.PathConfs(ctx)
+	if err != nil || len(confs) != 1 || confs[0]["name"] != "cam" || confs[0]["record"] != true {
+		t.Fatalf("unexpected path confs: %v %v", confs, err)
+	}
+	if err := client.PatchPathConf(ctx, "cam", Conf{"rpiCameraBitrate": 4000000}); err != nil {
+		t.Fatalf("patch path: %v", err)
+	}
+	conf, err := client.PathConf(ctx, "cam")
+	if err != nil || conf["rpiCameraBitrate"] != json.Number("4000000") || conf["source"] != "rpiCamera" {
+		t.Fatalf("unexpected path conf: %v %v", conf, err)
+	}
+
+	err = client.PatchPathConf(ctx, "cam", Conf{"rpiCameraBogus": 1})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("expected a bad request for an unknown field, got %v", err)
+	}
+	if err := client.PatchPathConf(ctx, "missing", Conf{"record": true}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found for an unknown path, got %v", err)
+	}
+}
+
+func TestClientSessions(t *testing.T) {
+	fake, client := newFakeAPI(t)
+	ctx := context.Background()
+	now := time.Now()
+	fake.AddSession("rtmpconns", mediamtxtest.Session{ID: "rtmp-1", Created: now, State: "read", Path: "cam"})
+	fake.AddSession("srtconns", mediamtxtest.Session{ID: "srt-1", Created: now, State: "publish", Path: "cam"})
+
+	conns, err := client.RTSPConns(ctx)
+	if err != nil || len(conns) != 1 {
+		t.Fatalf("unexpected RTSP conns: %+v %v", conns, err)
+	}
+	sessions, err := client.RTSPSessions(ctx)
+	if err != nil || len(sessions) != 1 || sessions[0].State != "read" || sessions[0].Path != "cam" {
+		t.Fatalf("unexpected RTSP sessions: %+v %v", sessions, err)
+	}
+	if _, err := client.RTSPSConns(ctx); err != nil {
+		t.Fatalf("RTSPS conns: %v", err)
+	}
+	if _, err := client.RTSPSSessions(ctx); err != nil {
+		t.Fatalf("RTSPS sessions: %v", err)
+	}
+	if rtmp, err := client.RTMPConns(ctx); err != nil || len(rtmp) != 1 {
+		t.Fatalf("unexpected RTMP conns: %+v %v", rtmp, err)
+	}
+	if _, err := client.RTMPSConns(ctx); err != nil {
+		t.Fatalf("RTMPS conns: %v", err)
+	}
+	webrtc, err := client.WebRTCSessions(ctx)
+	if err != nil || len(webrtc) != 1 {
+		t.Fatalf("unexpected WebRTC sessions: %+v %v", webrtc, err)
+	}
+	if srt, err := client.SRTConns(ctx); err != nil || len(srt) != 1 || srt[0].State != "publish" {
+		t.Fatalf("unexpected SRT conns: %+v %v", srt, err)
+	}
+	if muxers, err := client.HLSMuxers(ctx); err != nil || len(muxers) != 1 || muxers[0].Path != "cam" {
+		t.Fatalf("unexpected HLS muxers: %+v %v", muxers, err)
+	}
+
+	if err := client.KickWebRTCSession(ctx, webrtc[0].ID); err != nil {
+		t.Fatalf("kick: %v", err)
+	}
+	if err := client.KickRTMPConn(ctx, "rtmp-1"); err != nil {
+		t.Fatalf("kick: %v", err)
+	}
+	if err := client.KickSRTConn(ctx, "srt-1"); err != nil {
+		t.Fatalf("kick: %v", err)
+	}
+	if err := client.KickRTSPSession(ctx, webrtc[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found for a kicked session, got %v", err)
+	}
+	if err := client.KickRTSPSSession(ctx, "none"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := client.KickRTMPSConn(ctx, "none"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	path, err := client.Path(ctx, "cam")
+	if err != nil || len(path.Readers) != 1 || path.Readers[0].Type != "rtspSession" {
+		t.Fatalf("expected only the RTSP reader left, got %+v %v", path.Readers, err)
+	}
+}
+
+func TestClientRecordings(t *testing.T) {
+	fake, client := newFakeAPI(t)
+	ctx := context.Background()
+
+	recordings, err := client.Recordings(ctx)
+	if err != nil || len(recordings) != 1 || recordings[0].Name != "cam" || len(recordings[0].Segments) != 24 {
+		t.Fatalf("unexpected recordings: %+v %v", recordings, err)
+	}
+	rec, err := client.Recording(ctx, "cam")
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	first := rec.Segments[0].Start
+	if err := client.DeleteRecordingSegment(ctx, "cam", first); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := fake.Recordings("cam"); len(got) != 23 || got[0].Equal(first) {
+		t.Fatalf("expected the oldest segment deleted, got %d segments", len(got))
+	}
+	if err := client.DeleteRecordingSegment(ctx, "cam", first); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found for a deleted segment, got %v", err)
+	}
+	if _, err := client.Recording(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestClientAuth(t *testing.T) {
+	fake := mediamtxtest.Sample("cam")
+	fake.User, fake.Pass, fake.Token = "admin", "secret", "jwt"
+	api := httptest.NewServer(fake)
+	defer api.Close()
+	ctx := context.Background()
+
+	if _, err := NewClient(api.URL).Paths(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected unauthorized without credentials, got %v", err)
+	}
+	if _, err := NewClient(api.URL, WithBasicAuth("admin", "wrong")).Paths(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected unauthorized with a wrong password, got %v", err)
+	}
+	if _, err := NewClient(api.URL, WithBasicAuth("admin", "secret")).Paths(ctx); err != nil {
+		t.Fatalf("basic auth: %v", err)
+	}
+	if _, err := NewClient(api.URL, WithBearerToken("jwt")).Paths(ctx); err != nil {
+		t.Fatalf("bearer token: %v", err)
+	}
+}
+
+func TestClientConnectionRefused(t *testing.T) {
+	api := httptest.NewServer(mediamtxtest.New())
+	url := api.URL
+	api.Close()
+
+	_, err := NewClient(url, WithTimeout(time.Second)).Info(context.Background())
+	var connErr *ConnError
+	if !errors.Is(err, ErrUnreachable) || !errors.As(err, &connErr) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected a connection refused error, got %v", err)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Fatalf("a refused connection is not a 404")
+	}
+}
+
+func TestPlaybackGet(t *testing.T) {
+	var gotQuery url.Values
+	var gotUser string
+	playback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		gotUser, _, _ = r.BasicAuth()
+		if gotQuery.Get("path") != "cam" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"no recordings found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("mp4"))
+	}))
+	t.Cleanup(playback.Close)
+	client := NewClient("http://127.0.0.1:9997", WithBasicAuth("ui", "secret")).Playback(playback.URL)
+	start := time.Date(2024, 6, 11, 8, 0, 0, 0, time.UTC)
+
+	resp, err := client.Get(context.Background(), "cam", start, 90*time.Second)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "mp4" || gotUser != "ui" {
+		t.Fatalf("unexpected response %q for user %q", body, gotUser)
+	}
+	if gotQuery.Get("start") != "2024-06-11T08:00:00Z" || gotQuery.Get("duration") != "90" || gotQuery.Get("format") != "mp4" {
+		t.Fatalf("unexpected query %v", gotQuery)
+	}
+
+	_, err = client.Get(context.Background(), "garden", start, time.Minute)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrNotFound) || apiErr.Message != "no recordings found" {
+		t.Fatalf("expected a not found APIError, got %v", err)
+	}
+}
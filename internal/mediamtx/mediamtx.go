@@ -2,77 +2,116 @@ package mediamtx
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
-	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
 )
 
+// APIState is the outcome of querying the Control API.
+type APIState string
+
+const (
+	APIOK          APIState = "ok"
+	APIUnavailable APIState = "unavailable"
+)
+
+// Status is the state of the service and its paths. Pointer fields are nil,
+// null in JSON, when the value could not be read: ServiceStatus when
+// systemctl failed, APIStatus when no path was queried.
 type Status struct {
-	ServiceStatus string
-	APIStatus     string
-	PathName      string
-	PathReady     *bool
-	SourceType    string
-	Readers       *int
-	Tracks        *int
+	ServiceStatus *string     `json:"serviceStatus"`
+	APIStatus     *APIState   `json:"apiStatus"`
+	Version       string      `json:"version"`
+	PathName      string      `json:"pathName"`
+	PathReady     *bool       `json:"pathReady"`
+	SourceType    *string     `json:"sourceType"`
+	Readers       *int        `json:"readers"`
+	Tracks        *int        `json:"tracks"`
+	Paths         []PathState `json:"paths"`
 }
 
-type pathResponse struct {
-	Name    string       `json:"name"`
-	Ready   bool         `json:"ready"`
-	Source  *pathSource  `json:"source"`
-	Readers []pathReader `json:"readers"`
-	Tracks  []string     `json:"tracks"`
+// ServiceActive reports whether systemctl reported the unit active.
+func (s Status) ServiceActive() bool {
+	return s.ServiceStatus != nil && *s.ServiceStatus == "active"
 }
 
-type pathSource struct {
-	Type string `json:"type"`
+// APIUp reports whether the Control API answered.
+func (s Status) APIUp() bool {
+	return s.APIStatus != nil && *s.APIStatus == APIOK
 }
 
-type pathReader struct {
-	Type string `json:"type"`
+// PathState is the API view of one path. Pointer fields are nil when the
+// path could not be queried.
+type PathState struct {
+	Name       string  `json:"name"`
+	Ready      *bool   `json:"ready"`
+	SourceType *string `json:"sourceType"`
+	Readers    *int    `json:"readers"`
+	Tracks     *int    `json:"tracks"`
 }
 
-func Collect(ctx context.Context, baseURL, pathName string) (Status, []string) {
-	status := Status{
-		ServiceStatus: "unknown",
-		APIStatus:     "unknown",
-		PathName:      pathName,
-		SourceType:    "unknown",
+// Collect queries the service and every named path. The first path fills
+// the flat Path* fields so single-camera consumers keep working.
+func Collect(ctx context.Context, runner host.Runner, client *Client, pathNames ...string) (Status, []string) {
+	status := Status{Paths: []PathState{}}
+	if len(pathNames) > 0 {
+		status.PathName = pathNames[0]
 	}
 	var warnings []string
 
-	if svc, err := ServiceStatus(ctx); err != nil {
+	if svc, err := ServiceStatus(ctx, runner); err != nil {
 		warnings = append(warnings, fmt.Sprintf("MediaMTX service status unavailable: %v", err))
 	} else {
-		status.ServiceStatus = svc
+		status.ServiceStatus = &svc
 	}
 
-	if pathName != "" {
-		path, err := GetPathStatus(ctx, baseURL, pathName)
+	for i, pathName := range pathNames {
+		if pathName == "" {
+			continue
+		}
+		state := PathState{Name: pathName}
+		path, err := client.PathStatus(ctx, pathName)
 		if err != nil {
 			warnings = append(warnings, fmt.Sprintf("MediaMTX API unavailable: %v", err))
-			status.APIStatus = "unavailable"
+			if !status.APIUp() {
+				status.APIStatus = apiState(APIUnavailable)
+			}
 		} else {
-			status.APIStatus = "ok"
-			status.PathReady = &path.Ready
-			status.SourceType = path.SourceType
-			status.Readers = &path.Readers
-			status.Tracks = &path.Tracks
+			status.APIStatus = apiState(APIOK)
+			state.Ready = &path.Ready
+			state.SourceType = &path.SourceType
+			state.Readers = &path.Readers
+			state.Tracks = &path.Tracks
+		}
+		status.Paths = append(status.Paths, state)
+		if i == 0 {
+			status.PathReady = state.Ready
+			status.SourceType = state.SourceType
+			status.Readers = state.Readers
+			status.Tracks = state.Tracks
+		}
+	}
+
+	// /v3/info is missing on older releases, so a failure only leaves the
+	// version unknown.
+	if status.APIUp() {
+		if info, err := client.Info(ctx); err == nil {
+			status.Version = info.Version
 		}
 	}
 
 	return status, warnings
 }
 
-func ServiceStatus(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "systemctl", "is-active", "mediamtx")
-	out, err := cmd.CombinedOutput()
+func apiState(state APIState) *APIState {
+	return &state
+}
+
+func ServiceStatus(ctx context.Context, runner host.Runner) (string, error) {
+	out, err := runner.CombinedOutput(ctx, "systemctl", "is-active", ServiceUnit)
 	status := strings.TrimSpace(string(out))
 	if err != nil {
 		if status != "" {
@@ -86,6 +125,48 @@ func ServiceStatus(ctx context.Context) (string, error) {
 	return status, nil
 }
 
+// WaitPathReady waits settle for MediaMTX to pick up a config change, then
+// polls every interval until the service is active and pathName is ready.
+// When ctx is done it returns the last problem seen.
+func WaitPathReady(ctx context.Context, runner host.Runner, client *Client, pathName string, settle, interval time.Duration) error {
+	timer := time.NewTimer(settle)
+	defer timer.Stop()
+
+	lastErr := errors.New("not checked")
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+
+		lastErr = CheckPathReady(ctx, runner, client, pathName)
+		if lastErr == nil {
+			return nil
+		}
+		timer.Reset(interval)
+	}
+}
+
+// CheckPathReady returns nil when the service is active and pathName is ready.
+func CheckPathReady(ctx context.Context, runner host.Runner, client *Client, pathName string) error {
+	svc, err := ServiceStatus(ctx, runner)
+	if err != nil {
+		return fmt.Errorf("service status: %w", err)
+	}
+	if svc != "active" {
+		return fmt.Errorf("service is %s", svc)
+	}
+	path, err := client.PathStatus(ctx, pathName)
+	if err != nil {
+		return err
+	}
+	if !path.Ready {
+		return fmt.Errorf("path %q is not ready", pathName)
+	}
+	return nil
+}
+
 type PathStatus struct {
 	Ready      bool
 	SourceType string
@@ -93,41 +174,23 @@ type PathStatus struct {
 	Tracks     int
 }
 
-func GetPathStatus(ctx context.Context, baseURL, pathName string) (PathStatus, error) {
-	client := &http.Client{Timeout: 2 * time.Second}
-	escapedPathName := url.PathEscape(pathName)
-	url := strings.TrimRight(baseURL, "/") + "/v3/paths/get/" + escapedPathName
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return PathStatus{}, err
+// PathStatus summarizes the runtime state of pathName.
+func (c *Client) PathStatus(ctx context.Context, pathName string) (PathStatus, error) {
+	path, err := c.Path(ctx, pathName)
+	if errors.Is(err, ErrNotFound) {
+		return PathStatus{}, fmt.Errorf("path %q not found: %w", pathName, err)
 	}
-
-	resp, err := client.Do(req)
 	if err != nil {
 		return PathStatus{}, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
-		return PathStatus{}, fmt.Errorf("path %q not found", pathName)
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return PathStatus{}, fmt.Errorf("unexpected status %s", resp.Status)
-	}
-
-	var pr pathResponse
-	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-		return PathStatus{}, err
-	}
 
 	status := PathStatus{
-		Ready:   pr.Ready,
-		Readers: len(pr.Readers),
-		Tracks:  len(pr.Tracks),
+		Ready:   path.Ready,
+		Readers: len(path.Readers),
+		Tracks:  len(path.Tracks),
 	}
-	if pr.Source != nil && pr.Source.Type != "" {
-		status.SourceType = pr.Source.Type
+	if path.Source != nil && path.Source.Type != "" {
+		status.SourceType = path.Source.Type
 	} else {
 		status.SourceType = "none"
 	}
@@ -3,9 +3,15 @@ package mediamtx
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
 )
 
 func TestGetPathStatus(t *testing.T) {
@@ -14,20 +20,20 @@ func TestGetPathStatus(t *testing.T) {
 			http.NotFound(w, r)
 			return
 		}
-		resp := pathResponse{
+		resp := Path{
 			Name:  "cam",
 			Ready: true,
-			Source: &pathSource{
+			Source: &PathSource{
 				Type: "rpiCameraSource",
 			},
-			Readers: []pathReader{{Type: "rtspSession"}},
+			Readers: []PathReader{{Type: "rtspSession"}},
 			Tracks:  []string{"video"},
 		}
 		_ = json.NewEncoder(w).Encode(resp)
 	}))
 	defer server.Close()
 
-	status, err := GetPathStatus(context.Background(), server.URL, "cam")
+	status, err := NewClient(server.URL).PathStatus(context.Background(), "cam")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -51,7 +57,7 @@ func TestGetPathStatusNotFound(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := GetPathStatus(context.Background(), server.URL, "cam")
+	_, err := NewClient(server.URL).PathStatus(context.Background(), "cam")
 	if err == nil {
 		t.Fatalf("expected not found error")
 	}
@@ -63,7 +69,7 @@ func TestGetPathStatusBadStatus(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := GetPathStatus(context.Background(), server.URL, "cam")
+	_, err := NewClient(server.URL).PathStatus(context.Background(), "cam")
 	if err == nil {
 		t.Fatalf("expected error for 500 status")
 	}
@@ -75,7 +81,7 @@ func TestGetPathStatusNoSource(t *testing.T) {
 			http.NotFound(w, r)
 			return
 		}
-		resp := pathResponse{
+		resp := Path{
 			Name:    "cam",
 			Ready:   false,
 			Source:  nil,
@@ -86,7 +92,7 @@ func TestGetPathStatusNoSource(t *testing.T) {
 	}))
 	defer server.Close()
 
-	status, err := GetPathStatus(context.Background(), server.URL, "cam")
+	status, err := NewClient(server.URL).PathStatus(context.Background(), "cam")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -94,3 +100,135 @@ func TestGetPathStatusNoSource(t *testing.T) {
 		t.Fatalf("expected source type none")
 	}
 }
+
+func TestCollectSyntheticPi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Path{
+			Name:    "cam",
+			Ready:   true,
+			Source:  &PathSource{Type: "rpiCameraSource"},
+			Readers: []PathReader{},
+			Tracks:  []string{"H264"},
+		})
+	}))
+	defer server.Close()
+
+	status, warnings := Collect(context.Background(), hosttest.SyntheticPi().Runner, NewClient(server.URL), "cam")
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if !status.ServiceActive() || !status.APIUp() {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.PathReady == nil || !*status.PathReady || status.Tracks == nil || *status.Tracks != 1 {
+		t.Fatalf("unexpected path status: %+v", status)
+	}
+}
+
+func TestCollectMultiplePaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/paths/get/front" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Path{
+			Name:    "front",
+			Ready:   true,
+			Source:  &PathSource{Type: "rpiCameraSource"},
+			Readers: []PathReader{{Type: "webRTCSession"}},
+			Tracks:  []string{"H264"},
+		})
+	}))
+	defer server.Close()
+
+	status, warnings := Collect(context.Background(), hosttest.SyntheticPi().Runner, NewClient(server.URL), "front", "back")
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning for the missing path, got %v", warnings)
+	}
+	if !status.APIUp() || status.PathName != "front" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if len(status.Paths) != 2 {
+		t.Fatalf("expected two paths, got %+v", status.Paths)
+	}
+	front, back := status.Paths[0], status.Paths[1]
+	if front.Readers == nil || *front.Readers != 1 || status.Readers != front.Readers {
+		t.Fatalf("unexpected front path: %+v", front)
+	}
+	if back.Name != "back" || back.Ready != nil || back.SourceType != nil {
+		t.Fatalf("unexpected back path: %+v", back)
+	}
+}
+
+func TestCollectUnknownIsNull(t *testing.T) {
+	runner := hosttest.FixtureRunner{Dir: t.TempDir()}
+	status, warnings := Collect(context.Background(), runner, NewClient("http://127.0.0.1:1"))
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning for the service status, got %v", warnings)
+	}
+	out, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, field := range []string{`"serviceStatus":null`, `"apiStatus":null`, `"sourceType":null`} {
+		if !strings.Contains(string(out), field) {
+			t.Errorf("expected %s in %s", field, out)
+		}
+	}
+}
+
+func TestWaitPathReady(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/paths/get/cam" {
+			http.NotFound(w, r)
+			return
+		}
+		ready := calls.Add(1) >= 3
+		_ = json.NewEncoder(w).Encode(Path{Name: "cam", Ready: ready})
+	}))
+	defer server.Close()
+	runner := hosttest.SyntheticPi().Runner
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := WaitPathReady(ctx, runner, NewClient(server.URL), "cam", 0, time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected polling until ready, got %d calls", calls.Load())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := WaitPathReady(ctx, runner, NewClient(server.URL), "missing", 0, 5*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected deadline error with last problem, got %v", err)
+	}
+}
+
+func TestServiceStatusMissingSystemctl(t *testing.T) {
+	runner := hosttest.FixtureRunner{Dir: t.TempDir()}
+	if _, err := ServiceStatus(context.Background(), runner); err == nil {
+		t.Fatalf("expected error without systemctl")
+	}
+}
+
+func TestInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/info" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":"v1.9.0","started":"2024-06-10T08:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	info, err := NewClient(server.URL).Info(context.Background())
+	if err != nil || info.Version != "v1.9.0" {
+		t.Fatalf("unexpected version: %q %v", info.Version, err)
+	}
+	if _, err := NewClient(server.URL + "/missing").Info(context.Background()); err == nil {
+		t.Fatalf("expected error for missing endpoint")
+	}
+}
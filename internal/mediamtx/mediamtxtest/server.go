@@ -0,0 +1,598 @@
+// Package mediamtxtest provides a fake MediaMTX Control API (v3) for tests
+// and local UI development. It keeps paths, configuration, sessions and
+// recordings in memory and answers the endpoints the mediamtx client uses.
+package mediamtxtest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+// Path is the runtime state of a path as /v3/paths reports it.
+type Path struct {
+	Name          string     `json:"name"`
+	ConfName      string     `json:"confName"`
+	Source        *Peer      `json:"source"`
+	Ready         bool       `json:"ready"`
+	ReadyTime     *time.Time `json:"readyTime"`
+	Tracks        []string   `json:"tracks"`
+	BytesReceived uint64     `json:"bytesReceived"`
+	BytesSent     uint64     `json:"bytesSent"`
+	Readers       []Peer     `json:"readers"`
+}
+
+// Peer is the source or a reader of a path.
+type Peer struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Session is a connection or session of any protocol. Fields a protocol
+// does not have are reported anyway; clients ignore them.
+type Session struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	UserAgent     string    `json:"userAgent"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+type HLSMuxer struct {
+	Path        string    `json:"path"`
+	Created     time.Time `json:"created"`
+	LastRequest time.Time `json:"lastRequest"`
+	BytesSent   uint64    `json:"bytesSent"`
+}
+
+// sessionKinds maps each session endpoint to the reader type a reading
+// session shows up as on its path. Connections that only carry a session
+// have no reader type.
+var sessionKinds = map[string]string{
+	"rtspconns":      "",
+	"rtspsessions":   "rtspSession",
+	"rtspsconns":     "",
+	"rtspssessions":  "rtspsSession",
+	"rtmpconns":      "rtmpConn",
+	"rtmpsconns":     "rtmpsConn",
+	"webrtcsessions": "webRTCSession",
+	"srtconns":       "srtConn",
+}
+
+// kickable lists the session endpoints that accept kick.
+var kickable = map[string]bool{
+	"rtspsessions":   true,
+	"rtspssessions":  true,
+	"rtmpconns":      true,
+	"rtmpsconns":     true,
+	"webrtcsessions": true,
+	"srtconns":       true,
+}
+
+// Server is an http.Handler serving the fake API. Set User and Pass to
+// require basic auth, or Token to require a bearer token.
+type Server struct {
+	User    string
+	Pass    string
+	Token   string
+	Version string
+	// ItemsPerPage is the default page size of list endpoints.
+	ItemsPerPage int
+
+	mu           sync.Mutex
+	paths        map[string]Path
+	global       map[string]any
+	pathDefaults map[string]any
+	pathConfs    map[string]map[string]any
+	sessions     map[string][]Session
+	hlsMuxers    []HLSMuxer
+	recordings   map[string][]time.Time
+
+	mux *http.ServeMux
+}
+
+// New returns a server with default configuration and no paths.
+func New() *Server {
+	s := &Server{
+		Version:      "v1.9.0",
+		ItemsPerPage: 100,
+		paths:        map[string]Path{},
+		global: map[string]any{
+			"logLevel":      "info",
+			"api":           true,
+			"apiAddress":    ":9997",
+			"rtsp":          true,
+			"rtspAddress":   ":8554",
+			"rtmp":          true,
+			"rtmpAddress":   ":1935",
+			"hls":           true,
+			"hlsAddress":    ":8888",
+			"webrtc":        true,
+			"webrtcAddress": ":8889",
+			"srt":           true,
+			"srtAddress":    ":8890",
+		},
+		pathDefaults: defaultPathConf(),
+		pathConfs:    map[string]map[string]any{},
+		sessions:     map[string][]Session{},
+		recordings:   map[string][]time.Time{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v3/info", s.handleInfo)
+	mux.HandleFunc("GET /v3/paths/list", s.handlePathsList)
+	mux.HandleFunc("GET /v3/paths/get/{name...}", s.handlePathsGet)
+	mux.HandleFunc("GET /v3/config/global/get", s.handleConfGet(func() map[string]any { return s.global }))
+	mux.HandleFunc("PATCH /v3/config/global/patch", s.handleConfPatch(func() map[string]any { return s.global }))
+	mux.HandleFunc("GET /v3/config/pathdefaults/get", s.handleConfGet(func() map[string]any { return s.pathDefaults }))
+	mux.HandleFunc("PATCH /v3/config/pathdefaults/patch", s.handleConfPatch(func() map[string]any { return s.pathDefaults }))
+	mux.HandleFunc("GET /v3/config/paths/list", s.handlePathConfsList)
+	mux.HandleFunc("GET /v3/config/paths/get/{name...}", s.handlePathConfGet)
+	mux.HandleFunc("PATCH /v3/config/paths/patch/{name...}", s.handlePathConfPatch)
+	for kind := range sessionKinds {
+		mux.HandleFunc("GET /v3/"+kind+"/list", s.handleSessionsList(kind))
+		if kickable[kind] {
+			mux.HandleFunc("POST /v3/"+kind+"/kick/{id}", s.handleSessionKick(kind))
+		}
+	}
+	mux.HandleFunc("GET /v3/hlsmuxers/list", s.handleHLSMuxersList)
+	mux.HandleFunc("GET /v3/recordings/list", s.handleRecordingsList)
+	mux.HandleFunc("GET /v3/recordings/get/{name...}", s.handleRecordingsGet)
+	mux.HandleFunc("DELETE /v3/recordings/deletesegment", s.handleRecordingsDelete)
+	s.mux = mux
+	return s
+}
+
+// Sample returns a server with a ready rpiCamera path named pathName, a
+// viewer on RTSP and on WebRTC, an HLS muxer and a day of hourly
+// recording segments.
+func Sample(pathName string) *Server {
+	s := New()
+	now := time.Now().UTC().Truncate(time.Second)
+	s.SetPathConf(pathName, map[string]any{"source": "rpiCamera"})
+	s.SetPath(Path{
+		Name:      pathName,
+		ConfName:  pathName,
+		Source:    &Peer{Type: "rpiCameraSource"},
+		Ready:     true,
+		ReadyTime: &now,
+		Tracks:    []string{"H264"},
+	})
+	s.AddSession("rtspconns", Session{ID: "7c1d2e3f-0000-4000-8000-000000000001", Created: now, RemoteAddr: "192.168.1.20:51234"})
+	s.AddSession("rtspsessions", Session{ID: "7c1d2e3f-0000-4000-8000-000000000002", Created: now, RemoteAddr: "192.168.1.20:51234", State: "read", Path: pathName, UserAgent: "VLC/3.0.20 LibVLC/3.0.20", BytesSent: 48 << 20})
+	s.AddSession("webrtcsessions", Session{ID: "7c1d2e3f-0000-4000-8000-000000000003", Created: now, RemoteAddr: "192.168.1.21:60112", State: "read", Path: pathName, UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0", BytesSent: 3 << 20})
+	s.AddHLSMuxer(HLSMuxer{Path: pathName, Created: now, LastRequest: now, BytesSent: 12 << 20})
+	for i := 24; i > 0; i-- {
+		s.AddRecording(pathName, now.Truncate(time.Hour).Add(-time.Duration(i)*time.Hour))
+	}
+	return s
+}
+
+// defaultPathConf builds the path defaults from the camera and recording
+// schemas.
+func defaultPathConf() map[string]any {
+	conf := map[string]any{"source": "publisher"}
+	for _, p := range append(append([]config.Param(nil), config.CameraSchema...), config.RecordSchema...) {
+		switch p.Type {
+		case config.ParamBool:
+			conf[p.Key] = p.Default == "true"
+		case config.ParamInt:
+			v, _ := strconv.Atoi(p.Default)
+			conf[p.Key] = v
+		case config.ParamFloat:
+			v, _ := strconv.ParseFloat(p.Default, 64)
+			conf[p.Key] = v
+		case config.ParamDuration:
+			// The Control API reports durations as Go formats them.
+			d, _ := config.ParseDuration(p.Default)
+			conf[p.Key] = d.String()
+		default:
+			conf[p.Key] = p.Default
+		}
+	}
+	return conf
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "authentication error")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.User == "" && s.Token == "" {
+		return true
+	}
+	if s.Token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.Token)) == 1 {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && s.User != "" && user == s.User && pass == s.Pass
+}
+
+// SetPath adds or replaces the runtime state of a path.
+func (s *Server) SetPath(p Path) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.Readers == nil {
+		p.Readers = []Peer{}
+	}
+	if p.Tracks == nil {
+		p.Tracks = []string{}
+	}
+	s.paths[p.Name] = p
+}
+
+func (s *Server) RemovePath(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.paths, name)
+}
+
+// SetPathConf adds or replaces the configuration of a path. Keys left out
+// take the path defaults.
+func (s *Server) SetPathConf(name string, conf map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := map[string]any{}
+	for k, v := range conf {
+		copied[k] = v
+	}
+	s.pathConfs[name] = copied
+}
+
+// PathConf returns the effective configuration of a path, or nil.
+func (s *Server) PathConf(name string) map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pathConf(name)
+}
+
+// GlobalConf returns a copy of the global configuration.
+func (s *Server) GlobalConf() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyConf(s.global)
+}
+
+// AddSession adds a session to the list endpoint kind, such as
+// "rtspsessions". A reading session on a known path is added to the
+// path's readers too.
+func (s *Server) AddSession(kind string, sess Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[kind] = append(s.sessions[kind], sess)
+	readerType := sessionKinds[kind]
+	if path, ok := s.paths[sess.Path]; ok && readerType != "" && sess.State == "read" {
+		path.Readers = append(path.Readers, Peer{Type: readerType, ID: sess.ID})
+		s.paths[sess.Path] = path
+	}
+}
+
+// Sessions returns the sessions listed under kind.
+func (s *Server) Sessions(kind string) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Session(nil), s.sessions[kind]...)
+}
+
+func (s *Server) AddHLSMuxer(m HLSMuxer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hlsMuxers = append(s.hlsMuxers, m)
+}
+
+// AddRecording adds a recording segment of path starting at start.
+func (s *Server) AddRecording(path string, start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordings[path] = append(s.recordings[path], start)
+	sort.Slice(s.recordings[path], func(i, j int) bool { return s.recordings[path][i].Before(s.recordings[path][j]) })
+}
+
+// Recordings returns the segment start times of path, oldest first.
+func (s *Server) Recordings(path string) []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.recordings[path]...)
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"version": s.Version, "started": time.Now().UTC().Truncate(time.Second)})
+}
+
+func (s *Server) handlePathsList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.paths))
+	for name := range s.paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]Path, 0, len(names))
+	for _, name := range names {
+		items = append(items, s.paths[name])
+	}
+	writeList(w, r, s.ItemsPerPage, items)
+}
+
+func (s *Server) handlePathsGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, ok := s.paths[r.PathValue("name")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "path not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, path)
+}
+
+func (s *Server) handleConfGet(conf func() map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, http.StatusOK, conf())
+	}
+}
+
+func (s *Server) handleConfPatch(conf func() map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		patch, err := decodePatch(r, conf())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for k, v := range patch {
+			conf()[k] = v
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) handlePathConfsList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.pathConfs))
+	for name := range s.pathConfs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		items = append(items, s.pathConf(name))
+	}
+	writeList(w, r, s.ItemsPerPage, items)
+}
+
+func (s *Server) handlePathConfGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conf := s.pathConf(r.PathValue("name"))
+	if conf == nil {
+		writeError(w, http.StatusNotFound, "path configuration not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, conf)
+}
+
+func (s *Server) handlePathConfPatch(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.PathValue("name")
+	conf, ok := s.pathConfs[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "path configuration not found")
+		return
+	}
+	patch, err := decodePatch(r, s.pathDefaults)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for k, v := range patch {
+		conf[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSessionsList(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeList(w, r, s.ItemsPerPage, append([]Session{}, s.sessions[kind]...))
+	}
+}
+
+func (s *Server) handleSessionKick(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := r.PathValue("id")
+		sessions := s.sessions[kind]
+		for i, sess := range sessions {
+			if sess.ID != id {
+				continue
+			}
+			s.sessions[kind] = append(sessions[:i:i], sessions[i+1:]...)
+			if path, ok := s.paths[sess.Path]; ok {
+				readers := []Peer{}
+				for _, reader := range path.Readers {
+					if reader.ID != id {
+						readers = append(readers, reader)
+					}
+				}
+				path.Readers = readers
+				s.paths[sess.Path] = path
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		writeError(w, http.StatusNotFound, "session not found")
+	}
+}
+
+func (s *Server) handleHLSMuxersList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeList(w, r, s.ItemsPerPage, append([]HLSMuxer{}, s.hlsMuxers...))
+}
+
+type recording struct {
+	Name     string             `json:"name"`
+	Segments []recordingSegment `json:"segments"`
+}
+
+type recordingSegment struct {
+	Start time.Time `json:"start"`
+}
+
+func (s *Server) recording(name string) recording {
+	rec := recording{Name: name, Segments: []recordingSegment{}}
+	for _, start := range s.recordings[name] {
+		rec.Segments = append(rec.Segments, recordingSegment{Start: start})
+	}
+	return rec
+}
+
+func (s *Server) handleRecordingsList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.recordings))
+	for name, segments := range s.recordings {
+		if len(segments) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	items := make([]recording, 0, len(names))
+	for _, name := range names {
+		items = append(items, s.recording(name))
+	}
+	writeList(w, r, s.ItemsPerPage, items)
+}
+
+func (s *Server) handleRecordingsGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.PathValue("name")
+	if len(s.recordings[name]) == 0 {
+		writeError(w, http.StatusNotFound, "recordings not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.recording(name))
+}
+
+func (s *Server) handleRecordingsDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := r.URL.Query().Get("path")
+	start, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("start"))
+	if name == "" || err != nil {
+		writeError(w, http.StatusBadRequest, "invalid path or start")
+		return
+	}
+	segments := s.recordings[name]
+	for i, seg := range segments {
+		if seg.Equal(start) {
+			s.recordings[name] = append(segments[:i:i], segments[i+1:]...)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "segment not found")
+}
+
+// pathConf merges the stored config of name over the path defaults. It
+// returns nil for an unknown path. Callers hold s.mu.
+func (s *Server) pathConf(name string) map[string]any {
+	stored, ok := s.pathConfs[name]
+	if !ok {
+		return nil
+	}
+	conf := copyConf(s.pathDefaults)
+	for k, v := range stored {
+		conf[k] = v
+	}
+	conf["name"] = name
+	return conf
+}
+
+// decodePatch reads a JSON object whose keys must all exist in known, the
+// way MediaMTX rejects unknown fields.
+func decodePatch(r *http.Request, known map[string]any) (map[string]any, error) {
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return nil, err
+	}
+	for k := range patch {
+		if _, ok := known[k]; !ok {
+			return nil, fmt.Errorf("json: unknown field %q", k)
+		}
+	}
+	return patch, nil
+}
+
+func copyConf(conf map[string]any) map[string]any {
+	copied := make(map[string]any, len(conf))
+	for k, v := range conf {
+		copied[k] = v
+	}
+	return copied
+}
+
+// writeList writes one page of items in the envelope of the */list
+// endpoints, honouring the page and itemsPerPage query parameters.
+func writeList[T any](w http.ResponseWriter, r *http.Request, defaultPerPage int, items []T) {
+	perPage := defaultPerPage
+	if v := r.URL.Query().Get("itemsPerPage"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid itemsPerPage")
+			return
+		}
+		perPage = n
+	}
+	pageNum := 0
+	if v := r.URL.Query().Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid page")
+			return
+		}
+		pageNum = n
+	}
+
+	pageCount := (len(items) + perPage - 1) / perPage
+	start := min(pageNum*perPage, len(items))
+	end := min(start+perPage, len(items))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"pageCount": pageCount,
+		"itemCount": len(items),
+		"items":     items[start:end],
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
@@ -0,0 +1,89 @@
+package mediamtx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Path is the runtime state of a path, from /v3/paths.
+type Path struct {
+	Name          string       `json:"name"`
+	ConfName      string       `json:"confName"`
+	Source        *PathSource  `json:"source"`
+	Ready         bool         `json:"ready"`
+	ReadyTime     *time.Time   `json:"readyTime"`
+	Tracks        []string     `json:"tracks"`
+	BytesReceived uint64       `json:"bytesReceived"`
+	BytesSent     uint64       `json:"bytesSent"`
+	Readers       []PathReader `json:"readers"`
+}
+
+// PathSource is what publishes to a path, such as "rpiCameraSource".
+type PathSource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// PathReader is a session reading a path. ID matches the session listed
+// under its protocol, such as an "rtspSession" or a "webRTCSession".
+type PathReader struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Conf is a MediaMTX configuration object with its JSON field names, such
+// as "rpiCameraWidth". Numbers decode as json.Number so a value read and
+// patched back is unchanged.
+type Conf map[string]any
+
+func (c *Client) Paths(ctx context.Context) ([]Path, error) {
+	return listAll[Path](ctx, c, "/v3/paths/list")
+}
+
+func (c *Client) Path(ctx context.Context, name string) (Path, error) {
+	var path Path
+	err := c.get(ctx, "/v3/paths/get/"+url.PathEscape(name), &path)
+	return path, err
+}
+
+func (c *Client) GlobalConf(ctx context.Context) (Conf, error) {
+	var conf Conf
+	err := c.get(ctx, "/v3/config/global/get", &conf)
+	return conf, err
+}
+
+// PatchGlobalConf changes the given global settings. MediaMTX applies them
+// at once, without writing mediamtx.yml.
+func (c *Client) PatchGlobalConf(ctx context.Context, patch Conf) error {
+	return c.do(ctx, http.MethodPatch, "/v3/config/global/patch", nil, patch, nil)
+}
+
+func (c *Client) PathDefaults(ctx context.Context) (Conf, error) {
+	var conf Conf
+	err := c.get(ctx, "/v3/config/pathdefaults/get", &conf)
+	return conf, err
+}
+
+func (c *Client) PatchPathDefaults(ctx context.Context, patch Conf) error {
+	return c.do(ctx, http.MethodPatch, "/v3/config/pathdefaults/patch", nil, patch, nil)
+}
+
+// PathConfs returns the configuration of every configured path, with the
+// path defaults filled in.
+func (c *Client) PathConfs(ctx context.Context) ([]Conf, error) {
+	return listAll[Conf](ctx, c, "/v3/config/paths/list")
+}
+
+func (c *Client) PathConf(ctx context.Context, name string) (Conf, error) {
+	var conf Conf
+	err := c.get(ctx, "/v3/config/paths/get/"+url.PathEscape(name), &conf)
+	return conf, err
+}
+
+// PatchPathConf changes the given settings of a configured path. Like
+// PatchGlobalConf it does not write mediamtx.yml.
+func (c *Client) PatchPathConf(ctx context.Context, name string, patch Conf) error {
+	return c.do(ctx, http.MethodPatch, "/v3/config/paths/patch/"+url.PathEscape(name), nil, patch, nil)
+}
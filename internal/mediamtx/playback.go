@@ -0,0 +1,56 @@
+package mediamtx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Playback reads recordings through the MediaMTX playback server.
+type Playback struct {
+	baseURL    string
+	httpClient *http.Client
+	api        *Client
+}
+
+// Playback returns a client for the playback server at baseURL, such as
+// "http://127.0.0.1:9996", that authenticates like c. Downloads can take
+// minutes, so only the request context bounds them.
+func (c *Client) Playback(baseURL string) *Playback {
+	hc := *c.httpClient
+	hc.Timeout = 0
+	return &Playback{baseURL: strings.TrimRight(baseURL, "/"), httpClient: &hc, api: c}
+}
+
+// Get requests the recording of path from start for duration as a single
+// MP4. The caller reads and closes the body of the returned response.
+func (p *Playback) Get(ctx context.Context, path string, start time.Time, duration time.Duration) (*http.Response, error) {
+	query := url.Values{
+		"path":     {path},
+		"start":    {start.Format(time.RFC3339Nano)},
+		"duration": {strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)},
+		"format":   {"mp4"},
+	}
+	target := p.baseURL + "/get?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.api.authorize(req)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &ConnError{URL: target, Err: err}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newAPIError(http.MethodGet, "/get", resp)
+	}
+	return resp, nil
+}
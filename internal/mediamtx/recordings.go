@@ -0,0 +1,34 @@
+package mediamtx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Recording lists the segments MediaMTX recorded for one path.
+type Recording struct {
+	Name     string             `json:"name"`
+	Segments []RecordingSegment `json:"segments"`
+}
+
+type RecordingSegment struct {
+	Start time.Time `json:"start"`
+}
+
+func (c *Client) Recordings(ctx context.Context) ([]Recording, error) {
+	return listAll[Recording](ctx, c, "/v3/recordings/list")
+}
+
+func (c *Client) Recording(ctx context.Context, path string) (Recording, error) {
+	var rec Recording
+	err := c.get(ctx, "/v3/recordings/get/"+url.PathEscape(path), &rec)
+	return rec, err
+}
+
+// DeleteRecordingSegment deletes the segment of path that starts at start.
+func (c *Client) DeleteRecordingSegment(ctx context.Context, path string, start time.Time) error {
+	query := url.Values{"path": {path}, "start": {start.Format(time.RFC3339Nano)}}
+	return c.do(ctx, http.MethodDelete, "/v3/recordings/deletesegment", query, nil, nil)
+}
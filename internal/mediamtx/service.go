@@ -0,0 +1,229 @@
+package mediamtx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+// ServiceUnit is the systemd unit MediaMTX runs as.
+const ServiceUnit = "mediamtx"
+
+// Service actions accepted by ControlService.
+const (
+	ServiceStart   = "start"
+	ServiceStop    = "stop"
+	ServiceRestart = "restart"
+)
+
+// Log levels, lowest first. They match MediaMTX's DEB, INF, WAR and ERR.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// LogLevels lists the log levels, lowest first.
+var LogLevels = []string{LevelDebug, LevelInfo, LevelWarn, LevelError}
+
+var (
+	ErrUnknownAction = errors.New("unknown service action")
+	ErrUnknownLevel  = errors.New("unknown log level")
+)
+
+// Unit is the systemd view of the MediaMTX service. Pointer fields are nil
+// when systemd does not report them: Since while the unit is not active,
+// Restarts on systemd releases without NRestarts and MemoryBytes without
+// memory accounting.
+type Unit struct {
+	ActiveState string     `json:"activeState"`
+	SubState    string     `json:"subState"`
+	MainPID     int        `json:"mainPid"`
+	Since       *time.Time `json:"since"`
+	// Restarts counts restarts by systemd's Restart= policy since the unit
+	// was loaded; manual restarts are not included.
+	Restarts    *int    `json:"restarts"`
+	MemoryBytes *uint64 `json:"memoryBytes"`
+}
+
+// LogEntry is one journal line of the service. Level is parsed from the
+// MediaMTX log prefix, or from the journal priority for lines systemd
+// writes itself.
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+var unitProperties = []string{"ActiveState", "SubState", "MainPID", "ActiveEnterTimestamp", "NRestarts", "MemoryCurrent"}
+
+// ServiceUnitStatus reads the unit state with systemctl show.
+func ServiceUnitStatus(ctx context.Context, runner host.Runner) (Unit, error) {
+	out, err := runner.Output(ctx, "systemctl", "show", ServiceUnit, "--property="+strings.Join(unitProperties, ","))
+	if err != nil {
+		return Unit{}, fmt.Errorf("systemctl show: %w", err)
+	}
+	props := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[key] = value
+		}
+	}
+	if props["ActiveState"] == "" {
+		return Unit{}, errors.New("systemctl show: no unit state")
+	}
+
+	unit := Unit{ActiveState: props["ActiveState"], SubState: props["SubState"]}
+	unit.MainPID, _ = strconv.Atoi(props["MainPID"])
+	if unit.ActiveState == "active" {
+		// systemctl prints timestamps in the local zone, e.g.
+		// "Tue 2024-06-11 08:15:00 CEST".
+		if t, err := time.ParseInLocation("Mon 2006-01-02 15:04:05 MST", props["ActiveEnterTimestamp"], time.Local); err == nil {
+			unit.Since = &t
+		}
+	}
+	if n, err := strconv.Atoi(props["NRestarts"]); err == nil {
+		unit.Restarts = &n
+	}
+	// An unset MemoryCurrent is "[not set]" or, on older releases, the
+	// maximum uint64.
+	if n, err := strconv.ParseUint(props["MemoryCurrent"], 10, 64); err == nil && n != ^uint64(0) {
+		unit.MemoryBytes = &n
+	}
+	return unit, nil
+}
+
+// ControlService starts, stops or restarts the service. The UI user needs
+// permission to manage the unit, see systemd/50-raspicam-ui.rules.
+func ControlService(ctx context.Context, runner host.Runner, action string) error {
+	switch action {
+	case ServiceStart, ServiceStop, ServiceRestart:
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownAction, action)
+	}
+	out, err := runner.CombinedOutput(ctx, "systemctl", action, ServiceUnit)
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("systemctl %s: %w: %s", action, err, msg)
+		}
+		return fmt.Errorf("systemctl %s: %w", action, err)
+	}
+	return nil
+}
+
+// ServiceLogs returns the entries at minLevel or above among the last lines
+// journal lines of the service, oldest first. An empty minLevel keeps every
+// entry.
+func ServiceLogs(ctx context.Context, runner host.Runner, lines int, minLevel string) ([]LogEntry, error) {
+	minRank := 0
+	if minLevel != "" {
+		minRank = levelRank(minLevel)
+		if minRank < 0 {
+			return nil, fmt.Errorf("%w %q", ErrUnknownLevel, minLevel)
+		}
+	}
+	if lines <= 0 {
+		return nil, fmt.Errorf("invalid line count %d", lines)
+	}
+	out, err := runner.Output(ctx, "journalctl", "--unit="+ServiceUnit, "--lines="+strconv.Itoa(lines), "--output=json", "--no-pager")
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+
+	entries := []LogEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		entry, ok := parseJournalLine(scanner.Bytes())
+		if ok && levelRank(entry.Level) >= minRank {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	return entries, nil
+}
+
+type journalLine struct {
+	RealtimeTimestamp string          `json:"__REALTIME_TIMESTAMP"`
+	Priority          string          `json:"PRIORITY"`
+	Message           json.RawMessage `json:"MESSAGE"`
+}
+
+// parseJournalLine decodes one line of journalctl --output=json.
+func parseJournalLine(line []byte) (LogEntry, bool) {
+	var raw journalLine
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return LogEntry{}, false
+	}
+	var entry LogEntry
+	if usec, err := strconv.ParseInt(raw.RealtimeTimestamp, 10, 64); err == nil {
+		entry.Time = time.UnixMicro(usec)
+	}
+	// The journal stores messages that are not valid UTF-8 as an array of
+	// bytes.
+	var message string
+	if err := json.Unmarshal(raw.Message, &message); err != nil {
+		var b []byte
+		var ints []int
+		if json.Unmarshal(raw.Message, &ints) != nil {
+			return LogEntry{}, false
+		}
+		for _, v := range ints {
+			b = append(b, byte(v))
+		}
+		message = string(b)
+	}
+	entry.Level, entry.Message = splitLogLevel(message)
+	if entry.Level == "" {
+		entry.Level = priorityLevel(raw.Priority)
+	}
+	return entry, true
+}
+
+var mediamtxLevels = map[string]string{"DEB": LevelDebug, "INF": LevelInfo, "WAR": LevelWarn, "ERR": LevelError}
+
+// splitLogLevel strips the "2024/06/11 08:15:00 INF " prefix MediaMTX
+// writes and returns the level it names. Other lines are returned as is.
+func splitLogLevel(message string) (string, string) {
+	fields := strings.SplitN(message, " ", 4)
+	if len(fields) == 4 {
+		if level, ok := mediamtxLevels[fields[2]]; ok {
+			return level, fields[3]
+		}
+	}
+	return "", message
+}
+
+func priorityLevel(priority string) string {
+	switch p, _ := strconv.Atoi(priority); {
+	case priority == "":
+		return LevelInfo
+	case p <= 3:
+		return LevelError
+	case p == 4:
+		return LevelWarn
+	case p == 7:
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+func levelRank(level string) int {
+	for i, l := range LogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
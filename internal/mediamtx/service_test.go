@@ -0,0 +1,105 @@
+package mediamtx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
+
+func TestServiceUnitStatus(t *testing.T) {
+	unit, err := ServiceUnitStatus(context.Background(), hosttest.SyntheticPi().Runner)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unit.ActiveState != "active" || unit.SubState != "running" || unit.MainPID != 812 {
+		t.Fatalf("unexpected unit: %+v", unit)
+	}
+	if unit.Since == nil || !unit.Since.Equal(time.Date(2024, 6, 11, 8, 15, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected since: %v", unit.Since)
+	}
+	if unit.Restarts == nil || *unit.Restarts != 2 || unit.MemoryBytes == nil || *unit.MemoryBytes != 23871488 {
+		t.Fatalf("unexpected restarts or memory: %+v", unit)
+	}
+}
+
+func TestServiceUnitStatusInactive(t *testing.T) {
+	dir := t.TempDir()
+	name := hosttest.FixtureName("systemctl", "show", ServiceUnit, "--property="+strings.Join(unitProperties, ","))
+	out := "ActiveState=inactive\nSubState=dead\nMainPID=0\nActiveEnterTimestamp=\nNRestarts=0\nMemoryCurrent=[not set]\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(out), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	unit, err := ServiceUnitStatus(context.Background(), hosttest.FixtureRunner{Dir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unit.ActiveState != "inactive" || unit.Since != nil || unit.MemoryBytes != nil {
+		t.Fatalf("unexpected unit: %+v", unit)
+	}
+}
+
+func TestControlService(t *testing.T) {
+	dir := t.TempDir()
+	runner := hosttest.FixtureRunner{Dir: dir}
+	if err := os.WriteFile(filepath.Join(dir, hosttest.FixtureName("systemctl", "restart", ServiceUnit)), nil, 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	stop := filepath.Join(dir, hosttest.FixtureName("systemctl", "stop", ServiceUnit))
+	if err := os.WriteFile(stop, []byte("Failed to stop mediamtx.service: Interactive authentication required.\n"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	if err := os.WriteFile(stop+".exit", []byte("1"), 0o644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+
+	if err := ControlService(context.Background(), runner, ServiceRestart); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := ControlService(context.Background(), runner, ServiceStop)
+	if err == nil || !strings.Contains(err.Error(), "Interactive authentication required") {
+		t.Fatalf("expected the systemctl message, got %v", err)
+	}
+	if err := ControlService(context.Background(), runner, "reload"); !errors.Is(err, ErrUnknownAction) {
+		t.Fatalf("expected ErrUnknownAction, got %v", err)
+	}
+}
+
+func TestServiceLogs(t *testing.T) {
+	runner := hosttest.SyntheticPi().Runner
+
+	entries, err := ServiceLogs(context.Background(), runner, 200, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected six entries, got %+v", entries)
+	}
+	if entries[0].Level != LevelInfo || entries[0].Message != "Started mediamtx.service - MediaMTX." {
+		t.Fatalf("unexpected systemd entry: %+v", entries[0])
+	}
+	if entries[1].Message != "MediaMTX v1.9.0" || !entries[1].Time.Equal(time.UnixMicro(1718093700100000)) {
+		t.Fatalf("unexpected MediaMTX entry: %+v", entries[1])
+	}
+
+	entries, err = ServiceLogs(context.Background(), runner, 200, LevelWarn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Level != LevelWarn || entries[1].Level != LevelError {
+		t.Fatalf("unexpected filtered entries: %+v", entries)
+	}
+	if !strings.HasPrefix(entries[1].Message, "[path cam]") {
+		t.Fatalf("unexpected binary message: %q", entries[1].Message)
+	}
+
+	if _, err := ServiceLogs(context.Background(), runner, 200, "verbose"); !errors.Is(err, ErrUnknownLevel) {
+		t.Fatalf("expected ErrUnknownLevel, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package mediamtx
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RTSPConn is a TCP connection to the RTSP or RTSPS server. Session is the
+// ID of the RTSP session it carries, if any.
+type RTSPConn struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+	Session       *string   `json:"session"`
+}
+
+// RTSPSession is an RTSP client. State is "idle", "read" or "publish".
+// UserAgent is empty on releases that do not report it.
+type RTSPSession struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	UserAgent     string    `json:"userAgent"`
+	Transport     *string   `json:"transport"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// RTMPConn is an RTMP client. State is "idle", "read" or "publish".
+type RTMPConn struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// WebRTCSession is a WHEP reader or WHIP publisher. State is "read" or
+// "publish". UserAgent is empty on releases that do not report it.
+type WebRTCSession struct {
+	ID                        string    `json:"id"`
+	Created                   time.Time `json:"created"`
+	RemoteAddr                string    `json:"remoteAddr"`
+	PeerConnectionEstablished bool      `json:"peerConnectionEstablished"`
+	LocalCandidate            string    `json:"localCandidate"`
+	RemoteCandidate           string    `json:"remoteCandidate"`
+	State                     string    `json:"state"`
+	Path                      string    `json:"path"`
+	Query                     string    `json:"query"`
+	UserAgent                 string    `json:"userAgent"`
+	BytesReceived             uint64    `json:"bytesReceived"`
+	BytesSent                 uint64    `json:"bytesSent"`
+}
+
+// SRTConn is an SRT client. State is "idle", "read" or "publish".
+type SRTConn struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// HLSMuxer serves one path over HLS. HLS has no sessions, so a muxer is
+// all MediaMTX reports and there is nothing to kick.
+type HLSMuxer struct {
+	Path        string    `json:"path"`
+	Created     time.Time `json:"created"`
+	LastRequest time.Time `json:"lastRequest"`
+	BytesSent   uint64    `json:"bytesSent"`
+}
+
+func (c *Client) RTSPConns(ctx context.Context) ([]RTSPConn, error) {
+	return listAll[RTSPConn](ctx, c, "/v3/rtspconns/list")
+}
+
+func (c *Client) RTSPSessions(ctx context.Context) ([]RTSPSession, error) {
+	return listAll[RTSPSession](ctx, c, "/v3/rtspsessions/list")
+}
+
+func (c *Client) KickRTSPSession(ctx context.Context, id string) error {
+	return c.kick(ctx, "rtspsessions", id)
+}
+
+func (c *Client) RTSPSConns(ctx context.Context) ([]RTSPConn, error) {
+	return listAll[RTSPConn](ctx, c, "/v3/rtspsconns/list")
+}
+
+func (c *Client) RTSPSSessions(ctx context.Context) ([]RTSPSession, error) {
+	return listAll[RTSPSession](ctx, c, "/v3/rtspssessions/list")
+}
+
+func (c *Client) KickRTSPSSession(ctx context.Context, id string) error {
+	return c.kick(ctx, "rtspssessions", id)
+}
+
+func (c *Client) RTMPConns(ctx context.Context) ([]RTMPConn, error) {
+	return listAll[RTMPConn](ctx, c, "/v3/rtmpconns/list")
+}
+
+func (c *Client) KickRTMPConn(ctx context.Context, id string) error {
+	return c.kick(ctx, "rtmpconns", id)
+}
+
+func (c *Client) RTMPSConns(ctx context.Context) ([]RTMPConn, error) {
+	return listAll[RTMPConn](ctx, c, "/v3/rtmpsconns/list")
+}
+
+func (c *Client) KickRTMPSConn(ctx context.Context, id string) error {
+	return c.kick(ctx, "rtmpsconns", id)
+}
+
+func (c *Client) WebRTCSessions(ctx context.Context) ([]WebRTCSession, error) {
+	return listAll[WebRTCSession](ctx, c, "/v3/webrtcsessions/list")
+}
+
+func (c *Client) KickWebRTCSession(ctx context.Context, id string) error {
+	return c.kick(ctx, "webrtcsessions", id)
+}
+
+func (c *Client) SRTConns(ctx context.Context) ([]SRTConn, error) {
+	return listAll[SRTConn](ctx, c, "/v3/srtconns/list")
+}
+
+func (c *Client) KickSRTConn(ctx context.Context, id string) error {
+	return c.kick(ctx, "srtconns", id)
+}
+
+func (c *Client) HLSMuxers(ctx context.Context) ([]HLSMuxer, error) {
+	return listAll[HLSMuxer](ctx, c, "/v3/hlsmuxers/list")
+}
+
+func (c *Client) kick(ctx context.Context, kind, id string) error {
+	return c.do(ctx, http.MethodPost, "/v3/"+kind+"/kick/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,154 @@
+package mediamtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Viewer protocols, as reported in Viewer.Protocol and taken by KickViewer.
+const (
+	ProtocolRTSP   = "rtsp"
+	ProtocolRTSPS  = "rtsps"
+	ProtocolWebRTC = "webrtc"
+	ProtocolHLS    = "hls"
+	ProtocolSRT    = "srt"
+)
+
+var ErrNotKickable = errors.New("viewer cannot be kicked")
+
+// Viewer is a client reading a path. HLS has no sessions: an HLS viewer is
+// the muxer of a path and stands for all of its HLS clients, so it has no
+// ID or remote address and cannot be kicked.
+type Viewer struct {
+	Protocol   string    `json:"protocol"`
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remoteAddr"`
+	UserAgent  string    `json:"userAgent"`
+	BytesSent  uint64    `json:"bytesSent"`
+	Created    time.Time `json:"created"`
+	Kickable   bool      `json:"kickable"`
+}
+
+// CollectViewers lists the reading sessions of every protocol, oldest
+// first. MediaMTX answers 404 for a protocol whose server is disabled, so
+// those are skipped; other failures become warnings.
+func CollectViewers(ctx context.Context, client *Client) ([]Viewer, []string) {
+	viewers := []Viewer{}
+	var warnings []string
+	add := func(protocol string, err error) bool {
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, ErrNotFound):
+		default:
+			warnings = append(warnings, fmt.Sprintf("MediaMTX %s viewers unavailable: %v", protocol, err))
+		}
+		return false
+	}
+
+	rtsp, err := client.RTSPSessions(ctx)
+	if errors.Is(err, ErrUnreachable) {
+		// Every other list would fail the same way.
+		return viewers, []string{fmt.Sprintf("MediaMTX viewers unavailable: %v", err)}
+	}
+	if add("RTSP", err) {
+		viewers = appendRTSPViewers(viewers, ProtocolRTSP, rtsp)
+	}
+	rtsps, err := client.RTSPSSessions(ctx)
+	if add("RTSPS", err) {
+		viewers = appendRTSPViewers(viewers, ProtocolRTSPS, rtsps)
+	}
+
+	webrtc, err := client.WebRTCSessions(ctx)
+	if add("WebRTC", err) {
+		for _, s := range webrtc {
+			if s.State != "read" {
+				continue
+			}
+			viewers = append(viewers, Viewer{
+				Protocol:   ProtocolWebRTC,
+				ID:         s.ID,
+				Path:       s.Path,
+				RemoteAddr: s.RemoteAddr,
+				UserAgent:  s.UserAgent,
+				BytesSent:  s.BytesSent,
+				Created:    s.Created,
+				Kickable:   true,
+			})
+		}
+	}
+
+	muxers, err := client.HLSMuxers(ctx)
+	if add("HLS", err) {
+		for _, m := range muxers {
+			viewers = append(viewers, Viewer{
+				Protocol:  ProtocolHLS,
+				Path:      m.Path,
+				BytesSent: m.BytesSent,
+				Created:   m.Created,
+			})
+		}
+	}
+
+	srt, err := client.SRTConns(ctx)
+	if add("SRT", err) {
+		for _, c := range srt {
+			if c.State != "read" {
+				continue
+			}
+			viewers = append(viewers, Viewer{
+				Protocol:   ProtocolSRT,
+				ID:         c.ID,
+				Path:       c.Path,
+				RemoteAddr: c.RemoteAddr,
+				BytesSent:  c.BytesSent,
+				Created:    c.Created,
+				Kickable:   true,
+			})
+		}
+	}
+
+	sort.SliceStable(viewers, func(i, j int) bool { return viewers[i].Created.Before(viewers[j].Created) })
+	return viewers, warnings
+}
+
+func appendRTSPViewers(viewers []Viewer, protocol string, sessions []RTSPSession) []Viewer {
+	for _, s := range sessions {
+		if s.State != "read" {
+			continue
+		}
+		viewers = append(viewers, Viewer{
+			Protocol:   protocol,
+			ID:         s.ID,
+			Path:       s.Path,
+			RemoteAddr: s.RemoteAddr,
+			UserAgent:  s.UserAgent,
+			BytesSent:  s.BytesSent,
+			Created:    s.Created,
+			Kickable:   true,
+		})
+	}
+	return viewers
+}
+
+// KickViewer disconnects the session id of protocol.
+func KickViewer(ctx context.Context, client *Client, protocol, id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: missing id", ErrNotKickable)
+	}
+	switch protocol {
+	case ProtocolRTSP:
+		return client.KickRTSPSession(ctx, id)
+	case ProtocolRTSPS:
+		return client.KickRTSPSSession(ctx, id)
+	case ProtocolWebRTC:
+		return client.KickWebRTCSession(ctx, id)
+	case ProtocolSRT:
+		return client.KickSRTConn(ctx, id)
+	}
+	return fmt.Errorf("%w: protocol %q", ErrNotKickable, protocol)
+}
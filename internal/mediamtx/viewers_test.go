@@ -0,0 +1,74 @@
+package mediamtx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx/mediamtxtest"
+)
+
+func TestCollectViewers(t *testing.T) {
+	fake, client := newFakeAPI(t)
+	ctx := context.Background()
+	fake.AddSession("srtconns", mediamtxtest.Session{ID: "srt-pub", Created: time.Now(), State: "publish", Path: "cam"})
+	fake.AddSession("srtconns", mediamtxtest.Session{ID: "srt-read", Created: time.Now().Add(time.Minute), State: "read", Path: "cam", RemoteAddr: "10.0.0.9:4000"})
+
+	viewers, warnings := CollectViewers(ctx, client)
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if len(viewers) != 4 {
+		t.Fatalf("expected RTSP, WebRTC, HLS and the SRT reader, got %+v", viewers)
+	}
+	byProtocol := map[string]Viewer{}
+	for _, v := range viewers {
+		byProtocol[v.Protocol] = v
+	}
+	if rtsp := byProtocol[ProtocolRTSP]; rtsp.UserAgent == "" || rtsp.RemoteAddr != "192.168.1.20:51234" || !rtsp.Kickable || rtsp.BytesSent == 0 {
+		t.Fatalf("unexpected RTSP viewer: %+v", rtsp)
+	}
+	if hls := byProtocol[ProtocolHLS]; hls.Kickable || hls.ID != "" || hls.Path != "cam" {
+		t.Fatalf("unexpected HLS viewer: %+v", hls)
+	}
+	if viewers[len(viewers)-1].ID != "srt-read" {
+		t.Fatalf("expected viewers oldest first, got %+v", viewers)
+	}
+
+	if err := KickViewer(ctx, client, ProtocolSRT, "srt-read"); err != nil {
+		t.Fatalf("kick: %v", err)
+	}
+	if err := KickViewer(ctx, client, ProtocolSRT, "srt-read"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found for a kicked viewer, got %v", err)
+	}
+	if err := KickViewer(ctx, client, ProtocolHLS, "x"); !errors.Is(err, ErrNotKickable) {
+		t.Fatalf("expected HLS not to be kickable, got %v", err)
+	}
+}
+
+func TestCollectViewersDisabledProtocol(t *testing.T) {
+	fake := mediamtxtest.Sample("cam")
+	// MediaMTX has no SRT routes when the SRT server is disabled.
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/srtconns/list" {
+			http.NotFound(w, r)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	viewers, warnings := CollectViewers(context.Background(), NewClient(api.URL))
+	if len(warnings) != 0 || len(viewers) != 3 {
+		t.Fatalf("expected the disabled protocol skipped: %+v %v", viewers, warnings)
+	}
+
+	api.Close()
+	viewers, warnings = CollectViewers(context.Background(), NewClient(api.URL))
+	if len(viewers) != 0 || len(warnings) != 1 {
+		t.Fatalf("expected a single warning when MediaMTX is down: %+v %v", viewers, warnings)
+	}
+}
@@ -6,58 +6,149 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
 )
 
 type Snapshot struct {
-	CPUUsagePercent *float64
-	TemperatureC    *float64
-	VoltageV        *float64
-	Throttled       *ThrottledStatus
+	CPUUsagePercent   *float64         `json:"cpuUsagePercent"`
+	TemperatureC      *float64         `json:"temperatureC"`
+	TemperatureSource string           `json:"temperatureSource,omitempty"`
+	VoltageV          *float64         `json:"voltageV"`
+	VoltageSource     string           `json:"voltageSource,omitempty"`
+	Throttled         *ThrottledStatus `json:"throttled"`
+	ThrottledSource   string           `json:"throttledSource,omitempty"`
+	UptimeSeconds     *float64         `json:"uptimeSeconds"`
+	Load              *LoadAverage     `json:"load"`
+	Memory            *MemoryStats     `json:"memory"`
+	RootDisk          *DiskUsage       `json:"rootDisk"`
+	RecordingsDisk    *DiskUsage       `json:"recordingsDisk"`
 }
 
+// ThrottledStatus splits get_throttled into the conditions active now (bits
+// 0-3) and the sticky conditions that have occurred since boot (bits 16-19).
 type ThrottledStatus struct {
-	Raw         uint32
-	IsThrottled bool
-	Flags       []string
+	Raw uint32 `json:"raw"`
+	// Known holds the bits the source reports. vcgencmd reports all of
+	// ThrottleMask; the rpi_volt alarm only current under-voltage, and the
+	// other bits, left 0, are unknown.
+	Known                uint32   `json:"known"`
+	IsThrottled          bool     `json:"isThrottled"`
+	Flags                []string `json:"flags"`
+	HasOccurred          bool     `json:"hasOccurred"`
+	OccurredFlags        []string `json:"occurredFlags"`
+	UnderVoltageOccurred bool     `json:"underVoltageOccurred"`
+}
+
+// ThrottleBit describes one bit of the vcgencmd get_throttled bitmask.
+type ThrottleBit struct {
+	Mask   uint32
+	Name   string
+	Label  string
+	Sticky bool
+}
+
+const (
+	underVoltageMask         = 0x1
+	underVoltageOccurredMask = 0x10000
+)
+
+// ThrottleMask covers every bit of ThrottleBits.
+const ThrottleMask = 0xf000f
+
+var ThrottleBits = []ThrottleBit{
+	{Mask: underVoltageMask, Name: "under_voltage", Label: "under-voltage"},
+	{Mask: 0x2, Name: "arm_frequency_capped", Label: "arm frequency capped"},
+	{Mask: 0x4, Name: "throttled", Label: "currently throttled"},
+	{Mask: 0x8, Name: "soft_temp_limit", Label: "soft temperature limit"},
+	{Mask: underVoltageOccurredMask, Name: "under_voltage_occurred", Label: "under-voltage has occurred", Sticky: true},
+	{Mask: 0x20000, Name: "arm_frequency_capped_occurred", Label: "arm frequency capping has occurred", Sticky: true},
+	{Mask: 0x40000, Name: "throttled_occurred", Label: "throttling has occurred", Sticky: true},
+	{Mask: 0x80000, Name: "soft_temp_limit_occurred", Label: "soft temperature limit has occurred", Sticky: true},
 }
 
-func Collect(ctx context.Context) (Snapshot, []string) {
+// CPUTimes holds cumulative CPU time counters from /proc/stat in clock ticks.
+type CPUTimes struct {
+	Idle  uint64
+	Total uint64
+}
+
+// Collect samples all device metrics. recordingsDir is optional; its disk
+// usage is skipped when empty or missing. It is read where the recordings
+// store reads it, not under HOST_ROOT.
+func Collect(ctx context.Context, env host.Env, recordingsDir string) (Snapshot, []string) {
 	var snap Snapshot
 	var warnings []string
 
-	if v, err := CPUUsagePercent(ctx, 150*time.Millisecond); err != nil {
+	if v, err := CPUUsagePercent(ctx, env, 150*time.Millisecond); err != nil {
 		warnings = append(warnings, fmt.Sprintf("CPU usage unavailable: %v", err))
 	} else {
 		snap.CPUUsagePercent = &v
 	}
 
-	if v, err := MeasureTempC(ctx); err != nil {
+	if r, err := ReadTemperature(ctx, env); err != nil {
 		warnings = append(warnings, fmt.Sprintf("Temperature unavailable: %v", err))
 	} else {
-		snap.TemperatureC = &v
+		snap.TemperatureC = &r.Value
+		snap.TemperatureSource = r.Source
 	}
 
-	if v, err := MeasureVolts(ctx); err != nil {
+	if r, err := ReadVoltage(ctx, env); err != nil {
 		warnings = append(warnings, fmt.Sprintf("Voltage unavailable: %v", err))
 	} else {
-		snap.VoltageV = &v
+		snap.VoltageV = &r.Value
+		snap.VoltageSource = r.Source
 	}
 
-	if v, err := GetThrottled(ctx); err != nil {
+	if v, source, err := ReadThrottled(ctx, env); err != nil {
 		warnings = append(warnings, fmt.Sprintf("Throttling status unavailable: %v", err))
 	} else {
 		snap.Throttled = &v
+		snap.ThrottledSource = source
+	}
+
+	if v, err := UptimeSeconds(env); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Uptime unavailable: %v", err))
+	} else {
+		snap.UptimeSeconds = &v
+	}
+
+	if v, err := ReadLoadAverage(env); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Load average unavailable: %v", err))
+	} else {
+		snap.Load = &v
+	}
+
+	if v, err := ReadMemory(env); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Memory usage unavailable: %v", err))
+	} else {
+		snap.Memory = &v
+	}
+
+	if v, err := DiskUsageFor(env, "/"); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Root disk usage unavailable: %v", err))
+	} else {
+		snap.RootDisk = &v
+	}
+
+	if recordingsDir != "" {
+		if v, err := DiskUsageAt(recordingsDir); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				warnings = append(warnings, fmt.Sprintf("Recordings disk usage unavailable: %v", err))
+			}
+		} else {
+			snap.RecordingsDisk = &v
+		}
 	}
 
 	return snap, warnings
 }
 
-func CPUUsagePercent(ctx context.Context, sampleDelay time.Duration) (float64, error) {
-	idle1, total1, err := readProcStat(ctx)
+func CPUUsagePercent(ctx context.Context, env host.Env, sampleDelay time.Duration) (float64, error) {
+	idle1, total1, err := readProcStat(ctx, env)
 	if err != nil {
 		return 0, err
 	}
@@ -68,7 +159,7 @@ func CPUUsagePercent(ctx context.Context, sampleDelay time.Duration) (float64, e
 		return 0, ctx.Err()
 	}
 
-	idle2, total2, err := readProcStat(ctx)
+	idle2, total2, err := readProcStat(ctx, env)
 	if err != nil {
 		return 0, err
 	}
@@ -90,8 +181,16 @@ func CPUUsagePercent(ctx context.Context, sampleDelay time.Duration) (float64, e
 	return usage, nil
 }
 
-func readProcStat(ctx context.Context) (uint64, uint64, error) {
-	f, err := os.Open("/proc/stat")
+func ReadCPUTimes(ctx context.Context, env host.Env) (CPUTimes, error) {
+	idle, total, err := readProcStat(ctx, env)
+	if err != nil {
+		return CPUTimes{}, err
+	}
+	return CPUTimes{Idle: idle, Total: total}, nil
+}
+
+func readProcStat(ctx context.Context, env host.Env) (uint64, uint64, error) {
+	f, err := env.Open("/proc/stat")
 	if err != nil {
 		return 0, 0, err
 	}
@@ -133,24 +232,24 @@ func readProcStat(ctx context.Context) (uint64, uint64, error) {
 	return idle, total, nil
 }
 
-func MeasureTempC(ctx context.Context) (float64, error) {
-	out, err := runVcgencmd(ctx, "measure_temp")
+func MeasureTempC(ctx context.Context, env host.Env) (float64, error) {
+	out, err := runVcgencmd(ctx, env, "measure_temp")
 	if err != nil {
 		return 0, err
 	}
 	return parseVcgencmdFloat(out, "temp=", "'C")
 }
 
-func MeasureVolts(ctx context.Context) (float64, error) {
-	out, err := runVcgencmd(ctx, "measure_volts")
+func MeasureVolts(ctx context.Context, env host.Env) (float64, error) {
+	out, err := runVcgencmd(ctx, env, "measure_volts")
 	if err != nil {
 		return 0, err
 	}
 	return parseVcgencmdFloat(out, "volt=", "V")
 }
 
-func GetThrottled(ctx context.Context) (ThrottledStatus, error) {
-	out, err := runVcgencmd(ctx, "get_throttled")
+func GetThrottled(ctx context.Context, env host.Env) (ThrottledStatus, error) {
+	out, err := runVcgencmd(ctx, env, "get_throttled")
 	if err != nil {
 		return ThrottledStatus{}, err
 	}
@@ -159,31 +258,41 @@ func GetThrottled(ctx context.Context) (ThrottledStatus, error) {
 	if err != nil {
 		return ThrottledStatus{}, err
 	}
+	return decodeThrottled(value), nil
+}
 
+func decodeThrottled(value uint32) ThrottledStatus {
 	active := []string{}
-	if value&0x1 != 0 {
-		active = append(active, "under-voltage")
-	}
-	if value&0x2 != 0 {
-		active = append(active, "arm frequency capped")
-	}
-	if value&0x4 != 0 {
-		active = append(active, "currently throttled")
-	}
-	if value&0x8 != 0 {
-		active = append(active, "soft temperature limit")
+	occurred := []string{}
+	for _, bit := range ThrottleBits {
+		if value&bit.Mask == 0 {
+			continue
+		}
+		if bit.Sticky {
+			occurred = append(occurred, bit.Label)
+		} else {
+			active = append(active, bit.Label)
+		}
 	}
 
 	return ThrottledStatus{
-		Raw:         value,
-		IsThrottled: len(active) > 0,
-		Flags:       active,
-	}, nil
+		Raw:                  value,
+		Known:                ThrottleMask,
+		IsThrottled:          len(active) > 0,
+		Flags:                active,
+		HasOccurred:          len(occurred) > 0,
+		OccurredFlags:        occurred,
+		UnderVoltageOccurred: value&underVoltageOccurredMask != 0,
+	}
+}
+
+// Reports reports whether the source knows every bit of mask.
+func (s ThrottledStatus) Reports(mask uint32) bool {
+	return s.Known&mask == mask
 }
 
-func runVcgencmd(ctx context.Context, arg string) (string, error) {
-	cmd := exec.CommandContext(ctx, "vcgencmd", arg)
-	out, err := cmd.Output()
+func runVcgencmd(ctx context.Context, env host.Env, arg string) (string, error) {
+	out, err := env.Runner.Output(ctx, "vcgencmd", arg)
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,14 @@
 package metrics
 
-import "testing"
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
 
 func TestParseVcgencmdFloat(t *testing.T) {
 	cases := []struct {
@@ -34,3 +42,128 @@ func TestParseHexValue(t *testing.T) {
 		t.Fatalf("got %x want %x", got, 0x50005)
 	}
 }
+
+func TestDecodeThrottled(t *testing.T) {
+	status := decodeThrottled(0x5)
+	if !status.IsThrottled {
+		t.Fatalf("expected throttled")
+	}
+	if len(status.Flags) != 2 || status.Flags[0] != "under-voltage" || status.Flags[1] != "currently throttled" {
+		t.Fatalf("unexpected flags: %v", status.Flags)
+	}
+	if status := decodeThrottled(0); status.IsThrottled || len(status.Flags) != 0 {
+		t.Fatalf("expected no flags for zero value")
+	}
+}
+
+func TestDecodeThrottledOccurred(t *testing.T) {
+	status := decodeThrottled(0x50000)
+	if status.IsThrottled || len(status.Flags) != 0 {
+		t.Fatalf("expected no current flags: %v", status.Flags)
+	}
+	if !status.HasOccurred || !status.UnderVoltageOccurred {
+		t.Fatalf("expected under-voltage history")
+	}
+	if len(status.OccurredFlags) != 2 || status.OccurredFlags[0] != "under-voltage has occurred" ||
+		status.OccurredFlags[1] != "throttling has occurred" {
+		t.Fatalf("unexpected occurred flags: %v", status.OccurredFlags)
+	}
+
+	status = decodeThrottled(0x50005)
+	if !status.IsThrottled || len(status.Flags) != 2 || len(status.OccurredFlags) != 2 {
+		t.Fatalf("expected both current and occurred flags: %+v", status)
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	got, err := parseUptime("350735.47 234388.90\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 350735.47 {
+		t.Fatalf("got %v want %v", got, 350735.47)
+	}
+	if _, err := parseUptime(""); err == nil {
+		t.Fatalf("expected error for empty input")
+	}
+}
+
+func TestParseLoadAverage(t *testing.T) {
+	got, err := parseLoadAverage("0.52 0.40 0.31 1/123 4567\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Load1 != 0.52 || got.Load5 != 0.40 || got.Load15 != 0.31 {
+		t.Fatalf("unexpected load: %+v", got)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	input := `MemTotal:         437568 kB
+MemFree:           81232 kB
+MemAvailable:     218784 kB
+Buffers:           20116 kB
+Cached:           140124 kB
+SwapTotal:        102396 kB
+SwapFree:          51198 kB
+`
+	got, err := parseMeminfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.TotalBytes != 437568*1024 || got.AvailableBytes != 218784*1024 {
+		t.Fatalf("unexpected memory: %+v", got)
+	}
+	if got.UsedPercent != 50 {
+		t.Fatalf("unexpected used percent: %v", got.UsedPercent)
+	}
+	if got.SwapUsedBytes != 51198*1024 {
+		t.Fatalf("unexpected swap used: %d", got.SwapUsedBytes)
+	}
+
+	if _, err := parseMeminfo(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Fatalf("expected error without MemTotal")
+	}
+}
+
+func TestDiskUsageFor(t *testing.T) {
+	usage, err := DiskUsageFor(host.Default(), t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.TotalBytes == 0 || usage.UsedPercent < 0 || usage.UsedPercent > 100 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestCollectSyntheticPi(t *testing.T) {
+	snap, _ := Collect(context.Background(), hosttest.SyntheticPi(), filepath.Join(t.TempDir(), "recordings"))
+	if snap.TemperatureC == nil || *snap.TemperatureC != 46.2 || snap.TemperatureSource != "vcgencmd" {
+		t.Fatalf("unexpected temperature: %v %q", snap.TemperatureC, snap.TemperatureSource)
+	}
+	if snap.VoltageV == nil || *snap.VoltageV != 1.2563 {
+		t.Fatalf("unexpected voltage: %v", snap.VoltageV)
+	}
+	if snap.Throttled == nil || snap.Throttled.IsThrottled || !snap.Throttled.UnderVoltageOccurred {
+		t.Fatalf("unexpected throttled status: %+v", snap.Throttled)
+	}
+	if snap.UptimeSeconds == nil || snap.Load == nil || snap.Memory == nil || snap.RootDisk == nil {
+		t.Fatalf("expected uptime, load, memory and root disk: %+v", snap)
+	}
+	if snap.RecordingsDisk != nil {
+		t.Fatalf("expected missing recordings dir to be skipped")
+	}
+}
+
+func TestReadTemperatureSysfsFallback(t *testing.T) {
+	env := hosttest.SyntheticPi()
+	env.Runner = hosttest.FixtureRunner{Dir: t.TempDir()}
+
+	r, err := ReadTemperature(context.Background(), env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Value != 46.16 || r.Source != "sysfs thermal_zone0 (cpu-thermal)" {
+		t.Fatalf("unexpected reading: %+v", r)
+	}
+}
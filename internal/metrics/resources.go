@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+type LoadAverage struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+type MemoryStats struct {
+	TotalBytes     uint64  `json:"totalBytes"`
+	AvailableBytes uint64  `json:"availableBytes"`
+	UsedBytes      uint64  `json:"usedBytes"`
+	UsedPercent    float64 `json:"usedPercent"`
+	SwapTotalBytes uint64  `json:"swapTotalBytes"`
+	SwapFreeBytes  uint64  `json:"swapFreeBytes"`
+	SwapUsedBytes  uint64  `json:"swapUsedBytes"`
+}
+
+type DiskUsage struct {
+	Path        string  `json:"path"`
+	TotalBytes  uint64  `json:"totalBytes"`
+	FreeBytes   uint64  `json:"freeBytes"`
+	UsedBytes   uint64  `json:"usedBytes"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+func UptimeSeconds(env host.Env) (float64, error) {
+	b, err := env.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	return parseUptime(string(b))
+}
+
+func ReadLoadAverage(env host.Env) (LoadAverage, error) {
+	b, err := env.ReadFile("/proc/loadavg")
+	if err != nil {
+		return LoadAverage{}, err
+	}
+	return parseLoadAverage(string(b))
+}
+
+func ReadMemory(env host.Env) (MemoryStats, error) {
+	f, err := env.Open("/proc/meminfo")
+	if err != nil {
+		return MemoryStats{}, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+// DiskUsageFor reports usage of the filesystem holding path on the host.
+func DiskUsageFor(env host.Env, path string) (DiskUsage, error) {
+	usage, err := DiskUsageAt(env.Path(path))
+	usage.Path = path
+	return usage, err
+}
+
+// DiskUsageAt reports usage of the filesystem holding path as this process
+// sees it, without the HOST_ROOT prefix. Free space is the space available
+// to unprivileged users, as reported by df.
+func DiskUsageAt(path string) (DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return DiskUsage{}, err
+	}
+
+	bsize := uint64(st.Bsize)
+	total := uint64(st.Blocks) * bsize
+	free := uint64(st.Bavail) * bsize
+	used := (uint64(st.Blocks) - uint64(st.Bfree)) * bsize
+
+	usage := DiskUsage{
+		Path:       path,
+		TotalBytes: total,
+		FreeBytes:  free,
+		UsedBytes:  used,
+	}
+	if used+free > 0 {
+		usage.UsedPercent = float64(used) / float64(used+free) * 100
+	}
+	return usage, nil
+}
+
+func parseUptime(out string) (float64, error) {
+	fields := strings.Fields(out)
+	if len(fields) < 1 {
+		return 0, errors.New("unexpected /proc/uptime format")
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse uptime: %w", err)
+	}
+	return v, nil
+}
+
+func parseLoadAverage(out string) (LoadAverage, error) {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return LoadAverage{}, errors.New("unexpected /proc/loadavg format")
+	}
+	var values [3]float64
+	for i := range values {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return LoadAverage{}, fmt.Errorf("parse load average: %w", err)
+		}
+		values[i] = v
+	}
+	return LoadAverage{Load1: values[0], Load5: values[1], Load15: values[2]}, nil
+}
+
+func parseMeminfo(r io.Reader) (MemoryStats, error) {
+	fields := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		parts := strings.Fields(rest)
+		if len(parts) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return MemoryStats{}, fmt.Errorf("parse %s: %w", key, err)
+		}
+		if len(parts) > 1 && parts[1] == "kB" {
+			v *= 1024
+		}
+		fields[key] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return MemoryStats{}, err
+	}
+
+	total, ok := fields["MemTotal"]
+	if !ok || total == 0 {
+		return MemoryStats{}, errors.New("missing MemTotal in /proc/meminfo")
+	}
+	available, ok := fields["MemAvailable"]
+	if !ok {
+		available = fields["MemFree"] + fields["Buffers"] + fields["Cached"]
+	}
+	if available > total {
+		available = total
+	}
+
+	stats := MemoryStats{
+		TotalBytes:     total,
+		AvailableBytes: available,
+		UsedBytes:      total - available,
+		UsedPercent:    float64(total-available) / float64(total) * 100,
+		SwapTotalBytes: fields["SwapTotal"],
+		SwapFreeBytes:  fields["SwapFree"],
+	}
+	if stats.SwapFreeBytes <= stats.SwapTotalBytes {
+		stats.SwapUsedBytes = stats.SwapTotalBytes - stats.SwapFreeBytes
+	}
+	return stats, nil
+}
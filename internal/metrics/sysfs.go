@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+const (
+	sourceVcgencmd = "vcgencmd"
+	sourceRpiVolt  = "rpi_volt"
+)
+
+// Reading is a measured value together with the source it was read from.
+type Reading struct {
+	Value  float64
+	Source string
+}
+
+// ReadTemperature returns the SoC temperature from vcgencmd, falling back to
+// the kernel thermal zones when vcgencmd is unavailable.
+func ReadTemperature(ctx context.Context, env host.Env) (Reading, error) {
+	v, vcErr := MeasureTempC(ctx, env)
+	if vcErr == nil {
+		return Reading{Value: v, Source: sourceVcgencmd}, nil
+	}
+	r, err := readThermalZoneTemp(env)
+	if err == nil {
+		return r, nil
+	}
+	return Reading{}, fmt.Errorf("vcgencmd: %v; sysfs: %v", vcErr, err)
+}
+
+// ReadVoltage returns the core voltage from vcgencmd, falling back to hwmon
+// voltage inputs when vcgencmd is unavailable.
+func ReadVoltage(ctx context.Context, env host.Env) (Reading, error) {
+	v, vcErr := MeasureVolts(ctx, env)
+	if vcErr == nil {
+		return Reading{Value: v, Source: sourceVcgencmd}, nil
+	}
+	r, err := readHwmonVoltage(env)
+	if err == nil {
+		return r, nil
+	}
+	return Reading{}, fmt.Errorf("vcgencmd: %v; sysfs: %v", vcErr, err)
+}
+
+// ReadThrottled returns the get_throttled status from vcgencmd, falling back
+// to the rpi_volt hwmon under-voltage alarm. The fallback only knows current
+// under-voltage; see ThrottledStatus.Known.
+func ReadThrottled(ctx context.Context, env host.Env) (ThrottledStatus, string, error) {
+	status, vcErr := GetThrottled(ctx, env)
+	if vcErr == nil {
+		return status, sourceVcgencmd, nil
+	}
+	status, source, err := readRpiVoltAlarm(env)
+	if err == nil {
+		return status, source, nil
+	}
+	return ThrottledStatus{}, "", fmt.Errorf("vcgencmd: %v; sysfs: %v", vcErr, err)
+}
+
+func readThermalZoneTemp(env host.Env) (Reading, error) {
+	zones, err := env.Glob("/sys/class/thermal/thermal_zone*")
+	if err != nil {
+		return Reading{}, err
+	}
+	sort.Strings(zones)
+
+	var fallback *Reading
+	for _, zone := range zones {
+		raw, err := readSysfsValue(env, path.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		zoneType, _ := readSysfsValue(env, path.Join(zone, "type"))
+		r := Reading{
+			Value:  milli / 1000,
+			Source: sysfsSource(path.Base(zone), zoneType),
+		}
+		if isSoCThermalZone(zoneType) {
+			return r, nil
+		}
+		if fallback == nil {
+			fallback = &r
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return Reading{}, errors.New("no readable thermal zone")
+}
+
+func isSoCThermalZone(zoneType string) bool {
+	t := strings.ToLower(zoneType)
+	return strings.Contains(t, "cpu") || strings.Contains(t, "soc")
+}
+
+func readHwmonVoltage(env host.Env) (Reading, error) {
+	devices, err := env.Glob("/sys/class/hwmon/hwmon*")
+	if err != nil {
+		return Reading{}, err
+	}
+	sort.Strings(devices)
+
+	var fallback *Reading
+	for _, device := range devices {
+		name, _ := readSysfsValue(env, path.Join(device, "name"))
+		inputs, err := env.Glob(path.Join(device, "in*_input"))
+		if err != nil {
+			continue
+		}
+		sort.Strings(inputs)
+		for _, input := range inputs {
+			raw, err := readSysfsValue(env, input)
+			if err != nil {
+				continue
+			}
+			milli, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			label, _ := readSysfsValue(env, strings.TrimSuffix(input, "_input")+"_label")
+			if label == "" {
+				label = name
+			}
+			r := Reading{
+				Value:  milli / 1000,
+				Source: sysfsSource(path.Base(device)+"/"+path.Base(input), label),
+			}
+			if strings.Contains(strings.ToLower(label), "core") {
+				return r, nil
+			}
+			if fallback == nil {
+				fallback = &r
+			}
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return Reading{}, errors.New("no readable hwmon voltage input")
+}
+
+func readRpiVoltAlarm(env host.Env) (ThrottledStatus, string, error) {
+	devices, err := env.Glob("/sys/class/hwmon/hwmon*")
+	if err != nil {
+		return ThrottledStatus{}, "", err
+	}
+	sort.Strings(devices)
+
+	for _, device := range devices {
+		name, _ := readSysfsValue(env, path.Join(device, "name"))
+		if name != "rpi_volt" {
+			continue
+		}
+		raw, err := readSysfsValue(env, path.Join(device, "in0_lcrit_alarm"))
+		if err != nil {
+			return ThrottledStatus{}, "", err
+		}
+		return underVoltageStatus(raw == "1"), sourceRpiVolt, nil
+	}
+	return ThrottledStatus{}, "", errors.New("rpi_volt hwmon device not found")
+}
+
+// underVoltageStatus is all the rpi_volt alarm tells: whether the supply is
+// under-voltage now. Every other bit stays unknown rather than reported 0.
+func underVoltageStatus(alarm bool) ThrottledStatus {
+	status := ThrottledStatus{Known: underVoltageMask, Flags: []string{}, OccurredFlags: []string{}}
+	if alarm {
+		status.Raw = underVoltageMask
+		status.IsThrottled = true
+		status.Flags = append(status.Flags, ThrottleBits[0].Label)
+	}
+	return status
+}
+
+func readSysfsValue(env host.Env, file string) (string, error) {
+	b, err := env.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func sysfsSource(node, detail string) string {
+	if detail == "" {
+		return "sysfs " + node
+	}
+	return fmt.Sprintf("sysfs %s (%s)", node, detail)
+}
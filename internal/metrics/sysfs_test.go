@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+)
+
+func writeSysfsFile(t *testing.T, root, rel, value string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+		t.Fatalf("write %s: %v", rel, err)
+	}
+}
+
+func TestReadThermalZoneTemp(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsFile(t, root, "sys/class/thermal/thermal_zone0/type", "gpu-thermal")
+	writeSysfsFile(t, root, "sys/class/thermal/thermal_zone0/temp", "41000")
+	writeSysfsFile(t, root, "sys/class/thermal/thermal_zone1/type", "cpu-thermal")
+	writeSysfsFile(t, root, "sys/class/thermal/thermal_zone1/temp", "46850")
+
+	r, err := readThermalZoneTemp(host.New(root))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Value != 46.85 {
+		t.Fatalf("unexpected temperature: %v", r.Value)
+	}
+	if r.Source != "sysfs thermal_zone1 (cpu-thermal)" {
+		t.Fatalf("unexpected source: %q", r.Source)
+	}
+
+	if _, err := readThermalZoneTemp(host.New(t.TempDir())); err == nil {
+		t.Fatalf("expected error without thermal zones")
+	}
+}
+
+func TestReadHwmonVoltage(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon0/name", "rpi_volt")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon0/in0_lcrit_alarm", "0")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/name", "rk808")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/in0_input", "3300")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/in1_input", "1200")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/in1_label", "vdd_core")
+
+	r, err := readHwmonVoltage(host.New(root))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Value != 1.2 {
+		t.Fatalf("unexpected voltage: %v", r.Value)
+	}
+	if r.Source != "sysfs hwmon1/in1_input (vdd_core)" {
+		t.Fatalf("unexpected source: %q", r.Source)
+	}
+}
+
+func TestReadRpiVoltAlarm(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon0/name", "cpu_thermal")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/name", "rpi_volt")
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/in0_lcrit_alarm", "1")
+
+	status, source, err := readRpiVoltAlarm(host.New(root))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.IsThrottled || len(status.Flags) != 1 || status.Flags[0] != "under-voltage" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if source != "rpi_volt" || status.Known != 0x1 || status.Reports(ThrottleMask) {
+		t.Fatalf("unexpected source or known bits: %q %x", source, status.Known)
+	}
+
+	// Without the alarm nothing is claimed about the sticky bits.
+	writeSysfsFile(t, root, "sys/class/hwmon/hwmon1/in0_lcrit_alarm", "0")
+	status, _, err = readRpiVoltAlarm(host.New(root))
+	if err != nil || status.IsThrottled || status.Raw != 0 || status.Reports(underVoltageOccurredMask) {
+		t.Fatalf("unexpected status: %+v %v", status, err)
+	}
+
+	if _, _, err := readRpiVoltAlarm(host.New(t.TempDir())); err == nil {
+		t.Fatalf("expected error without rpi_volt")
+	}
+}
@@ -0,0 +1,224 @@
+package recordings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/fmp4"
+)
+
+var (
+	ErrInvalidRange = errors.New("end must be after start")
+	ErrUnsupported  = errors.New("only fmp4 recordings can be exported")
+)
+
+// FormatChangeError is an export range over segments whose tracks differ,
+// such as after a resolution change, which one file cannot hold.
+type FormatChangeError struct {
+	At time.Time
+}
+
+func (e *FormatChangeError) Error() string {
+	return fmt.Sprintf("the recording format changes at %s", e.At.Format("2006-01-02 15:04:05"))
+}
+
+// Export is a time range of one path, planned by PlanExport and written as
+// a single fragmented MP4 by WriteTo.
+type Export struct {
+	Path string
+	// Start is the time of the first exported fragment: the last one at
+	// or before the requested start that begins with a keyframe.
+	Start time.Time
+	End   time.Time
+
+	store *Store
+	init  fmp4.Init
+	parts []exportPart
+}
+
+// exportPart is the fragments taken from one segment.
+type exportPart struct {
+	name      string
+	start     time.Time
+	first     map[uint32]uint64 // first decode time of each track
+	fragments []fmp4.Fragment
+}
+
+// exportFragment is a fragment with its wall clock time.
+type exportFragment struct {
+	part int
+	frag fmp4.Fragment
+	at   time.Time
+}
+
+// PlanExport picks the fragments of path between start and end. The
+// range is widened back to the keyframe that precedes start, so the export
+// plays from its first frame, and ends with the last fragment starting
+// before end.
+func (s *Store) PlanExport(name string, start, end time.Time) (*Export, error) {
+	if !end.After(start) {
+		return nil, ErrInvalidRange
+	}
+	segments, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Export{Path: name, End: end, store: s}
+	var fragments []exportFragment
+	for _, seg := range segments {
+		if seg.Path != name || !seg.Start.Before(end) || seg.Start.Add(seg.Duration).Before(start) {
+			continue
+		}
+		if path.Ext(seg.Name) != ".mp4" {
+			return nil, ErrUnsupported
+		}
+		part, parsed, err := s.readPart(seg)
+		if err != nil {
+			return nil, err
+		}
+		if len(e.parts) == 0 {
+			e.init = parsed.Init
+		} else if !e.init.Compatible(parsed.Init) {
+			if len(fragments) > 0 && !fragments[len(fragments)-1].at.Before(start) {
+				return nil, &FormatChangeError{At: seg.Start}
+			}
+			// Nothing before the change is needed; start over.
+			e.init, e.parts, fragments = parsed.Init, nil, nil
+		}
+		for _, f := range parsed.Fragments {
+			tt, ok := f.Time(parsed.Init)
+			if !ok {
+				continue
+			}
+			at := seg.Start.Add(ticksToDuration(tt.Time-part.first[tt.Track], timescale(parsed.Init, tt.Track)))
+			if !at.Before(end) {
+				break
+			}
+			fragments = append(fragments, exportFragment{part: len(e.parts), frag: f, at: at})
+		}
+		e.parts = append(e.parts, part)
+	}
+	if len(fragments) == 0 {
+		return nil, ErrNotFound
+	}
+
+	from := 0
+	for i, f := range fragments {
+		if f.at.After(start) {
+			break
+		}
+		if f.frag.Keyframe {
+			from = i
+		}
+	}
+	e.Start = fragments[from].at
+	for _, f := range fragments[from:] {
+		e.parts[f.part].fragments = append(e.parts[f.part].fragments, f.frag)
+	}
+	return e, nil
+}
+
+func (s *Store) readPart(seg Segment) (exportPart, fmp4.Segment, error) {
+	f, _, err := s.Open(seg.Name)
+	if err != nil {
+		return exportPart{}, fmp4.Segment{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return exportPart{}, fmp4.Segment{}, err
+	}
+	parsed, err := fmp4.Read(f, info.Size())
+	if err != nil {
+		return exportPart{}, fmp4.Segment{}, fmt.Errorf("%s: %w", seg.Name, err)
+	}
+	part := exportPart{name: seg.Name, start: seg.Start, first: map[uint32]uint64{}}
+	for _, frag := range parsed.Fragments {
+		for _, tt := range frag.Times {
+			if _, ok := part.first[tt.Track]; !ok {
+				part.first[tt.Track] = tt.Time
+			}
+		}
+	}
+	return part, parsed, nil
+}
+
+// Size is the number of bytes WriteTo writes.
+func (e *Export) Size() int64 {
+	size := int64(len(e.init.Raw))
+	for _, part := range e.parts {
+		for _, f := range part.fragments {
+			size += f.Size
+		}
+	}
+	return size
+}
+
+// WriteTo writes the export to w. Decode times are rebased on Start and
+// follow the wall clock across segments, so gaps in the recording stay
+// gaps in the file.
+func (e *Export) WriteTo(w io.Writer) (int64, error) {
+	writer, err := fmp4.NewWriter(w, e.init)
+	if err != nil {
+		return writer.Written(), err
+	}
+	for _, part := range e.parts {
+		if len(part.fragments) == 0 {
+			continue
+		}
+		if err := e.writePart(writer, part); err != nil {
+			return writer.Written(), err
+		}
+	}
+	return writer.Written(), nil
+}
+
+func (e *Export) writePart(w *fmp4.Writer, part exportPart) error {
+	full, _, err := e.store.resolve(part.name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return notFound(err)
+	}
+	defer f.Close()
+
+	offset := part.start.Sub(e.Start)
+	for _, frag := range part.fragments {
+		times := make(map[uint32]uint64, len(frag.Times))
+		for _, tt := range frag.Times {
+			scale := timescale(e.init, tt.Track)
+			t := durationToTicks(offset, scale) + int64(tt.Time) - int64(part.first[tt.Track])
+			times[tt.Track] = uint64(max(t, 0))
+		}
+		if err := w.WriteFragment(f, frag, times); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func timescale(init fmp4.Init, track uint32) uint32 {
+	for _, t := range init.Tracks {
+		if t.ID == track {
+			return t.Timescale
+		}
+	}
+	return 1
+}
+
+func ticksToDuration(ticks uint64, scale uint32) time.Duration {
+	secs, rest := ticks/uint64(scale), ticks%uint64(scale)
+	return time.Duration(secs)*time.Second + time.Duration(rest)*time.Second/time.Duration(scale)
+}
+
+func durationToTicks(d time.Duration, scale uint32) int64 {
+	secs, rest := int64(d/time.Second), int64(d%time.Second)
+	return secs*int64(scale) + rest*int64(scale)/int64(time.Second)
+}
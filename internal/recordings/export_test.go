@@ -0,0 +1,113 @@
+package recordings
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/fmp4"
+	"github.com/xpereta/RaspiCam/internal/fmp4/fmp4test"
+)
+
+func writeFMP4(t *testing.T, root, name string, start time.Time, codec string, fragments ...fmp4test.Fragment) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, fmp4test.Segment(codec, fragments...), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	end := start.Add(time.Duration(len(fragments)) * time.Second)
+	if err := os.Chtimes(p, end, end); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+// second returns a one second fragment starting n seconds into a segment.
+func second(n uint64, keyframe bool) fmp4test.Fragment {
+	return fmp4test.Fragment{Time: n * fmp4test.Timescale, Keyframe: keyframe, Data: []byte{byte(n)}}
+}
+
+func newExportStore(t *testing.T) (*Store, time.Time) {
+	t.Helper()
+	root := t.TempDir()
+	start := time.Date(2024, 6, 11, 8, 0, 0, 0, time.Local)
+	writeFMP4(t, root, "cam/2024-06-11_08-00-00-000000.mp4", start, "h264", second(0, true), second(1, false), second(2, true), second(3, false))
+	writeFMP4(t, root, "cam/2024-06-11_08-00-04-000000.mp4", start.Add(4*time.Second), "h264", second(0, true), second(1, false))
+	store, err := New(root, Layout{Pattern: "%path/%Y-%m-%d_%H-%M-%S-%f", Extension: ".mp4"})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	return store, start
+}
+
+func TestExport(t *testing.T) {
+	store, start := newExportStore(t)
+
+	export, err := store.PlanExport("cam", start.Add(2500*time.Millisecond), start.Add(5*time.Second))
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	// Widened back to the keyframe at 2s; the fragment at 5s is past the end.
+	if !export.Start.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("unexpected start %v", export.Start)
+	}
+
+	var out bytes.Buffer
+	n, err := export.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n != int64(out.Len()) || n != export.Size() {
+		t.Fatalf("wrote %d bytes, buffer %d, size %d", n, out.Len(), export.Size())
+	}
+	got, err := fmp4.Read(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	var times []uint64
+	for _, f := range got.Fragments {
+		times = append(times, f.Times[0].Time)
+	}
+	if len(times) != 3 || times[0] != 0 || times[1] != fmp4test.Timescale || times[2] != 2*fmp4test.Timescale {
+		t.Fatalf("unexpected decode times %v", times)
+	}
+	if !got.Fragments[0].Keyframe {
+		t.Fatal("export must start on a keyframe")
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	store, start := newExportStore(t)
+
+	if _, err := store.PlanExport("cam", start, start); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("expected ErrInvalidRange, got %v", err)
+	}
+	if _, err := store.PlanExport("cam", start.Add(time.Hour), start.Add(2*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	writeFMP4(t, store.Root(), "cam/2024-06-11_08-00-06-000000.mp4", start.Add(6*time.Second), "h265", second(0, true))
+	_, err := store.PlanExport("cam", start, start.Add(10*time.Second))
+	var changed *FormatChangeError
+	if !errors.As(err, &changed) || !changed.At.Equal(start.Add(6*time.Second)) {
+		t.Fatalf("expected a format change at 08:00:06, got %v", err)
+	}
+	// A range that starts after the change only needs the new format.
+	if export, err := store.PlanExport("cam", start.Add(6*time.Second), start.Add(10*time.Second)); err != nil || len(export.parts) != 1 {
+		t.Fatalf("unexpected export after the change: %v", err)
+	}
+
+	ts, err := New(store.Root(), Layout{Pattern: "%path/%Y-%m-%d_%H-%M-%S-%f", Extension: ".ts"})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	writeSegment(t, store.Root(), "cam/2024-06-11_09-00-00-000000.ts", 10, start.Add(90*time.Minute))
+	if _, err := ts.PlanExport("cam", start.Add(time.Hour), start.Add(2*time.Hour)); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
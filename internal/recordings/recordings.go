@@ -0,0 +1,363 @@
+// Package recordings lists, serves and deletes the segments MediaMTX writes
+// below the recordings directory. Segment names are parsed with the
+// recordPath pattern of the MediaMTX config, and only files matching it are
+// ever opened or removed.
+package recordings
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("recording not found")
+	ErrInvalidName = errors.New("invalid recording name")
+)
+
+// Layout is a MediaMTX recordPath and the extension of its record format.
+type Layout struct {
+	Pattern   string
+	Extension string
+}
+
+// Segment is one recorded file. Name is its slash-separated location below
+// the recordings root and identifies it in downloads and deletes. Duration
+// runs from Start to the last write, so it grows while MediaMTX is still
+// writing the segment.
+type Segment struct {
+	Path     string        `json:"path"`
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"-"`
+	Size     int64         `json:"size"`
+}
+
+// Day groups the segments of one path that start on the same local date.
+type Day struct {
+	Path     string
+	Date     string
+	Segments []Segment
+	Size     int64
+	Duration time.Duration
+}
+
+// Store reads segments below Root.
+type Store struct {
+	root    string
+	layouts []layout
+}
+
+type layout struct {
+	re     *regexp.Regexp
+	fields []string
+}
+
+var patternTokens = map[string]string{
+	"%path": `(.+?)`,
+	"%Y":    `(\d{4})`,
+	"%m":    `(\d{2})`,
+	"%d":    `(\d{2})`,
+	"%H":    `(\d{2})`,
+	"%M":    `(\d{2})`,
+	"%S":    `(\d{2})`,
+	"%f":    `(\d{6})`,
+	"%s":    `(\d+)`,
+}
+
+// New returns a Store for the segments below root written with any of
+// layouts. A pattern is matched from the first of its directories that
+// holds a placeholder, or from root when it is an absolute path below it,
+// since MediaMTX may resolve a relative recordPath from another directory.
+func New(root string, layouts ...Layout) (*Store, error) {
+	s := &Store{root: filepath.Clean(root)}
+	for _, l := range layouts {
+		compiled, err := compile(relativePattern(s.root, l.Pattern), l.Extension)
+		if err != nil {
+			return nil, fmt.Errorf("recordPath %q: %w", l.Pattern, err)
+		}
+		s.layouts = append(s.layouts, compiled)
+	}
+	if len(s.layouts) == 0 {
+		return nil, errors.New("no recordPath layout")
+	}
+	return s, nil
+}
+
+func (s *Store) Root() string {
+	return s.root
+}
+
+func relativePattern(root, pattern string) string {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	prefix := filepath.ToSlash(root) + "/"
+	if rest, ok := strings.CutPrefix(pattern, prefix); ok {
+		return rest
+	}
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if strings.Contains(part, "%") {
+			return strings.Join(parts[i:], "/")
+		}
+	}
+	return pattern
+}
+
+func compile(pattern, ext string) (layout, error) {
+	var expr strings.Builder
+	var fields []string
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); {
+		token := ""
+		for t := range patternTokens {
+			if strings.HasPrefix(pattern[i:], t) && len(t) > len(token) {
+				token = t
+			}
+		}
+		if token == "" {
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+			continue
+		}
+		expr.WriteString(patternTokens[token])
+		fields = append(fields, token)
+		i += len(token)
+	}
+	expr.WriteString(regexp.QuoteMeta(ext) + "$")
+
+	has := map[string]bool{}
+	for _, f := range fields {
+		has[f] = true
+	}
+	if !has["%path"] {
+		return layout{}, errors.New("no %path placeholder")
+	}
+	if !has["%s"] && !(has["%Y"] && has["%m"] && has["%d"] && has["%H"] && has["%M"] && has["%S"]) {
+		return layout{}, errors.New("no timestamp placeholders")
+	}
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return layout{}, err
+	}
+	return layout{re: re, fields: fields}, nil
+}
+
+// parse returns the path and start time encoded in name.
+func (s *Store) parse(name string) (Segment, bool) {
+	for _, l := range s.layouts {
+		m := l.re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		seg := Segment{Name: name}
+		values := map[string]int{}
+		unix := int64(-1)
+		for i, field := range l.fields {
+			value := m[i+1]
+			switch field {
+			case "%path":
+				seg.Path = value
+			case "%s":
+				unix, _ = strconv.ParseInt(value, 10, 64)
+			default:
+				values[field], _ = strconv.Atoi(value)
+			}
+		}
+		if unix >= 0 {
+			seg.Start = time.Unix(unix, 0)
+		} else {
+			seg.Start = time.Date(values["%Y"], time.Month(values["%m"]), values["%d"], values["%H"], values["%M"], values["%S"], values["%f"]*1000, time.Local)
+		}
+		return seg, true
+	}
+	return Segment{}, false
+}
+
+// List returns every segment below the root, sorted by path and start.
+func (s *Store) List() ([]Segment, error) {
+	var segments []Segment
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Nothing recorded yet is not an error.
+			if p == s.root && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return nil
+		}
+		seg, ok := s.parse(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		segments = append(segments, withFileInfo(seg, info))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].Path != segments[j].Path {
+			return segments[i].Path < segments[j].Path
+		}
+		return segments[i].Start.Before(segments[j].Start)
+	})
+	return segments, nil
+}
+
+func withFileInfo(seg Segment, info fs.FileInfo) Segment {
+	seg.Size = info.Size()
+	if d := info.ModTime().Sub(seg.Start); d > 0 {
+		seg.Duration = d
+	}
+	return seg
+}
+
+// Days groups segments sorted by List into days, newest day first within
+// each path.
+func Days(segments []Segment) []Day {
+	var days []Day
+	index := map[[2]string]int{}
+	for _, seg := range segments {
+		key := [2]string{seg.Path, seg.Start.Format("2006-01-02")}
+		i, ok := index[key]
+		if !ok {
+			i = len(days)
+			index[key] = i
+			days = append(days, Day{Path: key[0], Date: key[1]})
+		}
+		days[i].Segments = append(days[i].Segments, seg)
+		days[i].Size += seg.Size
+		days[i].Duration += seg.Duration
+	}
+	sort.SliceStable(days, func(i, j int) bool {
+		if days[i].Path != days[j].Path {
+			return days[i].Path < days[j].Path
+		}
+		return days[i].Date > days[j].Date
+	})
+	return days
+}
+
+// Open opens the segment called name for reading.
+func (s *Store) Open(name string) (*os.File, Segment, error) {
+	full, seg, err := s.resolve(name)
+	if err != nil {
+		return nil, Segment{}, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return nil, Segment{}, notFound(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Segment{}, err
+	}
+	return f, withFileInfo(seg, info), nil
+}
+
+// Delete removes the segment called name and the directories it leaves
+// empty, up to the root.
+func (s *Store) Delete(name string) error {
+	full, _, err := s.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil {
+		return notFound(err)
+	}
+	s.pruneDirs(filepath.Dir(full))
+	return nil
+}
+
+// DeleteDay removes every segment of path that starts on date
+// (YYYY-MM-DD, local time) and reports how many were removed.
+func (s *Store) DeleteDay(path, date string) (int, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return 0, fmt.Errorf("%w: date %q", ErrInvalidName, date)
+	}
+	segments, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, seg := range segments {
+		if seg.Path != path || seg.Start.Format("2006-01-02") != date {
+			continue
+		}
+		if err := s.Delete(seg.Name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0, ErrNotFound
+	}
+	return removed, nil
+}
+
+// resolve maps name to a file below the root. The name must be local, match
+// a layout and, once symlinks are resolved, still point inside the root.
+func (s *Store) resolve(name string) (string, Segment, error) {
+	if name == "" || strings.Contains(name, `\`) || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", Segment{}, ErrInvalidName
+	}
+	seg, ok := s.parse(name)
+	if !ok {
+		return "", Segment{}, ErrInvalidName
+	}
+
+	full := filepath.Join(s.root, filepath.FromSlash(name))
+	real, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", Segment{}, notFound(err)
+	}
+	root, err := filepath.EvalSymlinks(s.root)
+	if err != nil {
+		return "", Segment{}, err
+	}
+	if rel, err := filepath.Rel(root, real); err != nil || !filepath.IsLocal(rel) {
+		return "", Segment{}, ErrInvalidName
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", Segment{}, notFound(err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", Segment{}, ErrInvalidName
+	}
+	return full, seg, nil
+}
+
+func (s *Store) pruneDirs(dir string) {
+	for dir != s.root && strings.HasPrefix(dir, s.root+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,163 @@
+package recordings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSegment(t *testing.T, root, name string, size int, modTime time.Time) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	root := t.TempDir()
+	day := time.Date(2024, 6, 11, 8, 0, 0, 0, time.Local)
+	writeSegment(t, root, "cam/2024-06-11_08-00-00-000000.mp4", 100, day.Add(time.Hour))
+	writeSegment(t, root, "cam/2024-06-11_09-00-00-000000.mp4", 200, day.Add(90*time.Minute))
+	writeSegment(t, root, "cam/2024-06-12_08-00-00-000000.mp4", 300, day.Add(24*time.Hour+time.Hour))
+	writeSegment(t, root, "garden/front/2024-06-11_10-30-00-500000.mp4", 50, day.Add(3*time.Hour))
+	writeSegment(t, root, "cam/notes.txt", 10, day)
+
+	store, err := New(root, Layout{Pattern: "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f", Extension: ".mp4"})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	return store
+}
+
+func TestList(t *testing.T) {
+	store := newTestStore(t)
+
+	segments, err := store.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(segments) != 4 {
+		t.Fatalf("expected four segments, got %+v", segments)
+	}
+	first := segments[0]
+	if first.Path != "cam" || first.Size != 100 || first.Duration != time.Hour || !first.Start.Equal(time.Date(2024, 6, 11, 8, 0, 0, 0, time.Local)) {
+		t.Fatalf("unexpected first segment: %+v", first)
+	}
+	if last := segments[3]; last.Path != "garden/front" || last.Start.Nanosecond() != 500000000 {
+		t.Fatalf("unexpected nested path segment: %+v", last)
+	}
+
+	days := Days(segments)
+	if len(days) != 3 || days[0].Date != "2024-06-12" || days[1].Date != "2024-06-11" || len(days[1].Segments) != 2 || days[1].Size != 300 {
+		t.Fatalf("unexpected days: %+v", days)
+	}
+}
+
+func TestListMissingRoot(t *testing.T) {
+	store, err := New(filepath.Join(t.TempDir(), "missing"), Layout{Pattern: "%path/%s", Extension: ".ts"})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	segments, err := store.List()
+	if err != nil || len(segments) != 0 {
+		t.Fatalf("expected no segments, got %+v, %v", segments, err)
+	}
+}
+
+func TestNewRejectsPatternWithoutTimestamp(t *testing.T) {
+	if _, err := New(t.TempDir(), Layout{Pattern: "/recordings/%path/clip", Extension: ".mp4"}); err == nil {
+		t.Fatalf("expected an error for a pattern without timestamp")
+	}
+}
+
+func TestUnixPattern(t *testing.T) {
+	root := t.TempDir()
+	writeSegment(t, root, "cams/cam/1718093700.ts", 10, time.Unix(1718093760, 0))
+	store, err := New(root, Layout{Pattern: root + "/cams/%path/%s", Extension: ".ts"})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	segments, err := store.List()
+	if err != nil || len(segments) != 1 || segments[0].Path != "cam" || segments[0].Duration != time.Minute {
+		t.Fatalf("unexpected segments: %+v %v", segments, err)
+	}
+}
+
+func TestOpenRejectsTraversal(t *testing.T) {
+	store := newTestStore(t)
+	outside := filepath.Join(t.TempDir(), "secret.mp4")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(store.Root(), "cam", "2024-06-13_08-00-00-000000.mp4")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	for _, name := range []string{
+		"",
+		"../secret.mp4",
+		"/etc/passwd",
+		"cam/../../2024-06-11_08-00-00-000000.mp4",
+		`cam\..\2024-06-11_08-00-00-000000.mp4`,
+		"cam/notes.txt",
+		"cam/2024-06-13_08-00-00-000000.mp4",
+	} {
+		if f, _, err := store.Open(name); !errors.Is(err, ErrInvalidName) {
+			if f != nil {
+				f.Close()
+			}
+			t.Fatalf("Open(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+	if _, _, err := store.Open("cam/2024-06-14_08-00-00-000000.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	f, seg, err := store.Open("cam/2024-06-11_09-00-00-000000.mp4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	if seg.Size != 200 || seg.Path != "cam" {
+		t.Fatalf("unexpected segment: %+v", seg)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	store := newTestStore(t)
+
+	if err := store.Delete("garden/front/2024-06-11_10-30-00-500000.mp4"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Root(), "garden")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected empty directories pruned, got %v", err)
+	}
+	if err := store.Delete("garden/front/2024-06-11_10-30-00-500000.mp4"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	n, err := store.DeleteDay("cam", "2024-06-11")
+	if err != nil || n != 2 {
+		t.Fatalf("DeleteDay = %d, %v", n, err)
+	}
+	segments, _ := store.List()
+	if len(segments) != 1 || segments[0].Name != "cam/2024-06-12_08-00-00-000000.mp4" {
+		t.Fatalf("unexpected segments left: %+v", segments)
+	}
+	if _, err := os.Stat(filepath.Join(store.Root(), "cam", "notes.txt")); err != nil {
+		t.Fatalf("unrelated file removed: %v", err)
+	}
+	if _, err := store.DeleteDay("cam", "../2024"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+}
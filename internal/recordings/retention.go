@@ -0,0 +1,268 @@
+package recordings
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons a segment is deleted by the retention policy.
+const (
+	ReasonAge     = "max age"
+	ReasonBudget  = "size budget"
+	ReasonMinFree = "free space"
+)
+
+// rateWindow is how far back the write rate is measured.
+const rateWindow = 24 * time.Hour
+
+// minRateSpan is the least recording time a write rate is estimated from.
+const minRateSpan = 10 * time.Minute
+
+// Policy bounds the space recordings take. Zero disables a limit.
+type Policy struct {
+	// MinFreeBytes is the free space kept on the recordings filesystem.
+	MinFreeBytes uint64
+	// MaxBytes is the budget for all segments together.
+	MaxBytes uint64
+	// MaxAge is how long a segment is kept after its last write.
+	MaxAge time.Duration
+}
+
+// Enabled reports whether any limit is set.
+func (p Policy) Enabled() bool {
+	return p.MinFreeBytes > 0 || p.MaxBytes > 0 || p.MaxAge > 0
+}
+
+// Removal is a segment deleted by the retention policy.
+type Removal struct {
+	Segment
+	Reason string
+}
+
+// Usage is the recordings state seen by the last retention pass.
+type Usage struct {
+	Checked   time.Time
+	Segments  int
+	UsedBytes uint64
+	FreeBytes uint64
+	// WriteRate is in bytes per second over the last day, 0 when there
+	// is not enough recording to tell.
+	WriteRate float64
+	// Removed counts the segments deleted since the process started.
+	Removed int
+	// Unmet reports a limit that still did not hold after deleting every
+	// segment that may be deleted.
+	Unmet bool
+	Err   error
+}
+
+// Enforce deletes the oldest segments until policy holds, given the free
+// bytes on the filesystem. The newest segment of each path is never
+// deleted, since MediaMTX may still be writing it. It returns the removals
+// and whether every limit holds afterwards.
+func (s *Store) Enforce(policy Policy, free uint64, now time.Time) ([]Removal, bool, error) {
+	segments, err := s.List()
+	if err != nil {
+		return nil, false, err
+	}
+
+	newest := map[string]string{}
+	var used uint64
+	for _, seg := range segments {
+		newest[seg.Path] = seg.Name // List sorts by start within a path
+		used += uint64(seg.Size)
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start.Before(segments[j].Start) })
+
+	var removals []Removal
+	for _, seg := range segments {
+		reason := ""
+		switch {
+		case policy.MaxAge > 0 && now.Sub(seg.Start.Add(seg.Duration)) > policy.MaxAge:
+			reason = ReasonAge
+		case policy.MaxBytes > 0 && used > policy.MaxBytes:
+			reason = ReasonBudget
+		case policy.MinFreeBytes > 0 && free < policy.MinFreeBytes:
+			reason = ReasonMinFree
+		}
+		if reason == "" || newest[seg.Path] == seg.Name {
+			continue
+		}
+		if err := s.Delete(seg.Name); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return removals, false, err
+		}
+		size := uint64(seg.Size)
+		used -= size
+		free += size
+		removals = append(removals, Removal{Segment: seg, Reason: reason})
+	}
+
+	met := (policy.MaxBytes == 0 || used <= policy.MaxBytes) && (policy.MinFreeBytes == 0 || free >= policy.MinFreeBytes)
+	return removals, met, nil
+}
+
+// WriteRate estimates the bytes per second written to segments over the
+// day before now. A segment that started before the window counts for its
+// share inside it. It returns 0 with less than minRateSpan of recording.
+func WriteRate(segments []Segment, now time.Time) float64 {
+	from := now.Add(-rateWindow)
+	var bytes float64
+	first := now
+	for _, seg := range segments {
+		end := seg.Start.Add(seg.Duration)
+		if end.Before(from) {
+			continue
+		}
+		start := seg.Start
+		share := 1.0
+		if start.Before(from) {
+			share = float64(end.Sub(from)) / float64(seg.Duration)
+			start = from
+		}
+		bytes += float64(seg.Size) * share
+		if start.Before(first) {
+			first = start
+		}
+	}
+	span := now.Sub(first)
+	if span < minRateSpan {
+		return 0
+	}
+	return bytes / span.Seconds()
+}
+
+// Remaining estimates how long recording at rate can go on before policy
+// starts deleting segments, or the disk fills when it sets no space limit.
+// It reports false when the rate is unknown.
+func Remaining(policy Policy, usage Usage) (time.Duration, bool) {
+	if usage.WriteRate <= 0 {
+		return 0, false
+	}
+	var headroom uint64
+	if usage.FreeBytes > policy.MinFreeBytes {
+		headroom = usage.FreeBytes - policy.MinFreeBytes
+	}
+	if policy.MaxBytes > 0 {
+		budget := uint64(0)
+		if policy.MaxBytes > usage.UsedBytes {
+			budget = policy.MaxBytes - usage.UsedBytes
+		}
+		headroom = min(headroom, budget)
+	}
+	return time.Duration(float64(headroom) / usage.WriteRate * float64(time.Second)), true
+}
+
+// Retention applies a Policy to the recordings directory on an interval.
+type Retention struct {
+	policy   Policy
+	interval time.Duration
+	store    func() (*Store, error)
+	free     func() (uint64, error)
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewRetention returns a Retention that opens the store on every pass, so
+// recordPath changes in the MediaMTX config are picked up, and reads the
+// free bytes of the recordings filesystem with free.
+func NewRetention(policy Policy, interval time.Duration, store func() (*Store, error), free func() (uint64, error)) *Retention {
+	return &Retention{policy: policy, interval: interval, store: store, free: free}
+}
+
+// Policy returns the enforced policy.
+func (r *Retention) Policy() Policy {
+	return r.policy
+}
+
+// Usage returns the state seen by the last pass, and false before the
+// first one.
+func (r *Retention) Usage() (Usage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage, !r.usage.Checked.IsZero()
+}
+
+// Run enforces the policy immediately and then on every interval until ctx
+// is done.
+func (r *Retention) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Tick(time.Now()); err != nil {
+			log.Printf("recordings retention: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick runs one pass: it deletes what the policy no longer allows, logging
+// each deletion, and records the resulting usage.
+func (r *Retention) Tick(now time.Time) error {
+	usage := Usage{Checked: now}
+	r.mu.Lock()
+	usage.Removed = r.usage.Removed
+	r.mu.Unlock()
+
+	err := r.tick(now, &usage)
+	usage.Err = err
+	r.mu.Lock()
+	r.usage = usage
+	r.mu.Unlock()
+	return err
+}
+
+func (r *Retention) tick(now time.Time, usage *Usage) error {
+	store, err := r.store()
+	if err != nil {
+		return err
+	}
+	free, err := r.free()
+	if err != nil {
+		return err
+	}
+
+	if r.policy.Enabled() {
+		removals, met, err := store.Enforce(r.policy, free, now)
+		for _, rm := range removals {
+			log.Printf("recordings retention: deleted %s (%d bytes, %s)", rm.Name, rm.Size, rm.Reason)
+		}
+		usage.Removed += len(removals)
+		if err != nil {
+			return err
+		}
+		if !met {
+			usage.Unmet = true
+			log.Printf("recordings retention: limits still exceeded with only the segments being written left")
+		}
+		if len(removals) > 0 {
+			if free, err = r.free(); err != nil {
+				return err
+			}
+		}
+	}
+
+	segments, err := store.List()
+	if err != nil {
+		return err
+	}
+	usage.Segments = len(segments)
+	for _, seg := range segments {
+		usage.UsedBytes += uint64(seg.Size)
+	}
+	usage.FreeBytes = free
+	usage.WriteRate = WriteRate(segments, now)
+	return nil
+}
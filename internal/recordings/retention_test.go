@@ -0,0 +1,108 @@
+package recordings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnforceMaxAge(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2024, 6, 12, 12, 0, 0, 0, time.Local)
+
+	removals, met, err := store.Enforce(Policy{MaxAge: 24 * time.Hour}, 1<<30, now)
+	if err != nil || !met {
+		t.Fatalf("unexpected result: %v, %v", met, err)
+	}
+	// Both cam segments of the 11th ended more than a day ago; garden/front
+	// is older too but is the only, so newest, segment of its path.
+	if len(removals) != 2 || removals[0].Name != "cam/2024-06-11_08-00-00-000000.mp4" || removals[1].Reason != ReasonAge {
+		t.Fatalf("unexpected removals: %+v", removals)
+	}
+	segments, _ := store.List()
+	if len(segments) != 2 {
+		t.Fatalf("expected two segments left, got %+v", segments)
+	}
+}
+
+func TestEnforceBudgetAndFreeSpace(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2024, 6, 12, 12, 0, 0, 0, time.Local)
+
+	// 650 bytes recorded; a 600 byte budget drops the oldest cam segment.
+	removals, met, err := store.Enforce(Policy{MaxBytes: 600}, 1<<30, now)
+	if err != nil || !met || len(removals) != 1 || removals[0].Size != 100 || removals[0].Reason != ReasonBudget {
+		t.Fatalf("unexpected result: %+v, %v, %v", removals, met, err)
+	}
+
+	// 150 bytes short of free space: the next oldest deletable segment goes.
+	removals, met, err = store.Enforce(Policy{MinFreeBytes: 1000}, 850, now)
+	if err != nil || !met || len(removals) != 1 || removals[0].Size != 200 || removals[0].Reason != ReasonMinFree {
+		t.Fatalf("unexpected result: %+v, %v, %v", removals, met, err)
+	}
+
+	// Only the newest segment of each path is left, so nothing else can go.
+	removals, met, err = store.Enforce(Policy{MinFreeBytes: 1000}, 0, now)
+	if err != nil || met || len(removals) != 0 {
+		t.Fatalf("unexpected result: %+v, %v, %v", removals, met, err)
+	}
+}
+
+func TestWriteRate(t *testing.T) {
+	now := time.Date(2024, 6, 12, 12, 0, 0, 0, time.Local)
+	segments := []Segment{
+		// Half inside the window.
+		{Start: now.Add(-25 * time.Hour), Duration: 2 * time.Hour, Size: 7200},
+		{Start: now.Add(-2 * time.Hour), Duration: time.Hour, Size: 3600},
+		// Outside the window.
+		{Start: now.Add(-48 * time.Hour), Duration: time.Hour, Size: 1 << 20},
+	}
+	if got := WriteRate(segments, now); got != (3600+3600)/(24*3600.0) {
+		t.Fatalf("unexpected rate %v", got)
+	}
+	if got := WriteRate(segments[1:2], now.Add(-2*time.Hour+time.Minute)); got != 0 {
+		t.Fatalf("expected no rate from a minute of recording, got %v", got)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	usage := Usage{UsedBytes: 40 << 30, FreeBytes: 10 << 30, WriteRate: float64(1<<30) / (24 * 3600)}
+
+	if got, ok := Remaining(Policy{MinFreeBytes: 2 << 30}, usage); !ok || got.Round(time.Second) != 8*24*time.Hour {
+		t.Fatalf("unexpected remaining %v, %v", got, ok)
+	}
+	if got, ok := Remaining(Policy{MaxBytes: 43 << 30}, usage); !ok || got.Round(time.Second) != 3*24*time.Hour {
+		t.Fatalf("unexpected remaining with budget %v, %v", got, ok)
+	}
+	if _, ok := Remaining(Policy{}, Usage{FreeBytes: 1 << 30}); ok {
+		t.Fatal("expected no estimate without a write rate")
+	}
+}
+
+func TestRetentionTick(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	writeSegment(t, root, "cam/"+now.Add(-3*time.Hour).Format("2006-01-02_15-04-05")+"-000000.mp4", 4000, now.Add(-2*time.Hour))
+	writeSegment(t, root, "cam/"+now.Add(-2*time.Hour).Format("2006-01-02_15-04-05")+"-000000.mp4", 4000, now.Add(-time.Hour))
+	writeSegment(t, root, "cam/"+now.Add(-time.Hour).Format("2006-01-02_15-04-05")+"-000000.mp4", 4000, now)
+
+	free := uint64(100)
+	retention := NewRetention(Policy{MinFreeBytes: 5000}, time.Minute, func() (*Store, error) {
+		return New(root, Layout{Pattern: "%path/%Y-%m-%d_%H-%M-%S-%f", Extension: ".mp4"})
+	}, func() (uint64, error) {
+		entries, _ := os.ReadDir(filepath.Join(root, "cam"))
+		return free + uint64(3-len(entries))*4000, nil
+	})
+
+	if _, ok := retention.Usage(); ok {
+		t.Fatal("expected no usage before the first pass")
+	}
+	if err := retention.Tick(now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	usage, ok := retention.Usage()
+	if !ok || usage.Removed != 2 || usage.Segments != 1 || usage.FreeBytes != 8100 || usage.Unmet {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+}
@@ -0,0 +1,209 @@
+package sampler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/system"
+)
+
+type Sample struct {
+	Time     time.Time              `json:"time"`
+	Metrics  metrics.Snapshot       `json:"metrics"`
+	Network  system.NetworkSnapshot `json:"network"`
+	MediaMTX mediamtx.Status        `json:"mediamtx"`
+	Warnings []string               `json:"warnings"`
+}
+
+// Point is the numeric part of a Sample that the history keeps: the series
+// charts plot. A field is nil when the sample did not have the value.
+type Point struct {
+	Time                      time.Time `json:"time"`
+	CPUUsagePercent           *float64  `json:"cpuUsagePercent"`
+	TemperatureC              *float64  `json:"temperatureC"`
+	VoltageV                  *float64  `json:"voltageV"`
+	ThrottledRaw              *uint32   `json:"throttledRaw"`
+	Load1                     *float64  `json:"load1"`
+	MemoryUsedPercent         *float64  `json:"memoryUsedPercent"`
+	RootDiskUsedPercent       *float64  `json:"rootDiskUsedPercent"`
+	RecordingsDiskUsedPercent *float64  `json:"recordingsDiskUsedPercent"`
+	RxBytesPerSec             *float64  `json:"rxBytesPerSec"`
+	TxBytesPerSec             *float64  `json:"txBytesPerSec"`
+	Readers                   *int      `json:"readers"`
+}
+
+// PointOf extracts the history point of a sample. Values are copied so the
+// point does not keep the sample alive.
+func PointOf(sample Sample) Point {
+	m := sample.Metrics
+	point := Point{
+		Time:            sample.Time,
+		CPUUsagePercent: copyValue(m.CPUUsagePercent),
+		TemperatureC:    copyValue(m.TemperatureC),
+		VoltageV:        copyValue(m.VoltageV),
+		RxBytesPerSec:   copyValue(sample.Network.RxBytesPerSec),
+		TxBytesPerSec:   copyValue(sample.Network.TxBytesPerSec),
+		Readers:         copyValue(sample.MediaMTX.Readers),
+	}
+	if m.Throttled != nil {
+		raw := m.Throttled.Raw
+		point.ThrottledRaw = &raw
+	}
+	if m.Load != nil {
+		load := m.Load.Load1
+		point.Load1 = &load
+	}
+	if m.Memory != nil {
+		used := m.Memory.UsedPercent
+		point.MemoryUsedPercent = &used
+	}
+	if m.RootDisk != nil {
+		used := m.RootDisk.UsedPercent
+		point.RootDiskUsedPercent = &used
+	}
+	if m.RecordingsDisk != nil {
+		used := m.RecordingsDisk.UsedPercent
+		point.RecordingsDiskUsedPercent = &used
+	}
+	return point
+}
+
+func copyValue[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+type CollectFunc func(ctx context.Context) Sample
+
+// Sources tells Collect which host to sample and where to read MediaMTX and
+// recordings state from.
+type Sources struct {
+	Env           host.Env
+	MediaMTX      *mediamtx.Client
+	MediaMTXPaths []string
+	RecordingsDir string
+}
+
+// Sampler collects a Sample on a fixed interval. It keeps the latest Sample
+// whole and the Points of the most recent ones in a fixed-size ring buffer.
+type Sampler struct {
+	interval time.Duration
+	timeout  time.Duration
+	collect  CollectFunc
+
+	mu     sync.RWMutex
+	latest *Sample
+	points []Point
+	next   int
+	full   bool
+}
+
+func New(interval time.Duration, capacity int, collect CollectFunc) *Sampler {
+	if capacity < 1 {
+		capacity = 1
+	}
+	timeout := interval
+	if timeout > 5*time.Second {
+		timeout = 5 * time.Second
+	}
+	return &Sampler{
+		interval: interval,
+		timeout:  timeout,
+		collect:  collect,
+		points:   make([]Point, capacity),
+	}
+}
+
+// Collect samples metrics, network and MediaMTX state once.
+func Collect(ctx context.Context, src Sources) Sample {
+	snap, warnings := metrics.Collect(ctx, src.Env, src.RecordingsDir)
+	network, networkWarnings := system.CollectNetwork(ctx, src.Env)
+	mtxStatus, mtxWarnings := mediamtx.Collect(ctx, src.Env.Runner, src.MediaMTX, src.MediaMTXPaths...)
+
+	return Sample{
+		Time:     time.Now(),
+		Metrics:  snap,
+		Network:  network,
+		MediaMTX: mtxStatus,
+		Warnings: append(warnings, append(mtxWarnings, networkWarnings...)...),
+	}
+}
+
+func (s *Sampler) Interval() time.Duration {
+	return s.interval
+}
+
+// Run samples immediately and then on every interval until ctx is done.
+func (s *Sampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sampleOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sampler) sampleOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	sample := s.collect(ctx)
+	if sample.Time.IsZero() {
+		sample.Time = time.Now()
+	}
+	s.add(sample)
+}
+
+func (s *Sampler) add(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = &sample
+	s.points[s.next] = PointOf(sample)
+	s.next = (s.next + 1) % len(s.points)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+func (s *Sampler) Latest() (Sample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.latest == nil {
+		return Sample{}, false
+	}
+	return *s.latest, true
+}
+
+// Since returns the points of the samples taken at or after t, oldest first.
+func (s *Sampler) Since(t time.Time) []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start, count := 0, s.next
+	if s.full {
+		start, count = s.next, len(s.points)
+	}
+
+	result := []Point{}
+	for i := 0; i < count; i++ {
+		point := s.points[(start+i)%len(s.points)]
+		if !point.Time.Before(t) {
+			result = append(result, point)
+		}
+	}
+	return result
+}
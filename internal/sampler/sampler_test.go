@@ -0,0 +1,103 @@
+package sampler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/metrics"
+)
+
+func TestSamplerRingWraps(t *testing.T) {
+	s := New(time.Second, 3, nil)
+	if _, ok := s.Latest(); ok {
+		t.Fatalf("expected no sample before first collection")
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.add(Sample{Time: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	latest, ok := s.Latest()
+	if !ok || !latest.Time.Equal(base.Add(4*time.Minute)) {
+		t.Fatalf("unexpected latest sample: %v", latest.Time)
+	}
+
+	all := s.Since(time.Time{})
+	if len(all) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(all))
+	}
+	for i, point := range all {
+		if want := base.Add(time.Duration(i+2) * time.Minute); !point.Time.Equal(want) {
+			t.Fatalf("point %d: got %v want %v", i, point.Time, want)
+		}
+	}
+
+	recent := s.Since(base.Add(3 * time.Minute))
+	if len(recent) != 2 {
+		t.Fatalf("expected 2 recent samples, got %d", len(recent))
+	}
+}
+
+func TestSamplerRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	s := New(10*time.Millisecond, 10, func(ctx context.Context) Sample {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+		return Sample{Warnings: []string{"sampled"}}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("sampler did not stop")
+	}
+
+	points := s.Since(time.Time{})
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(points))
+	}
+	if points[0].Time.IsZero() {
+		t.Fatalf("expected point time to be set")
+	}
+	if latest, ok := s.Latest(); !ok || len(latest.Warnings) != 1 {
+		t.Fatalf("expected the latest full sample, got %+v", latest)
+	}
+}
+
+func TestPointOf(t *testing.T) {
+	cpu, readers := 12.5, 2
+	sample := Sample{
+		Metrics: metrics.Snapshot{
+			CPUUsagePercent: &cpu,
+			Load:            &metrics.LoadAverage{Load1: 0.5},
+			Memory:          &metrics.MemoryStats{UsedPercent: 40},
+		},
+		MediaMTX: mediamtx.Status{Readers: &readers},
+	}
+	point := PointOf(sample)
+	cpu = 99
+	if point.CPUUsagePercent == nil || *point.CPUUsagePercent != 12.5 {
+		t.Fatalf("expected a copied CPU usage, got %v", point.CPUUsagePercent)
+	}
+	if point.Load1 == nil || *point.Load1 != 0.5 || point.MemoryUsedPercent == nil || *point.MemoryUsedPercent != 40 {
+		t.Fatalf("unexpected load or memory: %+v", point)
+	}
+	if point.Readers == nil || *point.Readers != 2 {
+		t.Fatalf("unexpected readers: %v", point.Readers)
+	}
+	if point.TemperatureC != nil || point.RootDiskUsedPercent != nil || point.ThrottledRaw != nil {
+		t.Fatalf("expected missing values to stay nil: %+v", point)
+	}
+}
@@ -0,0 +1,347 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrRuleNotFound is returned for a rule ID that is not stored.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// Rule applies camera profile Profile to path Path whenever At occurs.
+// Occurrences before Created are ignored, so adding a rule does not apply
+// it retroactively.
+type Rule struct {
+	ID      int       `yaml:"id" json:"id"`
+	At      string    `yaml:"at" json:"at"`
+	Path    string    `yaml:"path" json:"path"`
+	Profile string    `yaml:"profile" json:"profile"`
+	Created time.Time `yaml:"created,omitempty" json:"created"`
+}
+
+// Applied records the last scheduled change handled for a path. Due is the
+// occurrence it belonged to, so a change is handled once even across
+// restarts.
+type Applied struct {
+	Rule    int       `yaml:"rule" json:"rule"`
+	Profile string    `yaml:"profile" json:"profile"`
+	Due     time.Time `yaml:"due" json:"due"`
+	Time    time.Time `yaml:"time" json:"time"`
+	// Changed is false when the camera already matched the profile and
+	// nothing was saved.
+	Changed bool   `yaml:"changed" json:"changed"`
+	Error   string `yaml:"error,omitempty" json:"error,omitempty"`
+	// Attempts counts the failed tries of the occurrence.
+	Attempts int `yaml:"attempts,omitempty" json:"attempts,omitempty"`
+}
+
+// maxApplyAttempts bounds the tries of one occurrence, so a broken profile
+// cannot restart MediaMTX on every tick until the next one.
+const maxApplyAttempts = 3
+
+// handled reports whether the occurrence at due needs no further try.
+func (a Applied) handled(due time.Time) bool {
+	if a.Due.Before(due) {
+		return false
+	}
+	return a.Error == "" || !a.Due.Equal(due) || a.Attempts >= maxApplyAttempts
+}
+
+// Change is an occurrence of a rule.
+type Change struct {
+	Rule Rule      `json:"rule"`
+	At   time.Time `json:"at"`
+}
+
+// ApplyFunc applies a profile to a camera path. It reports false when the
+// camera already matched the profile.
+type ApplyFunc func(ctx context.Context, path, profile string) (bool, error)
+
+type scheduleFile struct {
+	Rules   []Rule             `yaml:"rules"`
+	Applied map[string]Applied `yaml:"applied,omitempty"`
+}
+
+// Scheduler applies camera profiles at the times of its rules. Rules and
+// the last handled change per path are kept in a YAML file.
+type Scheduler struct {
+	path     string
+	location *Location
+	apply    ApplyFunc
+	interval time.Duration
+
+	mu sync.Mutex
+}
+
+// New returns a scheduler storing its rules at path. location may be nil,
+// in which case sunrise and sunset rules never occur.
+func New(path string, location *Location, apply ApplyFunc) *Scheduler {
+	return &Scheduler{path: path, location: location, apply: apply, interval: 30 * time.Second}
+}
+
+// SchedulePath returns the schedule file of the config file at path,
+// mediamtx.schedule.yml for mediamtx.yml.
+func SchedulePath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".schedule" + ext
+}
+
+// Location returns the configured location, or nil.
+func (s *Scheduler) Location() *Location {
+	return s.location
+}
+
+// Rules returns the stored rules in ID order.
+func (s *Scheduler) Rules() ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := s.load()
+	return file.Rules, err
+}
+
+// Applied returns the last handled change per path.
+func (s *Scheduler) Applied() (map[string]Applied, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := s.load()
+	return file.Applied, err
+}
+
+// AddRule validates and stores a rule, assigning its ID. A zero Created is
+// set to now.
+func (s *Scheduler) AddRule(rule Rule) (Rule, error) {
+	rule.At = strings.TrimSpace(rule.At)
+	spec, err := ParseSpec(rule.At)
+	if err != nil {
+		return Rule{}, err
+	}
+	if spec.IsSun() && s.location == nil {
+		return Rule{}, ErrNeedsLocation
+	}
+	if rule.Path == "" || rule.Profile == "" {
+		return Rule{}, errors.New("rule needs a path and a profile")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := s.load()
+	if err != nil {
+		return Rule{}, err
+	}
+	if rule.Created.IsZero() {
+		rule.Created = time.Now()
+	}
+	rule.ID = 1
+	for _, r := range file.Rules {
+		if r.ID >= rule.ID {
+			rule.ID = r.ID + 1
+		}
+	}
+	file.Rules = append(file.Rules, rule)
+	return rule, s.write(file)
+}
+
+// DeleteRule removes a stored rule.
+func (s *Scheduler) DeleteRule(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, r := range file.Rules {
+		if r.ID == id {
+			file.Rules = append(file.Rules[:i], file.Rules[i+1:]...)
+			return s.write(file)
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// Upcoming returns the next change of every path with rules, soonest
+// first.
+func (s *Scheduler) Upcoming(now time.Time) ([]Change, error) {
+	s.mu.Lock()
+	file, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	next := map[string]Change{}
+	for _, rule := range file.Rules {
+		spec, err := ParseSpec(rule.At)
+		if err != nil {
+			continue
+		}
+		at, ok := spec.Next(now, s.location)
+		if !ok {
+			continue
+		}
+		if current, ok := next[rule.Path]; !ok || at.Before(current.At) {
+			next[rule.Path] = Change{Rule: rule, At: at}
+		}
+	}
+
+	changes := make([]Change, 0, len(next))
+	for _, change := range next {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].At.Equal(changes[j].At) {
+			return changes[i].At.Before(changes[j].At)
+		}
+		return changes[i].Rule.Path < changes[j].Rule.Path
+	})
+	return changes, nil
+}
+
+// Run checks the rules immediately and then on every interval until ctx is
+// done. The first check also catches up on a change missed while the
+// process was down.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Tick(ctx, time.Now()); err != nil {
+			log.Printf("camera schedule: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick applies, per path, the latest change due at now unless it was
+// already handled. A failed change is recorded and tried again on the next
+// ticks, up to maxApplyAttempts times per occurrence. A change cut short
+// because ctx ended, as at shutdown, is not recorded and stays due.
+func (s *Scheduler) Tick(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	file, err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var pending []Change
+	for path, due := range dueChanges(file.Rules, now, s.location) {
+		if last, ok := file.Applied[path]; ok && last.handled(due.At) {
+			continue
+		}
+		pending = append(pending, due)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Rule.Path < pending[j].Rule.Path })
+
+	results := map[string]Applied{}
+	for _, due := range pending {
+		changed, err := s.apply(ctx, due.Rule.Path, due.Rule.Profile)
+		if err != nil && ctx.Err() != nil {
+			log.Printf("camera schedule: apply %q to %s interrupted: %v", due.Rule.Profile, due.Rule.Path, err)
+			break
+		}
+		applied := Applied{Rule: due.Rule.ID, Profile: due.Rule.Profile, Due: due.At, Time: now, Changed: changed}
+		if err != nil {
+			applied.Error = err.Error()
+			applied.Attempts = 1
+			if last := file.Applied[due.Rule.Path]; last.Due.Equal(due.At) && last.Error != "" {
+				applied.Attempts = last.Attempts + 1
+			}
+			log.Printf("camera schedule: apply %q to %s (attempt %d): %v", due.Rule.Profile, due.Rule.Path, applied.Attempts, err)
+		}
+		results[due.Rule.Path] = applied
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err = s.load()
+	if err != nil {
+		return err
+	}
+	if file.Applied == nil {
+		file.Applied = map[string]Applied{}
+	}
+	for path, applied := range results {
+		file.Applied[path] = applied
+	}
+	return s.write(file)
+}
+
+// dueChanges returns, per path, the rule occurrence most recently due at
+// now. A later rule wins a tie.
+func dueChanges(rules []Rule, now time.Time, loc *Location) map[string]Change {
+	due := map[string]Change{}
+	for _, rule := range rules {
+		spec, err := ParseSpec(rule.At)
+		if err != nil {
+			continue
+		}
+		at, ok := spec.Prev(now, loc)
+		if !ok || at.Before(rule.Created) {
+			continue
+		}
+		if current, ok := due[rule.Path]; !ok || !at.Before(current.At) {
+			due[rule.Path] = Change{Rule: rule, At: at}
+		}
+	}
+	return due
+}
+
+func (s *Scheduler) load() (scheduleFile, error) {
+	var file scheduleFile
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return file, err
+	}
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return file, fmt.Errorf("parse schedule: %w", err)
+	}
+	sort.Slice(file.Rules, func(i, j int) bool { return file.Rules[i].ID < file.Rules[j].ID })
+	return file, nil
+}
+
+// write atomically replaces the schedule file.
+func (s *Scheduler) write(file scheduleFile) error {
+	out, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,170 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type applyCall struct {
+	Path    string
+	Profile string
+}
+
+func TestSchedulerTick(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.schedule.yml")
+	var calls []applyCall
+	active := map[string]string{}
+	apply := func(ctx context.Context, path, profile string) (bool, error) {
+		if active[path] == profile {
+			return false, nil
+		}
+		calls = append(calls, applyCall{path, profile})
+		active[path] = profile
+		return true, nil
+	}
+
+	created := time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)
+	s := New(path, nil, apply)
+	if _, err := s.AddRule(Rule{At: "0 7 * * *", Path: "cam", Profile: "day", Created: created}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	if _, err := s.AddRule(Rule{At: "0 20 * * *", Path: "cam", Profile: "night", Created: created}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	if _, err := s.AddRule(Rule{At: "sunset", Path: "cam", Profile: "night"}); !errors.Is(err, ErrNeedsLocation) {
+		t.Fatalf("expected ErrNeedsLocation, got %v", err)
+	}
+
+	// Nothing is due yet: 07:00 on the 20th is before the rules existed.
+	if err := s.Tick(context.Background(), time.Date(2024, 6, 20, 15, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no retroactive change, got %+v", calls)
+	}
+
+	if err := s.Tick(context.Background(), time.Date(2024, 6, 20, 20, 0, 30, 0, time.UTC)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if err := s.Tick(context.Background(), time.Date(2024, 6, 20, 20, 1, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != (applyCall{"cam", "night"}) {
+		t.Fatalf("expected night applied once, got %+v", calls)
+	}
+
+	// A restart while the day change was due catches up once.
+	s = New(path, nil, apply)
+	now := time.Date(2024, 6, 21, 9, 0, 0, 0, time.UTC)
+	if err := s.Tick(context.Background(), now); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if err := s.Tick(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(calls) != 2 || calls[1] != (applyCall{"cam", "day"}) {
+		t.Fatalf("expected day applied after restart, got %+v", calls)
+	}
+	applied, err := s.Applied()
+	if err != nil || applied["cam"].Profile != "day" || !applied["cam"].Due.Equal(time.Date(2024, 6, 21, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected applied state: %+v %v", applied, err)
+	}
+
+	upcoming, err := s.Upcoming(now)
+	if err != nil || len(upcoming) != 1 || upcoming[0].Rule.Profile != "night" || !upcoming[0].At.Equal(time.Date(2024, 6, 21, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected upcoming: %+v %v", upcoming, err)
+	}
+}
+
+func TestSchedulerTickAlreadyActive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.schedule.yml")
+	s := New(path, nil, func(ctx context.Context, path, profile string) (bool, error) {
+		return false, nil
+	})
+	if _, err := s.AddRule(Rule{At: "0 20 * * *", Path: "cam", Profile: "night", Created: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	if err := s.Tick(context.Background(), time.Date(2024, 6, 20, 21, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	applied, _ := s.Applied()
+	if applied["cam"].Changed || applied["cam"].Profile != "night" {
+		t.Fatalf("expected the change recorded without a save: %+v", applied)
+	}
+}
+
+func TestSchedulerTickFailureRetriedWithinOccurrence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.schedule.yml")
+	attempts := 0
+	s := New(path, nil, func(ctx context.Context, path, profile string) (bool, error) {
+		attempts++
+		return false, errors.New("profile not found")
+	})
+	rule, err := s.AddRule(Rule{At: "*/10 * * * *", Path: "cam", Profile: "gone", Created: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	now := time.Date(2024, 6, 20, 21, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := s.Tick(context.Background(), now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("tick: %v", err)
+		}
+	}
+	if attempts != maxApplyAttempts {
+		t.Fatalf("expected %d attempts for the occurrence, got %d", maxApplyAttempts, attempts)
+	}
+	applied, _ := s.Applied()
+	if applied["cam"].Error == "" || applied["cam"].Attempts != maxApplyAttempts {
+		t.Fatalf("expected the failures recorded: %+v", applied)
+	}
+	// The next occurrence is tried afresh.
+	if err := s.Tick(context.Background(), now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if attempts != maxApplyAttempts+1 {
+		t.Fatalf("expected the next occurrence tried, got %d attempts", attempts)
+	}
+
+	if err := s.DeleteRule(rule.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.DeleteRule(rule.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+}
+
+func TestSchedulerTickCancelledNotRecorded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.schedule.yml")
+	var calls []applyCall
+	s := New(path, nil, func(ctx context.Context, path, profile string) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		calls = append(calls, applyCall{path, profile})
+		return true, nil
+	})
+	if _, err := s.AddRule(Rule{At: "0 20 * * *", Path: "cam", Profile: "night", Created: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+
+	// Shutting down while the change is due leaves it due.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now := time.Date(2024, 6, 20, 20, 0, 0, 0, time.UTC)
+	if err := s.Tick(ctx, now); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if applied, _ := s.Applied(); len(applied) != 0 {
+		t.Fatalf("expected the cancelled change not recorded: %+v", applied)
+	}
+
+	if err := s.Tick(context.Background(), now.Add(time.Minute)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected the change applied after restart, got %+v", calls)
+	}
+}
@@ -0,0 +1,207 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchDays bounds how far Next and Prev look for an occurrence. A year
+// covers every cron expression that can match at all except 29 February.
+const searchDays = 366
+
+// ErrNeedsLocation is returned for sun events without a configured location.
+var ErrNeedsLocation = errors.New("sunrise and sunset need a latitude and longitude")
+
+// Spec is a parsed rule time: a five-field cron expression such as
+// "30 7 * * 1-5", or "sunrise" or "sunset" with an optional offset such as
+// "sunset+30m" or "sunrise-1h".
+type Spec struct {
+	cron   *cronSpec
+	rising bool
+	offset time.Duration
+}
+
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// ParseSpec parses a rule time.
+func ParseSpec(value string) (Spec, error) {
+	value = strings.TrimSpace(value)
+	for _, event := range []string{"sunrise", "sunset"} {
+		rest, ok := strings.CutPrefix(value, event)
+		if !ok {
+			continue
+		}
+		spec := Spec{rising: event == "sunrise"}
+		if rest != "" {
+			if rest[0] != '+' && rest[0] != '-' {
+				return Spec{}, fmt.Errorf("invalid offset %q", rest)
+			}
+			offset, err := time.ParseDuration(rest)
+			if err != nil || offset < -12*time.Hour || offset > 12*time.Hour {
+				return Spec{}, fmt.Errorf("invalid offset %q", rest)
+			}
+			spec.offset = offset
+		}
+		return spec, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) != 5 {
+		return Spec{}, fmt.Errorf("invalid time %q: want a five-field cron expression, sunrise or sunset", value)
+	}
+	var cron cronSpec
+	var err error
+	if cron.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return Spec{}, fmt.Errorf("minute: %w", err)
+	}
+	if cron.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return Spec{}, fmt.Errorf("hour: %w", err)
+	}
+	if cron.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return Spec{}, fmt.Errorf("day of month: %w", err)
+	}
+	if cron.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return Spec{}, fmt.Errorf("month: %w", err)
+	}
+	if cron.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return Spec{}, fmt.Errorf("day of week: %w", err)
+	}
+	// 7 is Sunday as well.
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	// Like Vixie cron, a day field starting with a star is unrestricted even
+	// with a step, so "*/2" still has to match the day of week as well.
+	cron.domAny = strings.HasPrefix(fields[2], "*")
+	cron.dowAny = strings.HasPrefix(fields[4], "*")
+	return Spec{cron: &cron}, nil
+}
+
+// parseCronField parses a comma-separated list of "*", "N" or "N-M", each
+// with an optional "/step", into a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// IsSun reports whether the spec is a sunrise or sunset.
+func (s Spec) IsSun() bool {
+	return s.cron == nil
+}
+
+// Next returns the first occurrence after t, in t's time zone. ok is false
+// when there is none within a year or a sun event has no location.
+func (s Spec) Next(t time.Time, loc *Location) (time.Time, bool) {
+	return s.search(t, loc, 1)
+}
+
+// Prev returns the last occurrence at or before t, looking back at most a
+// year.
+func (s Spec) Prev(t time.Time, loc *Location) (time.Time, bool) {
+	return s.search(t, loc, -1)
+}
+
+func (s Spec) search(t time.Time, loc *Location, dir int) (time.Time, bool) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// Sun events with an offset can fall on the neighbouring day.
+	if s.IsSun() {
+		day = day.AddDate(0, 0, -dir)
+	}
+	for i := 0; i <= searchDays+1; i++ {
+		for _, occurrence := range s.onDay(day, loc, dir) {
+			if dir > 0 && occurrence.After(t) || dir < 0 && !occurrence.After(t) {
+				return occurrence, true
+			}
+		}
+		day = day.AddDate(0, 0, dir)
+	}
+	return time.Time{}, false
+}
+
+// onDay returns the occurrences on the local calendar day, ascending when
+// dir is positive and descending otherwise.
+func (s Spec) onDay(day time.Time, loc *Location, dir int) []time.Time {
+	if s.IsSun() {
+		if loc == nil {
+			return nil
+		}
+		event, ok := sunTime(day.Year(), day.Month(), day.Day(), *loc, s.rising)
+		if !ok {
+			return nil
+		}
+		return []time.Time{event.Add(s.offset).In(day.Location())}
+	}
+
+	c := s.cron
+	if c.month&(1<<uint(day.Month())) == 0 {
+		return nil
+	}
+	domMatch := c.dom&(1<<uint(day.Day())) != 0
+	dowMatch := c.dow&(1<<uint(day.Weekday())) != 0
+	// Like cron, a day matches either field when both are restricted.
+	if c.domAny || c.dowAny {
+		if !domMatch || !dowMatch {
+			return nil
+		}
+	} else if !domMatch && !dowMatch {
+		return nil
+	}
+
+	var times []time.Time
+	for h := 0; h < 24; h++ {
+		if c.hour&(1<<uint(h)) == 0 {
+			continue
+		}
+		for m := 0; m < 60; m++ {
+			if c.minute&(1<<uint(m)) != 0 {
+				times = append(times, time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location()))
+			}
+		}
+	}
+	if dir < 0 {
+		for i, j := 0, len(times)-1; i < j; i, j = i+1, j-1 {
+			times[i], times[j] = times[j], times[i]
+		}
+	}
+	return times
+}
@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTime(t *testing.T) {
+	london := Location{Latitude: 51.5074, Longitude: -0.1278}
+	sanFrancisco := Location{Latitude: 37.7749, Longitude: -122.4194}
+	tests := []struct {
+		name   string
+		loc    Location
+		date   time.Time
+		rising bool
+		want   time.Time
+	}{
+		{"london sunrise", london, time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), true, time.Date(2024, 6, 21, 3, 43, 0, 0, time.UTC)},
+		{"london sunset", london, time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), false, time.Date(2024, 6, 21, 20, 21, 0, 0, time.UTC)},
+		{"san francisco sunrise", sanFrancisco, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), true, time.Date(2024, 12, 21, 15, 21, 0, 0, time.UTC)},
+		// 16:54 local, already the next day in UTC.
+		{"san francisco sunset", sanFrancisco, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), false, time.Date(2024, 12, 22, 0, 54, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := sunTime(tt.date.Year(), tt.date.Month(), tt.date.Day(), tt.loc, tt.rising)
+		if !ok {
+			t.Fatalf("%s: expected an event", tt.name)
+		}
+		if diff := got.Sub(tt.want); diff < -3*time.Minute || diff > 3*time.Minute {
+			t.Fatalf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, ok := sunTime(2024, 6, 21, Location{Latitude: 69.65, Longitude: 18.96}, false); ok {
+		t.Fatalf("expected no sunset during the polar day")
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	for _, value := range []string{"", "sunset+", "sunset30m", "sunrise+13h", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSpec(value); err == nil {
+			t.Fatalf("expected %q to be rejected", value)
+		}
+	}
+	for _, value := range []string{"sunrise", "sunset-45m", "30 7 * * 1-5", "*/15 6-8 * * *", "0 0 1,15 * 7"} {
+		if _, err := ParseSpec(value); err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+	}
+}
+
+func TestSpecNextPrev(t *testing.T) {
+	loc := time.FixedZone("CEST", 2*3600)
+	// Friday.
+	now := time.Date(2024, 6, 21, 12, 0, 0, 0, loc)
+
+	weekdays, _ := ParseSpec("30 7 * * 1-5")
+	if got, _ := weekdays.Next(now, nil); !got.Equal(time.Date(2024, 6, 24, 7, 30, 0, 0, loc)) {
+		t.Fatalf("unexpected next weekday: %s", got)
+	}
+	if got, _ := weekdays.Prev(now, nil); !got.Equal(time.Date(2024, 6, 21, 7, 30, 0, 0, loc)) {
+		t.Fatalf("unexpected previous weekday: %s", got)
+	}
+	if got, _ := weekdays.Prev(time.Date(2024, 6, 21, 7, 30, 0, 0, loc), nil); !got.Equal(time.Date(2024, 6, 21, 7, 30, 0, 0, loc)) {
+		t.Fatalf("expected Prev to include t: %s", got)
+	}
+
+	// Either day field matches when both are restricted: the 1st or a Sunday.
+	either, _ := ParseSpec("0 0 1 * 0")
+	if got, _ := either.Next(now, nil); !got.Equal(time.Date(2024, 6, 23, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected next: %s", got)
+	}
+	// A starred field with a step is still unrestricted, so both must match.
+	for _, tt := range []struct {
+		value string
+		want  time.Time
+	}{
+		{"0 8 */1 * 1", time.Date(2024, 6, 24, 8, 0, 0, 0, loc)},
+		{"0 8 */2 * 1", time.Date(2024, 7, 1, 8, 0, 0, 0, loc)},
+		{"0 8 1 * */2", time.Date(2024, 8, 1, 8, 0, 0, 0, loc)},
+	} {
+		spec, err := ParseSpec(tt.value)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.value, err)
+		}
+		if got, _ := spec.Next(now, nil); !got.Equal(tt.want) {
+			t.Fatalf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	sunset, _ := ParseSpec("sunset+30m")
+	if _, ok := sunset.Next(now, nil); ok {
+		t.Fatalf("expected no sun events without a location")
+	}
+	berlin := &Location{Latitude: 52.52, Longitude: 13.405}
+	got, ok := sunset.Next(now, berlin)
+	// Sunset in Berlin on 21 June is at 21:33 CEST.
+	want := time.Date(2024, 6, 21, 22, 3, 0, 0, loc)
+	if !ok || got.Sub(want) > 3*time.Minute || want.Sub(got) > 3*time.Minute {
+		t.Fatalf("unexpected next sunset: %s", got)
+	}
+	if got.Location() != loc {
+		t.Fatalf("expected the time zone of now, got %s", got.Location())
+	}
+	prev, ok := sunset.Prev(now, berlin)
+	if !ok || prev.Day() != 20 {
+		t.Fatalf("unexpected previous sunset: %s", prev)
+	}
+}
@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"math"
+	"time"
+)
+
+// Location is where sunrise and sunset are computed, in decimal degrees.
+// North and east are positive.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// sunZenith is the official zenith for sunrise and sunset: the sun's upper
+// limb on the horizon, corrected for refraction.
+const sunZenith = 90.833
+
+// sunTime returns the UTC time of sunrise or sunset on the local calendar
+// day y-m-d at loc, using the algorithm from the Almanac for Computers
+// (1990), accurate to a minute or two. ok is false on days the sun does not
+// rise or set, such as polar days and nights.
+func sunTime(y int, m time.Month, d int, loc Location, rising bool) (time.Time, bool) {
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	lngHour := loc.Longitude / 15
+
+	// Approximate time of the event, in hours from UTC midnight.
+	approx := 18 - lngHour
+	if rising {
+		approx = 6 - lngHour
+	}
+	t := float64(midnight.YearDay()) + approx/24
+
+	meanAnomaly := 0.9856*t - 3.289
+	trueLong := normalizeDegrees(meanAnomaly + 1.916*sinDeg(meanAnomaly) + 0.020*sinDeg(2*meanAnomaly) + 282.634)
+
+	rightAsc := normalizeDegrees(atanDeg(0.91764 * tanDeg(trueLong)))
+	rightAsc += math.Floor(trueLong/90)*90 - math.Floor(rightAsc/90)*90
+	rightAsc /= 15
+
+	sinDec := 0.39782 * sinDeg(trueLong)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosHour := (cosDeg(sunZenith) - sinDec*sinDeg(loc.Latitude)) / (cosDec * cosDeg(loc.Latitude))
+	if cosHour > 1 || cosHour < -1 {
+		return time.Time{}, false
+	}
+
+	hour := acosDeg(cosHour)
+	if rising {
+		hour = 360 - hour
+	}
+	hour /= 15
+
+	localMean := hour + rightAsc - 0.06571*t - 6.622
+	ut := localMean - lngHour
+	// The formula is periodic in days; pick the occurrence next to the
+	// approximation so days west of Greenwich keep their evening sunset.
+	ut += 24 * math.Round((approx-ut)/24)
+
+	return midnight.Add(time.Duration(ut * float64(time.Hour))).Truncate(time.Second), true
+}
+
+func normalizeDegrees(v float64) float64 {
+	v = math.Mod(v, 360)
+	if v < 0 {
+		v += 360
+	}
+	return v
+}
+
+func sinDeg(v float64) float64  { return math.Sin(v * math.Pi / 180) }
+func cosDeg(v float64) float64  { return math.Cos(v * math.Pi / 180) }
+func tanDeg(v float64) float64  { return math.Tan(v * math.Pi / 180) }
+func atanDeg(v float64) float64 { return math.Atan(v) * 180 / math.Pi }
+func acosDeg(v float64) float64 { return math.Acos(v) * 180 / math.Pi }
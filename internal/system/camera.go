@@ -6,14 +6,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/xpereta/RaspiCam/internal/host"
 )
 
 var cameraCodes = []string{"imx708", "imx477", "imx219", "ov5647"}
 
-func cameraModel() string {
+func cameraModel(env host.Env) string {
 	code := findCameraCode([]string{
-		"/sys/firmware/devicetree/base",
-		"/proc/device-tree",
+		env.Path("/sys/firmware/devicetree/base"),
+		env.Path("/proc/device-tree"),
 	})
 	if code == "" {
 		return "Unknown camera"
@@ -3,38 +3,40 @@ package system
 import (
 	"bufio"
 	"io"
-	"os"
 	"strings"
+
+	"github.com/xpereta/RaspiCam/internal/host"
 )
 
 type Info struct {
-	Model     string
-	Camera    string
-	OSName    string
-	OSVersion string
-	OSLabel   string
+	Model     string `json:"model"`
+	Camera    string `json:"camera"`
+	OSName    string `json:"osName"`
+	OSVersion string `json:"osVersion"`
+	OSLabel   string `json:"osLabel"`
 }
 
-func Collect() Info {
-	pretty := osPrettyName()
-	name := osName()
-	version := osVersion()
+func Collect(env host.Env) Info {
+	fields := osRelease(env)
+	pretty := osPrettyName(fields)
+	name := osName(fields)
+	version := osVersion(fields)
 	return Info{
-		Model:     deviceModel(),
-		Camera:    cameraModel(),
+		Model:     deviceModel(env),
+		Camera:    cameraModel(env),
 		OSName:    name,
 		OSVersion: version,
 		OSLabel:   buildOSLabel(pretty, name, version),
 	}
 }
 
-func deviceModel() string {
+func deviceModel(env host.Env) string {
 	paths := []string{
 		"/proc/device-tree/model",
 		"/sys/firmware/devicetree/base/model",
 	}
 	for _, path := range paths {
-		b, err := os.ReadFile(path)
+		b, err := env.ReadFile(path)
 		if err != nil {
 			continue
 		}
@@ -47,9 +49,9 @@ func deviceModel() string {
 	return "unknown"
 }
 
-func osRelease() map[string]string {
+func osRelease(env host.Env) map[string]string {
 	result := map[string]string{}
-	file, err := os.Open("/etc/os-release")
+	file, err := env.Open("/etc/os-release")
 	if err != nil {
 		return result
 	}
@@ -57,16 +59,14 @@ func osRelease() map[string]string {
 	return parseOSRelease(file)
 }
 
-func osName() string {
-	fields := osRelease()
+func osName(fields map[string]string) string {
 	if name := fields["NAME"]; name != "" {
 		return name
 	}
 	return "unknown"
 }
 
-func osVersion() string {
-	fields := osRelease()
+func osVersion(fields map[string]string) string {
 	if version := fields["VERSION"]; version != "" {
 		return version
 	}
@@ -76,8 +76,7 @@ func osVersion() string {
 	return "unknown"
 }
 
-func osPrettyName() string {
-	fields := osRelease()
+func osPrettyName(fields map[string]string) string {
 	if name := fields["PRETTY_NAME"]; name != "" {
 		return name
 	}
@@ -3,6 +3,8 @@ package system
 import (
 	"strings"
 	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
 )
 
 func TestParseOSRelease(t *testing.T) {
@@ -48,3 +50,16 @@ func TestMapCameraModel(t *testing.T) {
 		t.Fatalf("unexpected model: %q", got)
 	}
 }
+
+func TestCollectSyntheticPi(t *testing.T) {
+	info := Collect(hosttest.SyntheticPi())
+	if info.Model != "Raspberry Pi Zero 2 W Rev 1.0" {
+		t.Fatalf("unexpected model: %q", info.Model)
+	}
+	if info.Camera != "Pi Camera v3 (imx708)" {
+		t.Fatalf("unexpected camera: %q", info.Camera)
+	}
+	if info.OSLabel != "Raspbian GNU/Linux 12 (bookworm)" || info.OSVersion != "12 (bookworm)" {
+		t.Fatalf("unexpected os: %+v", info)
+	}
+}
@@ -6,54 +6,73 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
 )
 
+// NetworkSnapshot is the state of the default route interface. Pointer
+// fields are nil, null in JSON, when the value could not be read or, for the
+// WiFi fields, when the interface is not wireless.
 type NetworkSnapshot struct {
-	Interface        string
-	IPAddress        string
-	RxBytesPerSec    *float64
-	TxBytesPerSec    *float64
-	WiFiSSID         string
-	WiFiTxRate       string
-	WiFiRxRate       string
-	WiFiLinkQuality  string
+	Interface        *string  `json:"interface"`
+	IPAddress        *string  `json:"ipAddress"`
+	RxBytesPerSec    *float64 `json:"rxBytesPerSec"`
+	TxBytesPerSec    *float64 `json:"txBytesPerSec"`
+	WiFiSSID         *string  `json:"wifiSSID"`
+	WiFiTxRate       *string  `json:"wifiTxRate"`
+	WiFiRxRate       *string  `json:"wifiRxRate"`
+	WiFiLinkQuality  *string  `json:"wifiLinkQuality"`
 	wirelessDetected bool
 }
 
-func CollectNetwork(ctx context.Context) (NetworkSnapshot, []string) {
+// InterfaceCounters holds the cumulative byte counters of one interface from
+// /proc/net/dev.
+type InterfaceCounters struct {
+	Name    string
+	RxBytes uint64
+	TxBytes uint64
+}
+
+// WirelessLink holds the raw link values of one interface from
+// /proc/net/wireless.
+type WirelessLink struct {
+	Interface   string
+	LinkQuality float64
+	SignalDBm   *float64
+}
+
+func CollectNetwork(ctx context.Context, env host.Env) (NetworkSnapshot, []string) {
 	var warnings []string
 
-	iface, err := defaultRouteInterface()
+	iface, err := defaultRouteInterface(env)
 	if err != nil {
 		warnings = append(warnings, fmt.Sprintf("Default route unavailable: %v", err))
 	}
 	if iface == "" {
-		iface = firstActiveInterface()
+		iface = firstActiveInterface(env)
 		if iface == "" {
 			warnings = append(warnings, "Network interface unavailable")
 		}
 	}
 
 	snap := NetworkSnapshot{
-		Interface: iface,
+		Interface: optional(iface),
 	}
 
 	if iface != "" {
-		ip, err := interfaceIPv4(iface)
+		ip, err := InterfaceIPv4(env, iface)
 		if err != nil {
 			warnings = append(warnings, fmt.Sprintf("IP address unavailable: %v", err))
 		} else if ip == "" {
 			warnings = append(warnings, "IP address unavailable: no IPv4 found")
 		} else {
-			snap.IPAddress = ip
+			snap.IPAddress = &ip
 		}
 
-		rxRate, txRate, err := sampleNetRates(ctx, iface, 200*time.Millisecond)
+		rxRate, txRate, err := sampleNetRates(ctx, env, iface, 200*time.Millisecond)
 		if err != nil {
 			warnings = append(warnings, fmt.Sprintf("Network rates unavailable: %v", err))
 		} else {
@@ -61,22 +80,22 @@ func CollectNetwork(ctx context.Context) (NetworkSnapshot, []string) {
 			snap.TxBytesPerSec = &txRate
 		}
 
-		quality, ok, err := wifiLinkQuality(iface)
+		quality, ok, err := wifiLinkQuality(env, iface)
 		if err != nil {
 			warnings = append(warnings, fmt.Sprintf("WiFi quality unavailable: %v", err))
 		} else if ok {
-			snap.WiFiLinkQuality = quality
+			snap.WiFiLinkQuality = &quality
 			snap.wirelessDetected = true
 		}
 
 		if snap.wirelessDetected {
-			ssid, txRate, rxRate, err := wifiLinkInfo(ctx, iface)
+			ssid, txRate, rxRate, err := wifiLinkInfo(ctx, env, iface)
 			if err != nil {
 				warnings = append(warnings, fmt.Sprintf("WiFi details unavailable: %v", err))
 			} else {
-				snap.WiFiSSID = ssid
-				snap.WiFiTxRate = txRate
-				snap.WiFiRxRate = rxRate
+				snap.WiFiSSID = optional(ssid)
+				snap.WiFiTxRate = optional(txRate)
+				snap.WiFiRxRate = optional(rxRate)
 			}
 		}
 	}
@@ -84,8 +103,16 @@ func CollectNetwork(ctx context.Context) (NetworkSnapshot, []string) {
 	return snap, warnings
 }
 
-func defaultRouteInterface() (string, error) {
-	file, err := os.Open("/proc/net/route")
+// optional returns nil for an empty value, which was not found.
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func defaultRouteInterface(env host.Env) (string, error) {
+	file, err := env.Open("/proc/net/route")
 	if err != nil {
 		return "", err
 	}
@@ -114,8 +141,8 @@ func defaultRouteInterface() (string, error) {
 	return "", nil
 }
 
-func firstActiveInterface() string {
-	ifaces, err := net.Interfaces()
+func firstActiveInterface(env host.Env) string {
+	ifaces, err := env.Net.Interfaces()
 	if err != nil {
 		return ""
 	}
@@ -123,7 +150,7 @@ func firstActiveInterface() string {
 		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
 			continue
 		}
-		addrs, err := iface.Addrs()
+		addrs, err := env.Net.InterfaceAddrs(iface.Name)
 		if err != nil {
 			continue
 		}
@@ -140,12 +167,9 @@ func firstActiveInterface() string {
 	return ""
 }
 
-func interfaceIPv4(name string) (string, error) {
-	iface, err := net.InterfaceByName(name)
-	if err != nil {
-		return "", err
-	}
-	addrs, err := iface.Addrs()
+// InterfaceIPv4 returns the first non-loopback IPv4 address of an interface.
+func InterfaceIPv4(env host.Env, name string) (string, error) {
+	addrs, err := env.Net.InterfaceAddrs(name)
 	if err != nil {
 		return "", err
 	}
@@ -163,8 +187,8 @@ func interfaceIPv4(name string) (string, error) {
 	return "", nil
 }
 
-func sampleNetRates(ctx context.Context, iface string, delay time.Duration) (float64, float64, error) {
-	rx1, tx1, err := readNetDevBytes(iface)
+func sampleNetRates(ctx context.Context, env host.Env, iface string, delay time.Duration) (float64, float64, error) {
+	rx1, tx1, err := readNetDevBytes(env, iface)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -177,7 +201,7 @@ func sampleNetRates(ctx context.Context, iface string, delay time.Duration) (flo
 	case <-timer.C:
 	}
 
-	rx2, tx2, err := readNetDevBytes(iface)
+	rx2, tx2, err := readNetDevBytes(env, iface)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -196,8 +220,8 @@ func sampleNetRates(ctx context.Context, iface string, delay time.Duration) (flo
 	return rxRate, txRate, nil
 }
 
-func readNetDevBytes(iface string) (uint64, uint64, error) {
-	file, err := os.Open("/proc/net/dev")
+func readNetDevBytes(env host.Env, iface string) (uint64, uint64, error) {
+	file, err := env.Open("/proc/net/dev")
 	if err != nil {
 		return 0, 0, err
 	}
@@ -223,6 +247,34 @@ func readNetDevBytes(iface string) (uint64, uint64, error) {
 	return 0, 0, fmt.Errorf("interface not found: %s", iface)
 }
 
+func ReadNetDevCounters(env host.Env) ([]InterfaceCounters, error) {
+	file, err := env.Open("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var counters []InterfaceCounters
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "Inter-") || strings.HasPrefix(line, "face") {
+			continue
+		}
+		c, ok, err := parseNetDevCounters(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			counters = append(counters, c)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
 func parseNetDevLine(line, iface string) (uint64, uint64, bool, error) {
 	fields := strings.Fields(line)
 	if len(fields) < 17 {
@@ -232,19 +284,35 @@ func parseNetDevLine(line, iface string) (uint64, uint64, bool, error) {
 	if name != iface {
 		return 0, 0, false, nil
 	}
+	c, ok, err := parseNetDevCounters(line)
+	if err != nil || !ok {
+		return 0, 0, false, err
+	}
+	return c.RxBytes, c.TxBytes, true, nil
+}
+
+func parseNetDevCounters(line string) (InterfaceCounters, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 17 {
+		return InterfaceCounters{}, false, nil
+	}
 	rxBytes, err := strconv.ParseUint(fields[1], 10, 64)
 	if err != nil {
-		return 0, 0, false, fmt.Errorf("parse rx bytes: %w", err)
+		return InterfaceCounters{}, false, fmt.Errorf("parse rx bytes: %w", err)
 	}
 	txBytes, err := strconv.ParseUint(fields[9], 10, 64)
 	if err != nil {
-		return 0, 0, false, fmt.Errorf("parse tx bytes: %w", err)
+		return InterfaceCounters{}, false, fmt.Errorf("parse tx bytes: %w", err)
 	}
-	return rxBytes, txBytes, true, nil
+	return InterfaceCounters{
+		Name:    strings.TrimSuffix(fields[0], ":"),
+		RxBytes: rxBytes,
+		TxBytes: txBytes,
+	}, true, nil
 }
 
-func wifiLinkQuality(iface string) (string, bool, error) {
-	file, err := os.Open("/proc/net/wireless")
+func wifiLinkQuality(env host.Env, iface string) (string, bool, error) {
+	file, err := env.Open("/proc/net/wireless")
 	if err != nil {
 		return "", false, err
 	}
@@ -270,6 +338,34 @@ func wifiLinkQuality(iface string) (string, bool, error) {
 	return "", false, nil
 }
 
+func ReadWirelessLinks(env host.Env) ([]WirelessLink, error) {
+	file, err := env.Open("/proc/net/wireless")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var links []WirelessLink
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "Inter-") || strings.HasPrefix(line, "face") {
+			continue
+		}
+		link, ok, err := parseWirelessLink(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			links = append(links, link)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
 func parseWirelessLine(line, iface string) (string, bool, error) {
 	fields := strings.Fields(line)
 	if len(fields) < 4 {
@@ -279,19 +375,38 @@ func parseWirelessLine(line, iface string) (string, bool, error) {
 	if name != iface {
 		return "", false, nil
 	}
-	link, err := parseWirelessValue(fields[2])
+	link, ok, err := parseWirelessLink(line)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	quality := fmt.Sprintf("%.0f/70", link.LinkQuality)
+	if link.SignalDBm != nil {
+		quality = fmt.Sprintf("%s (%.0f dBm)", quality, *link.SignalDBm)
+	}
+	return quality, true, nil
+}
+
+func parseWirelessLink(line string) (WirelessLink, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return WirelessLink{}, false, nil
+	}
+	quality, err := parseWirelessValue(fields[2])
 	if err != nil {
-		return "", false, fmt.Errorf("parse link quality: %w", err)
+		return WirelessLink{}, false, fmt.Errorf("parse link quality: %w", err)
 	}
 	level, err := parseWirelessValue(fields[3])
 	if err != nil {
-		return "", false, fmt.Errorf("parse signal level: %w", err)
+		return WirelessLink{}, false, fmt.Errorf("parse signal level: %w", err)
+	}
+	link := WirelessLink{
+		Interface:   strings.TrimSuffix(fields[0], ":"),
+		LinkQuality: quality,
 	}
-	quality := fmt.Sprintf("%.0f/70", link)
 	if level != 0 && level > -200 {
-		quality = fmt.Sprintf("%s (%.0f dBm)", quality, level)
+		link.SignalDBm = &level
 	}
-	return quality, true, nil
+	return link, true, nil
 }
 
 func parseWirelessValue(value string) (float64, error) {
@@ -299,9 +414,8 @@ func parseWirelessValue(value string) (float64, error) {
 	return strconv.ParseFloat(value, 64)
 }
 
-func wifiLinkInfo(ctx context.Context, iface string) (string, string, string, error) {
-	cmd := exec.CommandContext(ctx, "iw", "dev", iface, "link")
-	out, err := cmd.Output()
+func wifiLinkInfo(ctx context.Context, env host.Env, iface string) (string, string, string, error) {
+	out, err := env.Runner.Output(ctx, "iw", "dev", iface, "link")
 	if err != nil {
 		return "", "", "", err
 	}
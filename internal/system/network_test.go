@@ -1,6 +1,14 @@
 package system
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
 
 func TestParseNetDevLine(t *testing.T) {
 	line := "wlan0: 12345 0 0 0 0 0 0 0 67890 0 0 0 0 0 0 0"
@@ -45,3 +53,72 @@ func TestParseWirelessLine(t *testing.T) {
 		t.Fatalf("expected no match")
 	}
 }
+
+func TestParseNetDevCounters(t *testing.T) {
+	c, ok, err := parseNetDevCounters("eth0: 100 0 0 0 0 0 0 0 200 0 0 0 0 0 0 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok || c.Name != "eth0" || c.RxBytes != 100 || c.TxBytes != 200 {
+		t.Fatalf("unexpected counters: %+v", c)
+	}
+	if _, ok, _ := parseNetDevCounters("short line"); ok {
+		t.Fatalf("expected short line to be skipped")
+	}
+}
+
+func TestParseWirelessLink(t *testing.T) {
+	link, ok, err := parseWirelessLink("wlan0: 0000   54.  -42.  0.  0 0 0 0 0 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok || link.Interface != "wlan0" || link.LinkQuality != 54 {
+		t.Fatalf("unexpected link: %+v", link)
+	}
+	if link.SignalDBm == nil || *link.SignalDBm != -42 {
+		t.Fatalf("unexpected signal: %v", link.SignalDBm)
+	}
+}
+
+func TestCollectNetworkSyntheticPi(t *testing.T) {
+	snap, warnings := CollectNetwork(context.Background(), hosttest.SyntheticPi())
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+	if snap.Interface == nil || *snap.Interface != "wlan0" || snap.IPAddress == nil || *snap.IPAddress != "192.168.1.42" {
+		t.Fatalf("unexpected interface: %+v", snap)
+	}
+	if snap.RxBytesPerSec == nil || snap.TxBytesPerSec == nil {
+		t.Fatalf("expected network rates")
+	}
+	if snap.WiFiSSID == nil || *snap.WiFiSSID != "workshop" || snap.WiFiLinkQuality == nil || *snap.WiFiLinkQuality != "54/70 (-56 dBm)" {
+		t.Fatalf("unexpected wifi: %+v", snap)
+	}
+	if snap.WiFiRxRate == nil || *snap.WiFiRxRate != "65.0 MBit/s MCS 7" {
+		t.Fatalf("unexpected rx rate: %v", snap.WiFiRxRate)
+	}
+}
+
+func TestCollectNetworkUnknownIsNull(t *testing.T) {
+	env := host.Env{Root: t.TempDir(), Runner: hosttest.FixtureRunner{Dir: t.TempDir()}, Net: hosttest.StaticNetwork{}}
+	snap, _ := CollectNetwork(context.Background(), env)
+	out, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, field := range []string{`"interface":null`, `"ipAddress":null`, `"wifiSSID":null`, `"wifiTxRate":null`, `"wifiRxRate":null`, `"wifiLinkQuality":null`} {
+		if !strings.Contains(string(out), field) {
+			t.Errorf("expected %s in %s", field, out)
+		}
+	}
+}
+
+func TestReadNetDevCountersSyntheticPi(t *testing.T) {
+	counters, err := ReadNetDevCounters(hosttest.SyntheticPi())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(counters) != 2 || counters[1].Name != "wlan0" || counters[1].TxBytes != 52613498213 {
+		t.Fatalf("unexpected counters: %+v", counters)
+	}
+}
@@ -0,0 +1,532 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/sampler"
+	"github.com/xpereta/RaspiCam/internal/system"
+)
+
+const maxAPIBodyBytes = 64 << 10
+
+// revisionCheckHeader is set to "skipped" on a save sent without a
+// revision, which overwrites whatever changed since the client read the
+// config.
+const revisionCheckHeader = "X-Revision-Check"
+
+type apiStatus struct {
+	GeneratedAt time.Time              `json:"generatedAt"`
+	Hostname    string                 `json:"hostname"`
+	IPAddress   *string                `json:"ipAddress"`
+	Device      system.Info            `json:"device"`
+	Metrics     metrics.Snapshot       `json:"metrics"`
+	MediaMTX    mediamtx.Status        `json:"mediamtx"`
+	Network     system.NetworkSnapshot `json:"network"`
+	Camera      apiCamera              `json:"camera"`
+	Cameras     []apiCamera            `json:"cameras"`
+	Retention   apiRetention           `json:"retention"`
+	Warnings    []string               `json:"warnings"`
+}
+
+type apiCamera struct {
+	Path        string              `json:"path"`
+	Config      config.CameraConfig `json:"config"`
+	LastUpdated *time.Time          `json:"lastUpdated"`
+	// Drift lists live parameters whose running value is not in the file.
+	Drift []config.FieldDrift `json:"drift"`
+	URLs  []config.StreamURL  `json:"urls"`
+}
+
+// apiRecord is the recording config of a path: Config the keys it sets
+// and Settings what applies once pathDefaults are taken into account.
+type apiRecord struct {
+	Path     string                `json:"path"`
+	Config   config.RecordConfig   `json:"config"`
+	Settings config.RecordSettings `json:"settings"`
+}
+
+type apiHistory struct {
+	IntervalSeconds float64         `json:"intervalSeconds"`
+	Points          []sampler.Point `json:"points"`
+}
+
+type apiError struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+	// Revision is the current config revision on a 409 conflict.
+	Revision string `json:"revision,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// cameraRequest is a partial camera update; omitted fields keep their
+// current value. Path selects the camera and defaults to the primary one.
+type cameraRequest struct {
+	Path *string `json:"path"`
+	// Revision is the config revision the update is based on. A stale
+	// revision fails with 409.
+	Revision     *string       `json:"revision"`
+	VFlip        *bool         `json:"vFlip"`
+	HFlip        *bool         `json:"hFlip"`
+	Width        *int          `json:"width"`
+	Height       *int          `json:"height"`
+	AWB          *string       `json:"awb"`
+	Mode         *string       `json:"mode"`
+	AfMode       *string       `json:"afMode"`
+	LensPosition optionalFloat `json:"lensPosition"`
+	// Params sets schema parameters without a dedicated field, keyed by
+	// YAML key. null or "" removes the key.
+	Params map[string]json.RawMessage `json:"params"`
+	// Live applies the update to the running path through the Control API
+	// instead of saving it. Only live parameters may change.
+	Live bool `json:"live"`
+}
+
+// recordRequest is a partial update of the recording keys of a path, keyed
+// by YAML key in Params; null or "" removes a key. Path and Revision work
+// as in cameraRequest.
+type recordRequest struct {
+	Path     *string                    `json:"path"`
+	Revision *string                    `json:"revision"`
+	Params   map[string]json.RawMessage `json:"params"`
+}
+
+// optionalFloat distinguishes an omitted field from an explicit null.
+type optionalFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (o *optionalFloat) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
+
+func (s *Server) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	data := s.collectStatus(r.Context())
+	cameras := make([]apiCamera, 0, len(data.Cameras))
+	for _, camera := range data.Cameras {
+		apiCam := newAPICamera(camera.Path, camera.Config, camera.Drift, data.LastUpdated, data.HasUpdated)
+		apiCam.URLs = nonNilStreamURLs(camera.URLs)
+		cameras = append(cameras, apiCam)
+	}
+	writeJSON(w, http.StatusOK, apiStatus{
+		GeneratedAt: data.GeneratedAt,
+		Hostname:    data.Hostname,
+		IPAddress:   optionalString(data.IPAddress),
+		Device:      data.Device,
+		Metrics:     data.Metrics,
+		MediaMTX:    data.MediaMTX,
+		Network:     data.Network,
+		Camera:      cameras[0],
+		Cameras:     cameras,
+		Retention:   s.apiRetention(),
+		Warnings:    nonNilStrings(data.Warnings),
+	})
+}
+
+func (s *Server) handleAPIHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	if s.sampler == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "history unavailable", nil)
+		return
+	}
+
+	hours := 1.0
+	if value := r.URL.Query().Get("hours"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "hours must be a positive number", []fieldError{{Field: "hours", Message: "must be a positive number"}})
+			return
+		}
+		hours = parsed
+	}
+
+	since := time.Now().Add(-time.Duration(hours * float64(time.Hour)))
+	writeJSON(w, http.StatusOK, apiHistory{
+		IntervalSeconds: s.sampler.Interval().Seconds(),
+		Points:          s.sampler.Since(since),
+	})
+}
+
+func (s *Server) handleAPICamera(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		path, ok := s.resolveCameraPath(r.URL.Query().Get("path"))
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "unknown camera path", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+			return
+		}
+		s.writeAPICamera(r.Context(), w, path)
+	case http.MethodPut:
+		s.handleAPICameraUpdate(w, r)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+	}
+}
+
+func (s *Server) handleAPICameraUpdate(w http.ResponseWriter, r *http.Request) {
+	var req cameraRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	name := r.URL.Query().Get("path")
+	if req.Path != nil {
+		name = *req.Path
+	}
+	path, ok := s.resolveCameraPath(name)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+		return
+	}
+
+	current, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("camera config unavailable: %v", err), nil)
+		return
+	}
+
+	if req.Revision != nil {
+		current.Revision = *req.Revision
+	}
+	cfg, fieldErrs := applyCameraRequest(current, req)
+	if len(fieldErrs) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", fieldErrs)
+		return
+	}
+
+	if req.Live {
+		if err := s.applyLiveConfig(r.Context(), path, cfg); err != nil {
+			var static *config.StaticChangeError
+			if errors.As(err, &static) {
+				writeAPIError(w, http.StatusUnprocessableEntity, err.Error(), staticChangeFieldErrors(static.Keys))
+				return
+			}
+			writeAPIError(w, http.StatusBadGateway, fmt.Sprintf("live apply failed: %v", err), nil)
+			return
+		}
+		s.writeAPICamera(r.Context(), w, path)
+		return
+	}
+
+	if req.Revision == nil || *req.Revision == "" {
+		w.Header().Set(revisionCheckHeader, "skipped")
+	}
+	if err := s.saveCameraConfig(r.Context(), path, cfg); err != nil {
+		writeAPISaveError(w, err)
+		return
+	}
+
+	s.writeAPICamera(r.Context(), w, path)
+}
+
+// writeAPISaveError answers a failed save: 409 with the differences for a
+// conflict, 502 for a rollback and 500 otherwise.
+func writeAPISaveError(w http.ResponseWriter, err error) {
+	var rollback *rollbackError
+	var conflict *config.ConflictError
+	if errors.As(err, &conflict) {
+		writeJSON(w, http.StatusConflict, apiError{
+			Error:    err.Error(),
+			Fields:   conflictFieldErrors(conflict.Fields),
+			Revision: conflict.Revision,
+		})
+		return
+	}
+	if errors.As(err, &rollback) {
+		writeAPIError(w, http.StatusBadGateway, rollback.Error(), nil)
+		return
+	}
+	writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("save failed: %v", err), nil)
+}
+
+func (s *Server) handleAPIRecord(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		path, ok := s.resolveCameraPath(r.URL.Query().Get("path"))
+		if !ok {
+			writeAPIError(w, http.StatusNotFound, "unknown camera path", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+			return
+		}
+		s.writeAPIRecord(w, path)
+	case http.MethodPut:
+		s.handleAPIRecordUpdate(w, r)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+	}
+}
+
+func (s *Server) handleAPIRecordUpdate(w http.ResponseWriter, r *http.Request) {
+	var req recordRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	name := r.URL.Query().Get("path")
+	if req.Path != nil {
+		name = *req.Path
+	}
+	path, ok := s.resolveCameraPath(name)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+		return
+	}
+
+	cfg, err := config.LoadRecordConfig(s.configPath, path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("recording config unavailable: %v", err), nil)
+		return
+	}
+	if req.Revision != nil {
+		cfg.Revision = *req.Revision
+	}
+	var errs []fieldError
+	for _, key := range sortedKeys(req.Params) {
+		field := "params." + key
+		p, ok := config.LookupRecordParam(key)
+		if !ok {
+			errs = append(errs, fieldError{Field: field, Message: "unknown recording key"})
+			continue
+		}
+		value, err := p.Validate(rawParamValue(req.Params[key]))
+		if err == nil {
+			err = cfg.SetValue(key, value)
+		}
+		if err != nil {
+			errs = append(errs, fieldError{Field: field, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", errs)
+		return
+	}
+
+	if req.Revision == nil || *req.Revision == "" {
+		w.Header().Set(revisionCheckHeader, "skipped")
+	}
+	if err := s.saveRecordConfig(r.Context(), path, cfg); err != nil {
+		writeAPISaveError(w, err)
+		return
+	}
+	s.writeAPIRecord(w, path)
+}
+
+// writeAPIRecord answers with the recording keys path sets and the
+// settings that apply to it.
+func (s *Server) writeAPIRecord(w http.ResponseWriter, path string) {
+	cfg, err := config.LoadRecordConfig(s.configPath, path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("recording config unavailable: %v", err), nil)
+		return
+	}
+	settings, err := config.LoadRecordSettings(s.configPath, path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("recording settings unavailable: %v", err), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiRecord{Path: path, Config: cfg, Settings: settings})
+}
+
+// writeAPICamera answers with the saved config of path and, when MediaMTX
+// is reachable, the live parameters it runs with a different value.
+func (s *Server) writeAPICamera(ctx context.Context, w http.ResponseWriter, path string) {
+	cfg, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("camera config unavailable: %v", err), nil)
+		return
+	}
+	lastUpdated, ok, err := config.ConfigModTime(s.configPath)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("camera update time unavailable: %v", err), nil)
+		return
+	}
+	drift, _ := s.cameraDrift(ctx, path, cfg)
+	camera := newAPICamera(path, cfg, drift, lastUpdated, ok)
+	camera.URLs = s.cameraStreamURLs(path)
+	writeJSON(w, http.StatusOK, camera)
+}
+
+func applyCameraRequest(cfg config.CameraConfig, req cameraRequest) (config.CameraConfig, []fieldError) {
+	var errs []fieldError
+
+	if req.VFlip != nil {
+		cfg.VFlip = *req.VFlip
+	}
+	if req.HFlip != nil {
+		cfg.HFlip = *req.HFlip
+	}
+	if req.Width != nil || req.Height != nil {
+		if req.Width == nil || req.Height == nil {
+			errs = append(errs, fieldError{Field: "width", Message: "width and height must be set together"})
+		} else if resolutionLabel(*req.Width, *req.Height) == "" {
+			errs = append(errs, fieldError{Field: "width", Message: fmt.Sprintf("unsupported resolution %dx%d", *req.Width, *req.Height)})
+		} else {
+			cfg.Width = *req.Width
+			cfg.Height = *req.Height
+		}
+	}
+	if req.AWB != nil {
+		if v, err := config.ValidateParam("rpiCameraAWB", *req.AWB); err != nil || v == "" {
+			errs = append(errs, fieldError{Field: "awb", Message: fmt.Sprintf("unsupported value %q", *req.AWB)})
+		} else {
+			cfg.AWB = v
+		}
+	}
+	if req.Mode != nil {
+		if v, err := config.ValidateParam("rpiCameraMode", *req.Mode); err != nil {
+			errs = append(errs, fieldError{Field: "mode", Message: fmt.Sprintf("unsupported value %q", *req.Mode)})
+		} else {
+			cfg.Mode = v
+		}
+	}
+	if req.AfMode != nil {
+		if v, err := config.ValidateParam("rpiCameraAfMode", *req.AfMode); err != nil || v == "" {
+			errs = append(errs, fieldError{Field: "afMode", Message: fmt.Sprintf("unsupported value %q", *req.AfMode)})
+		} else {
+			cfg.AfMode = v
+		}
+	}
+	if req.LensPosition.Set {
+		if req.LensPosition.Value != nil && *req.LensPosition.Value < 0 {
+			errs = append(errs, fieldError{Field: "lensPosition", Message: "must be zero or positive"})
+		} else {
+			cfg.LensPosition = req.LensPosition.Value
+			cfg.LensPositionSet = true
+		}
+	}
+
+	for _, key := range sortedKeys(req.Params) {
+		field := "params." + key
+		p, ok := config.LookupParam(key)
+		if !ok {
+			errs = append(errs, fieldError{Field: field, Message: "unknown parameter"})
+			continue
+		}
+		if p.Field != "" {
+			errs = append(errs, fieldError{Field: field, Message: fmt.Sprintf("set through %q instead", p.Field)})
+			continue
+		}
+		value, err := p.Validate(rawParamValue(req.Params[key]))
+		if err != nil {
+			errs = append(errs, fieldError{Field: field, Message: err.Error()})
+			continue
+		}
+		if err := cfg.SetValue(key, value); err != nil {
+			errs = append(errs, fieldError{Field: field, Message: err.Error()})
+		}
+	}
+
+	return cfg, errs
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// conflictFieldErrors names conflicting parameters by their request field.
+func conflictFieldErrors(fields []config.FieldConflict) []fieldError {
+	errs := make([]fieldError, 0, len(fields))
+	for _, f := range fields {
+		field := "params." + f.Key
+		if p, ok := config.LookupParam(f.Key); ok && p.Field != "" {
+			field = p.Field
+		}
+		errs = append(errs, fieldError{Field: field, Message: fmt.Sprintf("file has %q, request has %q", f.Current, f.Submitted)})
+	}
+	return errs
+}
+
+// rawParamValue accepts a parameter as a JSON string, number or bool; null
+// becomes the empty value.
+func rawParamValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	value := strings.TrimSpace(string(raw))
+	if value == "null" {
+		return ""
+	}
+	return value
+}
+
+func newAPICamera(path string, cfg config.CameraConfig, drift []config.FieldDrift, updated time.Time, ok bool) apiCamera {
+	camera := apiCamera{Path: path, Config: cfg, Drift: drift, URLs: []config.StreamURL{}}
+	if camera.Drift == nil {
+		camera.Drift = []config.FieldDrift{}
+	}
+	if ok {
+		camera.LastUpdated = &updated
+	}
+	return camera
+}
+
+func optionalString(value string) *string {
+	if value == "" || value == "unavailable" || value == "unknown" {
+		return nil
+	}
+	return &value
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string, fields []fieldError) {
+	writeJSON(w, status, apiError{Error: strings.TrimSpace(message), Fields: fields})
+}
@@ -0,0 +1,320 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/sampler"
+)
+
+func TestApplyCameraRequest(t *testing.T) {
+	var req cameraRequest
+	body := `{"vFlip": true, "width": 1920, "height": 1080, "awb": "cloudy", "lensPosition": null}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	lens := 1.5
+	current := config.CameraConfig{AWB: "auto", AfMode: "manual", LensPosition: &lens}
+	cfg, errs := applyCameraRequest(current, req)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	if !cfg.VFlip || cfg.Width != 1920 || cfg.Height != 1080 || cfg.AWB != "cloudy" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	if cfg.AfMode != "manual" {
+		t.Fatalf("expected omitted af mode to be kept")
+	}
+	if !cfg.LensPositionSet || cfg.LensPosition != nil {
+		t.Fatalf("expected lens position cleared")
+	}
+}
+
+func TestApplyCameraRequestInvalid(t *testing.T) {
+	var req cameraRequest
+	body := `{"width": 640, "height": 480, "awb": "nope", "afMode": "bad", "lensPosition": -1}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	_, errs := applyCameraRequest(config.CameraConfig{}, req)
+	fields := map[string]bool{}
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, want := range []string{"width", "awb", "afMode", "lensPosition"} {
+		if !fields[want] {
+			t.Fatalf("expected error for %s, got %+v", want, errs)
+		}
+	}
+}
+
+func TestApplyCameraRequestParams(t *testing.T) {
+	var req cameraRequest
+	body := `{"params": {"rpiCameraSaturation": 1.4, "rpiCameraMetering": "spot", "rpiCameraHDR": true, "rpiCameraBitrate": null}}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	cfg, errs := applyCameraRequest(config.CameraConfig{}, req)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	want := map[string]string{
+		"rpiCameraSaturation": "1.4",
+		"rpiCameraMetering":   "spot",
+		"rpiCameraHDR":        "true",
+		"rpiCameraBitrate":    "",
+	}
+	for key, value := range want {
+		if got, ok := cfg.Params[key]; !ok || got != value {
+			t.Fatalf("unexpected %s: %q", key, got)
+		}
+	}
+
+	body = `{"params": {"rpiCameraNope": 1, "rpiCameraAWB": "auto", "rpiCameraContrast": 99}}`
+	req = cameraRequest{}
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	_, errs = applyCameraRequest(config.CameraConfig{}, req)
+	if len(errs) != 3 || errs[0].Field != "params.rpiCameraAWB" {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+}
+
+func TestAPICameraUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: auto
+    rpiCameraAfMode: continuous
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	srv := &Server{configPath: path}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"awb": "bogus"}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	var apiErr apiError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "awb" {
+		t.Fatalf("unexpected field errors: %+v", apiErr.Fields)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"hFlip": true, "awb": "daylight"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var camera apiCamera
+	if err := json.Unmarshal(rec.Body.Bytes(), &camera); err != nil {
+		t.Fatalf("decode camera: %v", err)
+	}
+	if !camera.Config.HFlip || camera.Config.AWB != "daylight" || camera.Config.AfMode != "continuous" {
+		t.Fatalf("unexpected camera config: %+v", camera.Config)
+	}
+	if got := rec.Header().Get(revisionCheckHeader); got != "skipped" {
+		t.Fatalf("expected the save without revision marked unchecked, got %q", got)
+	}
+	if camera.LastUpdated == nil {
+		t.Fatalf("expected last updated time")
+	}
+	if !strings.Contains(rec.Body.String(), `"lensPosition": null`) {
+		t.Fatalf("expected typed null for lens position: %s", rec.Body.String())
+	}
+}
+
+func TestAPICameraPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraCamID: 0
+  back:
+    source: rpiCamera
+    rpiCameraCamID: 1
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	srv := &Server{configPath: path, mediamtxPath: "cam"}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"path": "nope", "hFlip": true}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"path": "back", "hFlip": true}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var camera apiCamera
+	if err := json.Unmarshal(rec.Body.Bytes(), &camera); err != nil {
+		t.Fatalf("decode camera: %v", err)
+	}
+	if camera.Path != "back" || !camera.Config.HFlip || camera.Config.Params["rpiCameraCamID"] != "1" {
+		t.Fatalf("unexpected camera: %+v", camera)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/camera", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	camera = apiCamera{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &camera); err != nil {
+		t.Fatalf("decode camera: %v", err)
+	}
+	if camera.Path != "cam" || camera.Config.HFlip {
+		t.Fatalf("expected untouched primary camera: %+v", camera)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/camera?path=nope", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAPICameraUpdateConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: auto
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	srv := &Server{configPath: path, mediamtxPath: "cam"}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"revision": "0000000000000000", "awb": "daylight"}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var apiErr apiError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "awb" || apiErr.Revision == "" {
+		t.Fatalf("unexpected conflict: %+v", apiErr)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"revision": "`+apiErr.Revision+`", "awb": "daylight"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with the current revision, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(revisionCheckHeader); got != "" {
+		t.Fatalf("expected a checked save, got %s %q", revisionCheckHeader, got)
+	}
+}
+
+func TestAPIRecordUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `pathDefaults:
+  recordDeleteAfter: 7d
+paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: auto
+`
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	srv := &Server{configPath: path, mediamtxPath: "cam"}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"params": {"record": true}}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected recording key rejected by the camera endpoint, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera/record", strings.NewReader(`{"params": {"record": true, "recordSegmentDuration": "1 hour", "rpiCameraAWB": "auto"}}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var apiErr apiError
+	if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(apiErr.Fields) != 2 || apiErr.Fields[0].Field != "params.recordSegmentDuration" || apiErr.Fields[1].Field != "params.rpiCameraAWB" {
+		t.Fatalf("unexpected field errors: %+v", apiErr.Fields)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera/record", strings.NewReader(`{"params": {"record": true, "recordSegmentDuration": "10m"}}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var record apiRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if record.Config.Params["recordSegmentDuration"] != "10m" || !record.Settings.Record || record.Settings.DeleteAfter != "7d" {
+		t.Fatalf("unexpected recording config: %+v", record)
+	}
+	if cfg, err := config.LoadCameraConfig(path, "cam"); err != nil || cfg.AWB != "auto" {
+		t.Fatalf("camera config changed: %+v %v", cfg, err)
+	}
+}
+
+func TestAPIHistory(t *testing.T) {
+	s := sampler.New(time.Minute, 10, func(ctx context.Context) sampler.Sample {
+		return sampler.Sample{Warnings: []string{"sampled"}}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx)
+	srv := &Server{sampler: s}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/history?hours=bad", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/history?hours=2", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var history apiHistory
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if history.IntervalSeconds != 60 || len(history.Points) != 1 {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+}
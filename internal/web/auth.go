@@ -0,0 +1,342 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/auth"
+)
+
+const sessionCookie = "raspicam_session"
+
+type sessionKey struct{}
+
+// errNoSession is returned by requestSession for a request without a
+// session cookie or basic credentials.
+var errNoSession = errors.New("not signed in")
+
+// AccountView is the signed-in user shown in the page header. Admin is
+// also true when authentication is off, so every control is shown, and
+// false before the first account exists. CSRF is the token the forms post.
+type AccountView struct {
+	Enabled bool
+	Name    string
+	Role    string
+	Admin   bool
+	CSRF    string
+}
+
+type LoginView struct {
+	Next    string
+	Name    string
+	Message string
+	// NoUsers shows how to create the first account instead of the form.
+	NoUsers   bool
+	UsersPath string
+}
+
+// UsersPath returns the UI accounts file: AUTH_USERS_FILE, or
+// mediamtx.ui-users.yml beside MEDIAMTX_CONFIG_PATH.
+func UsersPath() string {
+	return getEnvDefault("AUTH_USERS_FILE", auth.UsersPath(getEnvDefault("MEDIAMTX_CONFIG_PATH", defaultConfigPath)))
+}
+
+// requireAuth lets requests through only with a session cookie or, for
+// scripts, HTTP basic credentials, whose role has to allow what
+// requiredRole asks. A browser session has to send its CSRF token with
+// anything but a read. Without an authenticator everything is allowed;
+// before the first account exists, only what a viewer may do.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil || r.URL.Path == "/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.authEnabled() {
+			if requiredRole(r) != auth.RoleViewer {
+				msg := "no admin account exists yet; run raspicam-ui passwd on the Pi to create one"
+				if apiRequest(r) {
+					writeAPIError(w, http.StatusForbidden, msg, nil)
+				} else {
+					http.Error(w, msg, http.StatusForbidden)
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		session, err := s.requestSession(r)
+		var throttled *auth.ThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", retryAfterSeconds(throttled.RetryAfter))
+			if apiRequest(r) {
+				writeAPIError(w, http.StatusTooManyRequests, "too many failed attempts", nil)
+			} else {
+				http.Error(w, "too many failed attempts", http.StatusTooManyRequests)
+			}
+			return
+		}
+		if err != nil {
+			if apiRequest(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="RaspiCam", charset="UTF-8"`)
+				writeAPIError(w, http.StatusUnauthorized, "authentication required", nil)
+				return
+			}
+			target := "/login"
+			if r.Method == http.MethodGet && r.URL.RequestURI() != "/" {
+				target += "?next=" + url.QueryEscape(r.URL.RequestURI())
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return
+		}
+
+		if !session.Role.Allows(requiredRole(r)) {
+			if apiRequest(r) {
+				writeAPIError(w, http.StatusForbidden, "admin role required", nil)
+			} else {
+				http.Error(w, "admin role required", http.StatusForbidden)
+			}
+			return
+		}
+		if !validCSRF(r, session) {
+			log.Printf("auth: %s %s from %s without a valid CSRF token", r.Method, r.URL.Path, r.RemoteAddr)
+			if apiRequest(r) {
+				writeAPIError(w, http.StatusForbidden, "invalid CSRF token", nil)
+			} else {
+				http.Error(w, "invalid or expired form, reload the page and try again", http.StatusForbidden)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, session)))
+	})
+}
+
+// authEnabled reports whether logins are required. Until the accounts
+// file holds an account the UI can be read without one, as it was before
+// accounts existed, so an upgrade does not lock out the browser or a
+// Prometheus scrape, but nothing can be changed; the first time it is found
+// open a warning is logged. An unreadable accounts file keeps logins
+// required.
+func (s *Server) authEnabled() bool {
+	if s.auth == nil {
+		return false
+	}
+	hasUsers, err := s.auth.HasUsers()
+	open := err == nil && !hasUsers
+	if s.authOpen.Swap(open) != open {
+		if open {
+			log.Printf("auth: no accounts in %s, the UI is read-only and open to anyone who can reach it; run raspicam-ui passwd to create the admin account", s.auth.Path())
+		} else {
+			log.Printf("auth: accounts found in %s, logins are required", s.auth.Path())
+		}
+	}
+	return !open
+}
+
+// requestSession returns the session of the cookie or of basic
+// credentials, which start no session.
+func (s *Server) requestSession(r *http.Request) (auth.Session, error) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if session, ok := s.auth.Session(c.Value); ok {
+			return session, nil
+		}
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		user, err := s.auth.CheckBasic(clientAddr(r), name, password)
+		if err != nil {
+			log.Printf("auth: basic credentials for %q from %s rejected: %v", name, r.RemoteAddr, err)
+			return auth.Session{}, err
+		}
+		return auth.Session{User: user.Name, Role: user.Role}, nil
+	}
+	return auth.Session{}, errNoSession
+}
+
+// clientAddr is the address failed attempts are counted against. Behind a
+// reverse proxy every client shares the proxy's.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// adminReads are the read endpoints that show what only admins may see:
+// the MediaMTX journal, which holds client addresses and sometimes source
+// credentials, config backups, which hold the whole mediamtx.yml, and the
+// addresses of connected viewers.
+var adminReads = map[string]bool{
+	"/api/v1/service/logs": true,
+	"/api/v1/backups":      true,
+	"/api/v1/viewers":      true,
+}
+
+// requiredRole returns the role r needs. Reading needs the viewer role,
+// except for adminReads and the status page with its log viewer open, and
+// any other method the admin role.
+func requiredRole(r *http.Request) auth.Role {
+	if r.URL.Path == "/logout" {
+		return auth.RoleViewer
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return auth.RoleAdmin
+	}
+	if adminReads[r.URL.Path] || (r.URL.Path == "/" && r.URL.Query().Has("logs")) {
+		return auth.RoleAdmin
+	}
+	return auth.RoleViewer
+}
+
+// csrfField is the form field, and csrfHeader the header for scripts using
+// a session cookie, that carry the CSRF token of the session.
+const (
+	csrfField  = "csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// validCSRF reports whether r may act for session: reads always may, and
+// so may basic credentials, which a browser does not send on its own.
+// Anything else has to carry the CSRF token of the session.
+func validCSRF(r *http.Request, session auth.Session) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || session.Token == "" {
+		return true
+	}
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRF)) == 1
+}
+
+// apiRequest reports whether r comes from a script rather than a browser,
+// which gets a status code instead of the login page.
+func apiRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/metrics"
+}
+
+func (s *Server) accountView(ctx context.Context) AccountView {
+	// requireAuth only lets requests without a session through while
+	// authentication is off, or read-only before the first account.
+	session, ok := ctx.Value(sessionKey{}).(auth.Session)
+	if !ok {
+		return AccountView{Admin: s.auth == nil}
+	}
+	return AccountView{
+		Enabled: true,
+		Name:    session.User,
+		Role:    string(session.Role),
+		Admin:   session.Role == auth.RoleAdmin,
+		CSRF:    session.CSRF,
+	}
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		view := LoginView{Next: safeNext(r.URL.Query().Get("next"))}
+		if _, err := s.requestSession(r); err == nil {
+			http.Redirect(w, r, view.Next, http.StatusSeeOther)
+			return
+		}
+		s.renderLogin(w, http.StatusOK, view)
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		view := LoginView{Next: safeNext(r.FormValue("next")), Name: r.FormValue("name")}
+		session, err := s.auth.Login(clientAddr(r), view.Name, r.FormValue("password"))
+		if err != nil {
+			log.Printf("auth: login for %q from %s rejected: %v", view.Name, r.RemoteAddr, err)
+			status := http.StatusUnauthorized
+			var throttled *auth.ThrottledError
+			switch {
+			case errors.As(err, &throttled):
+				status = http.StatusTooManyRequests
+				w.Header().Set("Retry-After", retryAfterSeconds(throttled.RetryAfter))
+				view.Message = fmt.Sprintf("Too many failed attempts. Try again in %s seconds.", retryAfterSeconds(throttled.RetryAfter))
+			case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrNoUsers):
+				view.Message = "Invalid user name or password."
+			default:
+				status = http.StatusInternalServerError
+				view.Message = "Accounts unavailable. Check the UI log."
+			}
+			s.renderLogin(w, status, view)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    session.Token,
+			Path:     "/",
+			Expires:  session.Expires,
+			HttpOnly: true,
+			Secure:   secureRequest(r),
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, view.Next, http.StatusSeeOther)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && s.auth != nil {
+		s.auth.Logout(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, status int, view LoginView) {
+	hasUsers, err := s.auth.HasUsers()
+	view.NoUsers = err == nil && !hasUsers
+	view.UsersPath = s.auth.Path()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := s.tmpl.ExecuteTemplate(w, "login.html", view); err != nil {
+		log.Printf("render login: %v", err)
+	}
+}
+
+// safeNext keeps the post-login redirect on this site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.ContainsAny(next, `\`) {
+		return "/"
+	}
+	return next
+}
+
+// secureRequest reports whether the browser reached the UI over HTTPS,
+// directly or through a reverse proxy, so the cookie is only sent over it.
+func secureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,337 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/auth"
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+// newAuthServer returns the fixture server with authentication on, an
+// admin "admin" and a viewer "alice".
+func newAuthServer(t *testing.T) *Server {
+	t.Helper()
+	srv := newFixtureServer(t)
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+	srv.auth = auth.New(path, time.Hour)
+	if _, err := auth.SetPassword(path, "admin", auth.RoleAdmin, "correct horse"); err != nil {
+		t.Fatalf("set admin: %v", err)
+	}
+	if _, err := auth.SetPassword(path, "alice", auth.RoleViewer, "battery staple"); err != nil {
+		t.Fatalf("set viewer: %v", err)
+	}
+	return srv
+}
+
+// login signs in through the form and returns the session cookie.
+func login(t *testing.T, srv *Server, name, password string) *http.Cookie {
+	t.Helper()
+	form := url.Values{"name": {name}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("login %s: unexpected status %d", name, rec.Code)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionCookie {
+			return c
+		}
+	}
+	t.Fatalf("login %s: no session cookie", name)
+	return nil
+}
+
+// csrfToken returns the CSRF token of the session of cookie.
+func csrfToken(t *testing.T, srv *Server, cookie *http.Cookie) string {
+	t.Helper()
+	session, ok := srv.auth.Session(cookie.Value)
+	if !ok {
+		t.Fatal("no session for cookie")
+	}
+	return session.CSRF
+}
+
+func serveAs(srv *Server, cookie *http.Cookie, req *http.Request) *httptest.ResponseRecorder {
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	if req.Method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthRedirectsToLogin(t *testing.T) {
+	srv := newAuthServer(t)
+
+	rec := serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/recordings?path=cam", nil))
+	if got := rec.Header().Get("Location"); got != "/login?next=%2Frecordings%3Fpath%3Dcam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader("path=cam")))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="password"`) {
+		t.Fatalf("expected the login form: %d\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	srv := newAuthServer(t)
+
+	form := url.Values{"name": {"admin"}, "password": {"wrong horse"}}
+	rec := serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode())))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "Invalid user name or password.") {
+		t.Fatalf("expected the login rejected: %d\n%s", rec.Code, rec.Body.String())
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("expected no cookie for a rejected login")
+	}
+
+	form = url.Values{"name": {"admin"}, "password": {"correct horse"}, "next": {"/recordings?path=cam"}}
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode())))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/recordings?path=cam" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly ||
+		cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].Secure {
+		t.Fatalf("unexpected cookies: %+v", cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec = serveAs(srv, nil, req)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || !cookies[0].Secure {
+		t.Fatalf("expected a secure cookie behind HTTPS: %+v", cookies)
+	}
+}
+
+func TestAuthThrottle(t *testing.T) {
+	srv := newAuthServer(t)
+
+	form := url.Values{"name": {"admin"}, "password": {"wrong horse"}}
+	for i := 0; i < 5; i++ {
+		rec := serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode())))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: unexpected status %d", i, rec.Code)
+		}
+	}
+	form.Set("password", "correct horse")
+	rec := serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode())))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" || !strings.Contains(rec.Body.String(), "Too many failed attempts") {
+		t.Fatalf("expected the login throttled: %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Basic credentials count against the same client.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.SetBasicAuth("admin", "correct horse")
+	if rec = serveAs(srv, nil, req); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected basic credentials throttled, got %d", rec.Code)
+	}
+}
+
+func TestAuthRoles(t *testing.T) {
+	srv := newAuthServer(t)
+	admin := login(t, srv, "admin", "correct horse")
+	viewer := login(t, srv, "alice", "battery staple")
+
+	rec := serveAs(srv, viewer, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "alice") || !strings.Contains(body, "read-only") {
+		t.Fatalf("unexpected viewer page: %d\n%s", rec.Code, body)
+	}
+	if strings.Contains(body, `action="/service/control"`) || !strings.Contains(body, "disabled") {
+		t.Fatal("expected the admin controls hidden from a viewer")
+	}
+	if strings.Contains(body, "Configuration history") || strings.Contains(body, `name="logs"`) {
+		t.Fatal("expected backups and logs hidden from a viewer")
+	}
+	rec = serveAs(srv, viewer, httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader("path=cam")))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a viewer refused, got %d", rec.Code)
+	}
+	// The journal, backups and viewer addresses are admin reads.
+	for _, target := range []string{"/?logs=1", "/api/v1/service/logs", "/api/v1/backups", "/api/v1/viewers"} {
+		if rec = serveAs(srv, viewer, httptest.NewRequest(http.MethodGet, target, nil)); rec.Code != http.StatusForbidden {
+			t.Errorf("GET %s: expected a viewer refused, got %d", target, rec.Code)
+		}
+	}
+	if rec = serveAs(srv, admin, httptest.NewRequest(http.MethodGet, "/api/v1/backups", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("expected backups readable by an admin, got %d", rec.Code)
+	}
+
+	rec = serveAs(srv, admin, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `action="/service/control"`) || !strings.Contains(rec.Body.String(), "Configuration history") {
+		t.Fatal("expected the admin controls shown to an admin")
+	}
+	form := url.Values{"path": {"cam"}, "rpiCameraHFlip": {"on"}, "csrf": {csrfToken(t, srv, admin)}}
+	rec = serveAs(srv, admin, httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode())))
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=cam" {
+		t.Fatalf("unexpected redirect: %d %q", rec.Code, got)
+	}
+}
+
+func TestAuthCSRF(t *testing.T) {
+	srv := newAuthServer(t)
+	admin := login(t, srv, "admin", "correct horse")
+	token := csrfToken(t, srv, admin)
+
+	rec := serveAs(srv, admin, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `name="csrf" value="`+token+`"`) {
+		t.Fatal("expected the forms to carry the CSRF token")
+	}
+
+	for _, csrf := range []string{"", "forged"} {
+		form := url.Values{"path": {"cam"}, "rpiCameraHFlip": {"on"}, "csrf": {csrf}}
+		rec = serveAs(srv, admin, httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode())))
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("csrf %q: expected the post refused, got %d", csrf, rec.Code)
+		}
+	}
+	if cfg, err := config.LoadCameraConfig(srv.configPath, "cam"); err != nil || cfg.HFlip {
+		t.Fatalf("expected nothing saved: %+v %v", cfg, err)
+	}
+
+	// A script with the session cookie sends the token as a header; one
+	// with basic credentials needs none.
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"hFlip": true}`))
+	if rec = serveAs(srv, admin, req); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected the API refused without the token, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"hFlip": true}`))
+	req.Header.Set(csrfHeader, token)
+	if rec = serveAs(srv, admin, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected the API allowed with the token header, got %d: %s", rec.Code, rec.Body.String())
+	}
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"vFlip": true}`))
+	req.SetBasicAuth("admin", "correct horse")
+	if rec = serveAs(srv, nil, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected basic credentials allowed, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthAPI(t *testing.T) {
+	srv := newAuthServer(t)
+
+	rec := serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/api/v1/status", nil))
+	if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	req.SetBasicAuth("alice", "battery staple")
+	if rec = serveAs(srv, nil, req); rec.Code != http.StatusOK {
+		t.Fatalf("expected basic credentials accepted, got %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("alice", "wrong")
+	if rec = serveAs(srv, nil, req); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong credentials refused, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/service/control", strings.NewReader(`{"action":"restart"}`))
+	req.SetBasicAuth("alice", "battery staple")
+	rec = serveAs(srv, nil, req)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "admin role required") {
+		t.Fatalf("expected a viewer refused: %d\n%s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthLogout(t *testing.T) {
+	srv := newAuthServer(t)
+	viewer := login(t, srv, "alice", "battery staple")
+
+	form := url.Values{"csrf": {csrfToken(t, srv, viewer)}}
+	rec := serveAs(srv, viewer, httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(form.Encode())))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Fatalf("expected the cookie cleared: %+v", cookies)
+	}
+	rec = serveAs(srv, viewer, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected the session ended, got %d", rec.Code)
+	}
+}
+
+func TestAuthNoUsers(t *testing.T) {
+	srv := newFixtureServer(t)
+	path := filepath.Join(t.TempDir(), "mediamtx.ui-users.yml")
+	srv.auth = auth.New(path, time.Hour)
+
+	// Without accounts the UI can be read, as before an upgrade, but not
+	// changed.
+	rec := serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "No UI account exists") {
+		t.Fatalf("expected the open status page with a warning: %d", rec.Code)
+	}
+	if strings.Contains(body, `action="/service/control"`) || strings.Contains(body, "Configuration history") {
+		t.Fatal("expected the admin controls hidden without an account")
+	}
+	if rec = serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/metrics", nil)); rec.Code != http.StatusOK {
+		t.Fatalf("expected metrics open, got %d", rec.Code)
+	}
+	form := url.Values{"path": {"cam"}, "rpiCameraHFlip": {"on"}}
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode())))
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "raspicam-ui passwd") {
+		t.Fatalf("expected the save refused: %d %s", rec.Code, rec.Body.String())
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/v1/service/control", strings.NewReader(`{"action":"restart"}`)),
+		httptest.NewRequest(http.MethodGet, "/api/v1/backups", nil),
+	} {
+		if rec = serveAs(srv, nil, req); rec.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: expected refused, got %d", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+	if cfg, err := config.LoadCameraConfig(srv.configPath, "cam"); err != nil || cfg.HFlip {
+		t.Fatalf("expected nothing saved: %+v %v", cfg, err)
+	}
+	rec = serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if !strings.Contains(rec.Body.String(), "raspicam-ui passwd") {
+		t.Fatalf("expected the first account instructions:\n%s", rec.Body.String())
+	}
+
+	// The first account closes it without a restart.
+	if _, err := auth.SetPassword(path, "admin", "", "correct horse"); err != nil {
+		t.Fatalf("set admin: %v", err)
+	}
+	if rec = serveAs(srv, nil, httptest.NewRequest(http.MethodGet, "/metrics", nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected metrics closed, got %d", rec.Code)
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	cases := map[string]string{
+		"":                     "/",
+		"/recordings?path=cam": "/recordings?path=cam",
+		"https://example.com":  "/",
+		"//example.com":        "/",
+		`/\example.com`:        "/",
+	}
+	for next, want := range cases {
+		if got := safeNext(next); got != want {
+			t.Errorf("safeNext(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
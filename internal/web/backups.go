@@ -0,0 +1,197 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+type HistoryView struct {
+	Backups      []BackupView
+	Message      string
+	MessageClass string
+}
+
+// BackupView is one backup with its diff against the current config.
+type BackupView struct {
+	Name  string
+	Time  string
+	Size  string
+	Hunks []config.DiffHunk
+}
+
+type apiBackup struct {
+	config.Backup
+	Diff string `json:"diff"`
+}
+
+type restoreRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) handleConfigRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.restoreBackup(r.FormValue("name")); err != nil {
+		status := "restore-error"
+		if errors.Is(err, config.ErrBackupNotFound) {
+			status = "invalid-backup"
+		}
+		http.Redirect(w, r, "/?history="+status, http.StatusSeeOther)
+		return
+	}
+	s.pruneBackups()
+
+	http.Redirect(w, r, "/?history=restored", http.StatusSeeOther)
+}
+
+func (s *Server) handleAPIBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	s.writeAPIBackups(w)
+}
+
+func (s *Server) handleAPIBackupRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	var req restoreRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	if err := s.restoreBackup(req.Name); err != nil {
+		if errors.Is(err, config.ErrBackupNotFound) {
+			writeAPIError(w, http.StatusNotFound, err.Error(), []fieldError{{Field: "name", Message: "unknown backup"}})
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("restore failed: %v", err), nil)
+		return
+	}
+	s.pruneBackups()
+
+	s.writeAPIBackups(w)
+}
+
+func (s *Server) writeAPIBackups(w http.ResponseWriter) {
+	backups, err := config.ListBackups(s.configPath)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("backups unavailable: %v", err), nil)
+		return
+	}
+	out := make([]apiBackup, 0, len(backups))
+	for _, backup := range backups {
+		hunks, err := config.DiffBackup(s.configPath, backup.Name)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("diff %s: %v", backup.Name, err), nil)
+			return
+		}
+		out = append(out, apiBackup{Backup: backup, Diff: config.UnifiedDiff(backup.Name, "mediamtx.yml", hunks)})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) restoreBackup(name string) error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if err := config.RestoreBackup(s.configPath, name); err != nil {
+		return err
+	}
+	s.saveGen++
+	return nil
+}
+
+// pruneBackups applies the retention policy after the config file was
+// replaced. Failures only leave extra backups behind.
+func (s *Server) pruneBackups() {
+	if _, err := config.PruneBackups(s.configPath, s.backupPolicy, time.Now()); err != nil {
+		log.Printf("prune config backups: %v", err)
+	}
+}
+
+// historyCache keeps the rendered diffs while neither mediamtx.yml nor
+// the set of backups changes, so a page does not diff every backup again.
+type historyCache struct {
+	mu       sync.Mutex
+	key      string
+	view     HistoryView
+	warnings []string
+}
+
+func (s *Server) loadHistory() (HistoryView, []string) {
+	backups, err := config.ListBackups(s.configPath)
+	if err != nil {
+		return HistoryView{}, []string{fmt.Sprintf("Configuration history unavailable: %v", err)}
+	}
+	info, err := os.Stat(s.configPath)
+	if err != nil {
+		return s.diffBackups(backups)
+	}
+	// Backups are never rewritten, so their names stand for their content.
+	var key strings.Builder
+	fmt.Fprintf(&key, "%d/%d", info.ModTime().UnixNano(), info.Size())
+	for _, backup := range backups {
+		key.WriteString("/" + backup.Name)
+	}
+
+	s.history.mu.Lock()
+	defer s.history.mu.Unlock()
+	if s.history.key != key.String() {
+		s.history.view, s.history.warnings = s.diffBackups(backups)
+		s.history.key = key.String()
+	}
+	return s.history.view, s.history.warnings
+}
+
+func (s *Server) diffBackups(backups []config.Backup) (HistoryView, []string) {
+
+	var view HistoryView
+	var warnings []string
+	for _, backup := range backups {
+		hunks, err := config.DiffBackup(s.configPath, backup.Name)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Diff of %s unavailable: %v", backup.Name, err))
+		}
+		view.Backups = append(view.Backups, BackupView{
+			Name:  backup.Name,
+			Time:  backup.Time.Format("2006-01-02 15:04:05"),
+			Size:  formatBytes(uint64(backup.Size)),
+			Hunks: hunks,
+		})
+	}
+	return view, warnings
+}
+
+func historyMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "restored":
+		return "Backup restored. The replaced configuration was backed up first.", "notice ok"
+	case "restore-error":
+		return "Failed to restore backup.", "notice err"
+	case "invalid-backup":
+		return "Unknown backup.", "notice err"
+	}
+	return "", ""
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+func TestConfigRestore(t *testing.T) {
+	srv := newFixtureServer(t)
+	srv.backupPolicy = config.RetentionPolicy{MaxCount: 2}
+
+	form := url.Values{"rpiCameraHFlip": {"on"}}
+	req := httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	backups, err := config.ListBackups(srv.configPath)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one backup after save, got %+v %v", backups, err)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(view.History.Backups) != 1 || len(view.History.Backups[0].Hunks) != 1 {
+		t.Fatalf("unexpected history: %+v", view.History)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `rpiCameraHFlip: true</div>`) {
+		t.Fatalf("rendered page missing backup diff")
+	}
+
+	form = url.Values{"name": {backups[0].Name}}
+	req = httptest.NewRequest(http.MethodPost, "/config-restore", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if got := rec.Header().Get("Location"); got != "/?history=restored" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil || cfg.HFlip {
+		t.Fatalf("expected restored config without hflip: %+v %v", cfg, err)
+	}
+
+	form = url.Values{"name": {"../../etc/passwd"}}
+	req = httptest.NewRequest(http.MethodPost, "/config-restore", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if got := rec.Header().Get("Location"); got != "/?history=invalid-backup" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPIBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mediamtx.yml")
+	if err := os.WriteFile(path, []byte("paths:\n  cam:\n    source: rpiCamera\n    rpiCameraAWB: indoor\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	name := "mediamtx.yml.bak-20240610-081500"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("paths:\n  cam:\n    source: rpiCamera\n    rpiCameraAWB: daylight\n"), 0o644); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+	srv := &Server{configPath: path, mediamtxPath: "cam"}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/backups", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var backups []apiBackup
+	if err := json.Unmarshal(rec.Body.Bytes(), &backups); err != nil {
+		t.Fatalf("decode backups: %v", err)
+	}
+	if len(backups) != 1 || backups[0].Name != name || !strings.Contains(backups[0].Diff, "+    rpiCameraAWB: indoor") {
+		t.Fatalf("unexpected backups: %+v", backups)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/backups/restore", strings.NewReader(`{"name": "nope"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/backups/restore", strings.NewReader(`{"name": "`+name+`"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	cfg, err := config.LoadCameraConfig(path, "cam")
+	if err != nil || cfg.AWB != "daylight" {
+		t.Fatalf("expected restored awb: %+v %v", cfg, err)
+	}
+}
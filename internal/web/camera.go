@@ -0,0 +1,236 @@
+package web
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+// presetParams are edited through the resolution presets and the flip
+// checkboxes rather than through a field of their own.
+var presetParams = map[string]bool{
+	"rpiCameraWidth":  true,
+	"rpiCameraHeight": true,
+	"rpiCameraVFlip":  true,
+	"rpiCameraHFlip":  true,
+}
+
+type OptionView struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+type ParamGroupView struct {
+	Name   string
+	Params []ParamView
+}
+
+// ParamView is one schema-driven form control. Input is "select", "number"
+// or "text"; ID is the element id, unique across camera cards.
+type ParamView struct {
+	Key         string
+	ID          string
+	Label       string
+	Input       string
+	Value       string
+	Placeholder string
+	Min         string
+	Max         string
+	Step        string
+	Options     []OptionView
+	Help        string
+	Supported   bool
+	Note        string
+	// Live marks parameters "Apply live" can change without a restart.
+	Live bool
+}
+
+// applyCameraForm validates the schema parameters present in form and stores
+// them in cfg. Parameters missing from the form, such as disabled inputs,
+// keep their current value and an empty value clears the key. On failure
+// it returns the offending key.
+func applyCameraForm(cfg *config.CameraConfig, form url.Values) (string, error) {
+	return applyParamForm(config.CameraSchema, cfg.SetValue, form)
+}
+
+// applyRecordForm is applyCameraForm for the recording form.
+func applyRecordForm(cfg *config.RecordConfig, form url.Values) (string, error) {
+	return applyParamForm(config.RecordSchema, cfg.SetValue, form)
+}
+
+func applyParamForm(schema []config.Param, set func(key, value string) error, form url.Values) (string, error) {
+	for _, p := range schema {
+		if presetParams[p.Key] {
+			continue
+		}
+		values, ok := form[p.Key]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		raw := strings.TrimSpace(values[0])
+		if p.Type == config.ParamFloat && raw != "" {
+			v, ok := parseDecimal(raw)
+			if !ok {
+				return p.Key, fmt.Errorf("invalid float for %s", p.Key)
+			}
+			raw = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		value, err := p.Validate(raw)
+		if err != nil {
+			return p.Key, err
+		}
+		if err := set(p.Key, value); err != nil {
+			return p.Key, err
+		}
+	}
+	return "", nil
+}
+
+// cameraParamGroups builds the advanced form controls for every schema
+// parameter without a dedicated control, grouped in schema order.
+func cameraParamGroups(path string, cfg config.CameraConfig, version string) []ParamGroupView {
+	var groups []ParamGroupView
+	for _, p := range config.CameraSchema {
+		if p.Field != "" {
+			continue
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Name != p.Group {
+			groups = append(groups, ParamGroupView{Name: p.Group})
+		}
+		last := &groups[len(groups)-1]
+		value, _ := cfg.Value(p.Key)
+		last.Params = append(last.Params, paramView(path, p, value, version))
+	}
+	return groups
+}
+
+// cameraRecordParams builds the recording controls of path. A key the path
+// does not set shows the value it inherits from pathDefaults as its
+// default.
+func cameraRecordParams(path string, cfg config.RecordConfig, inherited config.RecordSettings, version string) []ParamView {
+	var params []ParamView
+	for _, p := range config.RecordSchema {
+		if v, ok := inherited.Value(p.Key); ok && v != "" {
+			p.Default = v
+		}
+		value, _ := cfg.Value(p.Key)
+		params = append(params, paramView(path, p, value, version))
+	}
+	return params
+}
+
+func paramView(path string, p config.Param, value, version string) ParamView {
+	view := ParamView{
+		Key:       p.Key,
+		ID:        path + "-" + p.Key,
+		Label:     p.Label,
+		Value:     value,
+		Help:      p.Help,
+		Supported: p.SupportedBy(version),
+		Live:      p.Live,
+	}
+	if !view.Supported {
+		view.Note = fmt.Sprintf("Requires MediaMTX %s or later (running %s).", p.Since, version)
+	}
+
+	unset := "Not set"
+	if p.Default != "" {
+		unset = "Default (" + optionLabel(p, p.Default) + ")"
+		view.Placeholder = "default " + p.Default
+	}
+
+	switch p.Type {
+	case config.ParamBool:
+		view.Input = "select"
+		view.Options = optionViews([]config.Option{
+			{Value: "", Label: unset},
+			{Value: "true", Label: "On"},
+			{Value: "false", Label: "Off"},
+		}, value)
+	case config.ParamEnum:
+		view.Input = "select"
+		view.Options = optionViews(append([]config.Option{{Value: "", Label: unset}}, p.Options...), value)
+	case config.ParamInt, config.ParamFloat:
+		view.Input = "number"
+		view.Step = "any"
+		if p.Type == config.ParamInt {
+			view.Step = "1"
+		}
+		if p.Min != nil {
+			view.Min = strconv.FormatFloat(*p.Min, 'g', -1, 64)
+		}
+		if p.Max != nil {
+			view.Max = strconv.FormatFloat(*p.Max, 'g', -1, 64)
+		}
+	default:
+		view.Input = "text"
+	}
+	return view
+}
+
+func optionLabel(p config.Param, value string) string {
+	switch p.Type {
+	case config.ParamBool:
+		if value == "true" {
+			return "On"
+		}
+		return "Off"
+	case config.ParamEnum:
+		for _, o := range p.Options {
+			if o.Value == value {
+				return o.Label
+			}
+		}
+	}
+	return value
+}
+
+func optionViews(options []config.Option, selected string) []OptionView {
+	views := make([]OptionView, 0, len(options))
+	for _, o := range options {
+		views = append(views, OptionView{Value: o.Value, Label: o.Label, Selected: o.Value == selected})
+	}
+	return views
+}
+
+// schemaOptions returns the enum options of key for a dedicated select.
+func schemaOptions(key, selected string) []OptionView {
+	p, ok := config.LookupParam(key)
+	if !ok {
+		return nil
+	}
+	return optionViews(p.Options, selected)
+}
+
+// paramSlug turns rpiCameraAfMode into "af-mode" for status redirects.
+func paramSlug(key string) string {
+	name := []rune(strings.TrimPrefix(key, "rpiCamera"))
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(name[i-1])
+			nextLower := i+1 < len(name) && unicode.IsLower(name[i+1])
+			if prevLower || (unicode.IsUpper(name[i-1]) && nextLower) {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func paramBySlug(slug string) (config.Param, bool) {
+	for _, schema := range [][]config.Param{config.CameraSchema, config.RecordSchema} {
+		for _, p := range schema {
+			if paramSlug(p.Key) == slug {
+				return p, true
+			}
+		}
+	}
+	return config.Param{}, false
+}
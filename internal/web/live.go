@@ -0,0 +1,164 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+// DriftView is a setting MediaMTX runs with a value that is not in
+// mediamtx.yml yet.
+type DriftView struct {
+	Label   string
+	File    string
+	Running string
+}
+
+type cameraPersistRequest struct {
+	Path *string `json:"path"`
+}
+
+// cameraDrift lists the live parameters of path whose running value
+// differs from file. Without a Control API client there is nothing to
+// compare against.
+func (s *Server) cameraDrift(ctx context.Context, path string, file config.CameraConfig) ([]config.FieldDrift, error) {
+	if s.mediamtxAPI == nil {
+		return nil, nil
+	}
+	running, err := s.mediamtxAPI.PathConf(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return config.LiveDrift(file, running), nil
+}
+
+// applyLiveConfig patches the live parameters of cfg into the running path
+// without touching mediamtx.yml. It fails with a config.StaticChangeError
+// when cfg also changes parameters that need a restart of the path.
+func (s *Server) applyLiveConfig(ctx context.Context, path string, cfg config.CameraConfig) error {
+	file, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		return err
+	}
+	running, err := s.mediamtxAPI.PathConf(ctx, path)
+	if err != nil {
+		return err
+	}
+	patch, err := config.LivePatch(cfg, file, running)
+	if err != nil || len(patch) == 0 {
+		return err
+	}
+	defer s.invalidateState()
+	return s.mediamtxAPI.PatchPathConf(ctx, path, patch)
+}
+
+// persistLiveConfig writes the running value of every drifted parameter of
+// path to mediamtx.yml through the normal save. MediaMTX then reloads a
+// file that matches what it runs, so the path is not restarted. It reports
+// false when there was nothing to write.
+func (s *Server) persistLiveConfig(ctx context.Context, path string) (bool, error) {
+	file, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		return false, err
+	}
+	drift, err := s.cameraDrift(ctx, path, file)
+	if err != nil {
+		return false, err
+	}
+	if len(drift) == 0 {
+		return false, nil
+	}
+	if err := s.saveCameraConfig(ctx, path, config.WithRunning(file, drift)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Server) handleCameraPersist(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		http.Redirect(w, r, "/?camera=invalid-path", http.StatusSeeOther)
+		return
+	}
+	redirect := func(status string) {
+		http.Redirect(w, r, "/?camera="+status+"&path="+url.QueryEscape(path), http.StatusSeeOther)
+	}
+
+	persisted, err := s.persistLiveConfig(r.Context(), path)
+	var rollback *rollbackError
+	switch {
+	case err == nil && persisted:
+		redirect("persisted")
+	case err == nil:
+		redirect("nothing-to-persist")
+	case errors.As(err, &rollback) && rollback.Restore == nil:
+		redirect("rolled-back")
+	case errors.As(err, &rollback):
+		redirect("rollback-failed")
+	default:
+		redirect("save-error")
+	}
+}
+
+func (s *Server) handleAPICameraPersist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	var req cameraPersistRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+	name := ""
+	if req.Path != nil {
+		name = *req.Path
+	}
+	path, ok := s.resolveCameraPath(name)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+		return
+	}
+
+	if _, err := s.persistLiveConfig(r.Context(), path); err != nil {
+		var rollback *rollbackError
+		switch {
+		case errors.As(err, &rollback):
+			writeAPIError(w, http.StatusBadGateway, rollback.Error(), nil)
+		case errors.Is(err, mediamtx.ErrUnreachable), errors.Is(err, mediamtx.ErrNotFound):
+			writeAPIError(w, http.StatusBadGateway, fmt.Sprintf("running config unavailable: %v", err), nil)
+		default:
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("save failed: %v", err), nil)
+		}
+		return
+	}
+	s.writeAPICamera(r.Context(), w, path)
+}
+
+func formatDrift(drift []config.FieldDrift) []DriftView {
+	views := make([]DriftView, 0, len(drift))
+	for _, d := range drift {
+		p, _ := config.LookupParam(d.Key)
+		views = append(views, DriftView{Label: p.Label, File: optionLabel(p, d.File), Running: optionLabel(p, d.Running)})
+	}
+	return views
+}
+
+func staticChangeFieldErrors(keys []string) []fieldError {
+	errs := make([]fieldError, 0, len(keys))
+	for _, key := range keys {
+		errs = append(errs, fieldError{Field: key, Message: "restarts the path; save it without live"})
+	}
+	return errs
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+func TestCameraLiveApply(t *testing.T) {
+	srv, fake := newFixtureServerWithAPI(t)
+	form := url.Values{
+		"path":                {"cam"},
+		"resolution":          {"1920x1080"},
+		"rpiCameraAWB":        {"cloudy"},
+		"rpiCameraBrightness": {"0.4"},
+		"apply":               {"live"},
+	}
+	if got := postForm(t, srv, "/camera-config", form); got != "/?camera=live-applied&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	conf := fake.PathConf("cam")
+	if conf["rpiCameraAWB"] != "cloudy" || conf["rpiCameraBrightness"] != 0.4 {
+		t.Fatalf("running config not patched: %v", conf)
+	}
+	file, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil || file.AWB != "daylight" {
+		t.Fatalf("file changed by a live apply: %+v %v", file, err)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cam := view.Cameras[0]
+	if cam.AWB != "cloudy" || len(cam.Drift) != 2 {
+		t.Fatalf("expected the running values and their drift, got AWB %q drift %+v", cam.AWB, cam.Drift)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `action="/camera-persist"`) {
+		t.Fatalf("rendered page missing the make permanent form")
+	}
+
+	form.Set("rpiCameraVFlip", "on")
+	if got := postForm(t, srv, "/camera-config", form); got != "/?camera=live-static&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+
+	if got := postForm(t, srv, "/camera-persist", url.Values{"path": {"cam"}}); got != "/?camera=persisted&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	file, err = config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil || file.AWB != "cloudy" || file.Params["rpiCameraBrightness"] != "0.4" {
+		t.Fatalf("running values not persisted: %+v %v", file, err)
+	}
+	if got := postForm(t, srv, "/camera-persist", url.Values{"path": {"cam"}}); got != "/?camera=nothing-to-persist&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPICameraLive(t *testing.T) {
+	srv, fake := newFixtureServerWithAPI(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"live":true,"params":{"rpiCameraFPS":"15"}}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var camera apiCamera
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &camera) != nil {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	want := []config.FieldDrift{{Key: "rpiCameraFPS", File: "30", Running: "15"}}
+	if len(camera.Drift) != 1 || camera.Drift[0] != want[0] {
+		t.Fatalf("unexpected drift: %+v", camera.Drift)
+	}
+	if fake.PathConf("cam")["rpiCameraFPS"] != 15.0 {
+		t.Fatalf("running config not patched: %v", fake.PathConf("cam"))
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"live":true,"vFlip":true}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "rpiCameraVFlip") {
+		t.Fatalf("expected 422 naming the flip, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/camera/persist", strings.NewReader(`{"path":"cam"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &camera) != nil || len(camera.Drift) != 0 {
+		t.Fatalf("unexpected persist response: %d %s", rec.Code, rec.Body.String())
+	}
+	if camera.Config.Params["rpiCameraFPS"] != "15" {
+		t.Fatalf("FPS not persisted: %+v", camera.Config.Params)
+	}
+}
@@ -0,0 +1,268 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+type ProfilesView struct {
+	Profiles     []ProfileView
+	Message      string
+	MessageClass string
+}
+
+// ProfileView is a stored profile with its values in schema order.
+type ProfileView struct {
+	Name   string
+	Values []ProfileValueView
+}
+
+type ProfileValueView struct {
+	Label string
+	Value string
+}
+
+type profileApplyRequest struct {
+	Name string  `json:"name"`
+	Path *string `json:"path"`
+}
+
+// handleProfileSave stores the current config of a camera path as a
+// profile.
+func (s *Server) handleProfileSave(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		http.Redirect(w, r, "/?camera=invalid-path", http.StatusSeeOther)
+		return
+	}
+	cfg, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		redirectProfiles(w, r, "error")
+		return
+	}
+
+	s.saveMu.Lock()
+	err = config.SaveProfile(s.configPath, config.NewProfile(r.FormValue("name"), cfg))
+	s.saveMu.Unlock()
+	redirectProfiles(w, r, profileStatus(err, "saved"))
+}
+
+// handleProfileApply saves a profile to a camera path through the same
+// path as the camera form, with a backup and the save watchdog.
+func (s *Server) handleProfileApply(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		http.Redirect(w, r, "/?camera=invalid-path", http.StatusSeeOther)
+		return
+	}
+	redirect := func(status string) {
+		http.Redirect(w, r, "/?camera="+status+"&path="+url.QueryEscape(path), http.StatusSeeOther)
+	}
+
+	if err := s.applyProfile(r.Context(), path, r.FormValue("name")); err != nil {
+		var rollback *rollbackError
+		switch {
+		case errors.Is(err, config.ErrProfileNotFound):
+			redirect("invalid-profile")
+		case errors.As(err, &rollback) && rollback.Restore == nil:
+			redirect("rolled-back")
+		case errors.As(err, &rollback):
+			redirect("rollback-failed")
+		default:
+			redirect("save-error")
+		}
+		return
+	}
+	redirect("profile-applied")
+}
+
+func (s *Server) handleProfileRename(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	s.saveMu.Lock()
+	err := config.RenameProfile(s.configPath, r.FormValue("name"), r.FormValue("newName"))
+	s.saveMu.Unlock()
+	redirectProfiles(w, r, profileStatus(err, "renamed"))
+}
+
+func (s *Server) handleProfileDelete(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	s.saveMu.Lock()
+	err := config.DeleteProfile(s.configPath, r.FormValue("name"))
+	s.saveMu.Unlock()
+	redirectProfiles(w, r, profileStatus(err, "deleted"))
+}
+
+func (s *Server) handleAPIProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	profiles, err := config.ListProfiles(s.configPath)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("profiles unavailable: %v", err), nil)
+		return
+	}
+	if profiles == nil {
+		profiles = []config.Profile{}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+func (s *Server) handleAPIProfileApply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	var req profileApplyRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+	name := ""
+	if req.Path != nil {
+		name = *req.Path
+	}
+	path, ok := s.resolveCameraPath(name)
+	if !ok {
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "path", Message: "not an rpiCamera path"}})
+		return
+	}
+
+	if err := s.applyProfile(r.Context(), path, req.Name); err != nil {
+		var rollback *rollbackError
+		switch {
+		case errors.Is(err, config.ErrProfileNotFound):
+			writeAPIError(w, http.StatusNotFound, err.Error(), []fieldError{{Field: "name", Message: "unknown profile"}})
+		case errors.As(err, &rollback):
+			writeAPIError(w, http.StatusBadGateway, rollback.Error(), nil)
+		default:
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("apply failed: %v", err), nil)
+		}
+		return
+	}
+	s.writeAPICamera(r.Context(), w, path)
+}
+
+func (s *Server) applyProfile(ctx context.Context, path, name string) error {
+	profile, err := config.LoadProfile(s.configPath, name)
+	if err != nil {
+		return err
+	}
+	cfg, err := profile.CameraConfig()
+	if err != nil {
+		return err
+	}
+	return s.saveCameraConfig(ctx, path, cfg)
+}
+
+// applyScheduledProfile is the scheduler's apply function. It saves nothing
+// when the camera already matches the profile, so a scheduled change does
+// not restart MediaMTX for nothing.
+func (s *Server) applyScheduledProfile(ctx context.Context, path, name string) (bool, error) {
+	if resolved, ok := s.resolveCameraPath(path); !ok || resolved != path {
+		return false, fmt.Errorf("unknown camera path %q", path)
+	}
+	profile, err := config.LoadProfile(s.configPath, name)
+	if err != nil {
+		return false, err
+	}
+	current, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		return false, err
+	}
+	if profile.Matches(current) {
+		return false, nil
+	}
+	if err := s.applyProfile(ctx, path, name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Server) loadProfiles() (ProfilesView, []string) {
+	profiles, err := config.ListProfiles(s.configPath)
+	if err != nil {
+		return ProfilesView{}, []string{fmt.Sprintf("Camera profiles unavailable: %v", err)}
+	}
+
+	var view ProfilesView
+	for _, profile := range profiles {
+		pv := ProfileView{Name: profile.Name}
+		for _, p := range config.CameraSchema {
+			if value, ok := profile.Values[p.Key]; ok && value != "" {
+				pv.Values = append(pv.Values, ProfileValueView{Label: p.Label, Value: optionLabel(p, value)})
+			}
+		}
+		view.Profiles = append(view.Profiles, pv)
+	}
+	return view, nil
+}
+
+func parsePostForm(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func redirectProfiles(w http.ResponseWriter, r *http.Request, status string) {
+	http.Redirect(w, r, "/?profiles="+status, http.StatusSeeOther)
+}
+
+func profileStatus(err error, ok string) string {
+	switch {
+	case err == nil:
+		return ok
+	case errors.Is(err, config.ErrInvalidProfileName):
+		return "invalid-name"
+	case errors.Is(err, config.ErrProfileExists):
+		return "exists"
+	case errors.Is(err, config.ErrProfileNotFound):
+		return "not-found"
+	}
+	return "error"
+}
+
+func profilesMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "saved":
+		return "Profile saved.", "notice ok"
+	case "renamed":
+		return "Profile renamed.", "notice ok"
+	case "deleted":
+		return "Profile deleted.", "notice ok"
+	case "invalid-name":
+		return "Profile names must be 1 to 64 printable characters.", "notice err"
+	case "exists":
+		return "A profile with that name already exists.", "notice err"
+	case "not-found":
+		return "Unknown profile.", "notice err"
+	case "error":
+		return "Failed to update profiles.", "notice err"
+	}
+	return "", ""
+}
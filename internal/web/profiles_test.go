@@ -0,0 +1,111 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+)
+
+func postForm(t *testing.T, srv *Server, action string, form url.Values) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, action, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("%s: unexpected status %d", action, rec.Code)
+	}
+	return rec.Header().Get("Location")
+}
+
+func TestProfileForms(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	if got := postForm(t, srv, "/profiles/save", url.Values{"path": {"cam"}, "name": {"daylight wide"}}); got != "/?profiles=saved" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/profiles/save", url.Values{"path": {"cam"}, "name": {""}}); got != "/?profiles=invalid-name" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/profiles/rename", url.Values{"name": {"daylight wide"}, "newName": {"wide"}}); got != "/?profiles=renamed" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(view.Profiles.Profiles) != 1 || view.Profiles.Profiles[0].Name != "wide" {
+		t.Fatalf("unexpected profiles: %+v", view.Profiles)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<option value="wide">wide</option>`) {
+		t.Fatalf("rendered page missing profile select")
+	}
+
+	// Change the camera, then apply the profile to bring it back.
+	postCameraForm(srv, url.Values{"rpiCameraAWB": {"indoor"}})
+	if got := postForm(t, srv, "/profiles/apply", url.Values{"path": {"cam"}, "name": {"wide"}}); got != "/?camera=profile-applied&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil || cfg.AWB != "daylight" {
+		t.Fatalf("expected profile applied: %+v %v", cfg, err)
+	}
+	if backups, _ := config.ListBackups(srv.configPath); len(backups) != 2 {
+		t.Fatalf("expected a backup per save, got %+v", backups)
+	}
+
+	if got := postForm(t, srv, "/profiles/apply", url.Values{"path": {"cam"}, "name": {"nope"}}); got != "/?camera=invalid-profile&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/profiles/delete", url.Values{"name": {"wide"}}); got != "/?profiles=deleted" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/profiles/delete", url.Values{"name": {"wide"}}); got != "/?profiles=not-found" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPIProfiles(t *testing.T) {
+	srv := newFixtureServer(t)
+	if err := config.SaveProfile(srv.configPath, config.Profile{Name: "indoor", Values: map[string]string{"rpiCameraAWB": "indoor"}}); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/profiles", nil))
+	var profiles []config.Profile
+	if err := json.Unmarshal(rec.Body.Bytes(), &profiles); err != nil {
+		t.Fatalf("decode profiles: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0].Values["rpiCameraAWB"] != "indoor" {
+		t.Fatalf("unexpected profiles: %+v", profiles)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/profiles/apply", strings.NewReader(`{"name":"indoor"}`)))
+	var camera apiCamera
+	if err := json.Unmarshal(rec.Body.Bytes(), &camera); err != nil {
+		t.Fatalf("decode camera: %v", err)
+	}
+	if rec.Code != http.StatusOK || camera.Path != "cam" || camera.Config.AWB != "indoor" {
+		t.Fatalf("unexpected apply response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/profiles/apply", strings.NewReader(`{"name":"nope"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown profile, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,269 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/host"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/system"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of the /proc/stat counters.
+const clockTicksPerSecond = 100
+
+// promWriter renders the Prometheus text exposition format (version 0.0.4).
+type promWriter struct {
+	buf bytes.Buffer
+}
+
+func (p *promWriter) header(name, typ, help string) {
+	fmt.Fprintf(&p.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	p.buf.WriteString(name)
+	if len(labels) > 0 {
+		p.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&p.buf, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		p.buf.WriteByte('}')
+	}
+	p.buf.WriteByte(' ')
+	p.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	p.buf.WriteByte('\n')
+}
+
+func (p *promWriter) gauge(name, help string, value float64, labels ...string) {
+	p.header(name, "gauge", help)
+	p.sample(name, value, labels...)
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
+
+func boolValue(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	var p promWriter
+	collectors := map[string]bool{}
+	collectors["cpu"] = writeCPUMetrics(ctx, &p, s.env)
+	collectors["temperature"] = writeTemperatureMetrics(ctx, &p, s.env)
+	collectors["voltage"] = writeVoltageMetrics(ctx, &p, s.env)
+	collectors["throttled"] = writeThrottledMetrics(ctx, &p, s.env)
+	collectors["netdev"] = writeNetDevMetrics(&p, s.env)
+	collectors["wireless"] = writeWirelessMetrics(&p, s.env)
+	collectors["resources"] = writeResourceMetrics(&p, s.env, s.recordingsDir)
+	collectors["mediamtx"] = writeMediaMTXMetrics(ctx, &p, s.env, s.mediamtxAPI, s.cameraPaths())
+
+	p.header("raspicam_scrape_collector_success", "gauge", "Whether a collector succeeded during this scrape.")
+	for _, name := range []string{"cpu", "temperature", "voltage", "throttled", "netdev", "wireless", "resources", "mediamtx"} {
+		p.sample("raspicam_scrape_collector_success", boolValue(collectors[name]), "collector", name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(p.buf.Bytes())
+}
+
+func writeCPUMetrics(ctx context.Context, p *promWriter, env host.Env) bool {
+	times, err := metrics.ReadCPUTimes(ctx, env)
+	if err != nil {
+		return false
+	}
+	p.header("raspicam_cpu_seconds_total", "counter", "Cumulative CPU time across all cores from /proc/stat.")
+	p.sample("raspicam_cpu_seconds_total", float64(times.Total-times.Idle)/clockTicksPerSecond, "mode", "busy")
+	p.sample("raspicam_cpu_seconds_total", float64(times.Idle)/clockTicksPerSecond, "mode", "idle")
+	return true
+}
+
+func writeTemperatureMetrics(ctx context.Context, p *promWriter, env host.Env) bool {
+	temp, err := metrics.ReadTemperature(ctx, env)
+	if err != nil {
+		return false
+	}
+	p.gauge("raspicam_soc_temperature_celsius", "SoC temperature.", temp.Value, "source", temp.Source)
+	return true
+}
+
+func writeVoltageMetrics(ctx context.Context, p *promWriter, env host.Env) bool {
+	volts, err := metrics.ReadVoltage(ctx, env)
+	if err != nil {
+		return false
+	}
+	p.gauge("raspicam_core_voltage_volts", "Core voltage.", volts.Value, "source", volts.Source)
+	return true
+}
+
+func writeThrottledMetrics(ctx context.Context, p *promWriter, env host.Env) bool {
+	status, source, err := metrics.ReadThrottled(ctx, env)
+	if err != nil {
+		return false
+	}
+	// A fallback source knows only some bits; the others are left out
+	// rather than exported as 0.
+	if status.Reports(metrics.ThrottleMask) {
+		p.gauge("raspicam_throttled_raw", "Raw vcgencmd get_throttled bitmask.", float64(status.Raw), "source", source)
+	}
+	p.header("raspicam_throttled_flag", "gauge", "Decoded vcgencmd get_throttled bits.")
+	for _, bit := range metrics.ThrottleBits {
+		if status.Reports(bit.Mask) {
+			p.sample("raspicam_throttled_flag", boolValue(status.Raw&bit.Mask != 0), "flag", bit.Name, "source", source)
+		}
+	}
+	return true
+}
+
+func writeNetDevMetrics(p *promWriter, env host.Env) bool {
+	counters, err := system.ReadNetDevCounters(env)
+	if err != nil {
+		return false
+	}
+	p.header("raspicam_network_receive_bytes_total", "counter", "Bytes received per interface from /proc/net/dev.")
+	for _, c := range counters {
+		if c.Name == "lo" {
+			continue
+		}
+		p.sample("raspicam_network_receive_bytes_total", float64(c.RxBytes), "interface", c.Name)
+	}
+	p.header("raspicam_network_transmit_bytes_total", "counter", "Bytes transmitted per interface from /proc/net/dev.")
+	for _, c := range counters {
+		if c.Name == "lo" {
+			continue
+		}
+		p.sample("raspicam_network_transmit_bytes_total", float64(c.TxBytes), "interface", c.Name)
+	}
+	return true
+}
+
+func writeWirelessMetrics(p *promWriter, env host.Env) bool {
+	links, err := system.ReadWirelessLinks(env)
+	if err != nil {
+		return false
+	}
+	p.header("raspicam_wifi_link_quality", "gauge", "WiFi link quality out of 70 from /proc/net/wireless.")
+	for _, link := range links {
+		p.sample("raspicam_wifi_link_quality", link.LinkQuality, "interface", link.Interface)
+	}
+	p.header("raspicam_wifi_signal_dbm", "gauge", "WiFi signal level in dBm from /proc/net/wireless.")
+	for _, link := range links {
+		if link.SignalDBm != nil {
+			p.sample("raspicam_wifi_signal_dbm", *link.SignalDBm, "interface", link.Interface)
+		}
+	}
+	return true
+}
+
+func writeResourceMetrics(p *promWriter, env host.Env, recordingsDir string) bool {
+	ok := true
+	if uptime, err := metrics.UptimeSeconds(env); err != nil {
+		ok = false
+	} else {
+		p.gauge("raspicam_uptime_seconds", "System uptime.", uptime)
+	}
+	if load, err := metrics.ReadLoadAverage(env); err != nil {
+		ok = false
+	} else {
+		p.header("raspicam_load_average", "gauge", "System load average from /proc/loadavg.")
+		p.sample("raspicam_load_average", load.Load1, "period", "1m")
+		p.sample("raspicam_load_average", load.Load5, "period", "5m")
+		p.sample("raspicam_load_average", load.Load15, "period", "15m")
+	}
+	if mem, err := metrics.ReadMemory(env); err != nil {
+		ok = false
+	} else {
+		p.gauge("raspicam_memory_total_bytes", "Total memory.", float64(mem.TotalBytes))
+		p.gauge("raspicam_memory_available_bytes", "Memory available for new work.", float64(mem.AvailableBytes))
+		p.gauge("raspicam_swap_total_bytes", "Total swap.", float64(mem.SwapTotalBytes))
+		p.gauge("raspicam_swap_free_bytes", "Free swap.", float64(mem.SwapFreeBytes))
+	}
+
+	var disks []metrics.DiskUsage
+	if root, err := metrics.DiskUsageFor(env, "/"); err != nil {
+		ok = false
+	} else {
+		disks = append(disks, root)
+	}
+	if recordingsDir != "" {
+		if rec, err := metrics.DiskUsageAt(recordingsDir); err == nil {
+			disks = append(disks, rec)
+		}
+	}
+	p.header("raspicam_filesystem_size_bytes", "gauge", "Filesystem size.")
+	for _, d := range disks {
+		p.sample("raspicam_filesystem_size_bytes", float64(d.TotalBytes), "path", d.Path)
+	}
+	p.header("raspicam_filesystem_avail_bytes", "gauge", "Filesystem space available to unprivileged users.")
+	for _, d := range disks {
+		p.sample("raspicam_filesystem_avail_bytes", float64(d.FreeBytes), "path", d.Path)
+	}
+	return ok
+}
+
+func writeMediaMTXMetrics(ctx context.Context, p *promWriter, env host.Env, client *mediamtx.Client, pathNames []string) bool {
+	status, warnings := mediamtx.Collect(ctx, env.Runner, client, pathNames...)
+
+	p.gauge("raspicam_mediamtx_service_active", "Whether the mediamtx systemd unit is active.", boolValue(status.ServiceActive()))
+	if status.ServiceStatus != nil {
+		p.gauge("raspicam_mediamtx_service_info", "MediaMTX systemd unit state.", 1, "state", *status.ServiceStatus)
+	}
+	p.gauge("raspicam_mediamtx_api_up", "Whether the MediaMTX Control API answered.", boolValue(status.APIUp()))
+
+	var ready, readers, tracks []mediamtx.PathState
+	for _, path := range status.Paths {
+		if path.Ready != nil {
+			ready = append(ready, path)
+		}
+		if path.Readers != nil {
+			readers = append(readers, path)
+		}
+		if path.Tracks != nil {
+			tracks = append(tracks, path)
+		}
+	}
+	if len(ready) > 0 {
+		p.header("raspicam_mediamtx_path_ready", "gauge", "Whether the stream path is ready.")
+		for _, path := range ready {
+			p.sample("raspicam_mediamtx_path_ready", boolValue(*path.Ready), "path", path.Name)
+		}
+	}
+	if len(readers) > 0 {
+		p.header("raspicam_mediamtx_path_readers", "gauge", "Number of readers on the stream path.")
+		for _, path := range readers {
+			p.sample("raspicam_mediamtx_path_readers", float64(*path.Readers), "path", path.Name)
+		}
+	}
+	if len(tracks) > 0 {
+		p.header("raspicam_mediamtx_path_tracks", "gauge", "Number of tracks on the stream path.")
+		for _, path := range tracks {
+			p.sample("raspicam_mediamtx_path_tracks", float64(*path.Tracks), "path", path.Name)
+		}
+	}
+
+	return len(warnings) == 0
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+)
+
+func TestPromWriter(t *testing.T) {
+	var p promWriter
+	p.gauge("raspicam_test", "Test gauge.", 1.5, "path", `a"b`)
+	p.header("raspicam_counter_total", "counter", "Test counter.")
+	p.sample("raspicam_counter_total", 42)
+
+	want := `# HELP raspicam_test Test gauge.
+# TYPE raspicam_test gauge
+raspicam_test{path="a\"b"} 1.5
+# HELP raspicam_counter_total Test counter.
+# TYPE raspicam_counter_total counter
+raspicam_counter_total 42
+`
+	if got := p.buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	got := escapeLabelValue("a\\b\n\"c\"")
+	if !strings.Contains(got, `a\\b\n\"c\"`) {
+		t.Fatalf("unexpected escape: %q", got)
+	}
+}
+
+func TestHandleMetricsSyntheticPi(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`raspicam_soc_temperature_celsius{source="vcgencmd"} 46.2`,
+		`raspicam_throttled_raw{source="vcgencmd"} 327680`,
+		`raspicam_throttled_flag{flag="under_voltage_occurred",source="vcgencmd"} 1`,
+		`raspicam_network_transmit_bytes_total{interface="wlan0"} 5.2613498213e+10`,
+		`raspicam_wifi_signal_dbm{interface="wlan0"} -56`,
+		`raspicam_mediamtx_path_ready{path="cam"} 1`,
+		`raspicam_scrape_collector_success{collector="resources"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandleMetricsRpiVoltThrottled(t *testing.T) {
+	srv := newFixtureServer(t)
+	// Without vcgencmd only the rpi_volt under-voltage alarm is read.
+	srv.env.Runner = hosttest.FixtureRunner{Dir: t.TempDir()}
+
+	rec := httptest.NewRecorder()
+	srv.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `raspicam_throttled_flag{flag="under_voltage",source="rpi_volt"} 0`) {
+		t.Fatalf("expected the under-voltage flag from rpi_volt in:\n%s", body)
+	}
+	if strings.Contains(body, "raspicam_throttled_raw") || strings.Contains(body, `flag="under_voltage_occurred"`) {
+		t.Fatalf("expected no raw value or unknown bits in:\n%s", body)
+	}
+}
@@ -0,0 +1,447 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/recordings"
+)
+
+// RecordingsView is the recordings page.
+type RecordingsView struct {
+	Root         string
+	Total        string
+	Paths        []RecordingPathView
+	Message      string
+	MessageClass string
+	Account      AccountView
+	Warnings     []string
+}
+
+type RecordingPathView struct {
+	Path string
+	Days []RecordingDayView
+	// ExportStart and ExportEnd prefill the export form with the newest
+	// segment, in datetime-local format.
+	ExportStart string
+	ExportEnd   string
+}
+
+type RecordingDayView struct {
+	Path     string
+	Date     string
+	Count    int
+	Size     string
+	Duration string
+	Segments []SegmentView
+}
+
+type SegmentView struct {
+	Name        string
+	Start       string
+	Duration    string
+	Size        string
+	DownloadURL string
+}
+
+// apiSegment is a segment with its duration in seconds, which JSON clients
+// handle better than nanoseconds.
+type apiSegment struct {
+	recordings.Segment
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// recordingDeleteRequest deletes either one segment by name or every
+// segment of a path on a date.
+type recordingDeleteRequest struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Date string `json:"date"`
+}
+
+// exportTimeLayouts are the local times a datetime-local input sends;
+// RFC 3339 is accepted too.
+var exportTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+var segmentContentTypes = map[string]string{
+	".mp4": "video/mp4",
+	".ts":  "video/mp2t",
+}
+
+// recordingsStore returns a store for RECORDINGS_DIR that understands the
+// recordPath of pathDefaults and of every camera path.
+func (s *Server) recordingsStore() (*recordings.Store, error) {
+	defaults, err := config.LoadRecordSettings(s.configPath, "")
+	if err != nil {
+		return nil, fmt.Errorf("read record settings: %w", err)
+	}
+	layouts := []recordings.Layout{{Pattern: defaults.Path, Extension: defaults.Extension()}}
+	for _, name := range s.cameraPaths() {
+		settings, err := config.LoadRecordSettings(s.configPath, name)
+		if err != nil {
+			continue
+		}
+		layout := recordings.Layout{Pattern: settings.Path, Extension: settings.Extension()}
+		if !slices.Contains(layouts, layout) {
+			layouts = append(layouts, layout)
+		}
+	}
+	return recordings.New(s.recordingsDir, layouts...)
+}
+
+func (s *Server) handleRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	view := s.buildRecordingsView(r.URL.Query().Get("path"))
+	view.Message, view.MessageClass = recordingsMessageFromStatus(r.URL.Query().Get("recordings"))
+	view.Account = s.accountView(r.Context())
+	if err := s.tmpl.ExecuteTemplate(w, "recordings.html", view); err != nil {
+		http.Error(w, "template render error", http.StatusInternalServerError)
+	}
+}
+
+// buildRecordingsView lists the segments of every path, or of pathFilter
+// when set.
+func (s *Server) buildRecordingsView(pathFilter string) RecordingsView {
+	view := RecordingsView{Root: s.recordingsDir, Total: formatBytes(0)}
+	store, err := s.recordingsStore()
+	if err != nil {
+		view.Warnings = append(view.Warnings, fmt.Sprintf("Recordings unavailable: %v", err))
+		return view
+	}
+	segments, err := store.List()
+	if err != nil {
+		view.Warnings = append(view.Warnings, fmt.Sprintf("Recordings unavailable: %v", err))
+		return view
+	}
+
+	var total uint64
+	for _, day := range recordings.Days(segments) {
+		if pathFilter != "" && day.Path != pathFilter {
+			continue
+		}
+		total += uint64(day.Size)
+		if len(view.Paths) == 0 || view.Paths[len(view.Paths)-1].Path != day.Path {
+			// Days come newest first, so this holds the newest segment.
+			newest := day.Segments[len(day.Segments)-1]
+			view.Paths = append(view.Paths, RecordingPathView{
+				Path:        day.Path,
+				ExportStart: newest.Start.Format(exportTimeLayouts[0]),
+				ExportEnd:   newest.Start.Add(newest.Duration).Add(time.Second).Format(exportTimeLayouts[0]),
+			})
+		}
+		pathView := &view.Paths[len(view.Paths)-1]
+		pathView.Days = append(pathView.Days, formatRecordingDay(day))
+	}
+	view.Total = formatBytes(total)
+	return view
+}
+
+func formatRecordingDay(day recordings.Day) RecordingDayView {
+	view := RecordingDayView{
+		Path:     day.Path,
+		Date:     day.Date,
+		Count:    len(day.Segments),
+		Size:     formatBytes(uint64(day.Size)),
+		Duration: formatSegmentDuration(day.Duration),
+	}
+	for _, seg := range day.Segments {
+		view.Segments = append(view.Segments, SegmentView{
+			Name:        seg.Name,
+			Start:       seg.Start.Format("15:04:05"),
+			Duration:    formatSegmentDuration(seg.Duration),
+			Size:        formatBytes(uint64(seg.Size)),
+			DownloadURL: "/recordings/download?name=" + url.QueryEscape(seg.Name),
+		})
+	}
+	return view
+}
+
+// formatSegmentDuration keeps seconds for segments shorter than a minute,
+// which formatUptime would show as 0m.
+func formatSegmentDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	return formatUptime(d.Seconds())
+}
+
+// handleRecordingDownload streams one segment. http.ServeContent answers
+// Range requests, so players can seek and interrupted downloads resume.
+func (s *Server) handleRecordingDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	store, err := s.recordingsStore()
+	if err != nil {
+		http.Error(w, "recordings unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	f, seg, err := store.Open(r.URL.Query().Get("name"))
+	switch {
+	case errors.Is(err, recordings.ErrInvalidName):
+		http.Error(w, "invalid recording name", http.StatusBadRequest)
+		return
+	case errors.Is(err, recordings.ErrNotFound):
+		http.NotFound(w, r)
+		return
+	case err != nil:
+		http.Error(w, "recording unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	base := path.Base(seg.Name)
+	if contentType, ok := segmentContentTypes[path.Ext(base)]; ok {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", base))
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "recording unavailable", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, base, info.ModTime(), f)
+}
+
+// handleRecordingExport streams the recordings of a path between start and
+// end as one MP4, from the MediaMTX playback server when it is enabled and
+// built from the fMP4 segments otherwise.
+func (s *Server) handleRecordingExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	name := query.Get("path")
+	start, startErr := parseExportTime(query.Get("start"))
+	end, endErr := parseExportTime(query.Get("end"))
+	switch {
+	case name == "":
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	case startErr != nil || endErr != nil:
+		http.Error(w, "start and end must be RFC 3339 or YYYY-MM-DDTHH:MM[:SS] local times", http.StatusBadRequest)
+		return
+	case !end.After(start):
+		http.Error(w, recordings.ErrInvalidRange.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.exportFromPlayback(w, r, name, start, end) {
+		return
+	}
+
+	store, err := s.recordingsStore()
+	if err != nil {
+		http.Error(w, "recordings unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	export, err := store.PlanExport(name, start, end)
+	var changed *recordings.FormatChangeError
+	switch {
+	case errors.Is(err, recordings.ErrNotFound):
+		http.Error(w, "no recordings of "+name+" in that range", http.StatusNotFound)
+		return
+	case errors.Is(err, recordings.ErrUnsupported), errors.As(err, &changed):
+		http.Error(w, err.Error()+"; export a shorter range or enable the MediaMTX playback server", http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("export failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	setExportHeaders(w, name, export.Start)
+	w.Header().Set("Content-Length", strconv.FormatInt(export.Size(), 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := export.WriteTo(w); err != nil {
+		log.Printf("recordings export of %s: %v", name, err)
+	}
+}
+
+// exportFromPlayback proxies an export to the MediaMTX playback server
+// when mediamtx.yml enables it. It reports false when the server is
+// disabled or fails for a reason other than a missing recording, so the
+// export is built locally instead.
+func (s *Server) exportFromPlayback(w http.ResponseWriter, r *http.Request, name string, start, end time.Time) bool {
+	if s.mediamtxAPI == nil {
+		return false
+	}
+	listener, err := config.LoadPlayback(s.configPath)
+	if err != nil || !listener.Enabled {
+		return false
+	}
+	base, err := listener.LocalURL()
+	if err != nil {
+		return false
+	}
+	resp, err := s.mediamtxAPI.Playback(base).Get(r.Context(), name, start, end.Sub(start))
+	if errors.Is(err, mediamtx.ErrNotFound) {
+		http.Error(w, "no recordings of "+name+" in that range", http.StatusNotFound)
+		return true
+	}
+	if err != nil {
+		log.Printf("recordings export of %s: playback server: %v; building it locally", name, err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	setExportHeaders(w, name, start)
+	if length := resp.Header.Get("Content-Length"); length != "" {
+		w.Header().Set("Content-Length", length)
+	}
+	if r.Method == http.MethodHead {
+		return true
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("recordings export of %s: %v", name, err)
+	}
+	return true
+}
+
+func parseExportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range exportTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func setExportHeaders(w http.ResponseWriter, name string, start time.Time) {
+	filename := strings.ReplaceAll(name, "/", "_") + "_" + start.Format("2006-01-02_15-04-05") + ".mp4"
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+func (s *Server) handleRecordingDelete(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	req := recordingDeleteRequest{Name: r.FormValue("name"), Path: r.FormValue("path"), Date: r.FormValue("date")}
+	_, err := s.deleteRecordings(req)
+	switch {
+	case err == nil && req.Name != "":
+		redirectRecordings(w, r, "deleted")
+	case err == nil:
+		redirectRecordings(w, r, "day-deleted")
+	case errors.Is(err, recordings.ErrInvalidName):
+		redirectRecordings(w, r, "invalid")
+	case errors.Is(err, recordings.ErrNotFound):
+		redirectRecordings(w, r, "gone")
+	default:
+		redirectRecordings(w, r, "error")
+	}
+}
+
+func (s *Server) handleAPIRecordings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	store, err := s.recordingsStore()
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("recordings unavailable: %v", err), nil)
+		return
+	}
+	segments, err := store.List()
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("recordings unavailable: %v", err), nil)
+		return
+	}
+	pathFilter := r.URL.Query().Get("path")
+	out := make([]apiSegment, 0, len(segments))
+	for _, seg := range segments {
+		if pathFilter != "" && seg.Path != pathFilter {
+			continue
+		}
+		out = append(out, apiSegment{Segment: seg, DurationSeconds: seg.Duration.Seconds()})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleAPIRecordingDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	var req recordingDeleteRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	deleted, err := s.deleteRecordings(req)
+	switch {
+	case errors.Is(err, recordings.ErrInvalidName):
+		writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "name", Message: "must be a recording below the recordings directory, or give path and date (YYYY-MM-DD)"}})
+	case errors.Is(err, recordings.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, "recording not found", nil)
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("delete failed: %v", err), nil)
+	default:
+		writeJSON(w, http.StatusOK, map[string]int{"deleted": deleted})
+	}
+}
+
+// deleteRecordings removes the segment req names, or the day of req.Path
+// on req.Date, and reports how many segments went.
+func (s *Server) deleteRecordings(req recordingDeleteRequest) (int, error) {
+	// Exactly one of the two forms.
+	if (req.Name == "") == (req.Path == "" || req.Date == "") {
+		return 0, recordings.ErrInvalidName
+	}
+	store, err := s.recordingsStore()
+	if err != nil {
+		return 0, err
+	}
+	if req.Name != "" {
+		if err := store.Delete(req.Name); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	return store.DeleteDay(req.Path, req.Date)
+}
+
+func redirectRecordings(w http.ResponseWriter, r *http.Request, status string) {
+	http.Redirect(w, r, "/recordings?recordings="+status, http.StatusSeeOther)
+}
+
+func recordingsMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "deleted":
+		return "Recording deleted.", "notice ok"
+	case "day-deleted":
+		return "Recordings of the day deleted.", "notice ok"
+	case "gone":
+		return "That recording no longer exists.", "notice warn"
+	case "invalid":
+		return "Invalid recording.", "notice err"
+	case "error":
+		return "Deleting the recording failed. Check that the UI user may write to the recordings directory.", "notice err"
+	}
+	return "", ""
+}
@@ -0,0 +1,234 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/fmp4/fmp4test"
+)
+
+// newRecordingsServer is newFixtureServer with two cam segments on one day
+// and one on the next below a temporary recordings directory.
+func newRecordingsServer(t *testing.T) *Server {
+	t.Helper()
+	srv := newFixtureServer(t)
+	srv.recordingsDir = t.TempDir()
+	start := time.Date(2024, 6, 11, 8, 0, 0, 0, time.Local)
+	for i, name := range []string{
+		"cam/2024-06-11_08-00-00-000000.mp4",
+		"cam/2024-06-11_09-00-00-000000.mp4",
+		"cam/2024-06-12_08-00-00-000000.mp4",
+	} {
+		p := filepath.Join(srv.recordingsDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(strings.Repeat("0123456789", i+1)), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		modTime := start.Add(time.Duration(i+1) * time.Hour)
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	return srv
+}
+
+func TestRecordingsPage(t *testing.T) {
+	srv := newRecordingsServer(t)
+
+	view := srv.buildRecordingsView("")
+	if len(view.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", view.Warnings)
+	}
+	if len(view.Paths) != 1 || len(view.Paths[0].Days) != 2 {
+		t.Fatalf("unexpected paths: %+v", view.Paths)
+	}
+	day := view.Paths[0].Days[1]
+	if day.Date != "2024-06-11" || day.Count != 2 || day.Segments[0].Start != "08:00:00" || day.Segments[0].Duration != "1h 0m" {
+		t.Fatalf("unexpected day: %+v", day)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recordings?recordings=deleted", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "Recording deleted.") || !strings.Contains(body, "2024-06-12") {
+		t.Fatalf("unexpected page %d: %s", rec.Code, body)
+	}
+}
+
+func TestRecordingDownloadRange(t *testing.T) {
+	srv := newRecordingsServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/recordings/download?name="+url.QueryEscape("cam/2024-06-11_09-00-00-000000.mp4"), nil)
+	req.Header.Set("Range", "bytes=5-9")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "56789" {
+		t.Fatalf("unexpected response %d: %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Fatalf("unexpected content range: %q", got)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="2024-06-11_09-00-00-000000.mp4"` {
+		t.Fatalf("unexpected disposition: %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "video/mp4" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+}
+
+func TestRecordingDownloadRejectsTraversal(t *testing.T) {
+	srv := newRecordingsServer(t)
+	secret := filepath.Join(filepath.Dir(srv.configPath), "mediamtx.yml")
+
+	for _, name := range []string{
+		"../" + filepath.Base(filepath.Dir(srv.configPath)) + "/mediamtx.yml",
+		secret,
+		"cam/../../etc/2024-06-11_08-00-00-000000.mp4",
+		"cam/notes.txt",
+	} {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recordings/download?name="+url.QueryEscape(name), nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", name, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recordings/download?name="+url.QueryEscape("cam/2024-01-01_00-00-00-000000.mp4"), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing segment, got %d", rec.Code)
+	}
+}
+
+func TestRecordingDeleteForms(t *testing.T) {
+	srv := newRecordingsServer(t)
+
+	if got := postForm(t, srv, "/recordings/delete", url.Values{"name": {"cam/2024-06-12_08-00-00-000000.mp4"}}); got != "/recordings?recordings=deleted" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/recordings/delete", url.Values{"name": {"cam/2024-06-12_08-00-00-000000.mp4"}}); got != "/recordings?recordings=gone" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/recordings/delete", url.Values{"name": {"../mediamtx.yml"}}); got != "/recordings?recordings=invalid" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/recordings/delete", url.Values{"path": {"cam"}, "date": {"2024-06-11"}}); got != "/recordings?recordings=day-deleted" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(srv.recordingsDir, "cam")); !os.IsNotExist(err) {
+		t.Fatalf("expected the emptied path directory to be removed, got %v", err)
+	}
+	if _, err := os.Stat(srv.configPath); err != nil {
+		t.Fatalf("config must survive: %v", err)
+	}
+}
+
+func TestAPIRecordings(t *testing.T) {
+	srv := newRecordingsServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/recordings?path=cam", nil))
+	var segments []struct {
+		Path            string  `json:"path"`
+		Name            string  `json:"name"`
+		Size            int64   `json:"size"`
+		DurationSeconds float64 `json:"durationSeconds"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&segments); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(segments) != 3 || segments[1].Size != 20 || segments[0].DurationSeconds != 3600 {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/recordings/delete", strings.NewReader(`{"path":"cam","date":"2024-06-11"}`)))
+	var deleted struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&deleted); err != nil || rec.Code != http.StatusOK || deleted.Deleted != 2 {
+		t.Fatalf("unexpected response %d: %+v, %v", rec.Code, deleted, err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/recordings/delete", strings.NewReader(`{"name":"cam/x.mp4","path":"cam","date":"2024-06-12"}`)))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for name with path and date, got %d", rec.Code)
+	}
+}
+
+func TestRecordingExport(t *testing.T) {
+	srv := newFixtureServer(t)
+	srv.recordingsDir = t.TempDir()
+	start := time.Date(2024, 6, 11, 8, 0, 0, 0, time.Local)
+	p := filepath.Join(srv.recordingsDir, "cam", "2024-06-11_08-00-00-000000.mp4")
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	segment := fmp4test.Segment("h264",
+		fmp4test.Fragment{Time: 0, Keyframe: true, Data: []byte("key")},
+		fmp4test.Fragment{Time: fmp4test.Timescale, Data: []byte("delta")},
+	)
+	if err := os.WriteFile(p, segment, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(p, start.Add(2*time.Second), start.Add(2*time.Second)); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recordings/export?path=cam&start=2024-06-11T08:00:01&end=2024-06-11T08:00:05", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Disposition") != `attachment; filename="cam_2024-06-11_08-00-00.mp4"` {
+		t.Fatalf("unexpected response %d: %v", rec.Code, rec.Header())
+	}
+	if rec.Body.Len() != len(segment) || rec.Header().Get("Content-Length") != strconv.Itoa(len(segment)) {
+		t.Fatalf("expected the whole segment from its keyframe, got %d bytes", rec.Body.Len())
+	}
+
+	for target, code := range map[string]int{
+		"/recordings/export?path=cam&start=2024-06-11T09:00&end=2024-06-11T10:00": http.StatusNotFound,
+		"/recordings/export?path=cam&start=2024-06-11T09:00&end=2024-06-11T08:00": http.StatusBadRequest,
+		"/recordings/export?path=cam&start=yesterday&end=2024-06-11T08:00":        http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != code {
+			t.Fatalf("%s: expected %d, got %d", target, code, rec.Code)
+		}
+	}
+}
+
+func TestRecordingExportFromPlayback(t *testing.T) {
+	srv := newFixtureServer(t)
+	var gotQuery url.Values
+	playback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("from playback"))
+	}))
+	t.Cleanup(playback.Close)
+	input := "playback: yes\nplaybackAddress: " + strings.TrimPrefix(playback.URL, "http://") + "\npaths:\n  cam:\n    source: rpiCamera\n"
+	if err := os.WriteFile(srv.configPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/recordings/export?path=cam&start=2024-06-11T08:00:00Z&end=2024-06-11T08:10:00Z", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "from playback" {
+		t.Fatalf("unexpected response %d: %q", rec.Code, rec.Body.String())
+	}
+	if gotQuery.Get("path") != "cam" || gotQuery.Get("duration") != "600" {
+		t.Fatalf("unexpected playback query %v", gotQuery)
+	}
+}
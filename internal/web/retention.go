@@ -0,0 +1,179 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/recordings"
+)
+
+// RetentionView is the recordings retention part of the Resources card.
+type RetentionView struct {
+	Enabled        bool
+	Policy         string
+	WriteRate      string
+	Remaining      string
+	RemainingClass string
+	LastRun        string
+	Removed        string
+}
+
+// apiRetention is the retention policy and the usage of its last pass.
+// Sizes are bytes and durations seconds; null when unknown.
+type apiRetention struct {
+	MinFreeBytes     uint64   `json:"minFreeBytes"`
+	MaxBytes         uint64   `json:"maxBytes"`
+	MaxAgeSeconds    float64  `json:"maxAgeSeconds"`
+	Checked          *string  `json:"checked"`
+	UsedBytes        *uint64  `json:"usedBytes"`
+	WriteRate        *float64 `json:"writeRateBytesPerSecond"`
+	RemainingSeconds *float64 `json:"remainingSeconds"`
+	Removed          int      `json:"removed"`
+}
+
+// newRetention reads the RECORDINGS_* limits, all off by default. Free
+// space is read the way the Resources card reports it, on the same path the
+// store deletes from.
+func (s *Server) newRetention() (*recordings.Retention, error) {
+	minFree, err := getEnvBytes("RECORDINGS_MIN_FREE", 0)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := getEnvBytes("RECORDINGS_MAX_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	var maxAge time.Duration
+	if value := os.Getenv("RECORDINGS_MAX_AGE"); value != "" && value != "0" {
+		if maxAge, err = getEnvDuration("RECORDINGS_MAX_AGE", 0); err != nil {
+			return nil, err
+		}
+	}
+	interval, err := getEnvDuration("RECORDINGS_RETENTION_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	policy := recordings.Policy{MinFreeBytes: minFree, MaxBytes: maxSize, MaxAge: maxAge}
+	return recordings.NewRetention(policy, interval, s.recordingsStore, func() (uint64, error) {
+		usage, err := metrics.DiskUsageAt(s.recordingsDir)
+		return usage.FreeBytes, err
+	}), nil
+}
+
+func (s *Server) loadRetention(now time.Time) (RetentionView, []string) {
+	view := RetentionView{Policy: "off", Remaining: "unknown", RemainingClass: "badge", LastRun: "not yet", Removed: "0"}
+	if s.retention == nil {
+		return view, nil
+	}
+	policy := s.retention.Policy()
+	view.Enabled = policy.Enabled()
+	view.Policy = formatRetentionPolicy(policy)
+
+	usage, ok := s.retention.Usage()
+	if !ok {
+		return view, nil
+	}
+	view.LastRun = formatUptime(now.Sub(usage.Checked).Seconds()) + " ago"
+	view.Removed = strconv.Itoa(usage.Removed)
+	if usage.Err != nil {
+		return view, []string{fmt.Sprintf("Recordings retention failed: %v", usage.Err)}
+	}
+	var warnings []string
+	if usage.Unmet {
+		warnings = append(warnings, "Recordings retention cannot meet its limits: only the segments being written are left.")
+	}
+	if usage.WriteRate == 0 {
+		view.Remaining = "no recent recording"
+		return view, warnings
+	}
+	view.WriteRate = formatBytes(uint64(usage.WriteRate*86400)) + "/day"
+	remaining, _ := recordings.Remaining(policy, usage)
+	days := remaining.Hours() / 24
+	view.Remaining = fmt.Sprintf("%.1f days", days)
+	switch {
+	case days < 1:
+		view.RemainingClass = "badge err"
+	case days < 3:
+		view.RemainingClass = "badge warn"
+	default:
+		view.RemainingClass = "badge ok"
+	}
+	return view, warnings
+}
+
+func (s *Server) apiRetention() apiRetention {
+	var out apiRetention
+	if s.retention == nil {
+		return out
+	}
+	policy := s.retention.Policy()
+	out.MinFreeBytes, out.MaxBytes, out.MaxAgeSeconds = policy.MinFreeBytes, policy.MaxBytes, policy.MaxAge.Seconds()
+	usage, ok := s.retention.Usage()
+	if !ok || usage.Err != nil {
+		out.Removed = usage.Removed
+		return out
+	}
+	checked := usage.Checked.Format(time.RFC3339)
+	out.Checked = &checked
+	out.UsedBytes = &usage.UsedBytes
+	out.WriteRate = &usage.WriteRate
+	out.Removed = usage.Removed
+	if remaining, ok := recordings.Remaining(policy, usage); ok {
+		seconds := remaining.Seconds()
+		out.RemainingSeconds = &seconds
+	}
+	return out
+}
+
+func formatRetentionPolicy(p recordings.Policy) string {
+	var parts []string
+	if p.MinFreeBytes > 0 {
+		parts = append(parts, "keep "+formatBytes(p.MinFreeBytes)+" free")
+	}
+	if p.MaxBytes > 0 {
+		parts = append(parts, "at most "+formatBytes(p.MaxBytes))
+	}
+	if p.MaxAge > 0 {
+		parts = append(parts, "delete after "+formatUptime(p.MaxAge.Seconds()))
+	}
+	if len(parts) == 0 {
+		return "off"
+	}
+	return strings.Join(parts, " · ")
+}
+
+// getEnvBytes reads a size in bytes with an optional K, M, G or T suffix
+// (powers of 1024, "B" or "iB" may follow). "0" disables the limit.
+func getEnvBytes(key string, fallback uint64) (uint64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, ok := parseBytes(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return parsed, nil
+}
+
+func parseBytes(value string) (uint64, bool) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := 1.0
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			multiplier = math.Pow(1024, float64(i+1))
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, false
+	}
+	return uint64(n * multiplier), true
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/recordings"
+)
+
+func TestParseBytes(t *testing.T) {
+	cases := map[string]uint64{
+		"0":      0,
+		"512":    512,
+		"100B":   100,
+		"1K":     1 << 10,
+		"1.5GiB": 3 << 29,
+		"2g":     2 << 30,
+		"1T":     1 << 40,
+	}
+	for input, want := range cases {
+		if got, ok := parseBytes(input); !ok || got != want {
+			t.Fatalf("%q: got %d, %v, want %d", input, got, ok, want)
+		}
+	}
+	for _, input := range []string{"", "G", "-1G", "1X", "ten"} {
+		if _, ok := parseBytes(input); ok {
+			t.Fatalf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestLoadRetention(t *testing.T) {
+	srv := newRecordingsServer(t)
+	now := time.Now()
+	srv.retention = recordings.NewRetention(recordings.Policy{MinFreeBytes: 1 << 30, MaxAge: 30 * 24 * time.Hour}, time.Minute, srv.recordingsStore, func() (uint64, error) {
+		return 11 << 30, nil
+	})
+
+	view, warnings := srv.loadRetention(now)
+	if view.Policy != "keep 1.0 GB free · delete after 30d 0h 0m" || view.Remaining != "unknown" || view.LastRun != "not yet" || len(warnings) != 0 {
+		t.Fatalf("unexpected view before the first pass: %+v, %v", view, warnings)
+	}
+
+	// The fixture segments are from 2024, so nothing was written recently
+	// and every cam segment but the newest is past the maximum age.
+	if err := srv.retention.Tick(now); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	view, _ = srv.loadRetention(now)
+	if view.Removed != "2" || view.Remaining != "no recent recording" || view.WriteRate != "" {
+		t.Fatalf("unexpected view: %+v", view)
+	}
+
+	rec := srv.apiRetention()
+	if rec.Removed != 2 || rec.UsedBytes == nil || *rec.UsedBytes != 30 || rec.RemainingSeconds != nil {
+		t.Fatalf("unexpected api retention: %+v", rec)
+	}
+
+	status, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("build view: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, status); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "no recent recording") || !strings.Contains(buf.String(), "2 segments deleted since start") {
+		t.Fatal("expected the retention rows on the status page")
+	}
+}
+
+func TestRetentionOffByDefault(t *testing.T) {
+	for _, key := range []string{"RECORDINGS_MIN_FREE", "RECORDINGS_MAX_SIZE", "RECORDINGS_MAX_AGE"} {
+		t.Setenv(key, "")
+	}
+	srv := newRecordingsServer(t)
+	retention, err := srv.newRetention()
+	if err != nil {
+		t.Fatalf("new retention: %v", err)
+	}
+	if retention.Policy().Enabled() {
+		t.Fatalf("expected retention off without limits, got %+v", retention.Policy())
+	}
+	srv.retention = retention
+
+	status, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("build view: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, status); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Set RECORDINGS_MIN_FREE") {
+		t.Fatal("expected the Resources card to suggest enabling retention")
+	}
+}
@@ -0,0 +1,220 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/schedule"
+)
+
+type ScheduleView struct {
+	Location     string
+	SunEnabled   bool
+	Rules        []ScheduleRuleView
+	Upcoming     []ScheduleChangeView
+	Applied      []ScheduleAppliedView
+	Message      string
+	MessageClass string
+}
+
+type ScheduleRuleView struct {
+	ID      int
+	At      string
+	Path    string
+	Profile string
+	Next    string
+}
+
+// ScheduleChangeView is the next change of one camera path.
+type ScheduleChangeView struct {
+	Path    string
+	Profile string
+	At      string
+	Spec    string
+}
+
+// ScheduleAppliedView is the last change the scheduler handled for a path.
+type ScheduleAppliedView struct {
+	Path    string
+	Profile string
+	Time    string
+	Result  string
+	Class   string
+}
+
+type apiSchedule struct {
+	Location *schedule.Location          `json:"location"`
+	Rules    []schedule.Rule             `json:"rules"`
+	Upcoming []schedule.Change           `json:"upcoming"`
+	Applied  map[string]schedule.Applied `json:"applied"`
+}
+
+// scheduleLocation reads SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE. Both
+// unset means no location.
+func scheduleLocation() (*schedule.Location, error) {
+	lat, lon := os.Getenv("SCHEDULE_LATITUDE"), os.Getenv("SCHEDULE_LONGITUDE")
+	if lat == "" && lon == "" {
+		return nil, nil
+	}
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return nil, fmt.Errorf("invalid SCHEDULE_LATITUDE %q", lat)
+	}
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return nil, fmt.Errorf("invalid SCHEDULE_LONGITUDE %q", lon)
+	}
+	return &schedule.Location{Latitude: latitude, Longitude: longitude}, nil
+}
+
+func (s *Server) handleScheduleAdd(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		redirectSchedule(w, r, "invalid-path")
+		return
+	}
+	_, err := s.scheduler.AddRule(schedule.Rule{At: r.FormValue("at"), Path: path, Profile: r.FormValue("profile")})
+	switch {
+	case err == nil:
+		redirectSchedule(w, r, "added")
+	case errors.Is(err, schedule.ErrNeedsLocation):
+		redirectSchedule(w, r, "needs-location")
+	default:
+		redirectSchedule(w, r, "invalid-rule")
+	}
+}
+
+func (s *Server) handleScheduleDelete(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err == nil {
+		err = s.scheduler.DeleteRule(id)
+	}
+	if err != nil {
+		redirectSchedule(w, r, "delete-error")
+		return
+	}
+	redirectSchedule(w, r, "deleted")
+}
+
+func (s *Server) handleAPISchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	rules, err := s.scheduler.Rules()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("schedule unavailable: %v", err), nil)
+		return
+	}
+	upcoming, err := s.scheduler.Upcoming(time.Now())
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("schedule unavailable: %v", err), nil)
+		return
+	}
+	applied, err := s.scheduler.Applied()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("schedule unavailable: %v", err), nil)
+		return
+	}
+	if rules == nil {
+		rules = []schedule.Rule{}
+	}
+	if applied == nil {
+		applied = map[string]schedule.Applied{}
+	}
+	writeJSON(w, http.StatusOK, apiSchedule{
+		Location: s.scheduler.Location(),
+		Rules:    rules,
+		Upcoming: upcoming,
+		Applied:  applied,
+	})
+}
+
+func (s *Server) loadSchedule(now time.Time) (ScheduleView, []string) {
+	view := ScheduleView{Location: "not set"}
+	if loc := s.scheduler.Location(); loc != nil {
+		view.Location = fmt.Sprintf("%.4f, %.4f", loc.Latitude, loc.Longitude)
+		view.SunEnabled = true
+	}
+
+	rules, err := s.scheduler.Rules()
+	if err != nil {
+		return view, []string{fmt.Sprintf("Camera schedule unavailable: %v", err)}
+	}
+	for _, rule := range rules {
+		rv := ScheduleRuleView{ID: rule.ID, At: rule.At, Path: rule.Path, Profile: rule.Profile, Next: "never"}
+		if spec, err := schedule.ParseSpec(rule.At); err == nil {
+			if next, ok := spec.Next(now, s.scheduler.Location()); ok {
+				rv.Next = formatScheduleTime(next)
+			}
+		}
+		view.Rules = append(view.Rules, rv)
+	}
+
+	upcoming, err := s.scheduler.Upcoming(now)
+	if err != nil {
+		return view, []string{fmt.Sprintf("Camera schedule unavailable: %v", err)}
+	}
+	for _, change := range upcoming {
+		view.Upcoming = append(view.Upcoming, ScheduleChangeView{
+			Path:    change.Rule.Path,
+			Profile: change.Rule.Profile,
+			At:      formatScheduleTime(change.At),
+			Spec:    change.Rule.At,
+		})
+	}
+
+	applied, err := s.scheduler.Applied()
+	if err != nil {
+		return view, []string{fmt.Sprintf("Camera schedule unavailable: %v", err)}
+	}
+	for path, a := range applied {
+		av := ScheduleAppliedView{Path: path, Profile: a.Profile, Time: formatScheduleTime(a.Time), Result: "applied", Class: "badge ok"}
+		switch {
+		case a.Error != "":
+			av.Result, av.Class = "failed: "+a.Error, "badge err"
+		case !a.Changed:
+			av.Result = "already active"
+		}
+		view.Applied = append(view.Applied, av)
+	}
+	sort.Slice(view.Applied, func(i, j int) bool { return view.Applied[i].Path < view.Applied[j].Path })
+	return view, nil
+}
+
+func formatScheduleTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func redirectSchedule(w http.ResponseWriter, r *http.Request, status string) {
+	http.Redirect(w, r, "/?schedule="+status, http.StatusSeeOther)
+}
+
+func scheduleMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "added":
+		return "Schedule rule added.", "notice ok"
+	case "deleted":
+		return "Schedule rule deleted.", "notice ok"
+	case "invalid-rule":
+		return "Invalid rule. Use a five-field cron expression such as \"30 7 * * 1-5\", or sunrise or sunset with an optional offset such as \"sunset+30m\", and pick a profile.", "notice err"
+	case "needs-location":
+		return "Sunrise and sunset rules need SCHEDULE_LATITUDE and SCHEDULE_LONGITUDE.", "notice err"
+	case "invalid-path":
+		return "Unknown camera path.", "notice err"
+	case "delete-error":
+		return "Failed to delete schedule rule.", "notice err"
+	}
+	return "", ""
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/schedule"
+)
+
+func TestScheduleForms(t *testing.T) {
+	srv := newFixtureServer(t)
+	if err := config.SaveProfile(srv.configPath, config.Profile{Name: "night", Values: map[string]string{"rpiCameraAWB": "indoor"}}); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+
+	if got := postForm(t, srv, "/schedule/add", url.Values{"at": {"sunset"}, "path": {"cam"}, "profile": {"night"}}); got != "/?schedule=needs-location" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/schedule/add", url.Values{"at": {"0 25 * * *"}, "path": {"cam"}, "profile": {"night"}}); got != "/?schedule=invalid-rule" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/schedule/add", url.Values{"at": {"0 20 * * *"}, "path": {"cam"}, "profile": {"night"}}); got != "/?schedule=added" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(view.Schedule.Upcoming) != 1 || view.Schedule.Upcoming[0].Profile != "night" || !strings.HasSuffix(view.Schedule.Upcoming[0].At, "20:00") {
+		t.Fatalf("unexpected upcoming changes: %+v", view.Schedule.Upcoming)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Next scheduled change") {
+		t.Fatalf("rendered page missing the next scheduled change")
+	}
+
+	if got := postForm(t, srv, "/schedule/delete", url.Values{"id": {"1"}}); got != "/?schedule=deleted" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if rules, _ := srv.scheduler.Rules(); len(rules) != 0 {
+		t.Fatalf("expected rule deleted, got %+v", rules)
+	}
+}
+
+func TestApplyScheduledProfile(t *testing.T) {
+	srv := newFixtureServer(t)
+	if err := config.SaveProfile(srv.configPath, config.Profile{Name: "night", Values: map[string]string{"rpiCameraAWB": "indoor"}}); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+
+	changed, err := srv.applyScheduledProfile(context.Background(), "cam", "night")
+	if err != nil || !changed {
+		t.Fatalf("expected the profile applied: %v %v", changed, err)
+	}
+	changed, err = srv.applyScheduledProfile(context.Background(), "cam", "night")
+	if err != nil || changed {
+		t.Fatalf("expected an active profile not to be saved again: %v %v", changed, err)
+	}
+	if backups, _ := config.ListBackups(srv.configPath); len(backups) != 1 {
+		t.Fatalf("expected a single save, got %+v", backups)
+	}
+	if _, err := srv.applyScheduledProfile(context.Background(), "other", "night"); err == nil {
+		t.Fatalf("expected an unknown path to fail")
+	}
+
+	// The scheduler drives the same function.
+	if _, err := srv.scheduler.AddRule(schedule.Rule{At: "0 7 * * *", Path: "cam", Profile: "night"}); err != nil {
+		t.Fatalf("add rule: %v", err)
+	}
+	if err := srv.scheduler.Tick(context.Background(), time.Now().Add(48*time.Hour)); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+	applied, _ := srv.scheduler.Applied()
+	if applied["cam"].Profile != "night" || applied["cam"].Changed || applied["cam"].Error != "" {
+		t.Fatalf("unexpected applied state: %+v", applied)
+	}
+}
@@ -3,29 +3,62 @@ package web
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
-	"net"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/xpereta/RaspiCam/internal/auth"
 	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/host"
 	"github.com/xpereta/RaspiCam/internal/mediamtx"
 	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/recordings"
+	"github.com/xpereta/RaspiCam/internal/sampler"
+	"github.com/xpereta/RaspiCam/internal/schedule"
 	"github.com/xpereta/RaspiCam/internal/system"
 )
 
 //go:embed templates/*.html
 var templatesFS embed.FS
 
+const defaultConfigPath = "/usr/local/etc/mediamtx.yml"
+
 type Server struct {
-	tmpl         *template.Template
-	mediamtxURL  string
-	mediamtxPath string
-	configPath   string
+	tmpl          *template.Template
+	env           host.Env
+	mediamtxAPI   *mediamtx.Client
+	mediamtxPath  string
+	configPath    string
+	recordingsDir string
+	backupPolicy  config.RetentionPolicy
+	watchdog      watchdogConfig
+	sampler       *sampler.Sampler
+	scheduler     *schedule.Scheduler
+	retention     *recordings.Retention
+	// auth checks logins; nil turns authentication off.
+	auth *auth.Authenticator
+	// authOpen records that the last request found no accounts, so the
+	// warning is logged once per change.
+	authOpen atomic.Bool
+
+	state   stateCache
+	history historyCache
+
+	// saveMu serializes config writes so a revision check and the write
+	// that follows it cannot interleave with another save.
+	saveMu sync.Mutex
+	// saveGen counts the writes of mediamtx.yml under saveMu, so the save
+	// watchdog can tell whether its save is still the latest.
+	saveGen uint64
 }
 
 type StatusView struct {
@@ -36,32 +69,57 @@ type StatusView struct {
 	CameraModel string
 	OSLabel     string
 	Metrics     MetricsView
-	Camera      CameraView
+	Cameras     []CameraView
 	MediaMTX    MediaMTXView
 	Network     NetworkView
+	History     HistoryView
+	Profiles    ProfilesView
+	Schedule    ScheduleView
+	Viewers     ViewersView
+	Service     ServiceView
+	Retention   RetentionView
+	Account     AccountView
 	Warnings    []string
 }
 
 type MetricsView struct {
-	CPUUsagePercent string
-	TemperatureC    string
-	VoltageV        string
-	Throttled       string
-	ThrottledFlags  []string
-	ThrottledClass  string
+	CPUUsagePercent      string
+	TemperatureC         string
+	TemperatureSource    string
+	VoltageV             string
+	VoltageSource        string
+	Throttled            string
+	ThrottledSource      string
+	ThrottledFlags       []string
+	ThrottledClass       string
+	OccurredFlags        []string
+	UnderVoltageOccurred bool
+	Uptime               string
+	Load                 string
+	Memory               string
+	MemoryClass          string
+	Swap                 string
+	RootDisk             string
+	RootDiskClass        string
+	RecordingsDisk       string
+	RecordingsDiskClass  string
 }
 
 type MediaMTXView struct {
-	ServiceStatus  string
-	APIStatus      string
-	PathName       string
-	PathReady      string
-	SourceType     string
-	Readers        string
-	Tracks         string
-	ServiceClass   string
-	APIClass       string
-	PathReadyClass string
+	ServiceStatus string
+	APIStatus     string
+	ServiceClass  string
+	APIClass      string
+	Paths         []PathView
+}
+
+type PathView struct {
+	Name       string
+	Ready      string
+	ReadyClass string
+	SourceType string
+	Readers    string
+	Tracks     string
 }
 
 type NetworkView struct {
@@ -75,37 +133,149 @@ type NetworkView struct {
 }
 
 type CameraView struct {
-	VFlip        bool
-	HFlip        bool
-	Resolution   string
-	AWB          string
-	Mode         string
-	AfMode       string
-	LensPosition string
-	LastUpdated  string
-	Message      string
-	MessageClass string
+	Path          string
+	Revision      string
+	VFlip         bool
+	HFlip         bool
+	Resolution    string
+	AWB           string
+	Mode          string
+	AfMode        string
+	LensPosition  string
+	AWBOptions    []OptionView
+	ModeOptions   []OptionView
+	AfModeOptions []OptionView
+	Recording     []ParamView
+	Groups        []ParamGroupView
+	LastUpdated   string
+	Message       string
+	MessageClass  string
+	Conflicts     []ConflictView
+	// Drift lists settings applied live but not saved to mediamtx.yml.
+	Drift []DriftView
+	URLs  []StreamURLView
+	// WHEPURL feeds the preview player; empty when WebRTC is disabled.
+	WHEPURL string
+}
+
+// ConflictView is a parameter that differs between a rejected submission
+// and the file on disk.
+type ConflictView struct {
+	Label     string
+	Current   string
+	Submitted string
 }
 
 func NewServer() (*Server, error) {
-	tmpl, err := template.ParseFS(templatesFS, "templates/status.html")
+	tmpl, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := getEnvDuration("SAMPLE_INTERVAL", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	retention, err := getEnvDuration("HISTORY_RETENTION", 12*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	backupKeep, err := getEnvInt("BACKUP_KEEP", 20)
+	if err != nil {
+		return nil, err
+	}
+	backupMaxAge, err := getEnvDuration("BACKUP_MAX_AGE", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	var watchdogTimeout time.Duration
+	if os.Getenv("SAVE_WATCHDOG_TIMEOUT") != "0" {
+		watchdogTimeout, err = getEnvDuration("SAVE_WATCHDOG_TIMEOUT", 20*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+	location, err := scheduleLocation()
+	if err != nil {
+		return nil, err
+	}
+	sessionTTL, err := getEnvDuration("SESSION_TTL", 12*time.Hour)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Server{
-		tmpl:         tmpl,
-		mediamtxURL:  getEnvDefault("MEDIAMTX_API_URL", "http://127.0.0.1:9997"),
-		mediamtxPath: getEnvDefault("MEDIAMTX_PATH_NAME", "cam"),
-		configPath:   getEnvDefault("MEDIAMTX_CONFIG_PATH", "/usr/local/etc/mediamtx.yml"),
-	}, nil
+	s := &Server{
+		tmpl:          tmpl,
+		env:           host.New(getEnvDefault("HOST_ROOT", "/")),
+		mediamtxAPI:   newMediaMTXClient(),
+		mediamtxPath:  getEnvDefault("MEDIAMTX_PATH_NAME", "cam"),
+		configPath:    getEnvDefault("MEDIAMTX_CONFIG_PATH", defaultConfigPath),
+		recordingsDir: getEnvDefault("RECORDINGS_DIR", "/recordings"),
+		backupPolicy:  config.RetentionPolicy{MaxCount: backupKeep, MaxAge: backupMaxAge},
+		watchdog:      watchdogConfig{Timeout: watchdogTimeout, Settle: 2 * time.Second, Interval: time.Second},
+		auth:          auth.New(UsersPath(), sessionTTL),
+	}
+	s.sampler = sampler.New(interval, int(retention/interval), s.collectSample)
+	s.scheduler = schedule.New(schedule.SchedulePath(s.configPath), location, s.applyScheduledProfile)
+	if s.retention, err = s.newRetention(); err != nil {
+		return nil, err
+	}
+	s.authEnabled()
+	return s, nil
+}
+
+func parseTemplates() (*template.Template, error) {
+	// status.html comes first so Execute renders the status page.
+	return template.ParseFS(templatesFS, "templates/status.html", "templates/recordings.html", "templates/params.html", "templates/login.html", "templates/account.html", "templates/style.html")
+}
+
+// Run starts the background collectors and blocks until ctx is done.
+func (s *Server) Run(ctx context.Context) {
+	go s.scheduler.Run(ctx)
+	go s.retention.Run(ctx)
+	s.sampler.Run(ctx)
 }
 
 func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleStatus)
 	mux.HandleFunc("/camera-config", s.handleCameraUpdate)
-	return mux
+	mux.HandleFunc("/camera-record", s.handleRecordUpdate)
+	mux.HandleFunc("/camera-persist", s.handleCameraPersist)
+	mux.HandleFunc("/config-restore", s.handleConfigRestore)
+	mux.HandleFunc("/profiles/save", s.handleProfileSave)
+	mux.HandleFunc("/profiles/apply", s.handleProfileApply)
+	mux.HandleFunc("/profiles/rename", s.handleProfileRename)
+	mux.HandleFunc("/profiles/delete", s.handleProfileDelete)
+	mux.HandleFunc("/schedule/add", s.handleScheduleAdd)
+	mux.HandleFunc("/schedule/delete", s.handleScheduleDelete)
+	mux.HandleFunc("/viewers/kick", s.handleViewerKick)
+	mux.HandleFunc("/service/control", s.handleServiceControl)
+	mux.HandleFunc("/recordings", s.handleRecordings)
+	mux.HandleFunc("/recordings/download", s.handleRecordingDownload)
+	mux.HandleFunc("/recordings/delete", s.handleRecordingDelete)
+	mux.HandleFunc("/recordings/export", s.handleRecordingExport)
+	mux.HandleFunc("/api/v1/status", s.handleAPIStatus)
+	mux.HandleFunc("/api/v1/camera", s.handleAPICamera)
+	mux.HandleFunc("/api/v1/camera/persist", s.handleAPICameraPersist)
+	mux.HandleFunc("/api/v1/camera/record", s.handleAPIRecord)
+	mux.HandleFunc("/api/v1/history", s.handleAPIHistory)
+	mux.HandleFunc("/api/v1/backups", s.handleAPIBackups)
+	mux.HandleFunc("/api/v1/backups/restore", s.handleAPIBackupRestore)
+	mux.HandleFunc("/api/v1/profiles", s.handleAPIProfiles)
+	mux.HandleFunc("/api/v1/profiles/apply", s.handleAPIProfileApply)
+	mux.HandleFunc("/api/v1/schedule", s.handleAPISchedule)
+	mux.HandleFunc("/api/v1/viewers", s.handleAPIViewers)
+	mux.HandleFunc("/api/v1/viewers/kick", s.handleAPIViewerKick)
+	mux.HandleFunc("/api/v1/service", s.handleAPIService)
+	mux.HandleFunc("/api/v1/service/control", s.handleAPIServiceControl)
+	mux.HandleFunc("/api/v1/service/logs", s.handleAPIServiceLogs)
+	mux.HandleFunc("/api/v1/recordings", s.handleAPIRecordings)
+	mux.HandleFunc("/api/v1/recordings/delete", s.handleAPIRecordingDelete)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/login", s.handleLogin)
+	mux.HandleFunc("/logout", s.handleLogout)
+	return s.requireAuth(mux)
 }
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -114,12 +284,21 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	message, messageClass := cameraMessageFromStatus(r.URL.Query().Get("camera"))
-	view, err := s.buildStatusView(r.Context(), message, messageClass)
+	query := r.URL.Query()
+	message, messageClass := cameraMessageFromStatus(query.Get("camera"))
+	view, err := s.buildStatusView(r.Context(), query.Get("path"), message, messageClass)
 	if err != nil {
 		http.Error(w, "status unavailable", http.StatusInternalServerError)
 		return
 	}
+	view.History.Message, view.History.MessageClass = historyMessageFromStatus(query.Get("history"))
+	view.Profiles.Message, view.Profiles.MessageClass = profilesMessageFromStatus(query.Get("profiles"))
+	view.Schedule.Message, view.Schedule.MessageClass = scheduleMessageFromStatus(query.Get("schedule"))
+	view.Viewers.Message, view.Viewers.MessageClass = viewersMessageFromStatus(query.Get("viewers"))
+	view.Service.Message, view.Service.MessageClass = serviceMessageFromStatus(query.Get("service"))
+	if query.Has("logs") {
+		s.loadServiceLogs(r.Context(), &view.Service, query)
+	}
 	if err := s.tmpl.Execute(w, view); err != nil {
 		http.Error(w, "template render error", http.StatusInternalServerError)
 	}
@@ -135,91 +314,377 @@ func (s *Server) handleCameraUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := config.CameraConfig{
-		VFlip: r.FormValue("rpiCameraVFlip") == "on",
-		HFlip: r.FormValue("rpiCameraHFlip") == "on",
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		http.Redirect(w, r, "/?camera=invalid-path", http.StatusSeeOther)
+		return
+	}
+	redirect := func(status string) {
+		http.Redirect(w, r, "/?camera="+status+"&path="+url.QueryEscape(path), http.StatusSeeOther)
+	}
+
+	// Start from the file so parameters the form does not submit, such as
+	// disabled inputs, are kept.
+	cfg, err := config.LoadCameraConfig(s.configPath, path)
+	if err != nil {
+		log.Printf("camera config %s: %v", path, err)
+		redirect("load-error")
+		return
 	}
+	if revision := r.FormValue("revision"); revision != "" {
+		cfg.Revision = revision
+	}
+	cfg.VFlip = r.FormValue("rpiCameraVFlip") == "on"
+	cfg.HFlip = r.FormValue("rpiCameraHFlip") == "on"
+
 	resolution := r.FormValue("resolution")
 	if resolution != "" {
 		width, height, ok := parseResolution(resolution)
 		if !ok {
-			http.Redirect(w, r, "/?camera=invalid-resolution", http.StatusSeeOther)
+			redirect("invalid-resolution")
 			return
 		}
 		cfg.Width = width
 		cfg.Height = height
 	}
-	awb := r.FormValue("rpiCameraAWB")
-	if awb != "" {
-		if !isValidAWB(awb) {
-			http.Redirect(w, r, "/?camera=invalid-awb", http.StatusSeeOther)
-			return
+
+	if key, err := applyCameraForm(&cfg, r.Form); err != nil {
+		redirect("invalid-" + paramSlug(key))
+		return
+	}
+
+	if r.FormValue("apply") == "live" {
+		var static *config.StaticChangeError
+		switch err := s.applyLiveConfig(r.Context(), path, cfg); {
+		case err == nil:
+			redirect("live-applied")
+		case errors.As(err, &static):
+			redirect("live-static")
+		default:
+			redirect("live-error")
 		}
-		cfg.AWB = awb
+		return
+	}
+
+	if err := s.saveCameraConfig(r.Context(), path, cfg); err != nil {
+		s.saveFailed(w, r, path, err, redirect)
+		return
+	}
+
+	redirect("saved")
+}
+
+// handleRecordUpdate saves the recording form of a camera card.
+func (s *Server) handleRecordUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	path, ok := s.resolveCameraPath(r.FormValue("path"))
+	if !ok {
+		http.Redirect(w, r, "/?camera=invalid-path", http.StatusSeeOther)
+		return
+	}
+	redirect := func(status string) {
+		http.Redirect(w, r, "/?camera="+status+"&path="+url.QueryEscape(path), http.StatusSeeOther)
+	}
+
+	cfg, err := config.LoadRecordConfig(s.configPath, path)
+	if err != nil {
+		log.Printf("recording config %s: %v", path, err)
+		redirect("load-error")
+		return
 	}
-	mode := r.FormValue("rpiCameraMode")
-	if mode != "" && !isValidCameraMode(mode) {
-		http.Redirect(w, r, "/?camera=invalid-mode", http.StatusSeeOther)
+	if revision := r.FormValue("revision"); revision != "" {
+		cfg.Revision = revision
+	}
+	if key, err := applyRecordForm(&cfg, r.Form); err != nil {
+		redirect("invalid-" + paramSlug(key))
 		return
 	}
-	cfg.Mode = mode
 
-	afMode := r.FormValue("rpiCameraAfMode")
-	if !isValidAFMode(afMode) {
-		http.Redirect(w, r, "/?camera=invalid-af-mode", http.StatusSeeOther)
+	if err := s.saveRecordConfig(r.Context(), path, cfg); err != nil {
+		s.saveFailed(w, r, path, err, redirect)
+		return
+	}
+	redirect("saved")
+}
+
+// saveFailed answers a camera card save that failed: a conflict shows the
+// differences, anything else redirects with its status.
+func (s *Server) saveFailed(w http.ResponseWriter, r *http.Request, path string, err error, redirect func(status string)) {
+	var rollback *rollbackError
+	var conflict *config.ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		s.renderConflict(w, r, path, conflict)
+	case errors.As(err, &rollback) && rollback.Restore == nil:
+		redirect("rolled-back")
+	case errors.As(err, &rollback):
+		redirect("rollback-failed")
+	default:
+		redirect("save-error")
+	}
+}
+
+// renderConflict answers a rejected save with the status page, showing the
+// fields that differ between the submission and the file on disk.
+func (s *Server) renderConflict(w http.ResponseWriter, r *http.Request, path string, conflict *config.ConflictError) {
+	view, err := s.buildStatusView(r.Context(), path, "The camera configuration changed since this page was loaded, so nothing was saved. Review the differences and save again.", "notice err")
+	if err != nil {
+		http.Error(w, "status unavailable", http.StatusInternalServerError)
 		return
 	}
-	cfg.AfMode = afMode
+	for i := range view.Cameras {
+		if view.Cameras[i].Path == path {
+			view.Cameras[i].Conflicts = formatConflicts(conflict.Fields)
+		}
+	}
+	w.WriteHeader(http.StatusConflict)
+	if err := s.tmpl.Execute(w, view); err != nil {
+		http.Error(w, "template render error", http.StatusInternalServerError)
+	}
+}
+
+func formatConflicts(fields []config.FieldConflict) []ConflictView {
+	views := make([]ConflictView, 0, len(fields))
+	for _, f := range fields {
+		p, ok := config.LookupParam(f.Key)
+		if !ok {
+			p, ok = config.LookupRecordParam(f.Key)
+		}
+		if !ok {
+			p = config.Param{Key: f.Key, Label: f.Key}
+		}
+		views = append(views, ConflictView{Label: p.Label, Current: conflictValue(p, f.Current), Submitted: conflictValue(p, f.Submitted)})
+	}
+	return views
+}
+
+func conflictValue(p config.Param, value string) string {
+	if value == "" {
+		return "not set"
+	}
+	return optionLabel(p, value)
+}
 
-	if _, ok := r.Form["rpiCameraLensPosition"]; ok {
-		lensPosition := strings.TrimSpace(r.FormValue("rpiCameraLensPosition"))
-		cfg.LensPositionSet = true
-		if lensPosition == "" {
-			cfg.LensPosition = nil
+// cameraPaths returns the rpiCamera paths in the MediaMTX config, starting
+// with MEDIAMTX_PATH_NAME when it is one of them. It falls back to
+// MEDIAMTX_PATH_NAME alone when the config lists none.
+func (s *Server) cameraPaths() []string {
+	names, err := config.CameraPaths(s.configPath)
+	if err != nil || len(names) == 0 {
+		return []string{s.mediamtxPath}
+	}
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == s.mediamtxPath {
+			paths = append([]string{name}, paths...)
 		} else {
-			value, ok := parseLensPosition(lensPosition)
-			if !ok || value < 0 {
-				http.Redirect(w, r, "/?camera=invalid-lens-position", http.StatusSeeOther)
-				return
-			}
-			cfg.LensPosition = &value
+			paths = append(paths, name)
 		}
 	}
+	return paths
+}
 
-	if err := config.SaveCameraConfig(s.configPath, cfg); err != nil {
-		http.Redirect(w, r, "/?camera=save-error", http.StatusSeeOther)
-		return
+// resolveCameraPath maps a requested path name to a camera path; an empty
+// name selects the primary camera.
+func (s *Server) resolveCameraPath(name string) (string, bool) {
+	paths := s.cameraPaths()
+	if name == "" {
+		return paths[0], true
+	}
+	for _, path := range paths {
+		if path == name {
+			return path, true
+		}
 	}
+	return "", false
+}
+
+// statusData holds the raw values collected for one status render, before
+// they are formatted for the HTML page or encoded for the JSON API.
+type statusData struct {
+	GeneratedAt time.Time
+	Hostname    string
+	IPAddress   string
+	Device      system.Info
+	Metrics     metrics.Snapshot
+	MediaMTX    mediamtx.Status
+	Network     system.NetworkSnapshot
+	Cameras     []cameraData
+	LastUpdated time.Time
+	HasUpdated  bool
+	State       mediamtxState
+	Warnings    []string
+}
+
+// cameraData is the config of one rpiCamera path. Drift lists the live
+// parameters MediaMTX runs with a value that is not in the file, URLs
+// where the path can be watched, Record the recording keys the path sets
+// and RecordDefaults the recording settings it inherits when it does not
+// set them itself.
+type cameraData struct {
+	Path           string
+	Config         config.CameraConfig
+	Drift          []config.FieldDrift
+	URLs           []config.StreamURL
+	Record         config.RecordConfig
+	RecordDefaults config.RecordSettings
+}
 
-	http.Redirect(w, r, "/?camera=saved", http.StatusSeeOther)
+// collectSample samples the host and MediaMTX and, while the sampler runs,
+// refreshes the stored MediaMTX state alongside.
+func (s *Server) collectSample(ctx context.Context) sampler.Sample {
+	var wg sync.WaitGroup
+	if s.sampler != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.storeState(s.collectState(ctx))
+		}()
+	}
+	sample := sampler.Collect(ctx, sampler.Sources{
+		Env:           s.env,
+		MediaMTX:      s.mediamtxAPI,
+		MediaMTXPaths: s.cameraPaths(),
+		RecordingsDir: s.recordingsDir,
+	})
+	wg.Wait()
+	return sample
 }
 
-func (s *Server) buildStatusView(ctx context.Context, message, messageClass string) (StatusView, error) {
+// latestSample returns the most recent background sample, or collects one
+// inline when the sampler has not produced one yet.
+func (s *Server) latestSample(ctx context.Context) sampler.Sample {
+	if s.sampler != nil {
+		if sample, ok := s.sampler.Latest(); ok {
+			return sample
+		}
+	}
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
+	return s.collectSample(ctx)
+}
+
+func (s *Server) collectStatus(ctx context.Context) statusData {
+	sample := s.latestSample(ctx)
+	state := s.currentState(ctx)
+	warnings := append([]string(nil), sample.Warnings...)
 
-	snap, warnings := metrics.Collect(ctx)
-	mtxStatus, mtxWarnings := mediamtx.Collect(ctx, s.mediamtxURL, s.mediamtxPath)
-	device := system.Collect()
-	network, networkWarnings := system.CollectNetwork(ctx)
-	camera, camWarnings := s.loadCameraConfig()
+	device := system.Collect(s.env)
+	hostname := hostnameOrUnknown(s.env)
+	paths := s.cameraPaths()
+	urls, err := s.streamURLs(streamHost(sample.Network, hostname), paths)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("Stream URLs unavailable: %v", err))
+	}
+	// An unreadable file is reported with the camera configs; the schema
+	// defaults apply then.
+	recordDefaults, _ := config.LoadRecordSettings(s.configPath, "")
+	var cameras []cameraData
+	for _, path := range paths {
+		camera := cameraData{Path: path, URLs: urls[path], RecordDefaults: recordDefaults}
+		cfg, err := config.LoadCameraConfig(s.configPath, path)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Camera config for %s unavailable: %v", path, err))
+			cameras = append(cameras, camera)
+			continue
+		}
+		camera.Config = cfg
+		if camera.Record, err = config.LoadRecordConfig(s.configPath, path); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Recording config for %s unavailable: %v", path, err))
+		}
+		camera.Drift, err = state.drift(path, cfg)
+		if err != nil && !errors.Is(err, mediamtx.ErrUnreachable) {
+			warnings = append(warnings, fmt.Sprintf("Running config for %s unavailable: %v", path, err))
+		}
+		cameras = append(cameras, camera)
+	}
 	lastUpdated, ok, err := config.ConfigModTime(s.configPath)
 	if err != nil {
 		warnings = append(warnings, fmt.Sprintf("Camera update time unavailable: %v", err))
 	}
 
+	return statusData{
+		GeneratedAt: sample.Time,
+		State:       state,
+		Hostname:    hostname,
+		IPAddress:   primaryIPv4OrUnknown(s.env),
+		Device:      device,
+		Metrics:     sample.Metrics,
+		MediaMTX:    sample.MediaMTX,
+		Network:     sample.Network,
+		Cameras:     cameras,
+		LastUpdated: lastUpdated,
+		HasUpdated:  ok,
+		Warnings:    warnings,
+	}
+}
+
+// buildStatusView renders the collected status. The camera message is shown
+// on the card of messagePath, or of the primary camera when it is empty.
+func (s *Server) buildStatusView(ctx context.Context, messagePath, message, messageClass string) (StatusView, error) {
+	data := s.collectStatus(ctx)
+
+	cameras := make([]CameraView, 0, len(data.Cameras))
+	for i, camera := range data.Cameras {
+		msg, msgClass := "", ""
+		if camera.Path == messagePath || (messagePath == "" && i == 0) {
+			msg, msgClass = message, messageClass
+		}
+		// The form shows what the camera runs, so saving it keeps live
+		// changes instead of reverting them.
+		cfg := config.WithRunning(camera.Config, camera.Drift)
+		view := formatCamera(camera.Path, cfg, data.MediaMTX.Version, data.LastUpdated, data.HasUpdated, msg, msgClass)
+		view.Recording = cameraRecordParams(camera.Path, camera.Record, camera.RecordDefaults, data.MediaMTX.Version)
+		view.Drift = formatDrift(camera.Drift)
+		view.URLs = formatStreamURLs(camera.URLs)
+		view.WHEPURL = whepURL(camera.URLs)
+		cameras = append(cameras, view)
+	}
+	account := s.accountView(ctx)
+	// Backups hold the whole config and viewers their addresses, which
+	// only admins see.
+	var history HistoryView
+	var viewers ViewersView
+	var historyWarnings, viewerWarnings []string
+	if account.Admin {
+		history, historyWarnings = s.loadHistory()
+		viewers, viewerWarnings = loadViewers(data.State, time.Now())
+	}
+	profiles, profileWarnings := s.loadProfiles()
+	sched, scheduleWarnings := s.loadSchedule(time.Now())
+	service, serviceWarnings := loadService(data.State, time.Now())
+	retention, retentionWarnings := s.loadRetention(time.Now())
+
 	view := StatusView{
-		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
-		Hostname:    hostnameOrUnknown(),
-		IPAddress:   primaryIPv4OrUnknown(),
-		DeviceModel: device.Model,
-		CameraModel: device.Camera,
-		OSLabel:     device.OSLabel,
-		Metrics:     formatMetrics(snap),
-		Camera:      formatCamera(camera, lastUpdated, ok, message, messageClass),
-		MediaMTX:    formatMediaMTX(mtxStatus),
-		Network:     formatNetwork(network),
-		Warnings:    append(warnings, append(append(mtxWarnings, camWarnings...), networkWarnings...)...),
+		GeneratedAt: data.GeneratedAt.Format("2006-01-02 15:04:05"),
+		Hostname:    data.Hostname,
+		IPAddress:   data.IPAddress,
+		DeviceModel: data.Device.Model,
+		CameraModel: data.Device.Camera,
+		OSLabel:     data.Device.OSLabel,
+		Metrics:     formatMetrics(data.Metrics),
+		Cameras:     cameras,
+		MediaMTX:    formatMediaMTX(data.MediaMTX),
+		Network:     formatNetwork(data.Network),
+		History:     history,
+		Profiles:    profiles,
+		Schedule:    sched,
+		Viewers:     viewers,
+		Service:     service,
+		Retention:   retention,
+		Account:     account,
+		Warnings:    concatStrings(data.Warnings, historyWarnings, profileWarnings, scheduleWarnings, viewerWarnings, serviceWarnings, retentionWarnings),
+	}
+	if s.auth != nil && !s.authEnabled() {
+		view.Warnings = append(view.Warnings, fmt.Sprintf("No UI account exists, so the UI is read-only and anyone on the network can see it. Run raspicam-ui passwd on the Pi to create the admin account and require a login (accounts file %s).", s.auth.Path()))
 	}
 
 	return view, nil
@@ -232,6 +697,13 @@ func formatMetrics(snap metrics.Snapshot) MetricsView {
 		VoltageV:        "unavailable",
 		Throttled:       "unavailable",
 		ThrottledClass:  "badge warn",
+		Uptime:          "unavailable",
+		Load:            "unavailable",
+		Memory:          "unavailable",
+		MemoryClass:     "badge warn",
+		Swap:            "unavailable",
+		RootDisk:        "unavailable",
+		RootDiskClass:   "badge warn",
 	}
 
 	if snap.CPUUsagePercent != nil {
@@ -239,97 +711,172 @@ func formatMetrics(snap metrics.Snapshot) MetricsView {
 	}
 	if snap.TemperatureC != nil {
 		view.TemperatureC = formatFloat(*snap.TemperatureC, 1) + " C"
+		view.TemperatureSource = snap.TemperatureSource
 	}
 	if snap.VoltageV != nil {
 		view.VoltageV = formatFloat(*snap.VoltageV, 4) + " V"
+		view.VoltageSource = snap.VoltageSource
 	}
 	if snap.Throttled != nil {
 		if snap.Throttled.IsThrottled {
 			view.Throttled = "yes"
 			view.ThrottledClass = "badge err"
-		} else {
+		} else if snap.Throttled.HasOccurred {
+			view.Throttled = "since boot"
+			view.ThrottledClass = "badge warn"
+		} else if snap.Throttled.Reports(metrics.ThrottleMask) {
 			view.Throttled = "no"
 			view.ThrottledClass = "badge ok"
+		} else {
+			// Only current under-voltage is known.
+			view.Throttled = "no under-voltage"
+			view.ThrottledClass = "badge ok"
 		}
+		view.ThrottledSource = snap.ThrottledSource
 		view.ThrottledFlags = snap.Throttled.Flags
+		view.OccurredFlags = snap.Throttled.OccurredFlags
+		view.UnderVoltageOccurred = snap.Throttled.UnderVoltageOccurred
+	}
+	if snap.UptimeSeconds != nil {
+		view.Uptime = formatUptime(*snap.UptimeSeconds)
+	}
+	if snap.Load != nil {
+		view.Load = fmt.Sprintf("%.2f %.2f %.2f", snap.Load.Load1, snap.Load.Load5, snap.Load.Load15)
+	}
+	if snap.Memory != nil {
+		view.Memory = formatUsage(snap.Memory.UsedBytes, snap.Memory.TotalBytes, snap.Memory.UsedPercent)
+		view.MemoryClass = usageClass(snap.Memory.UsedPercent, 75, 90)
+		if snap.Memory.SwapTotalBytes == 0 {
+			view.Swap = "disabled"
+		} else {
+			swapPercent := float64(snap.Memory.SwapUsedBytes) / float64(snap.Memory.SwapTotalBytes) * 100
+			view.Swap = formatUsage(snap.Memory.SwapUsedBytes, snap.Memory.SwapTotalBytes, swapPercent)
+		}
+	}
+	if snap.RootDisk != nil {
+		view.RootDisk = formatDisk(*snap.RootDisk)
+		view.RootDiskClass = usageClass(snap.RootDisk.UsedPercent, 80, 90)
+	}
+	if snap.RecordingsDisk != nil {
+		view.RecordingsDisk = formatDisk(*snap.RecordingsDisk)
+		view.RecordingsDiskClass = usageClass(snap.RecordingsDisk.UsedPercent, 80, 90)
 	}
 
 	return view
 }
 
+func formatUsage(used, total uint64, percent float64) string {
+	return fmt.Sprintf("%s / %s (%.0f%%)", formatBytes(used), formatBytes(total), percent)
+}
+
+func formatDisk(usage metrics.DiskUsage) string {
+	return fmt.Sprintf("%s free of %s (%.0f%% used)", formatBytes(usage.FreeBytes), formatBytes(usage.TotalBytes), usage.UsedPercent)
+}
+
+func usageClass(percent, warnAt, errAt float64) string {
+	if percent >= errAt {
+		return "badge err"
+	}
+	if percent >= warnAt {
+		return "badge warn"
+	}
+	return "badge ok"
+}
+
+func formatUptime(seconds float64) string {
+	total := int64(seconds)
+	days := total / 86400
+	hours := (total % 86400) / 3600
+	minutes := (total % 3600) / 60
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
 func formatFloat(v float64, decimals int) string {
 	return fmt.Sprintf("%.*f", decimals, v)
 }
 
 func formatMediaMTX(status mediamtx.Status) MediaMTXView {
+	// Unknown values stay empty; the template shows them as unknown.
 	view := MediaMTXView{
-		ServiceStatus:  status.ServiceStatus,
-		APIStatus:      status.APIStatus,
-		PathName:       status.PathName,
-		PathReady:      "unavailable",
-		SourceType:     status.SourceType,
-		Readers:        "unavailable",
-		Tracks:         "unavailable",
-		ServiceClass:   "badge warn",
-		APIClass:       "badge warn",
-		PathReadyClass: "badge warn",
-	}
-
-	if status.PathReady != nil {
-		if *status.PathReady {
-			view.PathReady = "yes"
-			view.PathReadyClass = "badge ok"
-		} else {
-			view.PathReady = "no"
-			view.PathReadyClass = "badge err"
-		}
-	}
-	if status.Readers != nil {
-		view.Readers = fmt.Sprintf("%d", *status.Readers)
-	}
-	if status.Tracks != nil {
-		view.Tracks = fmt.Sprintf("%d", *status.Tracks)
+		ServiceClass: "badge warn",
+		APIClass:     "badge warn",
 	}
 
-	if view.ServiceStatus == "" {
-		view.ServiceStatus = "unknown"
+	for _, path := range status.Paths {
+		view.Paths = append(view.Paths, formatPath(path))
 	}
-	if view.APIStatus == "" {
-		view.APIStatus = "unknown"
+
+	if status.ServiceStatus != nil {
+		view.ServiceStatus = *status.ServiceStatus
 	}
-	if view.SourceType == "" {
-		view.SourceType = "unknown"
+	if status.APIStatus != nil {
+		view.APIStatus = string(*status.APIStatus)
 	}
 	if view.ServiceStatus == "active" {
 		view.ServiceClass = "badge ok"
 	} else if view.ServiceStatus == "failed" {
 		view.ServiceClass = "badge err"
 	}
-	if view.APIStatus == "ok" {
+	switch view.APIStatus {
+	case string(mediamtx.APIOK):
 		view.APIClass = "badge ok"
-	} else if view.APIStatus == "unavailable" {
+	case string(mediamtx.APIUnavailable):
 		view.APIClass = "badge err"
 	}
 
 	return view
 }
 
+func formatPath(path mediamtx.PathState) PathView {
+	view := PathView{
+		Name:       path.Name,
+		Ready:      "unavailable",
+		ReadyClass: "badge warn",
+		Readers:    "unavailable",
+		Tracks:     "unavailable",
+	}
+
+	if path.Ready != nil {
+		if *path.Ready {
+			view.Ready = "yes"
+			view.ReadyClass = "badge ok"
+		} else {
+			view.Ready = "no"
+			view.ReadyClass = "badge err"
+		}
+	}
+	if path.Readers != nil {
+		view.Readers = fmt.Sprintf("%d", *path.Readers)
+	}
+	if path.Tracks != nil {
+		view.Tracks = fmt.Sprintf("%d", *path.Tracks)
+	}
+	if path.SourceType != nil {
+		view.SourceType = *path.SourceType
+	}
+
+	return view
+}
+
 func formatNetwork(snap system.NetworkSnapshot) NetworkView {
+	// Missing interface and WiFi values stay empty; the template shows
+	// them as unavailable.
 	view := NetworkView{
-		Interface:       "unavailable",
-		IPAddress:       "unavailable",
-		RxRate:          "unavailable",
-		TxRate:          "unavailable",
-		WiFiSSID:        "unavailable",
-		WiFiRate:        "unavailable",
-		WiFiLinkQuality: "unavailable",
+		RxRate: "unavailable",
+		TxRate: "unavailable",
 	}
 
-	if snap.Interface != "" {
-		view.Interface = snap.Interface
+	if snap.Interface != nil {
+		view.Interface = *snap.Interface
 	}
-	if snap.IPAddress != "" {
-		view.IPAddress = snap.IPAddress
+	if snap.IPAddress != nil {
+		view.IPAddress = *snap.IPAddress
 	}
 	if snap.RxBytesPerSec != nil {
 		view.RxRate = formatRate(*snap.RxBytesPerSec)
@@ -337,17 +884,38 @@ func formatNetwork(snap system.NetworkSnapshot) NetworkView {
 	if snap.TxBytesPerSec != nil {
 		view.TxRate = formatRate(*snap.TxBytesPerSec)
 	}
-	if snap.WiFiSSID != "" {
-		view.WiFiSSID = snap.WiFiSSID
+	if snap.WiFiSSID != nil {
+		view.WiFiSSID = *snap.WiFiSSID
 	}
-	if snap.WiFiLinkQuality != "" {
-		view.WiFiLinkQuality = snap.WiFiLinkQuality
+	if snap.WiFiLinkQuality != nil {
+		view.WiFiLinkQuality = *snap.WiFiLinkQuality
 	}
 	view.WiFiRate = formatWiFiRate(snap.WiFiTxRate, snap.WiFiRxRate)
 
 	return view
 }
 
+func concatStrings(lists ...[]string) []string {
+	var out []string
+	for _, list := range lists {
+		out = append(out, list...)
+	}
+	return out
+}
+
+// newMediaMTXClient builds the Control API client from MEDIAMTX_API_URL
+// and, when MediaMTX requires authentication, MEDIAMTX_API_TOKEN or
+// MEDIAMTX_API_USER and MEDIAMTX_API_PASS.
+func newMediaMTXClient() *mediamtx.Client {
+	var opts []mediamtx.Option
+	if token := os.Getenv("MEDIAMTX_API_TOKEN"); token != "" {
+		opts = append(opts, mediamtx.WithBearerToken(token))
+	} else if user := os.Getenv("MEDIAMTX_API_USER"); user != "" {
+		opts = append(opts, mediamtx.WithBasicAuth(user, os.Getenv("MEDIAMTX_API_PASS")))
+	}
+	return mediamtx.NewClient(getEnvDefault("MEDIAMTX_API_URL", "http://127.0.0.1:9997"), opts...)
+}
+
 func getEnvDefault(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -355,31 +923,67 @@ func getEnvDefault(key, fallback string) string {
 	return fallback
 }
 
-func (s *Server) loadCameraConfig() (config.CameraConfig, []string) {
-	cfg, err := config.LoadCameraConfig(s.configPath)
-	if err != nil {
-		return config.CameraConfig{}, []string{fmt.Sprintf("Camera config unavailable: %v", err)}
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
 	}
-	return cfg, nil
+	return parsed, nil
 }
 
-func formatCamera(cfg config.CameraConfig, updated time.Time, ok bool, message, messageClass string) CameraView {
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return parsed, nil
+}
+
+func formatCamera(path string, cfg config.CameraConfig, version string, updated time.Time, ok bool, message, messageClass string) CameraView {
 	lastUpdated := "never"
 	if ok {
 		lastUpdated = updated.Format("2006-01-02 15:04:05")
 	}
 	return CameraView{
-		VFlip:        cfg.VFlip,
-		HFlip:        cfg.HFlip,
-		Resolution:   resolutionLabel(cfg.Width, cfg.Height),
-		AWB:          cfg.AWB,
-		Mode:         cfg.Mode,
-		AfMode:       cfg.AfMode,
-		LensPosition: formatLensPosition(cfg.LensPosition),
-		LastUpdated:  lastUpdated,
-		Message:      message,
-		MessageClass: messageClass,
+		Path:          path,
+		Revision:      cfg.Revision,
+		VFlip:         cfg.VFlip,
+		HFlip:         cfg.HFlip,
+		Resolution:    resolutionLabel(cfg.Width, cfg.Height),
+		AWB:           cfg.AWB,
+		Mode:          cfg.Mode,
+		AfMode:        cfg.AfMode,
+		LensPosition:  formatLensPosition(cfg.LensPosition),
+		AWBOptions:    schemaOptions("rpiCameraAWB", cfg.AWB),
+		ModeOptions:   schemaOptions("rpiCameraMode", cfg.Mode),
+		AfModeOptions: schemaOptions("rpiCameraAfMode", cfg.AfMode),
+		Groups:        cameraParamGroups(path, cfg, version),
+		LastUpdated:   lastUpdated,
+		Message:       message,
+		MessageClass:  messageClass,
+	}
+}
+
+func formatBytes(bytes uint64) string {
+	value := float64(bytes)
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	unit := units[0]
+	for i := 0; i < len(units); i++ {
+		if value < 1024 || i == len(units)-1 {
+			unit = units[i]
+			break
+		}
+		value /= 1024
 	}
+	return fmt.Sprintf("%.1f %s", value, unit)
 }
 
 func formatRate(bytesPerSec float64) string {
@@ -396,17 +1000,16 @@ func formatRate(bytesPerSec float64) string {
 	return fmt.Sprintf("%.1f %s", value, unit)
 }
 
-func formatWiFiRate(txRate, rxRate string) string {
-	if txRate == "" && rxRate == "" {
-		return "unavailable"
-	}
-	if txRate != "" && rxRate != "" {
-		return fmt.Sprintf("TX %s, RX %s", txRate, rxRate)
-	}
-	if txRate != "" {
-		return "TX " + txRate
+func formatWiFiRate(txRate, rxRate *string) string {
+	switch {
+	case txRate != nil && rxRate != nil:
+		return fmt.Sprintf("TX %s, RX %s", *txRate, *rxRate)
+	case txRate != nil:
+		return "TX " + *txRate
+	case rxRate != nil:
+		return "RX " + *rxRate
 	}
-	return "RX " + rxRate
+	return ""
 }
 
 func parseResolution(value string) (int, int, bool) {
@@ -430,52 +1033,43 @@ func resolutionLabel(width, height int) string {
 	return ""
 }
 
-func isValidAWB(value string) bool {
-	switch value {
-	case "auto", "incandescent", "tungsten", "fluorescent", "indoor", "daylight", "cloudy", "custom":
-		return true
-	default:
-		return false
-	}
-}
-
-func isValidCameraMode(value string) bool {
-	switch value {
-	case "2304:1296:10:P", "1536:864:10:P":
-		return true
-	default:
-		return false
-	}
-}
-
-func isValidAFMode(value string) bool {
-	switch value {
-	case "manual", "continuous":
-		return true
-	default:
-		return false
-	}
-}
-
 func cameraMessageFromStatus(status string) (string, string) {
 	switch status {
 	case "saved":
 		return "Camera configuration saved.", "notice ok"
 	case "save-error":
 		return "Failed to save camera configuration.", "notice err"
+	case "load-error":
+		return "Failed to read the camera configuration, so nothing was saved.", "notice err"
 	case "invalid-resolution":
 		return "Invalid resolution selection.", "notice err"
-	case "invalid-awb":
-		return "Invalid AWB selection.", "notice err"
-	case "invalid-mode":
-		return "Invalid camera mode selection.", "notice err"
-	case "invalid-af-mode":
-		return "Invalid focus mode selection.", "notice err"
-	case "invalid-lens-position":
-		return "Invalid lens position selection.", "notice err"
-	default:
-		return "", ""
+	case "invalid-path":
+		return "Unknown camera path.", "notice err"
+	case "profile-applied":
+		return "Profile applied.", "notice ok"
+	case "invalid-profile":
+		return "Unknown profile.", "notice err"
+	case "rolled-back":
+		return "MediaMTX did not report the stream ready after saving, so the previous configuration was restored.", "notice err"
+	case "live-applied":
+		return "Settings applied to the running camera. They are lost when MediaMTX restarts unless you make them permanent.", "notice ok"
+	case "live-static":
+		return "Flips, resolution, sensor mode and focus only take effect by restarting the stream, so nothing was applied. Use Save instead.", "notice err"
+	case "live-error":
+		return "Failed to apply settings to the running camera.", "notice err"
+	case "persisted":
+		return "Running settings saved to the configuration file.", "notice ok"
+	case "nothing-to-persist":
+		return "The running settings already match the configuration file.", "notice ok"
+	case "rollback-failed":
+		return "MediaMTX did not report the stream ready after saving, and the rollback also failed: the previous configuration could not be restored or the stream was still not ready with it. Check Configuration history.", "notice err"
 	}
+	if slug, ok := strings.CutPrefix(status, "invalid-"); ok {
+		if p, ok := paramBySlug(slug); ok {
+			return fmt.Sprintf("Invalid value for %s.", p.Label), "notice err"
+		}
+	}
+	return "", ""
 }
 
 func formatLensPosition(position *float64) string {
@@ -485,7 +1079,9 @@ func formatLensPosition(position *float64) string {
 	return strconv.FormatFloat(*position, 'g', -1, 64)
 }
 
-func parseLensPosition(value string) (float64, bool) {
+// parseDecimal accepts a dot or comma decimal separator, as typed into
+// number inputs with a European locale.
+func parseDecimal(value string) (float64, bool) {
 	if value == "" {
 		return 0, false
 	}
@@ -503,32 +1099,24 @@ func parseLensPosition(value string) (float64, bool) {
 	return parsed, true
 }
 
-func hostnameOrUnknown() string {
-	name, err := os.Hostname()
+func hostnameOrUnknown(env host.Env) string {
+	name, err := env.Hostname()
 	if err != nil || name == "" {
 		return "unknown"
 	}
 	return name
 }
 
-func primaryIPv4OrUnknown() string {
-	addrs, err := net.InterfaceAddrs()
+func primaryIPv4OrUnknown(env host.Env) string {
+	ifaces, err := env.Net.Interfaces()
 	if err != nil {
 		return "unavailable"
 	}
-	for _, addr := range addrs {
-		ipNet, ok := addr.(*net.IPNet)
-		if !ok || ipNet.IP == nil {
-			continue
-		}
-		ip := ipNet.IP.To4()
-		if ip == nil {
-			continue
-		}
-		if ip.IsLoopback() {
-			continue
+	for _, iface := range ifaces {
+		ip, err := system.InterfaceIPv4(env, iface.Name)
+		if err == nil && ip != "" {
+			return ip
 		}
-		return ip.String()
 	}
 	return "unavailable"
 }
@@ -1,6 +1,23 @@
 package web
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/host/hosttest"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+	"github.com/xpereta/RaspiCam/internal/mediamtx/mediamtxtest"
+	"github.com/xpereta/RaspiCam/internal/metrics"
+	"github.com/xpereta/RaspiCam/internal/schedule"
+)
 
 func TestParseResolution(t *testing.T) {
 	w, h, ok := parseResolution("1280x720")
@@ -12,53 +29,119 @@ func TestParseResolution(t *testing.T) {
 	}
 }
 
-func TestIsValidAWB(t *testing.T) {
-	if !isValidAWB("daylight") {
-		t.Fatalf("expected daylight valid")
+func TestParseDecimal(t *testing.T) {
+	value, ok := parseDecimal("1.25")
+	if !ok || value != 1.25 {
+		t.Fatalf("expected dot decimal")
+	}
+	value, ok = parseDecimal("1,5")
+	if !ok || value != 1.5 {
+		t.Fatalf("expected comma decimal")
+	}
+	if _, ok := parseDecimal("1.2.3"); ok {
+		t.Fatalf("expected multiple dots invalid")
+	}
+	if _, ok := parseDecimal("1,2,3"); ok {
+		t.Fatalf("expected multiple commas invalid")
 	}
-	if isValidAWB("nope") {
-		t.Fatalf("expected invalid awb")
+	if _, ok := parseDecimal("1,2.3"); ok {
+		t.Fatalf("expected mixed separators invalid")
+	}
+	if _, ok := parseDecimal(""); ok {
+		t.Fatalf("expected empty invalid")
 	}
 }
 
-func TestIsValidCameraMode(t *testing.T) {
-	if !isValidCameraMode("2304:1296:10:P") {
-		t.Fatalf("expected mode valid")
+func TestApplyCameraForm(t *testing.T) {
+	cfg := config.CameraConfig{AfMode: "manual"}
+	lens := 1.0
+	cfg.LensPosition = &lens
+	form := url.Values{
+		"rpiCameraAWB":           {"daylight"},
+		"rpiCameraMode":          {""},
+		"rpiCameraSaturation":    {"1,25"},
+		"rpiCameraHDR":           {"true"},
+		"rpiCameraTextOverlay":   {"%H:%M cam1"},
+		"rpiCameraFlickerPeriod": {""},
+	}
+	if key, err := applyCameraForm(&cfg, form); err != nil {
+		t.Fatalf("unexpected error for %s: %v", key, err)
+	}
+	if cfg.AWB != "daylight" || cfg.Mode != "" {
+		t.Fatalf("unexpected dedicated fields: %+v", cfg)
+	}
+	if cfg.LensPosition == nil || *cfg.LensPosition != 1 {
+		t.Fatalf("expected lens position kept when not submitted")
+	}
+	if cfg.Params["rpiCameraSaturation"] != "1.25" || cfg.Params["rpiCameraHDR"] != "true" {
+		t.Fatalf("unexpected params: %v", cfg.Params)
+	}
+	if v, ok := cfg.Params["rpiCameraFlickerPeriod"]; !ok || v != "" {
+		t.Fatalf("expected flicker period cleared: %v", cfg.Params)
 	}
-	if isValidCameraMode("bad") {
-		t.Fatalf("expected invalid mode")
+
+	for key, value := range map[string]string{
+		"rpiCameraAWB":        "nope",
+		"rpiCameraMode":       "bad",
+		"rpiCameraAfMode":     "bad",
+		"rpiCameraSaturation": "17",
+		"rpiCameraBitrate":    "fast",
+	} {
+		got, err := applyCameraForm(&config.CameraConfig{}, url.Values{key: {value}})
+		if err == nil || got != key {
+			t.Fatalf("expected %s=%q rejected, got %q %v", key, value, got, err)
+		}
 	}
 }
 
-func TestIsValidAFMode(t *testing.T) {
-	if !isValidAFMode("manual") {
-		t.Fatalf("expected manual valid")
+func TestParamSlug(t *testing.T) {
+	cases := map[string]string{
+		"rpiCameraAWB":               "awb",
+		"rpiCameraAfMode":            "af-mode",
+		"rpiCameraLensPosition":      "lens-position",
+		"rpiCameraIDRPeriod":         "idr-period",
+		"rpiCameraTextOverlayEnable": "text-overlay-enable",
 	}
-	if isValidAFMode("bad") {
-		t.Fatalf("expected invalid af mode")
+	for key, want := range cases {
+		if got := paramSlug(key); got != want {
+			t.Fatalf("paramSlug(%s) = %q, want %q", key, got, want)
+		}
 	}
 }
 
-func TestParseLensPosition(t *testing.T) {
-	value, ok := parseLensPosition("1.25")
-	if !ok || value != 1.25 {
-		t.Fatalf("expected dot decimal")
-	}
-	value, ok = parseLensPosition("1,5")
-	if !ok || value != 1.5 {
-		t.Fatalf("expected comma decimal")
+func TestCameraParamGroups(t *testing.T) {
+	cfg := config.CameraConfig{Params: map[string]string{"rpiCameraMetering": "matrix"}}
+	groups := cameraParamGroups("cam", cfg, "v1.0.0")
+
+	var metering, flicker *ParamView
+	for gi := range groups {
+		for pi := range groups[gi].Params {
+			p := &groups[gi].Params[pi]
+			if p.Key == "rpiCameraAWB" || p.Key == "rpiCameraWidth" {
+				t.Fatalf("dedicated control %s rendered in advanced groups", p.Key)
+			}
+			switch p.Key {
+			case "rpiCameraMetering":
+				metering = p
+			case "rpiCameraFlickerPeriod":
+				flicker = p
+			}
+		}
 	}
-	if _, ok := parseLensPosition("1.2.3"); ok {
-		t.Fatalf("expected multiple dots invalid")
+	if metering == nil || metering.Input != "select" {
+		t.Fatalf("expected metering select")
 	}
-	if _, ok := parseLensPosition("1,2,3"); ok {
-		t.Fatalf("expected multiple commas invalid")
+	selected := ""
+	for _, o := range metering.Options {
+		if o.Selected {
+			selected = o.Value
+		}
 	}
-	if _, ok := parseLensPosition("1,2.3"); ok {
-		t.Fatalf("expected mixed separators invalid")
+	if selected != "matrix" {
+		t.Fatalf("unexpected metering selection: %q", selected)
 	}
-	if _, ok := parseLensPosition(""); ok {
-		t.Fatalf("expected empty invalid")
+	if flicker == nil || flicker.Supported || flicker.Note == "" {
+		t.Fatalf("expected flicker period unsupported on v1.0.0: %+v", flicker)
 	}
 }
 
@@ -71,8 +154,382 @@ func TestCameraMessageFromStatus(t *testing.T) {
 	if message == "" || class == "" {
 		t.Fatalf("expected invalid mode message")
 	}
+	message, _ = cameraMessageFromStatus("invalid-flicker-period")
+	if message != "Invalid value for Flicker period (µs)." {
+		t.Fatalf("unexpected schema message: %q", message)
+	}
 	message, class = cameraMessageFromStatus("nope")
 	if message != "" || class != "" {
 		t.Fatalf("expected empty message for unknown status")
 	}
 }
+
+func TestFormatMetricsRpiVoltThrottled(t *testing.T) {
+	view := formatMetrics(metrics.Snapshot{Throttled: &metrics.ThrottledStatus{Known: 0x1}, ThrottledSource: "rpi_volt"})
+	if view.Throttled != "no under-voltage" || view.ThrottledSource != "rpi_volt" {
+		t.Fatalf("expected only under-voltage claimed: %q %q", view.Throttled, view.ThrottledSource)
+	}
+}
+
+func TestFormatMetricsThrottledHistory(t *testing.T) {
+	view := formatMetrics(metrics.Snapshot{Throttled: &metrics.ThrottledStatus{
+		HasOccurred:          true,
+		OccurredFlags:        []string{"under-voltage has occurred"},
+		UnderVoltageOccurred: true,
+	}})
+	if view.Throttled != "since boot" || view.ThrottledClass != "badge warn" {
+		t.Fatalf("unexpected throttled badge: %q %q", view.Throttled, view.ThrottledClass)
+	}
+	if !view.UnderVoltageOccurred || len(view.OccurredFlags) != 1 {
+		t.Fatalf("expected under-voltage history in view")
+	}
+}
+
+func TestUsageClass(t *testing.T) {
+	if got := usageClass(50, 80, 90); got != "badge ok" {
+		t.Fatalf("unexpected class: %q", got)
+	}
+	if got := usageClass(85, 80, 90); got != "badge warn" {
+		t.Fatalf("unexpected class: %q", got)
+	}
+	if got := usageClass(95, 80, 90); got != "badge err" {
+		t.Fatalf("unexpected class: %q", got)
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	if got := formatUptime(3*86400 + 4*3600 + 12*60 + 5); got != "3d 4h 12m" {
+		t.Fatalf("unexpected uptime: %q", got)
+	}
+	if got := formatUptime(125); got != "2m" {
+		t.Fatalf("unexpected uptime: %q", got)
+	}
+}
+
+// newFixtureServer returns a Server sampling the synthetic Pi fixture host,
+// a fake MediaMTX API serving path "cam" and a temporary mediamtx.yml.
+func newFixtureServer(t *testing.T) *Server {
+	t.Helper()
+	srv, _ := newFixtureServerWithAPI(t)
+	return srv
+}
+
+// newFixtureServerWithAPI is newFixtureServer that also returns the fake
+// MediaMTX API, for tests that change its state.
+func newFixtureServerWithAPI(t *testing.T) (*Server, *mediamtxtest.Server) {
+	t.Helper()
+	fake := mediamtxtest.Sample("cam")
+	fake.SetPathConf("cam", map[string]any{
+		"source":          "rpiCamera",
+		"rpiCameraWidth":  1920,
+		"rpiCameraHeight": 1080,
+		"rpiCameraAWB":    "daylight",
+	})
+	api := httptest.NewServer(fake)
+	t.Cleanup(api.Close)
+
+	configPath := filepath.Join(t.TempDir(), "mediamtx.yml")
+	input := `paths:
+  cam:
+    source: rpiCamera
+    rpiCameraWidth: 1920
+    rpiCameraHeight: 1080
+    rpiCameraAWB: daylight
+`
+	if err := os.WriteFile(configPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	tmpl, err := parseTemplates()
+	if err != nil {
+		t.Fatalf("parse templates: %v", err)
+	}
+	srv := &Server{
+		tmpl:         tmpl,
+		env:          hosttest.SyntheticPi(),
+		mediamtxAPI:  mediamtx.NewClient(api.URL),
+		mediamtxPath: "cam",
+		configPath:   configPath,
+	}
+	srv.scheduler = schedule.New(schedule.SchedulePath(configPath), nil, srv.applyScheduledProfile)
+	return srv, fake
+}
+
+func TestBuildStatusViewSyntheticPi(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if view.Hostname != "zero2" || view.IPAddress != "192.168.1.42" {
+		t.Fatalf("unexpected host: %q %q", view.Hostname, view.IPAddress)
+	}
+	if view.DeviceModel != "Raspberry Pi Zero 2 W Rev 1.0" || view.CameraModel != "Pi Camera v3 (imx708)" {
+		t.Fatalf("unexpected device: %q %q", view.DeviceModel, view.CameraModel)
+	}
+	if view.OSLabel != "Raspbian GNU/Linux 12 (bookworm)" {
+		t.Fatalf("unexpected os: %q", view.OSLabel)
+	}
+
+	m := view.Metrics
+	if m.TemperatureSource != "vcgencmd" || m.VoltageSource != "vcgencmd" {
+		t.Fatalf("unexpected sources: %q %q", m.TemperatureSource, m.VoltageSource)
+	}
+	if m.Throttled != "since boot" || !m.UnderVoltageOccurred {
+		t.Fatalf("unexpected throttling: %+v", m)
+	}
+	if m.Uptime != "4d 1h 25m" || m.Load != "0.52 0.40 0.31" {
+		t.Fatalf("unexpected uptime/load: %q %q", m.Uptime, m.Load)
+	}
+	if m.Memory == "unavailable" || m.RootDisk == "unavailable" {
+		t.Fatalf("expected memory and root disk: %+v", m)
+	}
+
+	if view.MediaMTX.ServiceStatus != "active" || len(view.MediaMTX.Paths) != 1 || view.MediaMTX.Paths[0].Ready != "yes" {
+		t.Fatalf("unexpected mediamtx: %+v", view.MediaMTX)
+	}
+	if view.Network.Interface != "wlan0" || view.Network.WiFiSSID != "workshop" {
+		t.Fatalf("unexpected network: %+v", view.Network)
+	}
+	if len(view.Cameras) != 1 || view.Cameras[0].Resolution != "1920x1080" || view.Cameras[0].AWB != "daylight" {
+		t.Fatalf("unexpected cameras: %+v", view.Cameras)
+	}
+
+	// The fixture /proc/stat is static, so only the CPU delta is missing.
+	for _, w := range view.Warnings {
+		if !strings.HasPrefix(w, "CPU usage unavailable") {
+			t.Fatalf("unexpected warning: %q", w)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Raspberry Pi Zero 2 W") {
+		t.Fatalf("rendered page missing device model")
+	}
+	if !strings.Contains(buf.String(), `name="rpiCameraSaturation"`) {
+		t.Fatalf("rendered page missing schema-driven controls")
+	}
+}
+
+func TestCameraPathsOnTwoCameraPi5(t *testing.T) {
+	srv := newFixtureServer(t)
+	input := `paths:
+  back:
+    source: rpiCamera
+    rpiCameraCamID: 1
+  cam:
+    source: rpiCamera
+    rpiCameraCamID: 0
+    rpiCameraAWB: daylight
+  relay:
+    source: rtsp://10.0.0.2/stream
+`
+	if err := os.WriteFile(srv.configPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	paths := srv.cameraPaths()
+	if len(paths) != 2 || paths[0] != "cam" || paths[1] != "back" {
+		t.Fatalf("unexpected camera paths: %v", paths)
+	}
+	if _, ok := srv.resolveCameraPath("relay"); ok {
+		t.Fatalf("expected non-camera path to be rejected")
+	}
+
+	form := url.Values{"path": {"back"}, "rpiCameraHFlip": {"on"}}
+	req := httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=back" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "back", "saved", "notice ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(view.Cameras) != 2 || view.Cameras[0].HFlip || !view.Cameras[1].HFlip {
+		t.Fatalf("unexpected cameras: %+v", view.Cameras)
+	}
+	if view.Cameras[0].Message != "" || view.Cameras[1].Message != "saved" {
+		t.Fatalf("expected message on the back camera only")
+	}
+	if len(view.MediaMTX.Paths) != 2 || view.MediaMTX.Paths[1].Ready != "unavailable" {
+		t.Fatalf("unexpected paths: %+v", view.MediaMTX.Paths)
+	}
+
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{`id="cam-rpiCameraSaturation"`, `id="back-rpiCameraSaturation"`, `name="path" value="back"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("rendered page missing %s", want)
+		}
+	}
+
+	form = url.Values{"path": {"relay"}}
+	req = httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if got := rec.Header().Get("Location"); got != "/?camera=invalid-path" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestCameraRecordSettings(t *testing.T) {
+	srv := newFixtureServer(t)
+	input := `pathDefaults:
+  recordDeleteAfter: 7d
+paths:
+  cam:
+    source: rpiCamera
+    rpiCameraAWB: daylight
+`
+	if err := os.WriteFile(srv.configPath, []byte(input), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	postRecordForm := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/camera-record", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := postRecordForm(url.Values{"record": {"true"}, "recordSegmentDuration": {"1 hour"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=invalid-record-segment-duration&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if msg, _ := cameraMessageFromStatus("invalid-record-segment-duration"); !strings.Contains(msg, "Segment duration") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	// The camera form leaves the recording keys alone.
+	rec = postCameraForm(srv, url.Values{"record": {"true"}, "rpiCameraAWB": {"daylight"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if settings, err := config.LoadRecordSettings(srv.configPath, "cam"); err != nil || settings.Record {
+		t.Fatalf("camera form changed the recording settings: %+v %v", settings, err)
+	}
+
+	rec = postRecordForm(url.Values{"path": {"cam"}, "record": {"true"}, "recordSegmentDuration": {"10m"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	settings, err := config.LoadRecordSettings(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load record settings: %v", err)
+	}
+	if !settings.Record || settings.SegmentDuration != "10m" || settings.DeleteAfter != "7d" {
+		t.Fatalf("unexpected record settings: %+v", settings)
+	}
+	if backups, err := config.ListBackups(srv.configPath); err != nil || len(backups) != 2 {
+		t.Fatalf("expected a backup per save, got %d: %v", len(backups), err)
+	}
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	params := map[string]ParamView{}
+	for _, p := range view.Cameras[0].Recording {
+		params[p.Key] = p
+	}
+	if params["recordSegmentDuration"].Value != "10m" || params["recordDeleteAfter"].Placeholder != "default 7d" {
+		t.Fatalf("unexpected recording controls: %+v", view.Cameras[0].Recording)
+	}
+	for _, g := range view.Cameras[0].Groups {
+		for _, p := range g.Params {
+			if _, ok := config.LookupRecordParam(p.Key); ok {
+				t.Fatalf("recording key %s rendered in advanced groups", p.Key)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `id="cam-recordDeleteAfter"`) || !strings.Contains(buf.String(), `action="/camera-record"`) {
+		t.Fatal("rendered page missing the recording form")
+	}
+}
+
+func TestCameraUpdateConflict(t *testing.T) {
+	srv := newFixtureServer(t)
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Someone edits the file over SSH while the page is open.
+	b, _ := os.ReadFile(srv.configPath)
+	edited := strings.Replace(string(b), "rpiCameraAWB: daylight", "rpiCameraAWB: cloudy", 1)
+	if err := os.WriteFile(srv.configPath, []byte(edited), 0o644); err != nil {
+		t.Fatalf("edit config: %v", err)
+	}
+
+	form := url.Values{"revision": {cfg.Revision}, "rpiCameraAWB": {"daylight"}, "rpiCameraHFlip": {"on"}}
+	rec := postCameraForm(srv, form)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"changed since this page was loaded", "Cloudy → Daylight", "Off → On"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("conflict page missing %q", want)
+		}
+	}
+	if out, _ := os.ReadFile(srv.configPath); string(out) != edited {
+		t.Fatalf("expected the edited file untouched")
+	}
+}
+
+func TestCameraUpdateLoadError(t *testing.T) {
+	srv := newFixtureServer(t)
+	// A value the config cannot parse must not make the form start from
+	// an empty config and drop every other key.
+	b, _ := os.ReadFile(srv.configPath)
+	broken := strings.Replace(string(b), "rpiCameraWidth: 1920", "rpiCameraWidth: wide", 1)
+	if err := os.WriteFile(srv.configPath, []byte(broken), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	rec := postCameraForm(srv, url.Values{"rpiCameraHFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=load-error&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if out, _ := os.ReadFile(srv.configPath); string(out) != broken {
+		t.Fatalf("expected the config untouched, got:\n%s", out)
+	}
+}
+
+func TestBuildStatusViewRecordedPiZero2W(t *testing.T) {
+	env, ok := hosttest.RecordedPiZero2W()
+	if !ok {
+		t.Skip("no fixture set recorded on a Pi Zero 2 W in hosttest/testdata/pizero2w")
+	}
+	srv := newFixtureServer(t)
+	srv.env = env
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("build view: %v", err)
+	}
+	if view.Hostname == "" || view.Metrics.Uptime == "" {
+		t.Fatalf("expected the host and metrics from the recording: %+v", view)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+const (
+	defaultLogLines = 200
+	maxLogLines     = 1000
+)
+
+// ServiceView is the systemd side of the MediaMTX card. Logs are only read
+// when the page asks for them.
+type ServiceView struct {
+	State        string
+	StateClass   string
+	PID          string
+	Uptime       string
+	Restarts     string
+	Memory       string
+	Message      string
+	MessageClass string
+
+	LogsShown    bool
+	LogLevel     string
+	LogLines     int
+	LogLevels    []OptionView
+	LogLineSizes []int
+	Logs         []LogView
+	LogsError    string
+}
+
+type LogView struct {
+	Time       string
+	Level      string
+	LevelClass string
+	Message    string
+}
+
+type serviceControlRequest struct {
+	Action string `json:"action"`
+}
+
+var serviceActionStatus = map[string]string{
+	mediamtx.ServiceStart:   "started",
+	mediamtx.ServiceStop:    "stopped",
+	mediamtx.ServiceRestart: "restarted",
+}
+
+var logLevelClasses = map[string]string{
+	mediamtx.LevelDebug: "badge",
+	mediamtx.LevelInfo:  "badge ok",
+	mediamtx.LevelWarn:  "badge warn",
+	mediamtx.LevelError: "badge err",
+}
+
+func (s *Server) handleServiceControl(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	action := r.FormValue("action")
+	err := mediamtx.ControlService(r.Context(), s.env.Runner, action)
+	s.invalidateState()
+	switch {
+	case err == nil:
+		redirectService(w, r, serviceActionStatus[action])
+	case errors.Is(err, mediamtx.ErrUnknownAction):
+		redirectService(w, r, "invalid-action")
+	default:
+		redirectService(w, r, "error")
+	}
+}
+
+func (s *Server) handleAPIService(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	s.writeAPIService(w, r)
+}
+
+// handleAPIServiceControl runs a systemctl action and answers with the unit
+// state after it.
+func (s *Server) handleAPIServiceControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	var req serviceControlRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	err := mediamtx.ControlService(r.Context(), s.env.Runner, req.Action)
+	s.invalidateState()
+	if err != nil {
+		if errors.Is(err, mediamtx.ErrUnknownAction) {
+			writeAPIError(w, http.StatusUnprocessableEntity, "validation failed", []fieldError{{Field: "action", Message: "must be start, stop or restart"}})
+			return
+		}
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("%s failed: %v", req.Action, err), nil)
+		return
+	}
+	s.writeAPIService(w, r)
+}
+
+func (s *Server) handleAPIServiceLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	level, lines, fieldErr := parseLogQuery(r.URL.Query())
+	if fieldErr != nil {
+		writeAPIError(w, http.StatusBadRequest, fieldErr.Message, []fieldError{*fieldErr})
+		return
+	}
+	entries, err := mediamtx.ServiceLogs(r.Context(), s.env.Runner, lines, level)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("logs unavailable: %v", err), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) writeAPIService(w http.ResponseWriter, r *http.Request) {
+	unit, err := mediamtx.ServiceUnitStatus(r.Context(), s.env.Runner)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, fmt.Sprintf("unit details unavailable: %v", err), nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, unit)
+}
+
+// parseLogQuery reads the level and lines parameters of a log request. An
+// empty level keeps every entry.
+func parseLogQuery(query url.Values) (string, int, *fieldError) {
+	level := query.Get("level")
+	if level != "" && !slices.Contains(mediamtx.LogLevels, level) {
+		return "", 0, &fieldError{Field: "level", Message: "level must be debug, info, warn or error"}
+	}
+	lines := defaultLogLines
+	if value := query.Get("lines"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxLogLines {
+			return "", 0, &fieldError{Field: "lines", Message: fmt.Sprintf("lines must be between 1 and %d", maxLogLines)}
+		}
+		lines = parsed
+	}
+	return level, lines, nil
+}
+
+func loadService(state mediamtxState, now time.Time) (ServiceView, []string) {
+	view := ServiceView{
+		State:        "unknown",
+		StateClass:   "badge warn",
+		PID:          "-",
+		Uptime:       "-",
+		Restarts:     "unavailable",
+		Memory:       "unavailable",
+		LogLevel:     mediamtx.LevelInfo,
+		LogLines:     defaultLogLines,
+		LogLevels:    logLevelOptions(mediamtx.LevelInfo),
+		LogLineSizes: []int{100, defaultLogLines, 500, maxLogLines},
+	}
+	unit := state.Unit
+	if state.UnitErr != nil {
+		return view, []string{fmt.Sprintf("MediaMTX unit details unavailable: %v", state.UnitErr)}
+	}
+
+	view.State = unit.ActiveState
+	if unit.SubState != "" {
+		view.State += " (" + unit.SubState + ")"
+	}
+	switch unit.ActiveState {
+	case "active":
+		view.StateClass = "badge ok"
+	case "failed":
+		view.StateClass = "badge err"
+	}
+	if unit.MainPID > 0 {
+		view.PID = strconv.Itoa(unit.MainPID)
+	}
+	if unit.Since != nil {
+		view.Uptime = formatUptime(now.Sub(*unit.Since).Seconds())
+	}
+	if unit.Restarts != nil {
+		view.Restarts = strconv.Itoa(*unit.Restarts)
+	}
+	if unit.MemoryBytes != nil {
+		view.Memory = formatBytes(*unit.MemoryBytes)
+	}
+	return view, nil
+}
+
+// loadServiceLogs fills the log viewer for the level and lines in query.
+func (s *Server) loadServiceLogs(ctx context.Context, view *ServiceView, query url.Values) {
+	view.LogsShown = true
+	level, lines, fieldErr := parseLogQuery(query)
+	if fieldErr != nil {
+		view.LogsError = fieldErr.Message
+		return
+	}
+	if level == "" {
+		level = mediamtx.LevelDebug
+	}
+	view.LogLevel, view.LogLines = level, lines
+	view.LogLevels = logLevelOptions(level)
+
+	entries, err := mediamtx.ServiceLogs(ctx, s.env.Runner, lines, level)
+	if err != nil {
+		view.LogsError = fmt.Sprintf("Logs unavailable: %v", err)
+		return
+	}
+	for _, e := range entries {
+		view.Logs = append(view.Logs, LogView{
+			Time:       e.Time.Local().Format("2006-01-02 15:04:05"),
+			Level:      e.Level,
+			LevelClass: logLevelClasses[e.Level],
+			Message:    e.Message,
+		})
+	}
+}
+
+func logLevelOptions(selected string) []OptionView {
+	options := make([]OptionView, 0, len(mediamtx.LogLevels))
+	for _, level := range mediamtx.LogLevels {
+		options = append(options, OptionView{Value: level, Label: level + " and above", Selected: level == selected})
+	}
+	return options
+}
+
+func redirectService(w http.ResponseWriter, r *http.Request, status string) {
+	http.Redirect(w, r, "/?service="+status, http.StatusSeeOther)
+}
+
+func serviceMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "started":
+		return "MediaMTX started.", "notice ok"
+	case "stopped":
+		return "MediaMTX stopped. Streams and recordings are down until it is started again.", "notice ok"
+	case "restarted":
+		return "MediaMTX restarted.", "notice ok"
+	case "invalid-action":
+		return "Unknown service action.", "notice err"
+	case "error":
+		return "The service action failed. Check that the UI user may manage the mediamtx unit.", "notice err"
+	}
+	return "", ""
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+func TestServiceCard(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if view.Service.State != "active (running)" || view.Service.PID != "812" || view.Service.Restarts != "2" || view.Service.Memory != "22.8 MB" {
+		t.Fatalf("unexpected service view: %+v", view.Service)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "listener opened") {
+		t.Fatalf("logs shown before they were asked for")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?logs=1&level=warn&lines=200", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "[RTSP] [conn 192.168.1.40:51234] closed: terminated") || strings.Contains(body, "listener opened") {
+		t.Fatalf("expected only warnings and errors in the log viewer")
+	}
+
+	// The fixture has no systemctl restart output, so the action fails.
+	if got := postForm(t, srv, "/service/control", url.Values{"action": {"restart"}}); got != "/?service=error" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/service/control", url.Values{"action": {"reload"}}); got != "/?service=invalid-action" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPIService(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/service", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var unit mediamtx.Unit
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &unit) != nil || unit.MainPID != 812 {
+		t.Fatalf("unexpected unit: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/service/logs?level=error", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var entries []mediamtx.LogEntry
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &entries) != nil || len(entries) != 1 {
+		t.Fatalf("unexpected logs: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/service/logs?lines=5000", nil)
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too many lines, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/service/control", strings.NewReader(`{"action":"reload"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unknown action, got %d", rec.Code)
+	}
+}
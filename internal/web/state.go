@@ -0,0 +1,109 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+// mediamtxState is what the status page shows of MediaMTX besides the
+// sample: the unit details, the viewers and the running config of each
+// camera path. It is too large for the sample history, so the sampler
+// refreshes it next to each sample and pages read the stored copy instead
+// of waiting on systemctl and the Control API.
+type mediamtxState struct {
+	Time           time.Time
+	Unit           mediamtx.Unit
+	UnitErr        error
+	Viewers        []mediamtx.Viewer
+	ViewerWarnings []string
+	Running        map[string]mediamtx.Conf
+	RunningErrs    map[string]error
+}
+
+// stateReloadMargin is how long after a write of mediamtx.yml MediaMTX may
+// still be reloading it, so a state taken that soon may be stale.
+const stateReloadMargin = 2 * time.Second
+
+// stateCache holds the last mediamtxState. A nil state makes the next page
+// collect one.
+type stateCache struct {
+	mu    sync.Mutex
+	state *mediamtxState
+}
+
+func (s *Server) collectState(ctx context.Context) mediamtxState {
+	state := mediamtxState{
+		Time:        time.Now(),
+		Running:     map[string]mediamtx.Conf{},
+		RunningErrs: map[string]error{},
+	}
+	state.Unit, state.UnitErr = mediamtx.ServiceUnitStatus(ctx, s.env.Runner)
+	if s.mediamtxAPI == nil {
+		return state
+	}
+	state.Viewers, state.ViewerWarnings = mediamtx.CollectViewers(ctx, s.mediamtxAPI)
+	for _, path := range s.cameraPaths() {
+		conf, err := s.mediamtxAPI.PathConf(ctx, path)
+		if err != nil {
+			state.RunningErrs[path] = err
+			continue
+		}
+		state.Running[path] = conf
+	}
+	return state
+}
+
+// currentState returns the stored state. Without one, or when mediamtx.yml
+// changed after it was taken so the running configs may be reloading, a
+// fresh one is collected within a short timeout; it is kept only while the
+// sampler runs, which otherwise refreshes it.
+func (s *Server) currentState(ctx context.Context) mediamtxState {
+	s.state.mu.Lock()
+	stored := s.state.state
+	s.state.mu.Unlock()
+	if stored != nil {
+		modTime, ok, err := config.ConfigModTime(s.configPath)
+		if err != nil || !ok || !modTime.After(stored.Time.Add(-stateReloadMargin)) {
+			return *stored
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	state := s.collectState(ctx)
+	if s.sampler != nil {
+		s.storeState(state)
+	}
+	return state
+}
+
+func (s *Server) storeState(state mediamtxState) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.state = &state
+}
+
+// invalidateState makes the next page collect the state again, after an
+// action that changed it.
+func (s *Server) invalidateState() {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.state = nil
+}
+
+// drift lists the live parameters of path whose running value in state
+// differs from file.
+func (state mediamtxState) drift(path string, file config.CameraConfig) ([]config.FieldDrift, error) {
+	if err := state.RunningErrs[path]; err != nil {
+		return nil, err
+	}
+	running, ok := state.Running[path]
+	if !ok {
+		return nil, nil
+	}
+	return config.LiveDrift(file, running), nil
+}
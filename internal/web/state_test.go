@@ -0,0 +1,48 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+func TestStatusReadsStoredState(t *testing.T) {
+	srv := newFixtureServer(t)
+	srv.storeState(srv.collectState(context.Background()))
+	// Stored before the config was written would look stale.
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(srv.configPath, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	// MediaMTX stops answering; the page still shows what was stored.
+	srv.mediamtxAPI = mediamtx.NewClient("http://127.0.0.1:1")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "192.168.1.21:60112") || strings.Contains(body, "viewers unavailable") {
+		t.Fatalf("expected the stored viewers:\n%s", body)
+	}
+
+	// A newer mediamtx.yml, or an action, makes the page collect again.
+	srv.invalidateState()
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), "viewers unavailable") {
+		t.Fatal("expected the state collected again")
+	}
+}
+
+func TestStoredStateStaleAfterConfigWrite(t *testing.T) {
+	srv := newFixtureServer(t)
+	srv.storeState(mediamtxState{Time: time.Now().Add(-time.Minute)})
+	if state := srv.currentState(context.Background()); state.Time.Before(time.Now().Add(-time.Second)) {
+		t.Fatal("expected a state older than mediamtx.yml collected again")
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/system"
+)
+
+// StreamURLView is one copyable address of a camera stream.
+type StreamURLView struct {
+	Label string
+	URL   string
+	Hint  string
+}
+
+var streamLabels = map[string]string{
+	config.StreamWebRTC: "WebRTC",
+	config.StreamHLS:    "HLS",
+	config.StreamRTSP:   "RTSP",
+	config.StreamRTSPS:  "RTSPS",
+	config.StreamRTMP:   "RTMP",
+	config.StreamRTMPS:  "RTMPS",
+	config.StreamSRT:    "SRT",
+}
+
+var streamHints = map[string]string{
+	config.StreamWebRTC: "Open in a browser.",
+	config.StreamHLS:    "Open in a browser, VLC or ffplay.",
+	config.StreamRTSP:   "Open in VLC or ffplay.",
+	config.StreamSRT:    "Open in VLC or ffplay.",
+}
+
+// streamHost is the address viewers reach the Pi at: the IP of the default
+// route interface, or the hostname when it has none.
+func streamHost(network system.NetworkSnapshot, hostname string) string {
+	if network.IPAddress != nil {
+		return *network.IPAddress
+	}
+	return hostname
+}
+
+// streamURLs lists the reader URLs of every camera path, keyed by path,
+// from the listeners in mediamtx.yml.
+func (s *Server) streamURLs(host string, paths []string) (map[string][]config.StreamURL, error) {
+	listeners, err := config.LoadListeners(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string][]config.StreamURL, len(paths))
+	for _, path := range paths {
+		urls[path] = config.StreamURLs(listeners, host, path)
+	}
+	return urls, nil
+}
+
+// cameraStreamURLs is streamURLs for one path. It takes the host from the
+// latest background sample rather than collecting one.
+func (s *Server) cameraStreamURLs(path string) []config.StreamURL {
+	var network system.NetworkSnapshot
+	if s.sampler != nil {
+		if sample, ok := s.sampler.Latest(); ok {
+			network = sample.Network
+		}
+	}
+	urls, err := s.streamURLs(streamHost(network, hostnameOrUnknown(s.env)), []string{path})
+	if err != nil {
+		return []config.StreamURL{}
+	}
+	return nonNilStreamURLs(urls[path])
+}
+
+func formatStreamURLs(urls []config.StreamURL) []StreamURLView {
+	views := make([]StreamURLView, 0, len(urls))
+	for _, u := range urls {
+		label := streamLabels[u.Protocol]
+		if label == "" {
+			label = u.Protocol
+		}
+		views = append(views, StreamURLView{Label: label, URL: u.URL, Hint: streamHints[u.Protocol]})
+	}
+	return views
+}
+
+// whepURL is the WebRTC-HTTP egress endpoint MediaMTX serves next to the
+// WebRTC page of a path, or "" without WebRTC.
+func whepURL(urls []config.StreamURL) string {
+	for _, u := range urls {
+		if u.Protocol == config.StreamWebRTC {
+			return u.URL + "whep"
+		}
+	}
+	return ""
+}
+
+func nonNilStreamURLs(urls []config.StreamURL) []config.StreamURL {
+	if urls == nil {
+		return []config.StreamURL{}
+	}
+	return urls
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCameraStreamURLs(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cam := view.Cameras[0]
+	host := view.Network.IPAddress
+	if len(cam.URLs) != 5 || cam.URLs[0].Label != "WebRTC" || cam.URLs[0].URL != "http://"+host+":8889/cam/" {
+		t.Fatalf("unexpected URLs for host %s: %+v", host, cam.URLs)
+	}
+	if cam.WHEPURL != "http://"+host+":8889/cam/whep" {
+		t.Fatalf("unexpected WHEP URL: %q", cam.WHEPURL)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), `data-whep="http://`+host+`:8889/cam/whep"`) || !strings.Contains(buf.String(), "rtsp://"+host+":8554/cam") {
+		t.Fatalf("rendered page missing stream URLs")
+	}
+
+	b, err := os.ReadFile(srv.configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if err := os.WriteFile(srv.configPath, append([]byte("webrtc: no\n"), b...), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/camera?path=cam", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var camera apiCamera
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &camera) != nil {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(camera.URLs) != 4 || camera.URLs[0].Protocol != "hls" {
+		t.Fatalf("expected WebRTC left out, got %+v", camera.URLs)
+	}
+}
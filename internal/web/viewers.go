@@ -0,0 +1,149 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+type ViewersView struct {
+	Viewers      []ViewerView
+	Message      string
+	MessageClass string
+}
+
+type ViewerView struct {
+	Protocol      string
+	ProtocolLabel string
+	ID            string
+	Path          string
+	RemoteAddr    string
+	UserAgent     string
+	BytesSent     string
+	Since         string
+	Kickable      bool
+}
+
+type viewerKickRequest struct {
+	Protocol string `json:"protocol"`
+	ID       string `json:"id"`
+}
+
+var protocolLabels = map[string]string{
+	mediamtx.ProtocolRTSP:   "RTSP",
+	mediamtx.ProtocolRTSPS:  "RTSPS",
+	mediamtx.ProtocolWebRTC: "WebRTC",
+	mediamtx.ProtocolHLS:    "HLS",
+	mediamtx.ProtocolSRT:    "SRT",
+}
+
+func (s *Server) handleViewerKick(w http.ResponseWriter, r *http.Request) {
+	if !parsePostForm(w, r) {
+		return
+	}
+	err := mediamtx.KickViewer(r.Context(), s.mediamtxAPI, r.FormValue("protocol"), r.FormValue("id"))
+	s.invalidateState()
+	switch {
+	case err == nil:
+		redirectViewers(w, r, "kicked")
+	case errors.Is(err, mediamtx.ErrNotFound):
+		redirectViewers(w, r, "gone")
+	case errors.Is(err, mediamtx.ErrNotKickable):
+		redirectViewers(w, r, "not-kickable")
+	default:
+		redirectViewers(w, r, "error")
+	}
+}
+
+func (s *Server) handleAPIViewers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	viewers, warnings := mediamtx.CollectViewers(r.Context(), s.mediamtxAPI)
+	if len(viewers) == 0 && len(warnings) > 0 {
+		writeAPIError(w, http.StatusBadGateway, warnings[0], nil)
+		return
+	}
+	writeJSON(w, http.StatusOK, viewers)
+}
+
+// handleAPIViewerKick disconnects a session and answers with the viewers
+// left.
+func (s *Server) handleAPIViewerKick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+
+	var req viewerKickRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %v", err), nil)
+		return
+	}
+
+	err := mediamtx.KickViewer(r.Context(), s.mediamtxAPI, req.Protocol, req.ID)
+	s.invalidateState()
+	if err != nil {
+		switch {
+		case errors.Is(err, mediamtx.ErrNotKickable):
+			writeAPIError(w, http.StatusUnprocessableEntity, err.Error(), nil)
+		case errors.Is(err, mediamtx.ErrNotFound):
+			writeAPIError(w, http.StatusNotFound, "session not found", []fieldError{{Field: "id", Message: "no such session"}})
+		default:
+			writeAPIError(w, http.StatusBadGateway, fmt.Sprintf("kick failed: %v", err), nil)
+		}
+		return
+	}
+	viewers, _ := mediamtx.CollectViewers(r.Context(), s.mediamtxAPI)
+	writeJSON(w, http.StatusOK, viewers)
+}
+
+func loadViewers(state mediamtxState, now time.Time) (ViewersView, []string) {
+	var view ViewersView
+	for _, v := range state.Viewers {
+		vv := ViewerView{
+			Protocol:      v.Protocol,
+			ProtocolLabel: protocolLabels[v.Protocol],
+			ID:            v.ID,
+			Path:          v.Path,
+			RemoteAddr:    v.RemoteAddr,
+			UserAgent:     v.UserAgent,
+			BytesSent:     formatBytes(v.BytesSent),
+			Since:         formatUptime(now.Sub(v.Created).Seconds()),
+			Kickable:      v.Kickable,
+		}
+		if vv.ProtocolLabel == "" {
+			vv.ProtocolLabel = v.Protocol
+		}
+		if v.Protocol == mediamtx.ProtocolHLS {
+			vv.RemoteAddr = "all HLS clients"
+		}
+		view.Viewers = append(view.Viewers, vv)
+	}
+	return view, state.ViewerWarnings
+}
+
+func redirectViewers(w http.ResponseWriter, r *http.Request, status string) {
+	http.Redirect(w, r, "/?viewers="+status, http.StatusSeeOther)
+}
+
+func viewersMessageFromStatus(status string) (string, string) {
+	switch status {
+	case "kicked":
+		return "Viewer disconnected.", "notice ok"
+	case "gone":
+		return "That session had already ended.", "notice ok"
+	case "not-kickable":
+		return "HLS viewers cannot be disconnected one by one.", "notice err"
+	case "error":
+		return "Failed to disconnect the viewer.", "notice err"
+	}
+	return "", ""
+}
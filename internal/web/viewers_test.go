@@ -0,0 +1,82 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+func TestViewersCard(t *testing.T) {
+	srv, fake := newFixtureServerWithAPI(t)
+
+	view, err := srv.buildStatusView(context.Background(), "", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(view.Viewers.Viewers) != 3 {
+		t.Fatalf("expected three viewers, got %+v", view.Viewers.Viewers)
+	}
+	var buf bytes.Buffer
+	if err := srv.tmpl.Execute(&buf, view); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(buf.String(), "VLC/3.0.20") || !strings.Contains(buf.String(), `action="/viewers/kick"`) {
+		t.Fatalf("rendered page missing viewer details")
+	}
+
+	id := fake.Sessions("webrtcsessions")[0].ID
+	if got := postForm(t, srv, "/viewers/kick", url.Values{"protocol": {"webrtc"}, "id": {id}}); got != "/?viewers=kicked" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if len(fake.Sessions("webrtcsessions")) != 0 {
+		t.Fatalf("expected the WebRTC session kicked")
+	}
+	if got := postForm(t, srv, "/viewers/kick", url.Values{"protocol": {"webrtc"}, "id": {id}}); got != "/?viewers=gone" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	if got := postForm(t, srv, "/viewers/kick", url.Values{"protocol": {"hls"}}); got != "/?viewers=not-kickable" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPIViewers(t *testing.T) {
+	srv, fake := newFixtureServerWithAPI(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/viewers", nil)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var viewers []mediamtx.Viewer
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &viewers) != nil || len(viewers) != 3 {
+		t.Fatalf("unexpected viewers: %d %s", rec.Code, rec.Body.String())
+	}
+
+	id := fake.Sessions("rtspsessions")[0].ID
+	body := `{"protocol":"rtsp","id":"` + id + `"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/viewers/kick", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &viewers) != nil || len(viewers) != 2 {
+		t.Fatalf("unexpected kick response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/viewers/kick", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a kicked session, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/viewers/kick", strings.NewReader(`{"protocol":"hls","id":"x"}`))
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for HLS, got %d", rec.Code)
+	}
+}
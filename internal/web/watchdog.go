@@ -0,0 +1,119 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+// watchdogConfig controls the check run after a camera save. A zero Timeout
+// disables it.
+type watchdogConfig struct {
+	Timeout  time.Duration
+	Settle   time.Duration
+	Interval time.Duration
+}
+
+// rollbackError reports a save that MediaMTX did not recover from. Restore
+// is set when the rollback failed as well: the backup could not be
+// restored, or the path was still not ready after it was.
+type rollbackError struct {
+	Cause   error
+	Backup  string
+	Restore error
+}
+
+func (e *rollbackError) Error() string {
+	if e.Restore != nil {
+		return fmt.Sprintf("mediamtx not ready after save (%v); rolling back to %s failed: %v", e.Cause, e.Backup, e.Restore)
+	}
+	return fmt.Sprintf("mediamtx not ready after save (%v); restored %s", e.Cause, e.Backup)
+}
+
+// saveCameraConfig saves cfg for path under the watchdog of saveWatched.
+func (s *Server) saveCameraConfig(ctx context.Context, path string, cfg config.CameraConfig) error {
+	return s.saveWatched(ctx, path, func() (string, error) {
+		return config.SaveCameraConfig(s.configPath, path, cfg)
+	})
+}
+
+// saveRecordConfig saves the recording keys of path under the watchdog of
+// saveWatched.
+func (s *Server) saveRecordConfig(ctx context.Context, path string, cfg config.RecordConfig) error {
+	return s.saveWatched(ctx, path, func() (string, error) {
+		return config.SaveRecordConfig(s.configPath, path, cfg)
+	})
+}
+
+// saveWatched runs save, which writes the config of path and returns the
+// name of its backup. When the path was ready before the save, it waits
+// for MediaMTX to report it ready again and restores that backup if this
+// does not happen within the timeout, then waits for the path once more to
+// tell whether the rollback worked. The waits run without saveMu, so other
+// saves go on meanwhile; when one of them replaced the config before the
+// timeout, nothing is restored. Only a path that stays not ready restores:
+// the waits outlive ctx, and a wait that was cancelled all the same leaves
+// the save in place.
+func (s *Server) saveWatched(ctx context.Context, path string, save func() (string, error)) error {
+	s.saveMu.Lock()
+	wasReady := s.watchdog.Timeout > 0 &&
+		mediamtx.CheckPathReady(ctx, s.env.Runner, s.mediamtxAPI, path) == nil
+	backup, err := save()
+	if err != nil {
+		s.saveMu.Unlock()
+		return err
+	}
+	s.saveGen++
+	gen := s.saveGen
+	if !wasReady {
+		s.pruneBackups()
+		s.saveMu.Unlock()
+		return nil
+	}
+	s.saveMu.Unlock()
+
+	rollback := &rollbackError{Backup: backup}
+	rollback.Cause = s.waitPathReady(ctx, path)
+	s.saveMu.Lock()
+	if rollback.Cause == nil {
+		s.pruneBackups()
+		s.saveMu.Unlock()
+		return nil
+	}
+	if errors.Is(rollback.Cause, context.Canceled) {
+		s.saveMu.Unlock()
+		log.Printf("camera config watchdog: %s not checked after save: %v", path, rollback.Cause)
+		return nil
+	}
+	if s.saveGen != gen {
+		s.saveMu.Unlock()
+		log.Printf("camera config watchdog: %s not ready after save (%v); not restored, the config was replaced since", path, rollback.Cause)
+		return nil
+	}
+	if rollback.Restore = config.RestoreBackup(s.configPath, rollback.Backup); rollback.Restore == nil {
+		s.saveGen++
+	}
+	s.saveMu.Unlock()
+
+	if rollback.Restore == nil {
+		if err := s.waitPathReady(ctx, path); err != nil {
+			rollback.Restore = fmt.Errorf("still not ready after restoring: %w", err)
+		}
+	}
+	log.Printf("camera config watchdog: %v", rollback)
+	return rollback
+}
+
+// waitPathReady waits up to the watchdog timeout for path to be ready. The
+// wait does not end with ctx, so a closed browser tab, a client hanging up
+// or a shutdown cannot pass for a path that did not come back.
+func (s *Server) waitPathReady(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.watchdog.Timeout)
+	defer cancel()
+	return mediamtx.WaitPathReady(ctx, s.env.Runner, s.mediamtxAPI, path, s.watchdog.Settle, s.watchdog.Interval)
+}
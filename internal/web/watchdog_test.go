@@ -0,0 +1,221 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xpereta/RaspiCam/internal/config"
+	"github.com/xpereta/RaspiCam/internal/mediamtx"
+)
+
+// newWatchdogServer returns a fixture server whose MediaMTX stub reports
+// the path as not ready while the config enables hflip.
+func newWatchdogServer(t *testing.T) *Server {
+	t.Helper()
+	srv := newFixtureServer(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := os.ReadFile(srv.configPath)
+		ready := !strings.Contains(string(b), "rpiCameraHFlip: true")
+		if ready {
+			_, _ = w.Write([]byte(`{"name":"cam","ready":true}`))
+		} else {
+			_, _ = w.Write([]byte(`{"name":"cam","ready":false}`))
+		}
+	}))
+	t.Cleanup(api.Close)
+	srv.mediamtxAPI = mediamtx.NewClient(api.URL)
+	srv.watchdog = watchdogConfig{Timeout: 100 * time.Millisecond, Interval: 5 * time.Millisecond}
+	return srv
+}
+
+func postCameraForm(srv *Server, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/camera-config", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCameraSaveWatchdog(t *testing.T) {
+	srv := newWatchdogServer(t)
+
+	rec := postCameraForm(srv, url.Values{"rpiCameraVFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+
+	rec = postCameraForm(srv, url.Values{"rpiCameraVFlip": {"on"}, "rpiCameraHFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=rolled-back&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HFlip || !cfg.VFlip {
+		t.Fatalf("expected last-known-good config, got %+v", cfg)
+	}
+	backups, err := config.ListBackups(srv.configPath)
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("expected the failed config to be kept as a backup, got %+v %v", backups, err)
+	}
+}
+
+func TestCameraSaveWatchdogSkipsUnhealthyPath(t *testing.T) {
+	srv := newWatchdogServer(t)
+	srv.mediamtxAPI = mediamtx.NewClient("http://127.0.0.1:1")
+
+	rec := postCameraForm(srv, url.Values{"rpiCameraHFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=saved&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+}
+
+func TestAPICameraUpdateWatchdog(t *testing.T) {
+	srv := newWatchdogServer(t)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/camera", strings.NewReader(`{"hFlip": true}`))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "restored") {
+		t.Fatalf("expected rollback error, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCameraSaveWatchdogRollbackNotReady(t *testing.T) {
+	srv := newFixtureServer(t)
+	var broken atomic.Bool
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := os.ReadFile(srv.configPath)
+		// Once hflip was tried, MediaMTX does not recover either way.
+		if strings.Contains(string(b), "rpiCameraHFlip: true") {
+			broken.Store(true)
+		}
+		_, _ = fmt.Fprintf(w, `{"name":"cam","ready":%t}`, !broken.Load())
+	}))
+	t.Cleanup(api.Close)
+	srv.mediamtxAPI = mediamtx.NewClient(api.URL)
+	srv.watchdog = watchdogConfig{Timeout: 100 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	rec := postCameraForm(srv, url.Values{"rpiCameraHFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=rollback-failed&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.HFlip {
+		t.Fatalf("expected the previous config restored, got %+v", cfg)
+	}
+}
+
+func TestCameraSaveWatchdogSuperseded(t *testing.T) {
+	srv := newFixtureServer(t)
+	var requests atomic.Int32
+	polled := make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ready only for the check before the first save.
+		n := requests.Add(1)
+		if n == 2 {
+			close(polled)
+		}
+		_, _ = fmt.Fprintf(w, `{"name":"cam","ready":%t}`, n == 1)
+	}))
+	t.Cleanup(api.Close)
+	srv.mediamtxAPI = mediamtx.NewClient(api.URL)
+	srv.watchdog = watchdogConfig{Timeout: 300 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	base, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	first := base
+	first.HFlip = true
+	done := make(chan error, 1)
+	go func() { done <- srv.saveCameraConfig(context.Background(), "cam", first) }()
+
+	// A second save goes through while the first one waits, and the first
+	// one then leaves it in place.
+	<-polled
+	second := first
+	second.Revision = ""
+	second.VFlip = true
+	start := time.Now()
+	if err := srv.saveCameraConfig(context.Background(), "cam", second); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+	if time.Since(start) > 200*time.Millisecond {
+		t.Fatal("expected the second save not to wait for the first watchdog")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.HFlip || !cfg.VFlip {
+		t.Fatalf("expected the second save kept, got %+v", cfg)
+	}
+}
+
+func TestCameraSaveWatchdogOutlivesRequest(t *testing.T) {
+	srv := newFixtureServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var requests atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ready before the save, then restarting for a few polls. The
+		// client hangs up on the first of them.
+		n := requests.Add(1)
+		if n == 2 {
+			cancel()
+		}
+		_, _ = fmt.Fprintf(w, `{"name":"cam","ready":%t}`, n == 1 || n > 4)
+	}))
+	t.Cleanup(api.Close)
+	srv.mediamtxAPI = mediamtx.NewClient(api.URL)
+	srv.watchdog = watchdogConfig{Timeout: 500 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cfg.HFlip = true
+	if err := srv.saveCameraConfig(ctx, "cam", cfg); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if cfg, _ = config.LoadCameraConfig(srv.configPath, "cam"); !cfg.HFlip {
+		t.Fatal("expected the save kept after the request was cancelled")
+	}
+}
+
+func TestCameraSaveWatchdogRestoresItsOwnBackup(t *testing.T) {
+	srv := newWatchdogServer(t)
+	// A backup stamped in the future, as left by a clock that jumped back
+	// at NTP sync, sorts before the one the save takes.
+	future := srv.configPath + ".bak-29991231-235959"
+	if err := os.WriteFile(future, []byte("paths: {}\n"), 0o644); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+
+	rec := postCameraForm(srv, url.Values{"rpiCameraVFlip": {"on"}, "rpiCameraHFlip": {"on"}})
+	if got := rec.Header().Get("Location"); got != "/?camera=rolled-back&path=cam" {
+		t.Fatalf("unexpected redirect: %q", got)
+	}
+	cfg, err := config.LoadCameraConfig(srv.configPath, "cam")
+	if err != nil {
+		t.Fatalf("expected the config before the save restored: %v", err)
+	}
+	if cfg.HFlip || cfg.VFlip || cfg.AWB != "daylight" {
+		t.Fatalf("unexpected restored config: %+v", cfg)
+	}
+}